@@ -5,9 +5,11 @@ import (
 	"childSessions/model"
 	"childSessions/services"
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
+	"os/user"
 	"path/filepath"
 
 	sysruntime "runtime"
@@ -26,7 +28,31 @@ type App struct {
 	activityService *services.ActivityService
 	noteService     *services.NoteService      
 	rewardService   *services.RewardService    
+	revisionService *services.RevisionService
+	auditService    *services.AuditService
+	settingService  *services.SettingService
+	trashService    *services.TrashService
+	erasureService  *services.ErasureService
+	therapistService *services.TherapistService
+	accessService   *services.AccessService
+	guardianService *services.GuardianService
+	clinicalProfileService *services.ClinicalProfileService
+	attachmentService *services.AttachmentService
+	consentService  *services.ConsentService
+	assessmentService *services.AssessmentService
+	milestoneService *services.MilestoneService
+	sessionPlanService *services.SessionPlanService
+	sessionTemplateService *services.SessionTemplateService
+	waitlistService        *services.WaitlistService
+	billingService         *services.BillingService
+	packageService         *services.PackageService
 	database        *gorm.DB
+
+	// currentTherapist is the logged-in account; nil in single-user mode or before login
+	currentTherapist *model.Therapist
+
+	// startedAt is when this run of the app started; sessions still open from before it were left behind
+	startedAt time.Time
 }
 
 // NewApp creates a new App application struct
@@ -38,6 +64,7 @@ func NewApp() *App {
 // so we can call the runtime methods
 func (a *App) startup(ctx context.Context) {
 	a.ctx = ctx
+	a.startedAt = time.Now()
 
 	// Initialize database
 	database, err := db.InitDB("therapy_sessions.db")
@@ -48,11 +75,144 @@ func (a *App) startup(ctx context.Context) {
 	a.database = database
 
 	// Initialize services
-	a.childService = services.NewChildService(database)
-	a.sessionService = services.NewSessionService(database)
-	a.activityService = services.NewActivityService(database)
-	a.noteService = services.NewNoteService(database)     
-	a.rewardService = services.NewRewardService(database) 
+	a.auditService = services.NewAuditService(database)
+	a.auditService.SetActor(a.currentActor())
+	a.childService = services.NewChildService(database, a.auditService)
+	a.sessionService = services.NewSessionService(database, a.auditService)
+	a.activityService = services.NewActivityService(database, a.auditService)
+	a.noteService = services.NewNoteService(database, a.auditService)     
+	a.rewardService = services.NewRewardService(database, a.auditService) 
+	a.revisionService = services.NewRevisionService(database)
+	a.settingService = services.NewSettingService(database)
+	a.trashService = services.NewTrashService(database, a.auditService, a.settingService)
+	a.erasureService = services.NewErasureService(database, a.auditService, a.settingService)
+	a.therapistService = services.NewTherapistService(database, a.auditService)
+	a.accessService = services.NewAccessService(database)
+	a.guardianService = services.NewGuardianService(database, a.auditService)
+	a.clinicalProfileService = services.NewClinicalProfileService(database, a.auditService)
+	a.attachmentService = services.NewAttachmentService(database, a.auditService, a.settingService, filepath.Join(appDataDir(), "attachments"))
+	a.consentService = services.NewConsentService(database, a.auditService)
+	a.assessmentService = services.NewAssessmentService(database, a.auditService)
+	a.milestoneService = services.NewMilestoneService(database, a.auditService)
+	a.sessionPlanService = services.NewSessionPlanService(database, a.auditService)
+	a.sessionTemplateService = services.NewSessionTemplateService(database, a.auditService)
+	a.waitlistService = services.NewWaitlistService(database, a.auditService)
+	a.billingService = services.NewBillingService(database, a.auditService, a.settingService)
+	a.packageService = services.NewPackageService(database, a.auditService)
+
+	// Permanently remove records that have outlived the trash retention period
+	if result, err := a.trashService.PurgeExpiredTrash(); err != nil {
+		fmt.Printf("Error purging expired trash: %v\n", err)
+	} else if len(result.Deleted) > 0 {
+		fmt.Printf("Purged expired trash: %+v\n", result.Deleted)
+	}
+	a.cleanupAttachmentFiles()
+
+	// Sessions and activities left running when the app was last closed or crashed
+	if orphans, err := a.sessionService.GetOrphanedSessions(a.startedAt, nil); err != nil {
+		fmt.Printf("Error checking for sessions left open: %v\n", err)
+	} else if len(orphans) > 0 {
+		fmt.Printf("Found %d session(s) left open from a previous run\n", len(orphans))
+	}
+}
+
+// appDataDir is where the app keeps files outside the database, such as attachments
+func appDataDir() string {
+    configDir, err := os.UserConfigDir()
+    if err != nil {
+        return "data"
+    }
+    return filepath.Join(configDir, "childSessions")
+}
+
+// openedAttachmentsDir holds decrypted copies of attachments opened in an external viewer
+func openedAttachmentsDir() string {
+    return filepath.Join(os.TempDir(), "childSessions-lampiran")
+}
+
+// openedAttachmentLifetime is how long a decrypted copy is kept for the external viewer
+// to load it before it is deleted
+const openedAttachmentLifetime = time.Minute
+
+// removeOpenedAttachmentLater deletes a decrypted copy once the viewer has had time to
+// read it. A copy the viewer still holds locked is removed at shutdown or next startup.
+func removeOpenedAttachmentLater(path string) {
+    time.AfterFunc(openedAttachmentLifetime, func() {
+        if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+            fmt.Printf("Error removing opened attachment copy: %v\n", err)
+        }
+    })
+}
+
+// shutdown is called when the app is closing
+func (a *App) shutdown(ctx context.Context) {
+    if err := os.RemoveAll(openedAttachmentsDir()); err != nil {
+        fmt.Printf("Error removing opened attachment copies: %v\n", err)
+    }
+}
+
+// cleanupAttachmentFiles removes files of purged or erased attachments and previously opened copies
+func (a *App) cleanupAttachmentFiles() {
+    if removed, err := a.attachmentService.RemoveOrphanedFiles(); err != nil {
+        fmt.Printf("Error removing orphaned attachment files: %v\n", err)
+    } else if removed > 0 {
+        fmt.Printf("Removed %d orphaned attachment files\n", removed)
+    }
+    if err := os.RemoveAll(openedAttachmentsDir()); err != nil {
+        fmt.Printf("Error removing opened attachment copies: %v\n", err)
+    }
+}
+
+// currentActor returns the name recorded as the author of changes made from this installation
+func (a *App) currentActor() string {
+    if a.currentTherapist != nil {
+        return a.currentTherapist.Username
+    }
+    if u, err := user.Current(); err == nil && u.Username != "" {
+        return u.Username
+    }
+    return "unknown"
+}
+
+// auditRead records that a sensitive record was viewed; failures are logged, not returned
+func (a *App) auditRead(entityType string, entityID uint) {
+    if err := a.auditService.RecordRead(entityType, entityID); err != nil {
+        fmt.Printf("Error recording audit read: %v\n", err)
+    }
+}
+
+// actingTherapistID returns the ID of the logged-in therapist, or nil in single-user mode
+func (a *App) actingTherapistID() *uint {
+    if a.currentTherapist == nil {
+        return nil
+    }
+    id := a.currentTherapist.ID
+    return &id
+}
+
+// authorize checks that the logged-in therapist holds a permission
+func (a *App) authorize(permission string) error {
+    return a.accessService.Authorize(a.currentTherapist, permission)
+}
+
+// authorizeChild checks a permission for one child, honouring caseload limits
+func (a *App) authorizeChild(permission string, childID uint) error {
+    return a.accessService.AuthorizeChild(a.currentTherapist, permission, childID)
+}
+
+// authorizeRecord checks a permission for the child a session-related record belongs to
+func (a *App) authorizeRecord(permission, table string, id uint) error {
+    return a.accessService.AuthorizeRecord(a.currentTherapist, permission, table, id)
+}
+
+// can reports whether the logged-in therapist holds a permission
+func (a *App) can(permission string) bool {
+    return a.accessService.Can(a.currentTherapist, permission)
+}
+
+// caseloadChildIDs returns the children the current user may see; scoped is false when all are visible
+func (a *App) caseloadChildIDs() (childIDs []uint, scoped bool, err error) {
+    return a.accessService.CaseloadChildIDs(a.currentTherapist)
 }
 
 // Greet returns a greeting for the given name
@@ -60,144 +220,2658 @@ func (a *App) Greet(name string) string {
 	return fmt.Sprintf("Hello %s, It's show time!", name)
 }
 
-// ===== CHILD MANAGEMENT METHODS =====
+// ===== THERAPIST ACCOUNTS =====
+
+// IsMultiUserMode reports whether therapist accounts exist and a login is required
+func (a *App) IsMultiUserMode() (bool, error) {
+    return a.therapistService.HasTherapists()
+}
+
+// Login signs a therapist in; their caseload scopes what they see from then on
+func (a *App) Login(username, password string) (*model.Therapist, error) {
+    therapist, err := a.therapistService.Authenticate(username, password)
+    if err != nil {
+        return nil, err
+    }
+
+    a.currentTherapist = therapist
+    a.auditService.SetActor(therapist.Username)
+
+    runtime.EventsEmit(a.ctx, "auth_changed", map[string]interface{}{
+        "therapist_id": therapist.ID,
+        "username":     therapist.Username,
+        "role":         therapist.Role,
+        "timestamp":    time.Now(),
+    })
+
+    return therapist, nil
+}
+
+// Logout signs the current therapist out
+func (a *App) Logout() {
+    a.currentTherapist = nil
+    a.auditService.SetActor(a.currentActor())
+
+    runtime.EventsEmit(a.ctx, "auth_changed", map[string]interface{}{
+        "therapist_id": 0,
+        "timestamp":    time.Now(),
+    })
+}
+
+// GetCurrentTherapist returns the logged-in therapist, or nil when nobody is logged in
+func (a *App) GetCurrentTherapist() *model.Therapist {
+    return a.currentTherapist
+}
+
+// CreateTherapist creates a therapist account; the first account created becomes an admin
+func (a *App) CreateTherapist(username, name, password, role string) (*model.Therapist, error) {
+    if err := a.authorize(services.PermAccountManage); err != nil {
+        return nil, err
+    }
+
+    return a.therapistService.CreateTherapist(username, name, password, role)
+}
+
+// GetAllTherapists lists all therapist accounts
+func (a *App) GetAllTherapists() ([]model.Therapist, error) {
+    if err := a.authorize(services.PermStaffView); err != nil {
+        return nil, err
+    }
+
+    return a.therapistService.GetAllTherapists()
+}
+
+// UpdateTherapist changes a therapist's name, role and active flag
+func (a *App) UpdateTherapist(id uint, name, role string, isActive bool) (*model.Therapist, error) {
+    if err := a.authorize(services.PermAccountManage); err != nil {
+        return nil, err
+    }
+
+    therapist, err := a.therapistService.UpdateTherapist(id, name, role, isActive)
+    if err != nil {
+        return nil, err
+    }
+    if a.currentTherapist != nil && a.currentTherapist.ID == therapist.ID {
+        a.currentTherapist = therapist
+    }
+    return therapist, nil
+}
+
+// SetTherapistPassword changes a password; therapists may change their own, admins anyone's
+func (a *App) SetTherapistPassword(id uint, password string) error {
+    if a.currentTherapist == nil || a.currentTherapist.ID != id {
+        if err := a.authorize(services.PermAccountManage); err != nil {
+            return err
+        }
+    }
+    return a.therapistService.SetTherapistPassword(id, password)
+}
+
+// SetTherapistRequiresReview designates a therapist (e.g. a trainee) whose sessions need a supervisor's co-signature
+func (a *App) SetTherapistRequiresReview(id uint, requiresReview bool) (*model.Therapist, error) {
+    if err := a.authorize(services.PermAccountManage); err != nil {
+        return nil, err
+    }
+    return a.therapistService.SetTherapistRequiresReview(id, requiresReview)
+}
+
+// AssignChildToTherapist adds a child to a therapist's caseload
+func (a *App) AssignChildToTherapist(childID, therapistID uint) error {
+    if err := a.authorize(services.PermAccountManage); err != nil {
+        return err
+    }
+
+    return a.therapistService.AssignChild(childID, therapistID)
+}
+
+// UnassignChildFromTherapist removes a child from a therapist's caseload
+func (a *App) UnassignChildFromTherapist(childID, therapistID uint) error {
+    if err := a.authorize(services.PermAccountManage); err != nil {
+        return err
+    }
+
+    return a.therapistService.UnassignChild(childID, therapistID)
+}
+
+// GetChildTherapists lists the therapists a child is assigned to
+func (a *App) GetChildTherapists(childID uint) ([]model.Therapist, error) {
+    if err := a.authorizeChild(services.PermChildView, childID); err != nil {
+        return nil, err
+    }
+
+    return a.therapistService.GetChildTherapists(childID)
+}
+
+// GetMyPermissions lists what the logged-in therapist may do; in single-user mode everything is allowed
+func (a *App) GetMyPermissions() ([]string, error) {
+    if a.currentTherapist != nil {
+        return services.GetRolePermissions(a.currentTherapist.Role), nil
+    }
+    if err := a.authorize(services.PermMigrationRun); err != nil {
+        return nil, err
+    }
+    return services.GetRolePermissions(services.RoleAdmin), nil
+}
+
+// ===== DATABASE MAINTENANCE =====
+
+// RunDatabaseMigrations applies any pending database migrations
+func (a *App) RunDatabaseMigrations() error {
+    if err := a.authorize(services.PermMigrationRun); err != nil {
+        return err
+    }
+    return db.RunMigrationsManual(a.database)
+}
+
+// RollbackDatabaseMigration reverts one applied migration by version
+func (a *App) RollbackDatabaseMigration(version string) error {
+    if err := a.authorize(services.PermMigrationRun); err != nil {
+        return err
+    }
+    return db.RollbackMigration(a.database, version)
+}
+
+// ===== CHILD MANAGEMENT METHODS =====
+
+// GetAllChildren retrieves the children in the logged-in therapist's caseload, or all children for admins
+func (a *App) GetAllChildren() ([]model.Child, error) {
+	_, scoped, err := a.caseloadChildIDs()
+	if err != nil {
+		return nil, err
+	}
+	var children []model.Child
+	if scoped {
+		children, err = a.childService.GetChildrenForTherapist(a.currentTherapist.ID)
+	} else {
+		children, err = a.childService.GetAllChildren()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !a.can(services.PermNoteRead) {
+		for i := range children {
+			services.RedactChild(&children[i])
+		}
+	}
+	return children, nil
+}
+
+// CreateChild creates a new child record
+func (a *App) CreateChild(name, gender, parentGuardianName, contactInfo, initialAssessment, dateOfBirth string) (*model.Child, error) {
+    if err := a.authorize(services.PermChildCreate); err != nil {
+        return nil, err
+    }
+
+    var dobPtr *string
+    if dateOfBirth != "" {
+        dobPtr = &dateOfBirth
+    }
+    child, err := a.childService.CreateChild(name, gender, parentGuardianName, contactInfo, initialAssessment, dobPtr)
+    if err != nil {
+        return nil, err
+    }
+
+    // A therapist's new child joins their own caseload so it stays visible to them
+    if a.currentTherapist != nil && !services.HasPermission(a.currentTherapist.Role, services.PermCaseloadAll) {
+        if err := a.therapistService.AssignChild(child.ID, a.currentTherapist.ID); err != nil {
+            return nil, err
+        }
+    }
+
+    // Emit child added event for dashboard/frontend updates
+    runtime.EventsEmit(a.ctx, "child_added", map[string]interface{}{
+        "child_id": child.ID,
+        "name":     child.Name,
+        "gender":   child.Gender,
+        "timestamp": time.Now(),
+    })
+
+    return child, nil
+}
+
+// GetAgeBands lists the age bands the children list can be filtered by
+func (a *App) GetAgeBands() []services.AgeBand {
+    return services.AgeBands
+}
+
+// GetChildrenByAgeBand retrieves the visible children whose current age falls in a band
+func (a *App) GetChildrenByAgeBand(band string) ([]model.Child, error) {
+    ageBand, ok := services.LookupAgeBand(band)
+    if !ok {
+        return nil, fmt.Errorf("kelompok usia tidak dikenal: %s", band)
+    }
+    return a.GetChildrenByAge(ageBand.MinMonths, ageBand.MaxMonths)
+}
+
+// GetChildrenByAge retrieves the visible children aged between minMonths and maxMonths
+// inclusive; a negative maxMonths means no upper bound
+func (a *App) GetChildrenByAge(minMonths, maxMonths int) ([]model.Child, error) {
+    children, err := a.GetAllChildren()
+    if err != nil {
+        return nil, err
+    }
+    return services.FilterChildrenByAge(children, minMonths, maxMonths), nil
+}
+
+// GetChildByID retrieves a specific child by ID
+func (a *App) GetChildByID(id uint) (*model.Child, error) {
+	if err := a.authorizeChild(services.PermChildView, id); err != nil {
+		return nil, err
+	}
+
+	child, err := a.childService.GetChildByID(id)
+	if err != nil {
+		return nil, err
+	}
+	a.auditRead(services.AuditEntityChild, child.ID)
+	if !a.can(services.PermNoteRead) {
+		services.RedactChild(child)
+	}
+	return child, nil
+}
+
+// UpdateChild updates a child's information
+func (a *App) UpdateChild(id uint, name, gender, parentGuardianName, contactInfo, initialAssessment, dateOfBirth string) (*model.Child, error) {
+	if err := a.authorizeChild(services.PermChildEditContact, id); err != nil {
+		return nil, err
+	}
+
+	// Roles that cannot see the initial assessment receive it blank; keep the stored one
+	if !a.can(services.PermNoteWrite) {
+		current, err := a.childService.GetChildByID(id)
+		if err != nil {
+			return nil, err
+		}
+		initialAssessment = current.InitialAssessment
+	}
+
+	child, err := a.childService.UpdateChild(id, name, gender, parentGuardianName, contactInfo, initialAssessment, dateOfBirth)
+	if err != nil {
+		return nil, err
+	}
+	if !a.can(services.PermNoteRead) {
+		services.RedactChild(child)
+	}
+	return child, nil
+}
+
+// DeleteChild removes a child record (soft delete)
+func (a *App) DeleteChild(id uint) error {
+	if err := a.authorize(services.PermChildDelete); err != nil {
+		return err
+	}
+
+	return a.childService.DeleteChild(id)
+}
+
+// EraseChild permanently erases a child and all of their data, returning a signed receipt.
+// Unlike DeleteChild this cannot be undone.
+func (a *App) EraseChild(id uint, reason string) (*model.ErasureReceipt, error) {
+    if err := a.authorize(services.PermChildErase); err != nil {
+        return nil, err
+    }
+
+    // The orphan sweep spares recent files, so the child's own files are removed by name
+    storedNames, err := a.attachmentService.StoredFileNames(id)
+    if err != nil {
+        return nil, err
+    }
+    receipt, err := a.erasureService.EraseChild(id, a.currentActor(), reason)
+    if err != nil {
+        return nil, err
+    }
+    if err := a.attachmentService.RemoveStoredFiles(storedNames); err != nil {
+        return nil, fmt.Errorf("data anak sudah dihapus, tetapi %w", err)
+    }
+    a.cleanupAttachmentFiles()
+
+    runtime.EventsEmit(a.ctx, "child_erased", map[string]interface{}{
+        "child_id":   id,
+        "receipt_id": receipt.ID,
+        "timestamp":  time.Now(),
+    })
+
+    return receipt, nil
+}
+
+// GetErasureReceipts lists the receipts of all permanent erasures
+func (a *App) GetErasureReceipts() ([]model.ErasureReceipt, error) {
+    if err := a.authorize(services.PermChildErase); err != nil {
+        return nil, err
+    }
+
+    return a.erasureService.GetErasureReceipts()
+}
+
+// VerifyErasureReceipt checks the signature of an erasure receipt
+func (a *App) VerifyErasureReceipt(receiptID uint) (*services.ErasureReceiptVerification, error) {
+    if err := a.authorize(services.PermChildErase); err != nil {
+        return nil, err
+    }
+
+    return a.erasureService.VerifyErasureReceipt(receiptID)
+}
+
+// ===== GUARDIANS =====
+
+// GetChildGuardians lists a child's parents, guardians and emergency contacts
+func (a *App) GetChildGuardians(childID uint) ([]model.Guardian, error) {
+    if err := a.authorizeChild(services.PermChildView, childID); err != nil {
+        return nil, err
+    }
+    return a.guardianService.GetGuardiansByChild(childID)
+}
+
+// CreateGuardian adds a parent, guardian or emergency contact to a child
+func (a *App) CreateGuardian(childID uint, input services.GuardianInput) (*model.Guardian, error) {
+    if err := a.authorizeChild(services.PermChildEditContact, childID); err != nil {
+        return nil, err
+    }
+    guardian, err := a.guardianService.CreateGuardian(childID, input)
+    if err != nil {
+        return nil, err
+    }
+    a.emitGuardianUpdate(guardian.ChildID, guardian.ID, "added")
+    return guardian, nil
+}
+
+// UpdateGuardian changes a guardian's details, relationship and consent flags
+func (a *App) UpdateGuardian(guardianID uint, input services.GuardianInput) (*model.Guardian, error) {
+    if err := a.authorizeRecord(services.PermChildEditContact, "guardians", guardianID); err != nil {
+        return nil, err
+    }
+    guardian, err := a.guardianService.UpdateGuardian(guardianID, input)
+    if err != nil {
+        return nil, err
+    }
+    a.emitGuardianUpdate(guardian.ChildID, guardian.ID, "updated")
+    return guardian, nil
+}
+
+// DeleteGuardian removes a guardian from a child
+func (a *App) DeleteGuardian(guardianID uint) error {
+    if err := a.authorizeRecord(services.PermChildEditContact, "guardians", guardianID); err != nil {
+        return err
+    }
+    guardian, err := a.guardianService.GetGuardianByID(guardianID)
+    if err != nil {
+        return err
+    }
+    if err := a.guardianService.DeleteGuardian(guardianID); err != nil {
+        return err
+    }
+    a.emitGuardianUpdate(guardian.ChildID, guardian.ID, "deleted")
+    return nil
+}
+
+func (a *App) emitGuardianUpdate(childID, guardianID uint, action string) {
+    runtime.EventsEmit(a.ctx, "guardian_updated", map[string]interface{}{
+        "action":      action,
+        "child_id":    childID,
+        "guardian_id": guardianID,
+        "timestamp":   time.Now(),
+    })
+}
+
+// ===== CLINICAL PROFILE =====
+
+// GetClinicalProfile returns a child's school, referral source, diagnoses, medications and sensitivities
+func (a *App) GetClinicalProfile(childID uint) (*services.ClinicalProfile, error) {
+    if err := a.authorizeChild(services.PermNoteRead, childID); err != nil {
+        return nil, err
+    }
+    profile, err := a.clinicalProfileService.GetClinicalProfile(childID)
+    if err != nil {
+        return nil, err
+    }
+    a.auditRead(services.AuditEntityChild, childID)
+    return profile, nil
+}
+
+// GetClinicalProfileHistory lists every change made to a child's clinical profile
+func (a *App) GetClinicalProfileHistory(childID uint) ([]model.ClinicalProfileChange, error) {
+    if err := a.authorizeChild(services.PermNoteRead, childID); err != nil {
+        return nil, err
+    }
+    return a.clinicalProfileService.GetClinicalHistory(childID)
+}
+
+// UpdateChildBackground sets the school and referral source of a child
+func (a *App) UpdateChildBackground(childID uint, school, referralSource string) (*model.Child, error) {
+    if err := a.authorizeChild(services.PermChildEditContact, childID); err != nil {
+        return nil, err
+    }
+    child, err := a.clinicalProfileService.UpdateBackground(childID, school, referralSource, a.currentActor())
+    if err != nil {
+        return nil, err
+    }
+    a.emitClinicalProfileUpdate(childID, services.ClinicalEntityBackground, childID, "updated")
+    return child, nil
+}
+
+// AddDiagnosis records a diagnosis with its ICD-10 code for a child
+func (a *App) AddDiagnosis(childID uint, input services.DiagnosisInput) (*model.ChildDiagnosis, error) {
+    if err := a.authorizeChild(services.PermNoteWrite, childID); err != nil {
+        return nil, err
+    }
+    diagnosis, err := a.clinicalProfileService.AddDiagnosis(childID, input, a.currentActor())
+    if err != nil {
+        return nil, err
+    }
+    a.emitClinicalProfileUpdate(childID, services.ClinicalEntityDiagnosis, diagnosis.ID, "added")
+    return diagnosis, nil
+}
+
+// UpdateDiagnosis changes a diagnosis, e.g. to mark it resolved
+func (a *App) UpdateDiagnosis(diagnosisID uint, input services.DiagnosisInput) (*model.ChildDiagnosis, error) {
+    if err := a.authorizeRecord(services.PermNoteWrite, "child_diagnoses", diagnosisID); err != nil {
+        return nil, err
+    }
+    diagnosis, err := a.clinicalProfileService.UpdateDiagnosis(diagnosisID, input, a.currentActor())
+    if err != nil {
+        return nil, err
+    }
+    a.emitClinicalProfileUpdate(diagnosis.ChildID, services.ClinicalEntityDiagnosis, diagnosis.ID, "updated")
+    return diagnosis, nil
+}
+
+// DeleteDiagnosis removes a diagnosis entered in error
+func (a *App) DeleteDiagnosis(diagnosisID uint) error {
+    if err := a.authorizeRecord(services.PermNoteWrite, "child_diagnoses", diagnosisID); err != nil {
+        return err
+    }
+    diagnosis, err := a.clinicalProfileService.DeleteDiagnosis(diagnosisID, a.currentActor())
+    if err != nil {
+        return err
+    }
+    a.emitClinicalProfileUpdate(diagnosis.ChildID, services.ClinicalEntityDiagnosis, diagnosis.ID, "deleted")
+    return nil
+}
+
+// AddMedication records a medication for a child
+func (a *App) AddMedication(childID uint, input services.MedicationInput) (*model.ChildMedication, error) {
+    if err := a.authorizeChild(services.PermNoteWrite, childID); err != nil {
+        return nil, err
+    }
+    medication, err := a.clinicalProfileService.AddMedication(childID, input, a.currentActor())
+    if err != nil {
+        return nil, err
+    }
+    a.emitClinicalProfileUpdate(childID, services.ClinicalEntityMedication, medication.ID, "added")
+    return medication, nil
+}
+
+// UpdateMedication changes a medication; setting an end date stops it
+func (a *App) UpdateMedication(medicationID uint, input services.MedicationInput) (*model.ChildMedication, error) {
+    if err := a.authorizeRecord(services.PermNoteWrite, "child_medications", medicationID); err != nil {
+        return nil, err
+    }
+    medication, err := a.clinicalProfileService.UpdateMedication(medicationID, input, a.currentActor())
+    if err != nil {
+        return nil, err
+    }
+    a.emitClinicalProfileUpdate(medication.ChildID, services.ClinicalEntityMedication, medication.ID, "updated")
+    return medication, nil
+}
+
+// DeleteMedication removes a medication entered in error
+func (a *App) DeleteMedication(medicationID uint) error {
+    if err := a.authorizeRecord(services.PermNoteWrite, "child_medications", medicationID); err != nil {
+        return err
+    }
+    medication, err := a.clinicalProfileService.DeleteMedication(medicationID, a.currentActor())
+    if err != nil {
+        return err
+    }
+    a.emitClinicalProfileUpdate(medication.ChildID, services.ClinicalEntityMedication, medication.ID, "deleted")
+    return nil
+}
+
+// AddSensitivity records an allergy or sensory sensitivity for a child
+func (a *App) AddSensitivity(childID uint, input services.SensitivityInput) (*model.ChildSensitivity, error) {
+    if err := a.authorizeChild(services.PermNoteWrite, childID); err != nil {
+        return nil, err
+    }
+    sensitivity, err := a.clinicalProfileService.AddSensitivity(childID, input, a.currentActor())
+    if err != nil {
+        return nil, err
+    }
+    a.emitClinicalProfileUpdate(childID, services.ClinicalEntitySensitivity, sensitivity.ID, "added")
+    return sensitivity, nil
+}
+
+// UpdateSensitivity changes an allergy or sensory sensitivity
+func (a *App) UpdateSensitivity(sensitivityID uint, input services.SensitivityInput) (*model.ChildSensitivity, error) {
+    if err := a.authorizeRecord(services.PermNoteWrite, "child_sensitivities", sensitivityID); err != nil {
+        return nil, err
+    }
+    sensitivity, err := a.clinicalProfileService.UpdateSensitivity(sensitivityID, input, a.currentActor())
+    if err != nil {
+        return nil, err
+    }
+    a.emitClinicalProfileUpdate(sensitivity.ChildID, services.ClinicalEntitySensitivity, sensitivity.ID, "updated")
+    return sensitivity, nil
+}
+
+// DeleteSensitivity removes an allergy or sensory sensitivity
+func (a *App) DeleteSensitivity(sensitivityID uint) error {
+    if err := a.authorizeRecord(services.PermNoteWrite, "child_sensitivities", sensitivityID); err != nil {
+        return err
+    }
+    sensitivity, err := a.clinicalProfileService.DeleteSensitivity(sensitivityID, a.currentActor())
+    if err != nil {
+        return err
+    }
+    a.emitClinicalProfileUpdate(sensitivity.ChildID, services.ClinicalEntitySensitivity, sensitivity.ID, "deleted")
+    return nil
+}
+
+func (a *App) emitClinicalProfileUpdate(childID uint, entityType string, entityID uint, action string) {
+    runtime.EventsEmit(a.ctx, "clinical_profile_updated", map[string]interface{}{
+        "action":      action,
+        "child_id":    childID,
+        "entity_type": entityType,
+        "entity_id":   entityID,
+        "timestamp":   time.Now(),
+    })
+}
+
+// ===== ATTACHMENTS =====
+
+// AttachFile asks for a file with the open-file dialog and attaches it to a child or session
+func (a *App) AttachFile(input services.AttachmentInput) (*model.Attachment, error) {
+    if err := a.authorizeChild(services.AttachmentPermission(input.Type, true), input.ChildID); err != nil {
+        return nil, err
+    }
+
+    filePath, err := runtime.OpenFileDialog(a.ctx, runtime.OpenDialogOptions{
+        Title: "Pilih File Lampiran",
+        Filters: []runtime.FileFilter{
+            {
+                DisplayName: "Dokumen dan Gambar (*.pdf, *.jpg, *.png, *.doc, *.docx)",
+                Pattern:     "*.pdf;*.jpg;*.jpeg;*.png;*.heic;*.doc;*.docx;*.odt;*.txt",
+            },
+            {
+                DisplayName: "Semua File",
+                Pattern:     "*.*",
+            },
+        },
+    })
+    if err != nil {
+        return nil, fmt.Errorf("dialog dibatalkan atau gagal: %w", err)
+    }
+    if filePath == "" {
+        return nil, fmt.Errorf("tidak ada file yang dipilih")
+    }
+
+    attachment, err := a.attachmentService.AttachFile(filePath, input, a.currentActor())
+    if err != nil {
+        return nil, err
+    }
+    a.emitAttachmentUpdate(attachment, "added")
+    return attachment, nil
+}
+
+// GetChildAttachments lists a child's attachments the current user may see
+func (a *App) GetChildAttachments(childID uint) ([]model.Attachment, error) {
+    if err := a.authorizeChild(services.PermChildView, childID); err != nil {
+        return nil, err
+    }
+    attachments, err := a.attachmentService.GetAttachmentsByChild(childID)
+    if err != nil {
+        return nil, err
+    }
+    return a.visibleAttachments(attachments), nil
+}
+
+// GetSessionAttachments lists the attachments of a session the current user may see
+func (a *App) GetSessionAttachments(sessionID uint) ([]model.Attachment, error) {
+    if err := a.authorizeRecord(services.PermSessionView, "sessions", sessionID); err != nil {
+        return nil, err
+    }
+    attachments, err := a.attachmentService.GetAttachmentsBySession(sessionID)
+    if err != nil {
+        return nil, err
+    }
+    return a.visibleAttachments(attachments), nil
+}
+
+// OpenAttachment verifies an attachment and opens a copy with the system's default application
+func (a *App) OpenAttachment(attachmentID uint) error {
+    attachment, err := a.attachmentService.GetAttachmentByID(attachmentID)
+    if err != nil {
+        return err
+    }
+    if err := a.authorizeRecord(services.AttachmentPermission(attachment.Type, false), "attachments", attachmentID); err != nil {
+        return err
+    }
+
+    path, err := a.attachmentService.ExportAttachment(attachmentID, openedAttachmentsDir())
+    if err != nil {
+        return err
+    }
+    a.auditRead(services.AuditEntityAttachment, attachmentID)
+    if err := openWithDefaultApp(path); err != nil {
+        os.Remove(path)
+        return err
+    }
+    removeOpenedAttachmentLater(path)
+    return nil
+}
+
+// VerifyAttachment checks an attachment's stored file against its checksum
+func (a *App) VerifyAttachment(attachmentID uint) (*services.AttachmentVerification, error) {
+    attachment, err := a.attachmentService.GetAttachmentByID(attachmentID)
+    if err != nil {
+        return nil, err
+    }
+    if err := a.authorizeRecord(services.AttachmentPermission(attachment.Type, false), "attachments", attachmentID); err != nil {
+        return nil, err
+    }
+    return a.attachmentService.VerifyAttachment(attachmentID)
+}
+
+// VerifyAllAttachments checks every stored attachment file against its checksum
+func (a *App) VerifyAllAttachments() ([]services.AttachmentVerification, error) {
+    if err := a.authorize(services.PermAuditView); err != nil {
+        return nil, err
+    }
+    return a.attachmentService.VerifyAllAttachments()
+}
+
+// DeleteAttachment moves an attachment to the trash
+func (a *App) DeleteAttachment(attachmentID uint) error {
+    attachment, err := a.attachmentService.GetAttachmentByID(attachmentID)
+    if err != nil {
+        return err
+    }
+    if err := a.authorizeRecord(services.AttachmentPermission(attachment.Type, true), "attachments", attachmentID); err != nil {
+        return err
+    }
+    if _, err := a.attachmentService.DeleteAttachment(attachmentID); err != nil {
+        return err
+    }
+    a.emitAttachmentUpdate(attachment, "deleted")
+    return nil
+}
+
+// GetAttachmentEncryption reports whether new attachments are encrypted at rest
+func (a *App) GetAttachmentEncryption() (bool, error) {
+    if err := a.authorize(services.PermAccountManage); err != nil {
+        return false, err
+    }
+    return a.attachmentService.IsEncryptionEnabled()
+}
+
+// SetAttachmentEncryption turns encryption at rest on or off for new attachments
+func (a *App) SetAttachmentEncryption(enabled bool) error {
+    if err := a.authorize(services.PermAccountManage); err != nil {
+        return err
+    }
+    return a.attachmentService.SetEncryptionEnabled(enabled)
+}
+
+// visibleAttachments drops attachments whose type the current user may not read
+func (a *App) visibleAttachments(attachments []model.Attachment) []model.Attachment {
+    visible := make([]model.Attachment, 0, len(attachments))
+    for _, attachment := range attachments {
+        if a.can(services.AttachmentPermission(attachment.Type, false)) {
+            visible = append(visible, attachment)
+        }
+    }
+    return visible
+}
+
+func (a *App) emitAttachmentUpdate(attachment *model.Attachment, action string) {
+    runtime.EventsEmit(a.ctx, "attachment_updated", map[string]interface{}{
+        "action":        action,
+        "attachment_id": attachment.ID,
+        "child_id":      attachment.ChildID,
+        "session_id":    attachment.SessionID,
+        "timestamp":     time.Now(),
+    })
+}
+
+// ===== CONSENTS =====
+
+// GetChildConsents lists a child's consents, including withdrawn ones
+func (a *App) GetChildConsents(childID uint) ([]model.Consent, error) {
+    if err := a.authorizeChild(services.PermChildView, childID); err != nil {
+        return nil, err
+    }
+    return a.consentService.GetConsentsByChild(childID)
+}
+
+// RecordConsent records a signed consent for treatment, photography or data sharing
+func (a *App) RecordConsent(childID uint, input services.ConsentInput) (*model.Consent, error) {
+    if err := a.authorizeChild(services.PermChildEditContact, childID); err != nil {
+        return nil, err
+    }
+    consent, err := a.consentService.RecordConsent(childID, input, a.currentActor())
+    if err != nil {
+        return nil, err
+    }
+    a.emitConsentUpdate(consent, "recorded")
+    return consent, nil
+}
+
+// UpdateConsent corrects the details of a consent, e.g. its expiry date
+func (a *App) UpdateConsent(consentID uint, input services.ConsentInput) (*model.Consent, error) {
+    if err := a.authorizeRecord(services.PermChildEditContact, "consents", consentID); err != nil {
+        return nil, err
+    }
+    consent, err := a.consentService.UpdateConsent(consentID, input)
+    if err != nil {
+        return nil, err
+    }
+    a.emitConsentUpdate(consent, "updated")
+    return consent, nil
+}
+
+// WithdrawConsent records that the family has withdrawn a consent
+func (a *App) WithdrawConsent(consentID uint, reason string) (*model.Consent, error) {
+    if err := a.authorizeRecord(services.PermChildEditContact, "consents", consentID); err != nil {
+        return nil, err
+    }
+    consent, err := a.consentService.WithdrawConsent(consentID, reason)
+    if err != nil {
+        return nil, err
+    }
+    a.emitConsentUpdate(consent, "withdrawn")
+    return consent, nil
+}
+
+// GetExpiringConsents lists consents in the current caseload expiring within the given number of days
+func (a *App) GetExpiringConsents(withinDays int) ([]services.ExpiringConsent, error) {
+    if err := a.authorize(services.PermChildView); err != nil {
+        return nil, err
+    }
+    if withinDays <= 0 {
+        withinDays = services.DefaultConsentExpiryWindowDays
+    }
+    return a.expiringConsents(withinDays)
+}
+
+func (a *App) expiringConsents(withinDays int) ([]services.ExpiringConsent, error) {
+    childIDs, scoped, err := a.caseloadChildIDs()
+    if err != nil {
+        return nil, err
+    }
+    if !scoped {
+        childIDs = nil
+    } else if childIDs == nil {
+        childIDs = []uint{}
+    }
+    return a.consentService.GetExpiringConsents(withinDays, childIDs)
+}
+
+func (a *App) emitConsentUpdate(consent *model.Consent, action string) {
+    runtime.EventsEmit(a.ctx, "consent_updated", map[string]interface{}{
+        "action":     action,
+        "consent_id": consent.ID,
+        "child_id":   consent.ChildID,
+        "type":       consent.Type,
+        "timestamp":  time.Now(),
+    })
+}
+
+// ===== ASSESSMENTS =====
+
+// GetAssessmentInstruments lists the standardised instruments available for new administrations
+func (a *App) GetAssessmentInstruments(includeInactive bool) ([]services.AssessmentInstrumentView, error) {
+    if err := a.authorize(services.PermCatalogView); err != nil {
+        return nil, err
+    }
+    return a.assessmentService.GetInstruments(!includeInactive)
+}
+
+// ImportAssessmentInstrument adds an instrument from a JSON definition file chosen by the user
+func (a *App) ImportAssessmentInstrument() (*model.AssessmentInstrument, error) {
+    if err := a.authorize(services.PermCatalogManage); err != nil {
+        return nil, err
+    }
+    path, err := runtime.OpenFileDialog(a.ctx, runtime.OpenDialogOptions{
+        Title:   "Pilih definisi instrumen",
+        Filters: []runtime.FileFilter{{DisplayName: "Definisi instrumen (*.json)", Pattern: "*.json"}},
+    })
+    if err != nil {
+        return nil, err
+    }
+    if path == "" {
+        return nil, nil
+    }
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, fmt.Errorf("gagal membaca file definisi: %w", err)
+    }
+    instrument, err := a.assessmentService.ImportInstrument(string(data))
+    if err != nil {
+        return nil, err
+    }
+    runtime.EventsEmit(a.ctx, "assessment_instrument_updated", map[string]interface{}{
+        "action":        "imported",
+        "instrument_id": instrument.ID,
+        "timestamp":     time.Now(),
+    })
+    return instrument, nil
+}
+
+// SetAssessmentInstrumentActive shows or hides an instrument for new administrations
+func (a *App) SetAssessmentInstrumentActive(instrumentID uint, active bool) error {
+    if err := a.authorize(services.PermCatalogManage); err != nil {
+        return err
+    }
+    if err := a.assessmentService.SetInstrumentActive(instrumentID, active); err != nil {
+        return err
+    }
+    runtime.EventsEmit(a.ctx, "assessment_instrument_updated", map[string]interface{}{
+        "action":        "updated",
+        "instrument_id": instrumentID,
+        "timestamp":     time.Now(),
+    })
+    return nil
+}
+
+// PreviewAssessmentScore scores responses without saving them
+func (a *App) PreviewAssessmentScore(instrumentID uint, responses map[string]string) (*services.AssessmentScore, error) {
+    if err := a.authorize(services.PermNoteWrite); err != nil {
+        return nil, err
+    }
+    return a.assessmentService.ScoreResponses(instrumentID, responses)
+}
+
+// RecordAssessment scores and saves a completed instrument for a child
+func (a *App) RecordAssessment(childID uint, input services.AdministrationInput) (*services.AdministrationResult, error) {
+    if err := a.authorizeChild(services.PermNoteWrite, childID); err != nil {
+        return nil, err
+    }
+    result, err := a.assessmentService.RecordAdministration(childID, input, a.currentActor(), a.actingTherapistID())
+    if err != nil {
+        return nil, err
+    }
+    a.emitAssessmentUpdate(&result.AssessmentAdministration, "recorded")
+    return result, nil
+}
+
+// GetChildAssessments lists a child's administrations, newest first; instrumentCode may be empty
+func (a *App) GetChildAssessments(childID uint, instrumentCode string) ([]services.AdministrationResult, error) {
+    if err := a.authorizeChild(services.PermNoteRead, childID); err != nil {
+        return nil, err
+    }
+    return a.assessmentService.GetAdministrationsByChild(childID, instrumentCode)
+}
+
+// GetAssessment retrieves one administration with its responses and scores
+func (a *App) GetAssessment(administrationID uint) (*services.AdministrationResult, error) {
+    if err := a.authorizeRecord(services.PermNoteRead, "assessment_administrations", administrationID); err != nil {
+        return nil, err
+    }
+    return a.assessmentService.GetAdministration(administrationID)
+}
+
+// DeleteAssessment removes an administration entered in error
+func (a *App) DeleteAssessment(administrationID uint) error {
+    if err := a.authorizeRecord(services.PermNoteWrite, "assessment_administrations", administrationID); err != nil {
+        return err
+    }
+    administration, err := a.assessmentService.DeleteAdministration(administrationID)
+    if err != nil {
+        return err
+    }
+    a.emitAssessmentUpdate(administration, "deleted")
+    return nil
+}
+
+// CompareAssessments shows how a child's scores on one instrument changed over time
+func (a *App) CompareAssessments(childID uint, instrumentCode string) (*services.AssessmentComparison, error) {
+    if err := a.authorizeChild(services.PermNoteRead, childID); err != nil {
+        return nil, err
+    }
+    return a.assessmentService.CompareAdministrations(childID, instrumentCode)
+}
+
+func (a *App) emitAssessmentUpdate(administration *model.AssessmentAdministration, action string) {
+    runtime.EventsEmit(a.ctx, "assessment_updated", map[string]interface{}{
+        "action":            action,
+        "administration_id": administration.ID,
+        "child_id":          administration.ChildID,
+        "session_id":        administration.SessionID,
+        "timestamp":         time.Now(),
+    })
+}
+
+// ===== MILESTONES =====
+
+// GetMilestones lists the developmental milestone library, optionally for one domain
+func (a *App) GetMilestones(domain string) ([]model.Milestone, error) {
+    if err := a.authorize(services.PermCatalogView); err != nil {
+        return nil, err
+    }
+    return a.milestoneService.GetMilestones(domain)
+}
+
+// GetChildMilestones lists every milestone with the child's status, optionally for one domain
+func (a *App) GetChildMilestones(childID uint, domain string) ([]services.ChildMilestoneView, error) {
+    if err := a.authorizeChild(services.PermNoteRead, childID); err != nil {
+        return nil, err
+    }
+    return a.milestoneService.GetChildMilestones(childID, domain)
+}
+
+// GetMissedMilestones lists milestones expected for the child's age that are not yet achieved
+func (a *App) GetMissedMilestones(childID uint) (*services.MissedMilestones, error) {
+    if err := a.authorizeChild(services.PermNoteRead, childID); err != nil {
+        return nil, err
+    }
+    return a.milestoneService.GetMissedMilestones(childID)
+}
+
+// SetChildMilestoneStatus records a child's progress on one milestone
+func (a *App) SetChildMilestoneStatus(childID, milestoneID uint, input services.MilestoneStatusInput) (*model.ChildMilestone, error) {
+    if err := a.authorizeChild(services.PermNoteWrite, childID); err != nil {
+        return nil, err
+    }
+    record, err := a.milestoneService.SetMilestoneStatus(childID, milestoneID, input, a.currentActor())
+    if err != nil {
+        return nil, err
+    }
+    runtime.EventsEmit(a.ctx, "milestone_updated", map[string]interface{}{
+        "child_id":     childID,
+        "milestone_id": milestoneID,
+        "status":       record.Status,
+        "timestamp":    time.Now(),
+    })
+    return record, nil
+}
+
+// ===== SESSION PLANS =====
+
+// CreateSessionPlan prepares an ordered list of activities for a child's next session
+func (a *App) CreateSessionPlan(childID uint, input services.SessionPlanInput) (*model.SessionPlan, error) {
+    if err := a.authorizeChild(services.PermNoteWrite, childID); err != nil {
+        return nil, err
+    }
+    if input.TherapistID == nil {
+        input.TherapistID = a.actingTherapistID()
+    }
+    plan, err := a.sessionPlanService.CreatePlan(childID, input, a.currentActor())
+    if err != nil {
+        return nil, err
+    }
+    a.emitSessionPlanUpdate(plan, "created")
+    return plan, nil
+}
+
+// UpdateSessionPlan replaces the activities of a plan that has not been used yet
+func (a *App) UpdateSessionPlan(planID uint, input services.SessionPlanInput) (*model.SessionPlan, error) {
+    if err := a.authorizeRecord(services.PermNoteWrite, "session_plans", planID); err != nil {
+        return nil, err
+    }
+    plan, err := a.sessionPlanService.UpdatePlan(planID, input)
+    if err != nil {
+        return nil, err
+    }
+    a.emitSessionPlanUpdate(plan, "updated")
+    return plan, nil
+}
+
+// DeleteSessionPlan removes a plan that has not been used yet
+func (a *App) DeleteSessionPlan(planID uint) error {
+    if err := a.authorizeRecord(services.PermNoteWrite, "session_plans", planID); err != nil {
+        return err
+    }
+    plan, err := a.sessionPlanService.DeletePlan(planID)
+    if err != nil {
+        return err
+    }
+    a.emitSessionPlanUpdate(plan, "deleted")
+    return nil
+}
+
+// GetSessionPlan retrieves a plan with its activities in order
+func (a *App) GetSessionPlan(planID uint) (*model.SessionPlan, error) {
+    if err := a.authorizeRecord(services.PermSessionView, "session_plans", planID); err != nil {
+        return nil, err
+    }
+    return a.sessionPlanService.GetPlan(planID)
+}
+
+// GetChildSessionPlans lists a child's plans; used plans are included on request
+func (a *App) GetChildSessionPlans(childID uint, includeUsed bool) ([]model.SessionPlan, error) {
+    if err := a.authorizeChild(services.PermSessionView, childID); err != nil {
+        return nil, err
+    }
+    return a.sessionPlanService.GetPlansByChild(childID, includeUsed)
+}
+
+// GetPlanForSession retrieves the plan a session was started with; nil when it had none
+func (a *App) GetPlanForSession(sessionID uint) (*model.SessionPlan, error) {
+    if err := a.authorizeRecord(services.PermSessionView, "sessions", sessionID); err != nil {
+        return nil, err
+    }
+    return a.sessionPlanService.GetSessionPlan(sessionID)
+}
+
+// GetNextPlannedActivity returns the next planned activity of a session; nil when the plan is done
+func (a *App) GetNextPlannedActivity(sessionID uint) (*model.SessionPlanItem, error) {
+    if err := a.authorizeRecord(services.PermSessionRun, "sessions", sessionID); err != nil {
+        return nil, err
+    }
+    return a.sessionPlanService.GetNextPlannedActivity(sessionID)
+}
+
+// StartNextPlannedActivity starts the next planned activity of a session
+func (a *App) StartNextPlannedActivity(sessionID uint) (*model.SessionActivity, error) {
+    if err := a.authorizeRecord(services.PermSessionRun, "sessions", sessionID); err != nil {
+        return nil, err
+    }
+    item, err := a.sessionPlanService.GetNextPlannedActivity(sessionID)
+    if err != nil {
+        return nil, err
+    }
+    if item == nil {
+        return nil, errors.New("semua aktivitas dalam rencana sudah dijalankan atau dilewati")
+    }
+    return a.StartActivityInSession(sessionID, item.ActivityID, "")
+}
+
+// SkipPlannedActivity marks a planned activity as not run in the session
+func (a *App) SkipPlannedActivity(itemID uint, reason string) (*model.SessionPlanItem, error) {
+    if err := a.authorizeRecord(services.PermSessionRun, "session_plan_items", itemID); err != nil {
+        return nil, err
+    }
+    item, err := a.sessionPlanService.SkipPlannedActivity(itemID, reason)
+    if err != nil {
+        return nil, err
+    }
+    runtime.EventsEmit(a.ctx, "session_plan_updated", map[string]interface{}{
+        "action":    "item_skipped",
+        "plan_id":   item.PlanID,
+        "item_id":   item.ID,
+        "timestamp": time.Now(),
+    })
+    return item, nil
+}
+
+func (a *App) emitSessionPlanUpdate(plan *model.SessionPlan, action string) {
+    runtime.EventsEmit(a.ctx, "session_plan_updated", map[string]interface{}{
+        "action":    action,
+        "plan_id":   plan.ID,
+        "child_id":  plan.ChildID,
+        "timestamp": time.Now(),
+    })
+}
+
+// ===== SESSION TEMPLATES =====
+
+// GetSessionTemplates lists the latest version of every template visible to the current user
+func (a *App) GetSessionTemplates(programme string) ([]model.SessionTemplate, error) {
+    if err := a.authorize(services.PermCatalogView); err != nil {
+        return nil, err
+    }
+    viewer, err := a.templateViewer()
+    if err != nil {
+        return nil, err
+    }
+    return a.sessionTemplateService.GetTemplates(viewer, programme)
+}
+
+// GetSessionTemplateVersions lists every version of a template, newest first
+func (a *App) GetSessionTemplateVersions(templateID uint) ([]model.SessionTemplate, error) {
+    if _, err := a.visibleTemplate(templateID); err != nil {
+        return nil, err
+    }
+    return a.sessionTemplateService.GetTemplateVersions(templateID)
+}
+
+// CreateSessionTemplate saves a new template owned by the current user
+func (a *App) CreateSessionTemplate(input services.SessionTemplateInput) (*model.SessionTemplate, error) {
+    if err := a.authorizeTemplateInput(input); err != nil {
+        return nil, err
+    }
+    viewer, err := a.templateViewer()
+    if err != nil {
+        return nil, err
+    }
+    template, err := a.sessionTemplateService.CreateTemplate(input, viewer, a.currentActor())
+    if err != nil {
+        return nil, err
+    }
+    a.emitSessionTemplateUpdate(template, "created")
+    return template, nil
+}
+
+// UpdateSessionTemplate saves a new version of a template
+func (a *App) UpdateSessionTemplate(templateID uint, input services.SessionTemplateInput) (*model.SessionTemplate, error) {
+    if err := a.authorizeTemplateInput(input); err != nil {
+        return nil, err
+    }
+    if _, err := a.visibleTemplate(templateID); err != nil {
+        return nil, err
+    }
+    viewer, err := a.templateViewer()
+    if err != nil {
+        return nil, err
+    }
+    template, err := a.sessionTemplateService.UpdateTemplate(templateID, input, viewer, a.currentActor())
+    if err != nil {
+        return nil, err
+    }
+    a.emitSessionTemplateUpdate(template, "updated")
+    return template, nil
+}
+
+// SetSessionTemplateShared shares a template with every therapist or makes it private
+func (a *App) SetSessionTemplateShared(templateID uint, shared bool) error {
+    if err := a.authorize(services.PermNoteWrite); err != nil {
+        return err
+    }
+    template, err := a.visibleTemplate(templateID)
+    if err != nil {
+        return err
+    }
+    viewer, err := a.templateViewer()
+    if err != nil {
+        return err
+    }
+    if err := a.sessionTemplateService.SetTemplateShared(templateID, shared, viewer); err != nil {
+        return err
+    }
+    a.emitSessionTemplateUpdate(template, "sharing_changed")
+    return nil
+}
+
+// DeleteSessionTemplate removes every version of a template
+func (a *App) DeleteSessionTemplate(templateID uint) error {
+    if err := a.authorize(services.PermNoteWrite); err != nil {
+        return err
+    }
+    if _, err := a.visibleTemplate(templateID); err != nil {
+        return err
+    }
+    viewer, err := a.templateViewer()
+    if err != nil {
+        return err
+    }
+    template, err := a.sessionTemplateService.DeleteTemplate(templateID, viewer)
+    if err != nil {
+        return err
+    }
+    a.emitSessionTemplateUpdate(template, "deleted")
+    return nil
+}
+
+// CreatePlanFromTemplate clones a template version into a session plan for a child
+func (a *App) CreatePlanFromTemplate(templateID, childID uint, title, plannedFor string) (*model.SessionPlan, error) {
+    if err := a.authorizeChild(services.PermNoteWrite, childID); err != nil {
+        return nil, err
+    }
+    if _, err := a.visibleTemplate(templateID); err != nil {
+        return nil, err
+    }
+    plan, err := a.sessionPlanService.CreatePlanFromTemplate(templateID, childID, title, plannedFor, a.actingTherapistID(), a.currentActor())
+    if err != nil {
+        return nil, err
+    }
+    a.emitSessionPlanUpdate(plan, "created")
+    return plan, nil
+}
+
+// GetSessionPlanResources returns the note templates, reward rules and flashcards of the
+// template a plan was cloned from; nil when the plan was not cloned from a template
+func (a *App) GetSessionPlanResources(planID uint) (map[string]interface{}, error) {
+    if err := a.authorizeRecord(services.PermSessionView, "session_plans", planID); err != nil {
+        return nil, err
+    }
+    resources, err := a.sessionTemplateService.GetPlanResources(planID)
+    if err != nil || resources == nil {
+        return nil, err
+    }
+
+    flashcards := make(map[string][]model.Flashcard, len(resources.FlashcardDecks))
+    for _, deck := range resources.FlashcardDecks {
+        cards, err := a.GetFlashcardsByCategory(deck)
+        if err != nil {
+            return nil, err
+        }
+        flashcards[deck] = cards
+    }
+    return map[string]interface{}{
+        "template_id":      resources.Template.ID,
+        "template_name":    resources.Template.Name,
+        "template_version": resources.Template.Version,
+        "note_templates":   resources.Template.NoteTemplates,
+        "reward_rules":     resources.Template.RewardRules,
+        "flashcard_decks":  resources.FlashcardDecks,
+        "flashcards":       flashcards,
+    }, nil
+}
+
+// templateViewer describes the current user for template visibility
+func (a *App) templateViewer() (services.TemplateViewer, error) {
+    childIDs, scoped, err := a.caseloadChildIDs()
+    if err != nil {
+        return services.TemplateViewer{}, err
+    }
+    viewer := services.TemplateViewer{
+        TherapistID: a.actingTherapistID(),
+        SeeAll:      a.can(services.PermCatalogManage),
+    }
+    if scoped {
+        viewer.ChildIDs = childIDs
+        if viewer.ChildIDs == nil {
+            viewer.ChildIDs = []uint{}
+        }
+    }
+    return viewer, nil
+}
+
+// visibleTemplate loads a template the current user may see
+func (a *App) visibleTemplate(templateID uint) (*model.SessionTemplate, error) {
+    if err := a.authorize(services.PermCatalogView); err != nil {
+        return nil, err
+    }
+    template, err := a.sessionTemplateService.GetTemplate(templateID)
+    if err != nil {
+        return nil, err
+    }
+    viewer, err := a.templateViewer()
+    if err != nil {
+        return nil, err
+    }
+    if !services.CanViewTemplate(template, viewer) {
+        return nil, errors.New("templat sesi tidak ditemukan")
+    }
+    return template, nil
+}
+
+func (a *App) authorizeTemplateInput(input services.SessionTemplateInput) error {
+    if input.ChildID != nil {
+        return a.authorizeChild(services.PermNoteWrite, *input.ChildID)
+    }
+    return a.authorize(services.PermNoteWrite)
+}
+
+func (a *App) emitSessionTemplateUpdate(template *model.SessionTemplate, action string) {
+    runtime.EventsEmit(a.ctx, "session_template_updated", map[string]interface{}{
+        "action":       action,
+        "template_id":  template.ID,
+        "template_key": template.TemplateKey,
+        "version":      template.Version,
+        "timestamp":    time.Now(),
+    })
+}
+
+// ===== GROUP SESSIONS =====
+
+// StartGroupSession begins one session for several children; the first is the session's primary child
+func (a *App) StartGroupSession(childIDs []uint, groupName string) (*model.Session, error) {
+    for _, childID := range childIDs {
+        if err := a.authorizeChild(services.PermSessionRun, childID); err != nil {
+            return nil, err
+        }
+    }
+
+    session, err := a.sessionService.StartGroupSession(childIDs, groupName, a.actingTherapistID())
+    if err != nil {
+        return nil, err
+    }
+    runtime.EventsEmit(a.ctx, "session_started", map[string]interface{}{
+        "session_id": session.ID,
+        "child_id":   session.ChildID,
+        "child_ids":  childIDs,
+        "start_time": session.StartTime,
+        "is_group":   true,
+        "warnings":   session.Warnings,
+    })
+    runtime.EventsEmit(a.ctx, "session_updated", map[string]interface{}{
+        "session_id": session.ID,
+        "child_id":   session.ChildID,
+        "change":     "started",
+        "timestamp":  time.Now(),
+    })
+    return session, nil
+}
+
+// AddGroupParticipant adds a child who arrives after a group session has started
+func (a *App) AddGroupParticipant(sessionID, childID uint) (*model.SessionParticipant, error) {
+    if err := a.authorizeRecord(services.PermSessionRun, "sessions", sessionID); err != nil {
+        return nil, err
+    }
+    if err := a.authorizeChild(services.PermSessionRun, childID); err != nil {
+        return nil, err
+    }
+
+    participant, err := a.sessionService.AddGroupParticipant(sessionID, childID)
+    if err != nil {
+        return nil, err
+    }
+    a.emitParticipantUpdate(participant, "added")
+    return participant, nil
+}
+
+// SetParticipantAttendance records whether a child was present, late, left early or absent
+func (a *App) SetParticipantAttendance(sessionID, childID uint, input services.ParticipantAttendanceInput) (*model.SessionParticipant, error) {
+    if err := a.authorizeChild(services.PermSessionRun, childID); err != nil {
+        return nil, err
+    }
+
+    participant, err := a.sessionService.SetParticipantAttendance(sessionID, childID, input)
+    if err != nil {
+        return nil, err
+    }
+    a.emitParticipantUpdate(participant, "attendance")
+    return participant, nil
+}
+
+// GetSessionParticipants lists the children of a group session with their attendance
+func (a *App) GetSessionParticipants(sessionID uint) ([]model.SessionParticipant, error) {
+    if err := a.authorizeRecord(services.PermSessionView, "sessions", sessionID); err != nil {
+        return nil, err
+    }
+
+    participants, err := a.sessionService.GetSessionParticipants(sessionID)
+    if err != nil {
+        return nil, err
+    }
+    if !a.can(services.PermNoteRead) {
+        for i := range participants {
+            services.RedactChild(&participants[i].Child)
+        }
+    }
+    return participants, nil
+}
+
+// AddParticipantNote adds a note about one child of a group session; it only shows in that child's records
+func (a *App) AddParticipantNote(sessionID, childID uint, noteText, category string) (*model.Note, error) {
+    if err := a.authorizeChild(services.PermNoteWrite, childID); err != nil {
+        return nil, err
+    }
+
+    return a.noteService.CreateChildNote(sessionID, childID, noteText, category, a.currentActor(), a.actingTherapistID())
+}
+
+func (a *App) emitParticipantUpdate(participant *model.SessionParticipant, action string) {
+    runtime.EventsEmit(a.ctx, "session_participant_updated", map[string]interface{}{
+        "action":     action,
+        "session_id": participant.SessionID,
+        "child_id":   participant.ChildID,
+        "attendance": participant.Attendance,
+        "timestamp":  time.Now(),
+    })
+}
+
+// ===== SESSION STATUS & ATTENDANCE =====
+
+// ScheduleSession books a session for a child at scheduledFor ("2006-01-02 15:04")
+func (a *App) ScheduleSession(childID uint, scheduledFor string) (*model.Session, error) {
+    if err := a.authorizeChild(services.PermSessionSchedule, childID); err != nil {
+        return nil, err
+    }
+    at, err := services.ParseSessionDateTime(scheduledFor)
+    if err != nil {
+        return nil, err
+    }
+
+    session, err := a.sessionService.ScheduleSession(childID, a.actingTherapistID(), at)
+    if err != nil {
+        return nil, err
+    }
+    a.emitSessionStatusUpdate(session, "scheduled")
+    return session, nil
+}
+
+// GetScheduledSessions lists booked sessions between two dates (inclusive) that have not started yet
+func (a *App) GetScheduledSessions(from, to string) ([]model.Session, error) {
+    if err := a.authorize(services.PermSessionView); err != nil {
+        return nil, err
+    }
+    start, end, err := services.ParseDateRange(from, to)
+    if err != nil {
+        return nil, err
+    }
+    if start.IsZero() {
+        start = time.Now()
+    }
+    if end.IsZero() {
+        end = start.AddDate(0, 1, 0)
+    }
+    childIDs, err := a.caseloadFilter()
+    if err != nil {
+        return nil, err
+    }
+
+    sessions, err := a.sessionService.GetScheduledSessions(start, end, childIDs)
+    if err != nil {
+        return nil, err
+    }
+    if !a.can(services.PermNoteRead) {
+        for i := range sessions {
+            services.RedactSession(&sessions[i])
+        }
+    }
+    return sessions, nil
+}
+
+// CheckInSession starts a scheduled session when the child arrives
+func (a *App) CheckInSession(sessionID uint) (*model.Session, error) {
+    if err := a.authorizeRecord(services.PermSessionRun, "sessions", sessionID); err != nil {
+        return nil, err
+    }
+
+    session, err := a.sessionService.CheckInSession(sessionID, a.actingTherapistID())
+    if err != nil {
+        return nil, err
+    }
+    runtime.EventsEmit(a.ctx, "session_started", map[string]interface{}{
+        "session_id": session.ID,
+        "child_id":   session.ChildID,
+        "start_time": session.StartTime,
+        "status":     session.Status,
+        "warnings":   session.Warnings,
+    })
+    a.emitSessionStatusUpdate(session, "started")
+    return session, nil
+}
+
+// SetSessionStatus records a cancellation, no-show or late arrival, with its reason
+func (a *App) SetSessionStatus(sessionID uint, status, reason string, lateMinutes int) (*model.Session, error) {
+    if err := a.authorizeRecord(services.PermSessionSchedule, "sessions", sessionID); err != nil {
+        return nil, err
+    }
+
+    session, err := a.sessionService.SetSessionStatus(sessionID, status, reason, lateMinutes)
+    if err != nil {
+        return nil, err
+    }
+    a.emitSessionStatusUpdate(session, "status")
+    return session, nil
+}
+
+// GetChildAttendanceStats returns a child's attendance rate overall and per month between two dates (inclusive, optional)
+func (a *App) GetChildAttendanceStats(childID uint, from, to string) (*services.ChildAttendanceStats, error) {
+    if err := a.authorizeChild(services.PermReportView, childID); err != nil {
+        return nil, err
+    }
+    start, end, err := services.ParseDateRange(from, to)
+    if err != nil {
+        return nil, err
+    }
+    return a.sessionService.GetChildAttendanceStats(childID, start, end)
+}
+
+// GetMonthlyAttendance returns attendance per month across the caseload between two dates (inclusive, optional)
+func (a *App) GetMonthlyAttendance(from, to string) ([]services.MonthlyAttendance, error) {
+    if err := a.authorize(services.PermReportView); err != nil {
+        return nil, err
+    }
+    start, end, err := services.ParseDateRange(from, to)
+    if err != nil {
+        return nil, err
+    }
+    childIDs, err := a.caseloadFilter()
+    if err != nil {
+        return nil, err
+    }
+    return a.sessionService.GetMonthlyAttendance(start, end, childIDs)
+}
+
+// GetRepeatedNoShows lists children with at least threshold no-shows in the last withinDays days
+func (a *App) GetRepeatedNoShows(withinDays, threshold int) ([]services.NoShowAlert, error) {
+    if err := a.authorize(services.PermReportView); err != nil {
+        return nil, err
+    }
+    if withinDays <= 0 {
+        withinDays = 90
+    }
+    childIDs, err := a.caseloadFilter()
+    if err != nil {
+        return nil, err
+    }
+    return a.sessionService.GetRepeatedNoShows(time.Now().AddDate(0, 0, -withinDays), threshold, childIDs)
+}
+
+// caseloadFilter returns the children a service query is limited to; nil when every child is visible
+func (a *App) caseloadFilter() ([]uint, error) {
+    childIDs, scoped, err := a.caseloadChildIDs()
+    if err != nil {
+        return nil, err
+    }
+    if !scoped {
+        return nil, nil
+    }
+    if childIDs == nil {
+        childIDs = []uint{}
+    }
+    return childIDs, nil
+}
+
+func (a *App) emitSessionStatusUpdate(session *model.Session, change string) {
+    runtime.EventsEmit(a.ctx, "session_updated", map[string]interface{}{
+        "session_id": session.ID,
+        "child_id":   session.ChildID,
+        "change":     change,
+        "status":     session.Status,
+        "timestamp":  time.Now(),
+    })
+}
+
+// ===== SESSION RECOVERY =====
+
+// GetOrphanedSessions lists sessions and activities left running when the app was last closed or crashed
+func (a *App) GetOrphanedSessions() ([]services.OrphanedSession, error) {
+    if err := a.authorize(services.PermSessionRun); err != nil {
+        return nil, err
+    }
+    childIDs, err := a.caseloadFilter()
+    if err != nil {
+        return nil, err
+    }
+    return a.sessionService.GetOrphanedSessions(a.startedAt, childIDs)
+}
+
+// ResumeOrphanedSession carries on with a session left open, as if the app had not been closed
+func (a *App) ResumeOrphanedSession(sessionID uint) (*model.Session, error) {
+    if err := a.authorizeRecord(services.PermSessionRun, "sessions", sessionID); err != nil {
+        return nil, err
+    }
+
+    session, err := a.sessionService.ResumeOrphanedSession(sessionID, a.startedAt)
+    if err != nil {
+        return nil, err
+    }
+    a.emitSessionRecovery(session, "resumed")
+    return session, nil
+}
+
+// CloseOrphanedSessionAtLastActivity ends a session left open at the last moment something was recorded in it
+func (a *App) CloseOrphanedSessionAtLastActivity(sessionID uint) (*model.Session, error) {
+    if err := a.authorizeRecord(services.PermSessionRun, "sessions", sessionID); err != nil {
+        return nil, err
+    }
+
+    session, err := a.sessionService.CloseOrphanedSession(sessionID, a.startedAt, nil, a.currentActor())
+    if err != nil {
+        return nil, err
+    }
+    a.emitSessionRecovery(session, "closed")
+    return session, nil
+}
+
+// CloseOrphanedSessionAt ends a session left open at a chosen time ("2006-01-02 15:04" or RFC 3339)
+func (a *App) CloseOrphanedSessionAt(sessionID uint, endTime string) (*model.Session, error) {
+    if err := a.authorizeRecord(services.PermSessionRun, "sessions", sessionID); err != nil {
+        return nil, err
+    }
+    at, err := services.ParseSessionDateTime(endTime)
+    if err != nil {
+        return nil, err
+    }
+
+    session, err := a.sessionService.CloseOrphanedSession(sessionID, a.startedAt, &at, a.currentActor())
+    if err != nil {
+        return nil, err
+    }
+    a.emitSessionRecovery(session, "closed")
+    return session, nil
+}
+
+func (a *App) emitSessionRecovery(session *model.Session, action string) {
+    runtime.EventsEmit(a.ctx, "session_updated", map[string]interface{}{
+        "session_id":       session.ID,
+        "child_id":         session.ChildID,
+        "change":           "recovered",
+        "action":           action,
+        "end_time":         session.EndTime,
+        "duration_minutes": session.DurationMinutes,
+        "timestamp":        time.Now(),
+    })
+}
+
+// ===== RETROACTIVE ENTRY =====
+
+// RecordPastSession records a completed session that took place without the app, with
+// its actual start and end ("2006-01-02 15:04" or RFC 3339)
+func (a *App) RecordPastSession(childID uint, startTime, endTime, summaryNotes string) (*model.Session, error) {
+    if err := a.authorizeChild(services.PermSessionRun, childID); err != nil {
+        return nil, err
+    }
+    start, err := services.ParseSessionDateTime(startTime)
+    if err != nil {
+        return nil, err
+    }
+    end, err := services.ParseSessionDateTime(endTime)
+    if err != nil {
+        return nil, err
+    }
+
+    session, err := a.sessionService.RecordPastSession(services.PastSessionInput{
+        ChildID:      childID,
+        StartTime:    start,
+        EndTime:      end,
+        SummaryNotes: summaryNotes,
+    }, a.actingTherapistID(), a.currentActor())
+    if err != nil {
+        return nil, err
+    }
+    runtime.EventsEmit(a.ctx, "session_updated", map[string]interface{}{
+        "session_id": session.ID,
+        "child_id":   session.ChildID,
+        "change":     "recorded_afterwards",
+        "timestamp":  time.Now(),
+    })
+    return session, nil
+}
+
+// AddPastActivity records an activity of a session with the times it started and ended
+func (a *App) AddPastActivity(sessionID, activityID uint, startTime, endTime, notes string) (*model.SessionActivity, error) {
+    if err := a.authorizeRecord(services.PermSessionRun, "sessions", sessionID); err != nil {
+        return nil, err
+    }
+    start, err := services.ParseSessionDateTime(startTime)
+    if err != nil {
+        return nil, err
+    }
+    end, err := services.ParseSessionDateTime(endTime)
+    if err != nil {
+        return nil, err
+    }
+
+    sessionActivity, err := a.sessionService.AddPastActivity(sessionID, activityID, start, end, notes)
+    if err != nil {
+        return nil, err
+    }
+    runtime.EventsEmit(a.ctx, "activity_updated", map[string]interface{}{
+        "session_id":          sessionID,
+        "activity_id":         sessionActivity.ActivityID,
+        "session_activity_id": sessionActivity.ID,
+        "action":              "recorded_afterwards",
+        "timestamp":           time.Now(),
+    })
+    return sessionActivity, nil
+}
+
+// AddPastNote adds a note to a session stamped with the time it was made; childID 0
+// means the whole session, otherwise one participant of a group session
+func (a *App) AddPastNote(sessionID, childID uint, noteText, category, at string) (*model.Note, error) {
+    if err := a.authorizeRecord(services.PermNoteWrite, "sessions", sessionID); err != nil {
+        return nil, err
+    }
+    var noteChildID *uint
+    if childID != 0 {
+        if err := a.authorizeChild(services.PermNoteWrite, childID); err != nil {
+            return nil, err
+        }
+        noteChildID = &childID
+    }
+    timestamp, err := services.ParseSessionDateTime(at)
+    if err != nil {
+        return nil, err
+    }
+
+    return a.noteService.CreatePastNote(sessionID, noteChildID, noteText, category, timestamp, a.currentActor(), a.actingTherapistID())
+}
+
+// GivePastReward records a reward given during a session, stamped with the time it was given
+func (a *App) GivePastReward(sessionID, childID uint, rewardType string, value int, notes, at string) (*model.Reward, error) {
+    if err := a.authorizeChild(services.PermSessionRun, childID); err != nil {
+        return nil, err
+    }
+    timestamp, err := services.ParseSessionDateTime(at)
+    if err != nil {
+        return nil, err
+    }
+
+    reward, err := a.rewardService.GivePastReward(childID, sessionID, rewardType, value, notes, timestamp, a.actingTherapistID())
+    if err != nil {
+        return nil, err
+    }
+    runtime.EventsEmit(a.ctx, "reward_updated", map[string]interface{}{
+        "action":     "added",
+        "reward_id":  reward.ID,
+        "child_id":   reward.ChildID,
+        "session_id": reward.SessionID,
+        "type":       reward.Type,
+        "value":      reward.Value,
+        "timestamp":  reward.Timestamp,
+    })
+    return reward, nil
+}
+
+// CorrectSessionTimes corrects when a session started and/or ended; an empty time is
+// left unchanged. The reason is kept in the session's amendment history.
+func (a *App) CorrectSessionTimes(sessionID uint, startTime, endTime, reason string) (*model.Session, error) {
+    if err := a.authorizeRecord(services.PermSessionRun, "sessions", sessionID); err != nil {
+        return nil, err
+    }
+    var start, end *time.Time
+    if strings.TrimSpace(startTime) != "" {
+        t, err := services.ParseSessionDateTime(startTime)
+        if err != nil {
+            return nil, err
+        }
+        start = &t
+    }
+    if strings.TrimSpace(endTime) != "" {
+        t, err := services.ParseSessionDateTime(endTime)
+        if err != nil {
+            return nil, err
+        }
+        end = &t
+    }
+
+    session, err := a.sessionService.CorrectSessionTimes(sessionID, start, end, reason, a.currentActor())
+    if err != nil {
+        return nil, err
+    }
+    runtime.EventsEmit(a.ctx, "session_updated", map[string]interface{}{
+        "session_id":       session.ID,
+        "child_id":         session.ChildID,
+        "change":           "times_corrected",
+        "start_time":       session.StartTime,
+        "end_time":         session.EndTime,
+        "duration_minutes": session.DurationMinutes,
+        "timestamp":        time.Now(),
+    })
+    return session, nil
+}
+
+// ===== WAITLIST =====
+
+// GetWaitlist returns referrals in queue order; see services.WaitlistFilter for the filters
+func (a *App) GetWaitlist(filter services.WaitlistFilter) ([]services.WaitlistPosition, error) {
+    if err := a.authorize(services.PermWaitlistManage); err != nil {
+        return nil, err
+    }
+    return a.waitlistService.GetWaitlist(filter)
+}
+
+// GetWaitlistEntry returns a referral with its availability and history
+func (a *App) GetWaitlistEntry(entryID uint) (*model.WaitlistEntry, error) {
+    if err := a.authorize(services.PermWaitlistManage); err != nil {
+        return nil, err
+    }
+    return a.waitlistService.GetEntry(entryID)
+}
+
+// AddWaitlistEntry records a new referral
+func (a *App) AddWaitlistEntry(input services.WaitlistEntryInput) (*model.WaitlistEntry, error) {
+    if err := a.authorize(services.PermWaitlistManage); err != nil {
+        return nil, err
+    }
+
+    entry, err := a.waitlistService.CreateEntry(input, a.currentActor())
+    if err != nil {
+        return nil, err
+    }
+    a.emitWaitlistUpdate(entry, "added")
+    return entry, nil
+}
+
+// UpdateWaitlistEntry edits a referral, including its priority and availability
+func (a *App) UpdateWaitlistEntry(entryID uint, input services.WaitlistEntryInput) (*model.WaitlistEntry, error) {
+    if err := a.authorize(services.PermWaitlistManage); err != nil {
+        return nil, err
+    }
+
+    entry, err := a.waitlistService.UpdateEntry(entryID, input, a.currentActor())
+    if err != nil {
+        return nil, err
+    }
+    a.emitWaitlistUpdate(entry, "updated")
+    return entry, nil
+}
+
+// SetWaitlistStatus marks a referral as offered a slot, withdrawn, or waiting again
+func (a *App) SetWaitlistStatus(entryID uint, status, note string) (*model.WaitlistEntry, error) {
+    if err := a.authorize(services.PermWaitlistManage); err != nil {
+        return nil, err
+    }
+
+    entry, err := a.waitlistService.SetStatus(entryID, status, note, a.currentActor())
+    if err != nil {
+        return nil, err
+    }
+    a.emitWaitlistUpdate(entry, "status")
+    return entry, nil
+}
+
+// DeleteWaitlistEntry permanently removes a referral that was never enrolled
+func (a *App) DeleteWaitlistEntry(entryID uint) error {
+    if err := a.authorize(services.PermWaitlistManage); err != nil {
+        return err
+    }
+
+    entry, err := a.waitlistService.GetEntry(entryID)
+    if err != nil {
+        return err
+    }
+    if err := a.waitlistService.DeleteEntry(entryID); err != nil {
+        return err
+    }
+    a.emitWaitlistUpdate(entry, "deleted")
+    return nil
+}
+
+// ConvertWaitlistEntry enrols a referral as a child and books the first appointment
+func (a *App) ConvertWaitlistEntry(entryID uint, input services.ConvertWaitlistInput) (*model.WaitlistEntry, error) {
+    if err := a.authorize(services.PermWaitlistManage); err != nil {
+        return nil, err
+    }
+    if err := a.authorize(services.PermChildCreate); err != nil {
+        return nil, err
+    }
+    if err := a.authorize(services.PermSessionSchedule); err != nil {
+        return nil, err
+    }
+
+    entry, err := a.waitlistService.ConvertEntry(entryID, input, a.currentActor())
+    if err != nil {
+        return nil, err
+    }
+    a.emitWaitlistUpdate(entry, "converted")
+    runtime.EventsEmit(a.ctx, "child_added", map[string]interface{}{
+        "child_id":  entry.ChildID,
+        "name":      entry.ChildName,
+        "gender":    entry.Gender,
+        "timestamp": time.Now(),
+    })
+    return entry, nil
+}
+
+// GetChildReferral returns the waitlist referral a child was enrolled from, with its history
+func (a *App) GetChildReferral(childID uint) (*model.WaitlistEntry, error) {
+    if err := a.authorizeChild(services.PermChildView, childID); err != nil {
+        return nil, err
+    }
+    return a.waitlistService.GetChildReferral(childID)
+}
+
+func (a *App) emitWaitlistUpdate(entry *model.WaitlistEntry, action string) {
+    runtime.EventsEmit(a.ctx, "waitlist_updated", map[string]interface{}{
+        "action":    action,
+        "entry_id":  entry.ID,
+        "status":    entry.Status,
+        "timestamp": time.Now(),
+    })
+}
+
+// ===== BILLING =====
+
+// GetRateCards lists session rates; archived rates are included on request
+func (a *App) GetRateCards(includeArchived bool) ([]model.RateCard, error) {
+    if err := a.authorize(services.PermBillingManage); err != nil {
+        return nil, err
+    }
+    return a.billingService.GetRateCards(includeArchived)
+}
+
+// CreateRateCard adds a rate for a therapy type and session length
+func (a *App) CreateRateCard(input services.RateCardInput) (*model.RateCard, error) {
+    if err := a.authorize(services.PermBillingManage); err != nil {
+        return nil, err
+    }
+    return a.billingService.CreateRateCard(input)
+}
+
+// UpdateRateCard changes a rate; invoices already issued are not affected
+func (a *App) UpdateRateCard(cardID uint, input services.RateCardInput) (*model.RateCard, error) {
+    if err := a.authorize(services.PermBillingManage); err != nil {
+        return nil, err
+    }
+    return a.billingService.UpdateRateCard(cardID, input)
+}
+
+// ArchiveRateCard stops charging a rate
+func (a *App) ArchiveRateCard(cardID uint) (*model.RateCard, error) {
+    if err := a.authorize(services.PermBillingManage); err != nil {
+        return nil, err
+    }
+    return a.billingService.ArchiveRateCard(cardID)
+}
+
+// GetBillingSettings returns the clinic details printed on invoices, the invoice number prefix and the payment term
+func (a *App) GetBillingSettings() (*services.BillingSettings, error) {
+    if err := a.authorize(services.PermBillingManage); err != nil {
+        return nil, err
+    }
+    return a.billingService.GetBillingSettings()
+}
+
+// SaveBillingSettings stores the clinic details, invoice number prefix and payment term
+func (a *App) SaveBillingSettings(input services.BillingSettings) (*services.BillingSettings, error) {
+    if err := a.authorize(services.PermBillingManage); err != nil {
+        return nil, err
+    }
+    return a.billingService.SaveBillingSettings(input)
+}
+
+// SetSessionTherapyType sets the therapy type a session is billed as
+func (a *App) SetSessionTherapyType(sessionID uint, therapyType string) (*model.Session, error) {
+    if err := a.authorizeRecord(services.PermBillingManage, "sessions", sessionID); err != nil {
+        return nil, err
+    }
+    return a.billingService.SetSessionTherapyType(sessionID, therapyType)
+}
+
+// PreviewInvoice shows what an invoice for a child's attended sessions between two dates (inclusive) would contain
+func (a *App) PreviewInvoice(childID uint, from, to string) (*services.InvoiceDraft, error) {
+    if err := a.authorizeChild(services.PermBillingManage, childID); err != nil {
+        return nil, err
+    }
+    start, end, err := services.ParseDateRange(from, to)
+    if err != nil {
+        return nil, err
+    }
+    return a.billingService.PreviewInvoice(childID, start, end)
+}
+
+// GenerateInvoice issues an invoice for a child's attended sessions between two dates (inclusive)
+func (a *App) GenerateInvoice(childID uint, from, to, notes string) (*model.Invoice, error) {
+    if err := a.authorizeChild(services.PermBillingManage, childID); err != nil {
+        return nil, err
+    }
+    start, end, err := services.ParseDateRange(from, to)
+    if err != nil {
+        return nil, err
+    }
+
+    invoice, err := a.billingService.GenerateInvoice(childID, start, end, notes, a.currentActor())
+    if err != nil {
+        return nil, err
+    }
+    a.emitInvoiceUpdate(invoice, "issued")
+    return invoice, nil
+}
+
+// GetInvoices lists invoices, newest first; see services.InvoiceFilter for the filters
+func (a *App) GetInvoices(filter services.InvoiceFilter) ([]model.Invoice, error) {
+    if err := a.authorize(services.PermBillingManage); err != nil {
+        return nil, err
+    }
+    childIDs, err := a.caseloadFilter()
+    if err != nil {
+        return nil, err
+    }
+    return a.billingService.GetInvoices(filter, childIDs)
+}
+
+// GetInvoice returns an invoice with its lines and payments
+func (a *App) GetInvoice(invoiceID uint) (*model.Invoice, error) {
+    if err := a.authorize(services.PermBillingManage); err != nil {
+        return nil, err
+    }
+    invoice, err := a.billingService.GetInvoice(invoiceID)
+    if err != nil {
+        return nil, err
+    }
+    if err := a.authorizeChild(services.PermBillingManage, invoice.ChildID); err != nil {
+        return nil, err
+    }
+    return invoice, nil
+}
+
+// VoidInvoice cancels an invoice without payments so its sessions can be billed again
+func (a *App) VoidInvoice(invoiceID uint, reason string) (*model.Invoice, error) {
+    if _, err := a.GetInvoice(invoiceID); err != nil {
+        return nil, err
+    }
+    invoice, err := a.billingService.VoidInvoice(invoiceID, reason)
+    if err != nil {
+        return nil, err
+    }
+    a.emitInvoiceUpdate(invoice, "voided")
+    return invoice, nil
+}
+
+// RecordPayment records money received against an invoice
+func (a *App) RecordPayment(invoiceID uint, input services.PaymentInput) (*model.Invoice, error) {
+    if _, err := a.GetInvoice(invoiceID); err != nil {
+        return nil, err
+    }
+    invoice, err := a.billingService.RecordPayment(invoiceID, input, a.currentActor())
+    if err != nil {
+        return nil, err
+    }
+    a.emitInvoiceUpdate(invoice, "payment_recorded")
+    return invoice, nil
+}
+
+// DeletePayment removes a payment recorded by mistake
+func (a *App) DeletePayment(paymentID uint) (*model.Invoice, error) {
+    if err := a.authorize(services.PermBillingManage); err != nil {
+        return nil, err
+    }
+    payment, err := a.billingService.GetPayment(paymentID)
+    if err != nil {
+        return nil, err
+    }
+    if _, err := a.GetInvoice(payment.InvoiceID); err != nil {
+        return nil, err
+    }
+    invoice, err := a.billingService.DeletePayment(paymentID)
+    if err != nil {
+        return nil, err
+    }
+    a.emitInvoiceUpdate(invoice, "payment_deleted")
+    return invoice, nil
+}
+
+// GetOutstandingBalances reports what each family still owes as of a date (default today), by how long it is overdue
+func (a *App) GetOutstandingBalances(asOf string) ([]services.OutstandingBalance, error) {
+    if err := a.authorize(services.PermBillingManage); err != nil {
+        return nil, err
+    }
+    date := time.Now()
+    parsed, err := services.ParseCalendarDate(asOf)
+    if err != nil {
+        return nil, err
+    }
+    if parsed != nil {
+        date = *parsed
+    }
+    childIDs, err := a.caseloadFilter()
+    if err != nil {
+        return nil, err
+    }
+    return a.billingService.GetOutstandingBalances(date, childIDs)
+}
+
+// ExportInvoicePDF saves an invoice rendered as PDF from GetInvoice and GetBillingSettings
+// and returns where it was saved
+func (a *App) ExportInvoicePDF(invoiceID uint, pdfData []byte) (string, error) {
+    invoice, err := a.GetInvoice(invoiceID)
+    if err != nil {
+        return "", err
+    }
+    return a.savePDFFile(pdfData, invoice.Number+".pdf")
+}
+
+func (a *App) emitInvoiceUpdate(invoice *model.Invoice, action string) {
+    runtime.EventsEmit(a.ctx, "invoice_updated", map[string]interface{}{
+        "action":     action,
+        "invoice_id": invoice.ID,
+        "child_id":   invoice.ChildID,
+        "status":     invoice.Status,
+        "timestamp":  time.Now(),
+    })
+}
+
+// ===== SESSION PACKAGES =====
+
+// CreateSessionPackage records a package of prepaid sessions bought for a child
+func (a *App) CreateSessionPackage(input services.SessionPackageInput) (*services.PackageBalance, error) {
+    if err := a.authorizeChild(services.PermBillingManage, input.ChildID); err != nil {
+        return nil, err
+    }
+    pkg, err := a.packageService.CreatePackage(input)
+    if err != nil {
+        return nil, err
+    }
+    a.emitPackageUpdate(pkg, "created")
+    return pkg, nil
+}
+
+// UpdateSessionPackage corrects a package or extends its sessions or expiry date
+func (a *App) UpdateSessionPackage(packageID uint, input services.SessionPackageInput) (*services.PackageBalance, error) {
+    if err := a.authorizePackage(packageID); err != nil {
+        return nil, err
+    }
+    pkg, err := a.packageService.UpdatePackage(packageID, input)
+    if err != nil {
+        return nil, err
+    }
+    a.emitPackageUpdate(pkg, "updated")
+    return pkg, nil
+}
+
+// CancelSessionPackage stops a package from being used, e.g. after a refund
+func (a *App) CancelSessionPackage(packageID uint, reason string) (*services.PackageBalance, error) {
+    if err := a.authorizePackage(packageID); err != nil {
+        return nil, err
+    }
+    pkg, err := a.packageService.CancelPackage(packageID, reason)
+    if err != nil {
+        return nil, err
+    }
+    a.emitPackageUpdate(pkg, "cancelled")
+    return pkg, nil
+}
+
+// GetChildPackageHistory lists a child's packages with every session taken from them
+func (a *App) GetChildPackageHistory(childID uint) ([]services.PackageBalance, error) {
+    if err := a.authorizeChild(services.PermSessionView, childID); err != nil {
+        return nil, err
+    }
+    return a.packageService.GetChildPackageHistory(childID)
+}
+
+// GetPackageReport lists packages with their remaining sessions, soonest expiry first;
+// statuses ("active", "exhausted", "expired", "cancelled") filter when given
+func (a *App) GetPackageReport(statuses []string) ([]services.PackageBalance, error) {
+    if err := a.authorize(services.PermBillingManage); err != nil {
+        return nil, err
+    }
+    childIDs, err := a.caseloadFilter()
+    if err != nil {
+        return nil, err
+    }
+    return a.packageService.GetPackageReport(statuses, childIDs)
+}
+
+// authorizePackage checks that the package's child may be billed
+func (a *App) authorizePackage(packageID uint) error {
+    if err := a.authorize(services.PermBillingManage); err != nil {
+        return err
+    }
+    pkg, err := a.packageService.GetPackage(packageID)
+    if err != nil {
+        return err
+    }
+    return a.authorizeChild(services.PermBillingManage, pkg.ChildID)
+}
+
+func (a *App) emitPackageUpdate(pkg *services.PackageBalance, action string) {
+    runtime.EventsEmit(a.ctx, "package_updated", map[string]interface{}{
+        "action":     action,
+        "package_id": pkg.ID,
+        "child_id":   pkg.ChildID,
+        "remaining":  pkg.Remaining,
+        "status":     pkg.Status,
+        "timestamp":  time.Now(),
+    })
+}
+
+// ===== TRASH BIN =====
+
+// GetTrash lists soft-deleted children, notes, rewards, activities and attachments; entityType filters to one kind
+func (a *App) GetTrash(entityType string) ([]services.TrashItem, error) {
+    if err := a.authorize(services.PermTrashManage); err != nil {
+        return nil, err
+    }
+
+    return a.trashService.ListTrash(entityType)
+}
+
+// RestoreFromTrash restores a soft-deleted record; restoring a child also restores its sessions
+func (a *App) RestoreFromTrash(entityType string, id uint) error {
+    if err := a.authorize(services.PermTrashManage); err != nil {
+        return err
+    }
+
+    if err := a.trashService.RestoreFromTrash(entityType, id); err != nil {
+        return err
+    }
+
+    runtime.EventsEmit(a.ctx, "trash_updated", map[string]interface{}{
+        "action":      "restored",
+        "entity_type": entityType,
+        "id":          id,
+        "timestamp":   time.Now(),
+    })
+
+    return nil
+}
+
+// PurgeExpiredTrash permanently deletes records kept in the trash longer than the retention period
+func (a *App) PurgeExpiredTrash() (*services.TrashPurgeResult, error) {
+    if err := a.authorize(services.PermTrashManage); err != nil {
+        return nil, err
+    }
+
+    result, err := a.trashService.PurgeExpiredTrash()
+    if err != nil {
+        return nil, err
+    }
+    a.cleanupAttachmentFiles()
+
+    runtime.EventsEmit(a.ctx, "trash_updated", map[string]interface{}{
+        "action":    "purged",
+        "deleted":   result.Deleted,
+        "timestamp": time.Now(),
+    })
+
+    return result, nil
+}
+
+// GetTrashRetentionDays returns how many days deleted records stay restorable
+func (a *App) GetTrashRetentionDays() (int, error) {
+    if err := a.authorize(services.PermTrashManage); err != nil {
+        return 0, err
+    }
+
+    return a.trashService.GetRetentionDays()
+}
+
+// SetTrashRetentionDays changes how many days deleted records stay restorable
+func (a *App) SetTrashRetentionDays(days int) error {
+    if err := a.authorize(services.PermTrashManage); err != nil {
+        return err
+    }
+
+    return a.trashService.SetRetentionDays(days)
+}
+
+// ===== SESSION MANAGEMENT METHODS =====
+
+// StartSession begins a new therapy session for a child
+func (a *App) StartSession(childID uint) (*model.Session, error) {
+    if err := a.authorizeChild(services.PermSessionRun, childID); err != nil {
+        return nil, err
+    }
+
+    return a.startSession(childID, nil)
+}
+
+// StartPlannedSession begins a session for the plan's child and attaches the plan
+func (a *App) StartPlannedSession(planID uint) (*model.Session, error) {
+    if err := a.authorizeRecord(services.PermSessionRun, "session_plans", planID); err != nil {
+        return nil, err
+    }
+    plan, err := a.sessionPlanService.GetPlan(planID)
+    if err != nil {
+        return nil, err
+    }
+
+    return a.startSession(plan.ChildID, &plan.ID)
+}
+
+func (a *App) startSession(childID uint, planID *uint) (*model.Session, error) {
+    fmt.Printf("Starting session for child ID: %d\n", childID)
+    
+    session, err := a.sessionService.StartSession(childID, a.actingTherapistID(), planID)
+    if err != nil {
+        fmt.Printf("Error starting session: %v\n", err)
+        return nil, err
+    }
+    
+    // Emit session started event
+    runtime.EventsEmit(a.ctx, "session_started", map[string]interface{}{
+        "session_id": session.ID,
+        "child_id":   session.ChildID,
+        "start_time": session.StartTime,
+        "plan_id":    planID,
+        "warnings":   session.Warnings,
+    })
+    // Also emit a generic session update for consumers listening to aggregate updates
+    runtime.EventsEmit(a.ctx, "session_updated", map[string]interface{}{
+        "session_id": session.ID,
+        "child_id":   session.ChildID,
+        "change":     "started",
+        "timestamp":  time.Now(),
+    })
+    
+    fmt.Printf("Session started successfully. ID: %d\n", session.ID)
+    return session, nil
+}
+
+// EndSession concludes an active session with summary notes
+func (a *App) EndSession(sessionID uint, summaryNotes string) (*model.Session, error) {
+    if err := a.authorizeRecord(services.PermSessionRun, "sessions", sessionID); err != nil {
+        return nil, err
+    }
+
+    session, err := a.sessionService.EndSession(sessionID, summaryNotes, a.currentActor())
+    if err != nil {
+        return nil, err
+    }
+    
+    // Emit session ended event
+    runtime.EventsEmit(a.ctx, "session_ended", map[string]interface{}{
+        "session_id":    session.ID,
+        "child_id":      session.ChildID,
+        "end_time":      session.EndTime,
+        "duration":      session.DurationMinutes,
+    })
+    // Also emit a generic session update for active listeners
+    runtime.EventsEmit(a.ctx, "session_updated", map[string]interface{}{
+        "session_id": session.ID,
+        "child_id":   session.ChildID,
+        "change":     "ended",
+        "timestamp":  time.Now(),
+    })
+    
+    return session, nil
+}
+
+// GetActiveSession retrieves the currently active session for a child
+func (a *App) GetActiveSession(childID uint) (*model.Session, error) {
+	if err := a.authorizeChild(services.PermSessionView, childID); err != nil {
+		return nil, err
+	}
+
+	session, err := a.sessionService.GetActiveSession(childID)
+	if err != nil || session == nil {
+		return session, err
+	}
+	if !a.can(services.PermNoteRead) {
+		services.RedactSession(session)
+	}
+	return session, nil
+}
+
+// GetSessionsByChild retrieves all sessions for a specific child
+func (a *App) GetSessionsByChild(childID uint) ([]model.Session, error) {
+	if err := a.authorizeChild(services.PermSessionView, childID); err != nil {
+		return nil, err
+	}
+
+	sessions, err := a.sessionService.GetSessionsByChild(childID)
+	if err != nil {
+		return nil, err
+	}
+	if !a.can(services.PermNoteRead) {
+		for i := range sessions {
+			services.RedactSession(&sessions[i])
+		}
+	}
+	return sessions, nil
+}
+
+// GetSessionByID retrieves detailed session information by ID
+func (a *App) GetSessionByID(sessionID uint) (*model.Session, error) {
+	if err := a.authorizeRecord(services.PermSessionView, "sessions", sessionID); err != nil {
+		return nil, err
+	}
+
+	session, err := a.sessionService.GetSessionByID(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.hideOtherChildrenRecords(session); err != nil {
+		return nil, err
+	}
+	a.auditRead(services.AuditEntitySession, session.ID)
+	if !a.can(services.PermNoteRead) {
+		services.RedactSession(session)
+	}
+	return session, nil
+}
+
+// FinalizeSession signs an ended session; afterwards it only accepts addenda
+func (a *App) FinalizeSession(sessionID uint) (*model.Session, error) {
+    if err := a.authorizeRecord(services.PermSessionFinalize, "sessions", sessionID); err != nil {
+        return nil, err
+    }
+
+    session, err := a.sessionService.FinalizeSession(sessionID, a.currentActor())
+    if err != nil {
+        return nil, err
+    }
+
+    runtime.EventsEmit(a.ctx, "session_updated", map[string]interface{}{
+        "session_id":   session.ID,
+        "child_id":     session.ChildID,
+        "change":       "finalized",
+        "finalized_by": session.FinalizedBy,
+        "timestamp":    time.Now(),
+    })
+
+    return session, nil
+}
+
+// AddSessionAddendum appends an addendum to a finalised session
+func (a *App) AddSessionAddendum(sessionID uint, text string) (*model.SessionAddendum, error) {
+    if err := a.authorizeRecord(services.PermNoteWrite, "sessions", sessionID); err != nil {
+        return nil, err
+    }
+
+    addendum, err := a.sessionService.AddSessionAddendum(sessionID, text, a.currentActor())
+    if err != nil {
+        return nil, err
+    }
+
+    runtime.EventsEmit(a.ctx, "session_updated", map[string]interface{}{
+        "session_id":  sessionID,
+        "change":      "addendum_added",
+        "addendum_id": addendum.ID,
+        "timestamp":   time.Now(),
+    })
+
+    return addendum, nil
+}
+
+// GetSessionAddenda lists the addenda of a session
+func (a *App) GetSessionAddenda(sessionID uint) ([]model.SessionAddendum, error) {
+    if err := a.authorizeRecord(services.PermNoteRead, "sessions", sessionID); err != nil {
+        return nil, err
+    }
+
+    return a.sessionService.GetSessionAddenda(sessionID)
+}
+
+// ===== SUPERVISOR REVIEW =====
+
+// GetReviewQueue lists ended sessions waiting for a supervisor's co-signature
+func (a *App) GetReviewQueue(includeChangesRequested bool) ([]model.Session, error) {
+    if err := a.authorize(services.PermSessionCosign); err != nil {
+        return nil, err
+    }
+    return a.sessionService.GetReviewQueue(includeChangesRequested)
+}
+
+// GetSessionReviewComments lists the supervisor review thread of a session
+func (a *App) GetSessionReviewComments(sessionID uint) ([]model.SessionReviewComment, error) {
+    if err := a.authorizeRecord(services.PermNoteRead, "sessions", sessionID); err != nil {
+        return nil, err
+    }
+    return a.sessionService.GetSessionReviewComments(sessionID)
+}
+
+// AddReviewComment adds a supervisor comment to a session under review; noteID targets a single note
+func (a *App) AddReviewComment(sessionID uint, noteID *uint, comment string) (*model.SessionReviewComment, error) {
+    if err := a.authorizeReview(sessionID); err != nil {
+        return nil, err
+    }
+    entry, err := a.sessionService.AddReviewComment(sessionID, noteID, comment, a.currentActor())
+    if err != nil {
+        return nil, err
+    }
+    a.emitReviewUpdate(sessionID, "commented")
+    return entry, nil
+}
 
-// GetAllChildren retrieves all children for the therapist
-func (a *App) GetAllChildren() ([]model.Child, error) {
-	return a.childService.GetAllChildren()
+// RequestSessionChanges sends a session back to its therapist with the changes needed
+func (a *App) RequestSessionChanges(sessionID uint, comment string) (*model.Session, error) {
+    if err := a.authorizeReview(sessionID); err != nil {
+        return nil, err
+    }
+    session, err := a.sessionService.RequestSessionChanges(sessionID, comment, a.currentActor())
+    if err != nil {
+        return nil, err
+    }
+    a.emitReviewUpdate(sessionID, "changes_requested")
+    return session, nil
 }
 
-// CreateChild creates a new child record
-func (a *App) CreateChild(name, gender, parentGuardianName, contactInfo, initialAssessment, dateOfBirth string) (*model.Child, error) {
-    var dobPtr *string
-    if dateOfBirth != "" {
-        dobPtr = &dateOfBirth
+// SubmitPastSessionForReview sends a session recorded afterwards to the review queue once
+// its activities, notes and rewards are filled in
+func (a *App) SubmitPastSessionForReview(sessionID uint, comment string) (*model.Session, error) {
+    if err := a.authorizeRecord(services.PermNoteWrite, "sessions", sessionID); err != nil {
+        return nil, err
     }
-    child, err := a.childService.CreateChild(name, gender, parentGuardianName, contactInfo, initialAssessment, dobPtr)
+    session, err := a.sessionService.SubmitPastSessionForReview(sessionID, comment, a.currentActor())
     if err != nil {
         return nil, err
     }
+    a.emitReviewUpdate(sessionID, "submitted")
+    return session, nil
+}
 
-    // Emit child added event for dashboard/frontend updates
-    runtime.EventsEmit(a.ctx, "child_added", map[string]interface{}{
-        "child_id": child.ID,
-        "name":     child.Name,
-        "gender":   child.Gender,
-        "timestamp": time.Now(),
-    })
+// ResubmitSessionForReview returns a session to the review queue once the requested changes are made
+func (a *App) ResubmitSessionForReview(sessionID uint, comment string) (*model.Session, error) {
+    if err := a.authorizeRecord(services.PermNoteWrite, "sessions", sessionID); err != nil {
+        return nil, err
+    }
+    session, err := a.sessionService.ResubmitSessionForReview(sessionID, comment, a.currentActor())
+    if err != nil {
+        return nil, err
+    }
+    a.emitReviewUpdate(sessionID, "resubmitted")
+    return session, nil
+}
 
-    return child, nil
+// CosignSession records the supervisor's co-signature; the session can then be finalised
+func (a *App) CosignSession(sessionID uint, comment string) (*model.Session, error) {
+    if err := a.authorizeReview(sessionID); err != nil {
+        return nil, err
+    }
+    session, err := a.sessionService.CosignSession(sessionID, comment, a.currentActor())
+    if err != nil {
+        return nil, err
+    }
+    a.emitReviewUpdate(sessionID, "cosigned")
+    return session, nil
 }
 
-// GetChildByID retrieves a specific child by ID
-func (a *App) GetChildByID(id uint) (*model.Child, error) {
-	return a.childService.GetChildByID(id)
+// authorizeReview checks the co-sign permission; nobody reviews a session they ran themselves
+func (a *App) authorizeReview(sessionID uint) error {
+    if err := a.authorizeRecord(services.PermSessionCosign, "sessions", sessionID); err != nil {
+        return err
+    }
+    if a.currentTherapist == nil {
+        return nil
+    }
+    var session model.Session
+    if err := a.database.Select("id", "therapist_id").First(&session, sessionID).Error; err != nil {
+        return fmt.Errorf("sesi tidak ditemukan: %w", err)
+    }
+    if session.TherapistID != nil && *session.TherapistID == a.currentTherapist.ID {
+        return errors.New("sesi sendiri tidak dapat direview sendiri")
+    }
+    return nil
 }
 
-// UpdateChild updates a child's information
-func (a *App) UpdateChild(id uint, name, gender, parentGuardianName, contactInfo, initialAssessment string) (*model.Child, error) {
-	return a.childService.UpdateChild(id, name, gender, parentGuardianName, contactInfo, initialAssessment)
+func (a *App) emitReviewUpdate(sessionID uint, change string) {
+    runtime.EventsEmit(a.ctx, "session_updated", map[string]interface{}{
+        "session_id": sessionID,
+        "change":     "review_" + change,
+        "timestamp":  time.Now(),
+    })
 }
 
-// DeleteChild removes a child record (soft delete)
-func (a *App) DeleteChild(id uint) error {
-	return a.childService.DeleteChild(id)
+// ===== STRUCTURED SESSION NOTES =====
+
+// GetNoteFormats returns the selectable session note formats (free text, SOAP, DAP)
+func (a *App) GetNoteFormats() []services.NoteFormatDefinition {
+    return services.GetNoteFormats()
 }
 
-// ===== SESSION MANAGEMENT METHODS =====
+// SetSessionNoteFormat selects the note format of a session and prefills its sections
+func (a *App) SetSessionNoteFormat(sessionID uint, format string) (*model.Session, error) {
+    if err := a.authorizeRecord(services.PermNoteWrite, "sessions", sessionID); err != nil {
+        return nil, err
+    }
 
-// StartSession begins a new therapy session for a child
-func (a *App) StartSession(childID uint) (*model.Session, error) {
-    fmt.Printf("Starting session for child ID: %d\n", childID)
-    
-    session, err := a.sessionService.StartSession(childID)
+    session, err := a.sessionService.SetSessionNoteFormat(sessionID, format, a.currentActor())
     if err != nil {
-        fmt.Printf("Error starting session: %v\n", err)
         return nil, err
     }
-    
-    // Emit session started event
-    runtime.EventsEmit(a.ctx, "session_started", map[string]interface{}{
-        "session_id": session.ID,
-        "child_id":   session.ChildID,
-        "start_time": session.StartTime,
-    })
-    // Also emit a generic session update for consumers listening to aggregate updates
+
     runtime.EventsEmit(a.ctx, "session_updated", map[string]interface{}{
         "session_id": session.ID,
-        "child_id":   session.ChildID,
-        "change":     "started",
+        "change":     "note_format_changed",
+        "format":     session.NoteFormat,
         "timestamp":  time.Now(),
     })
-    
-    fmt.Printf("Session started successfully. ID: %d\n", session.ID)
+
     return session, nil
 }
 
-// EndSession concludes an active session with summary notes
-func (a *App) EndSession(sessionID uint, summaryNotes string) (*model.Session, error) {
-    session, err := a.sessionService.EndSession(sessionID, summaryNotes)
+// GetSessionNoteSections retrieves the structured note sections of a session
+func (a *App) GetSessionNoteSections(sessionID uint) ([]model.SessionNoteSection, error) {
+    if err := a.authorizeRecord(services.PermNoteRead, "sessions", sessionID); err != nil {
+        return nil, err
+    }
+
+    return a.sessionService.GetSessionNoteSections(sessionID)
+}
+
+// UpdateSessionNoteSection updates the content of one structured note section; reason explains the amendment
+func (a *App) UpdateSessionNoteSection(sessionID uint, sectionKey, content, reason string) (*model.SessionNoteSection, error) {
+    if err := a.authorizeRecord(services.PermNoteWrite, "sessions", sessionID); err != nil {
+        return nil, err
+    }
+
+    section, err := a.sessionService.UpdateSessionNoteSection(sessionID, sectionKey, content, a.currentActor(), reason)
     if err != nil {
         return nil, err
     }
-    
-    // Emit session ended event
-    runtime.EventsEmit(a.ctx, "session_ended", map[string]interface{}{
-        "session_id":    session.ID,
-        "child_id":      session.ChildID,
-        "end_time":      session.EndTime,
-        "duration":      session.DurationMinutes,
-    })
-    // Also emit a generic session update for active listeners
+
     runtime.EventsEmit(a.ctx, "session_updated", map[string]interface{}{
-        "session_id": session.ID,
-        "child_id":   session.ChildID,
-        "change":     "ended",
+        "session_id": sessionID,
+        "change":     "note_section_updated",
+        "section":    sectionKey,
         "timestamp":  time.Now(),
     })
-    
-    return session, nil
-}
 
-// GetActiveSession retrieves the currently active session for a child
-func (a *App) GetActiveSession(childID uint) (*model.Session, error) {
-	return a.sessionService.GetActiveSession(childID)
+    return section, nil
 }
 
-// GetSessionsByChild retrieves all sessions for a specific child
-func (a *App) GetSessionsByChild(childID uint) ([]model.Session, error) {
-	return a.sessionService.GetSessionsByChild(childID)
-}
+// PrefillSessionNoteSections refills structured note sections from the captured notes, activities and rewards
+func (a *App) PrefillSessionNoteSections(sessionID uint, overwrite bool) ([]model.SessionNoteSection, error) {
+    if err := a.authorizeRecord(services.PermNoteWrite, "sessions", sessionID); err != nil {
+        return nil, err
+    }
 
-// GetSessionByID retrieves detailed session information by ID
-func (a *App) GetSessionByID(sessionID uint) (*model.Session, error) {
-	return a.sessionService.GetSessionByID(sessionID)
+    sections, err := a.sessionService.PrefillSessionNoteSections(sessionID, overwrite, a.currentActor())
+    if err != nil {
+        return nil, err
+    }
+
+    runtime.EventsEmit(a.ctx, "session_updated", map[string]interface{}{
+        "session_id": sessionID,
+        "change":     "note_sections_prefilled",
+        "timestamp":  time.Now(),
+    })
+
+    return sections, nil
 }
 
 // ===== ACTIVITY MANAGEMENT METHODS =====
 
 // GetAllActivities retrieves all available therapy activities
 func (a *App) GetAllActivities() ([]model.Activity, error) {
+	if err := a.authorize(services.PermCatalogView); err != nil {
+		return nil, err
+	}
+
 	return a.activityService.GetAllActivities()
 }
 
 // UpdateActivity updates an existing activity
 func (a *App) UpdateActivity(id uint, name, description string, defaultDurationMinutes int, category, objectives string) (*model.Activity, error) {
+    if err := a.authorize(services.PermCatalogManage); err != nil {
+        return nil, err
+    }
+
     return a.activityService.UpdateActivity(id, name, description, defaultDurationMinutes, category, objectives)
 }
 
 // DeleteActivity deletes an activity
 func (a *App) DeleteActivity(id uint) error {
+    if err := a.authorize(services.PermCatalogManage); err != nil {
+        return err
+    }
+
     return a.activityService.DeleteActivity(id)
 }
 
 // CreateActivity creates a new therapy activity
 func (a *App) CreateActivity(name, description string, defaultDurationMinutes int, category, objectives string) (*model.Activity, error) {
+	if err := a.authorize(services.PermCatalogManage); err != nil {
+		return nil, err
+	}
+
 	return a.activityService.CreateActivity(name, description, defaultDurationMinutes, category, objectives)
 }
 
 // GetActivityByID retrieves a specific activity by ID
 func (a *App) GetActivityByID(id uint) (*model.Activity, error) {
+	if err := a.authorize(services.PermCatalogView); err != nil {
+		return nil, err
+	}
+
 	return a.activityService.GetActivityByID(id)
 }
 
@@ -205,6 +2879,27 @@ func (a *App) GetActivityByID(id uint) (*model.Activity, error) {
 
 // StartActivityInSession begins an activity within a session
 func (a *App) StartActivityInSession(sessionID, activityID uint, notes string) (*model.SessionActivity, error) {
+	if err := a.authorizeRecord(services.PermSessionRun, "sessions", sessionID); err != nil {
+		return nil, err
+	}
+
+	if err := a.sessionService.EnsureSessionWritable(sessionID); err != nil {
+		return nil, err
+	}
+
+	var session model.Session
+	if err := a.database.First(&session, sessionID).Error; err != nil {
+		return nil, fmt.Errorf("sesi tidak ditemukan: %w", err)
+	}
+	var activity model.Activity
+	if err := a.database.First(&activity, activityID).Error; err != nil {
+		return nil, fmt.Errorf("aktivitas tidak ditemukan: %w", err)
+	}
+	warnings, err := a.clinicalProfileService.GetActivityWarnings(session.ChildID, []model.Activity{activity})
+	if err != nil {
+		return nil, err
+	}
+
 	sessionActivity := &model.SessionActivity{
 		SessionID:  sessionID,
 		ActivityID: activityID,
@@ -214,7 +2909,14 @@ func (a *App) StartActivityInSession(sessionID, activityID uint, notes string) (
 	now := time.Now()
 	sessionActivity.StartTime = &now
 
-	if err := a.database.Create(sessionActivity).Error; err != nil {
+	err = a.auditService.Transaction(a.database, func(tx *gorm.DB, trail *services.AuditTrail) error {
+		if err := tx.Create(sessionActivity).Error; err != nil {
+			return err
+		}
+		trail.Add(services.AuditActionCreate, services.AuditEntitySessionActivity, sessionActivity.ID, nil, sessionActivity)
+		return services.LinkPlannedActivity(tx, trail, sessionActivity)
+	})
+	if err != nil {
 		return nil, fmt.Errorf("gagal memulai aktivitas dalam sesi: %w", err)
 	}
 
@@ -222,6 +2924,7 @@ func (a *App) StartActivityInSession(sessionID, activityID uint, notes string) (
 	if err := a.database.Preload("Session").Preload("Activity").First(sessionActivity, sessionActivity.ID).Error; err != nil {
 		return nil, fmt.Errorf("gagal memuat data aktivitas sesi: %w", err)
 	}
+	sessionActivity.Warnings = warnings
 
     // Emit activity + session updates for real-time frontend listeners
     runtime.EventsEmit(a.ctx, "activity_updated", map[string]interface{}{
@@ -229,6 +2932,7 @@ func (a *App) StartActivityInSession(sessionID, activityID uint, notes string) (
         "activity_id":          sessionActivity.ActivityID,
         "session_activity_id":  sessionActivity.ID,
         "action":               "started",
+        "warnings":             warnings,
         "timestamp":            time.Now(),
     })
     runtime.EventsEmit(a.ctx, "session_updated", map[string]interface{}{
@@ -242,20 +2946,36 @@ func (a *App) StartActivityInSession(sessionID, activityID uint, notes string) (
 
 // EndActivityInSession concludes an activity within a session
 func (a *App) EndActivityInSession(sessionActivityID uint, notes string) (*model.SessionActivity, error) {
+	if err := a.authorizeRecord(services.PermSessionRun, "session_activities", sessionActivityID); err != nil {
+		return nil, err
+	}
+
 	var sessionActivity model.SessionActivity
 	if err := a.database.First(&sessionActivity, sessionActivityID).Error; err != nil {
 		return nil, fmt.Errorf("aktivitas sesi tidak ditemukan: %w", err)
 	}
 
+	if err := a.sessionService.EnsureSessionWritable(sessionActivity.SessionID); err != nil {
+		return nil, err
+	}
+
 	if sessionActivity.EndTime != nil {
 		return nil, fmt.Errorf("aktivitas sudah berakhir")
 	}
 
+	before := sessionActivity
 	now := time.Now()
 	sessionActivity.EndTime = &now
 	sessionActivity.Notes = notes
 
-	if err := a.database.Save(&sessionActivity).Error; err != nil {
+	err := a.auditService.Transaction(a.database, func(tx *gorm.DB, trail *services.AuditTrail) error {
+		if err := tx.Save(&sessionActivity).Error; err != nil {
+			return err
+		}
+		trail.Add(services.AuditActionUpdate, services.AuditEntitySessionActivity, sessionActivity.ID, before, sessionActivity)
+		return nil
+	})
+	if err != nil {
 		return nil, fmt.Errorf("gagal mengakhiri aktivitas: %w", err)
 	}
 
@@ -278,6 +2998,10 @@ func (a *App) EndActivityInSession(sessionActivityID uint, notes string) (*model
 
 // GetSessionActivities retrieves all activities for a specific session
 func (a *App) GetSessionActivities(sessionID uint) ([]model.SessionActivity, error) {
+	if err := a.authorizeRecord(services.PermNoteRead, "sessions", sessionID); err != nil {
+		return nil, err
+	}
+
 	var sessionActivities []model.SessionActivity
 	if err := a.database.Preload("Activity").Where("session_id = ?", sessionID).Find(&sessionActivities).Error; err != nil {
 		return nil, fmt.Errorf("gagal mengambil aktivitas sesi: %w", err)
@@ -287,14 +3011,30 @@ func (a *App) GetSessionActivities(sessionID uint) ([]model.SessionActivity, err
 
 // UpdateActivityInSession updates notes for an ongoing activity
 func (a *App) UpdateActivityInSession(sessionActivityID uint, notes string) (*model.SessionActivity, error) {
+    if err := a.authorizeRecord(services.PermSessionRun, "session_activities", sessionActivityID); err != nil {
+        return nil, err
+    }
+
     var sessionActivity model.SessionActivity
     if err := a.database.First(&sessionActivity, sessionActivityID).Error; err != nil {
         return nil, fmt.Errorf("aktivitas sesi tidak ditemukan: %w", err)
     }
 
+    if err := a.sessionService.EnsureSessionWritable(sessionActivity.SessionID); err != nil {
+        return nil, err
+    }
+
+    before := sessionActivity
     sessionActivity.Notes = notes
 
-    if err := a.database.Save(&sessionActivity).Error; err != nil {
+    err := a.auditService.Transaction(a.database, func(tx *gorm.DB, trail *services.AuditTrail) error {
+        if err := tx.Save(&sessionActivity).Error; err != nil {
+            return err
+        }
+        trail.Add(services.AuditActionUpdate, services.AuditEntitySessionActivity, sessionActivity.ID, before, sessionActivity)
+        return nil
+    })
+    if err != nil {
         return nil, fmt.Errorf("gagal memperbarui catatan aktivitas: %w", err)
     }
 
@@ -322,6 +3062,10 @@ func (a *App) UpdateActivityInSession(sessionActivityID uint, notes string) (*mo
 
 // GetActiveActivitiesInSession retrieves currently running activities in a session
 func (a *App) GetActiveActivitiesInSession(sessionID uint) ([]model.SessionActivity, error) {
+    if err := a.authorizeRecord(services.PermNoteRead, "sessions", sessionID); err != nil {
+        return nil, err
+    }
+
     var sessionActivities []model.SessionActivity
     if err := a.database.Preload("Activity").Where("session_id = ? AND end_time IS NULL", sessionID).Find(&sessionActivities).Error; err != nil {
         return nil, fmt.Errorf("gagal mengambil aktivitas aktif: %w", err)
@@ -333,67 +3077,202 @@ func (a *App) GetActiveActivitiesInSession(sessionID uint) ([]model.SessionActiv
 
 // AddNote adds a quick note to a session
 func (a *App) AddNote(sessionID uint, noteText, category string) (*model.Note, error) {
-	if noteText == "" {
-		return nil, fmt.Errorf("teks catatan harus diisi")
-	}
-
-	note := &model.Note{
-		SessionID: sessionID,
-		NoteText:  noteText,
-		Category:  category,
-		Timestamp: time.Now(),
-	}
-
-	if err := a.database.Create(note).Error; err != nil {
-		return nil, fmt.Errorf("gagal menyimpan catatan: %w", err)
+	if err := a.authorizeRecord(services.PermNoteWrite, "sessions", sessionID); err != nil {
+		return nil, err
 	}
 
-	return note, nil
+	return a.noteService.CreateNote(sessionID, noteText, category, a.currentActor(), a.actingTherapistID())
 }
 
 // GetSessionNotes retrieves all notes for a specific session
 func (a *App) GetSessionNotes(sessionID uint) ([]model.Note, error) {
+	if err := a.authorizeRecord(services.PermNoteRead, "sessions", sessionID); err != nil {
+		return nil, err
+	}
+
 	var notes []model.Note
 	if err := a.database.Where("session_id = ?", sessionID).Order("timestamp DESC").Find(&notes).Error; err != nil {
 		return nil, fmt.Errorf("gagal mengambil catatan sesi: %w", err)
 	}
+	notes, err := a.visibleNotes(notes)
+	if err != nil {
+		return nil, err
+	}
+	a.auditRead(services.AuditEntitySession, sessionID)
 	return notes, nil
 }
 
+// visibleNotes drops notes written about group session participants outside the caseload
+func (a *App) visibleNotes(notes []model.Note) ([]model.Note, error) {
+	childIDs, scoped, err := a.caseloadChildIDs()
+	if err != nil || !scoped {
+		return notes, err
+	}
+	caseload := make(map[uint]bool, len(childIDs))
+	for _, id := range childIDs {
+		caseload[id] = true
+	}
+	visible := make([]model.Note, 0, len(notes))
+	for _, note := range notes {
+		if note.ChildID == nil || caseload[*note.ChildID] {
+			visible = append(visible, note)
+		}
+	}
+	return visible, nil
+}
+
+// sessionClinicalProfiles loads the clinical profile of a session's child, or of each
+// participant of a group session that is in the caseload, keyed by child
+func (a *App) sessionClinicalProfiles(session model.Session) (map[uint]*services.ClinicalProfile, error) {
+	childIDs := []uint{session.ChildID}
+	if session.IsGroup {
+		caseloadIDs, scoped, err := a.caseloadChildIDs()
+		if err != nil {
+			return nil, err
+		}
+		caseload := make(map[uint]bool, len(caseloadIDs))
+		for _, id := range caseloadIDs {
+			caseload[id] = true
+		}
+		childIDs = nil
+		for _, participant := range session.Participants {
+			if !scoped || caseload[participant.ChildID] {
+				childIDs = append(childIDs, participant.ChildID)
+			}
+		}
+	}
+	profiles := make(map[uint]*services.ClinicalProfile, len(childIDs))
+	for _, childID := range childIDs {
+		profile, err := a.clinicalProfileService.GetClinicalProfile(childID)
+		if err != nil {
+			return nil, err
+		}
+		profiles[childID] = profile
+	}
+	return profiles, nil
+}
+
+// hideOtherChildrenRecords removes the notes and rewards of group session participants
+// outside the caseload from a loaded session
+func (a *App) hideOtherChildrenRecords(session *model.Session) error {
+	if !session.IsGroup {
+		return nil
+	}
+	notes, err := a.visibleNotes(session.Notes)
+	if err != nil {
+		return err
+	}
+	session.Notes = notes
+	childIDs, scoped, err := a.caseloadChildIDs()
+	if err != nil || !scoped {
+		return err
+	}
+	caseload := make(map[uint]bool, len(childIDs))
+	for _, id := range childIDs {
+		caseload[id] = true
+	}
+	rewards := make([]model.Reward, 0, len(session.Rewards))
+	for _, reward := range session.Rewards {
+		if caseload[reward.ChildID] {
+			rewards = append(rewards, reward)
+		}
+	}
+	session.Rewards = rewards
+	return nil
+}
+
 // GetSessionActivityHistoryByChild returns all session activities for a child
 func (a *App) GetSessionActivityHistoryByChild(childID uint) ([]model.SessionActivity, error) {
+    if err := a.authorizeChild(services.PermNoteRead, childID); err != nil {
+        return nil, err
+    }
+
     return a.sessionService.GetSessionActivityHistoryByChild(childID)
 }
 
 // UpdateNote updates an existing note
 func (a *App) UpdateNote(noteID uint, noteText, category string) (*model.Note, error) {
-	var note model.Note
-	if err := a.database.First(&note, noteID).Error; err != nil {
-		return nil, fmt.Errorf("catatan tidak ditemukan: %w", err)
+	if err := a.authorizeRecord(services.PermNoteWrite, "notes", noteID); err != nil {
+		return nil, err
 	}
 
-	note.NoteText = noteText
-	note.Category = category
+	return a.UpdateNoteWithReason(noteID, noteText, category, "")
+}
 
-	if err := a.database.Save(&note).Error; err != nil {
-		return nil, fmt.Errorf("gagal memperbarui catatan: %w", err)
+// UpdateNoteWithReason updates an existing note, recording why it was amended
+func (a *App) UpdateNoteWithReason(noteID uint, noteText, category, reason string) (*model.Note, error) {
+	if err := a.authorizeRecord(services.PermNoteWrite, "notes", noteID); err != nil {
+		return nil, err
 	}
 
-	return &note, nil
+	return a.noteService.UpdateNote(noteID, noteText, category, a.currentActor(), reason)
 }
 
 // DeleteNote removes a note
 func (a *App) DeleteNote(noteID uint) error {
-	if err := a.database.Delete(&model.Note{}, noteID).Error; err != nil {
-		return fmt.Errorf("gagal menghapus catatan: %w", err)
+	if err := a.authorizeRecord(services.PermNoteWrite, "notes", noteID); err != nil {
+		return err
 	}
-	return nil
+
+	return a.DeleteNoteWithReason(noteID, "")
+}
+
+// DeleteNoteWithReason removes a note, recording why it was deleted
+func (a *App) DeleteNoteWithReason(noteID uint, reason string) error {
+	if err := a.authorizeRecord(services.PermNoteWrite, "notes", noteID); err != nil {
+		return err
+	}
+
+	return a.noteService.DeleteNote(noteID, a.currentActor(), reason)
+}
+
+// ===== NOTE REVISION HISTORY =====
+
+// GetNoteRevisions lists every revision of a note, oldest first
+func (a *App) GetNoteRevisions(noteID uint) ([]model.NoteRevision, error) {
+	if err := a.authorizeRecord(services.PermNoteRead, "notes", noteID); err != nil {
+		return nil, err
+	}
+
+	revisions, err := a.revisionService.GetNoteRevisions(noteID)
+	if err != nil {
+		return nil, err
+	}
+	a.auditRead(services.AuditEntityNote, noteID)
+	return revisions, nil
+}
+
+// GetSessionRevisions lists every revision of a session's notes, summary and note sections
+func (a *App) GetSessionRevisions(sessionID uint) ([]model.NoteRevision, error) {
+	if err := a.authorizeRecord(services.PermNoteRead, "sessions", sessionID); err != nil {
+		return nil, err
+	}
+
+	revisions, err := a.revisionService.GetSessionRevisions(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	a.auditRead(services.AuditEntitySession, sessionID)
+	return revisions, nil
+}
+
+// DiffNoteRevisions compares two revisions of the same note, summary or note section
+func (a *App) DiffNoteRevisions(fromRevisionID, toRevisionID uint) (*services.RevisionDiff, error) {
+	if err := a.authorizeRecord(services.PermNoteRead, "note_revisions", fromRevisionID); err != nil {
+		return nil, err
+	}
+
+	return a.revisionService.DiffRevisions(fromRevisionID, toRevisionID)
 }
 
 // ===== NOTE TEMPLATE MANAGEMENT =====
 
 // GetAllNoteTemplates retrieves all available note templates
 func (a *App) GetAllNoteTemplates() ([]model.NoteTemplate, error) {
+    if err := a.authorize(services.PermCatalogView); err != nil {
+        return nil, err
+    }
+
     var templates []model.NoteTemplate
     if err := a.database.Order("created_at DESC").Find(&templates).Error; err != nil {
         return nil, fmt.Errorf("gagal mengambil template catatan: %w", err)
@@ -403,6 +3282,10 @@ func (a *App) GetAllNoteTemplates() ([]model.NoteTemplate, error) {
 
 // CreateNoteTemplate creates a new note template
 func (a *App) CreateNoteTemplate(templateText, categoryHint, keywords string) (*model.NoteTemplate, error) {
+    if err := a.authorize(services.PermCatalogManage); err != nil {
+        return nil, err
+    }
+
     if templateText == "" {
         return nil, fmt.Errorf("teks template harus diisi")
     }
@@ -411,7 +3294,14 @@ func (a *App) CreateNoteTemplate(templateText, categoryHint, keywords string) (*
         CategoryHint: categoryHint,
         Keywords:     keywords,
     }
-    if err := a.database.Create(template).Error; err != nil {
+    err := a.auditService.Transaction(a.database, func(tx *gorm.DB, trail *services.AuditTrail) error {
+        if err := tx.Create(template).Error; err != nil {
+            return err
+        }
+        trail.Add(services.AuditActionCreate, services.AuditEntityNoteTemplate, template.ID, nil, template)
+        return nil
+    })
+    if err != nil {
         return nil, fmt.Errorf("gagal membuat template: %w", err)
     }
     return template, nil
@@ -419,22 +3309,49 @@ func (a *App) CreateNoteTemplate(templateText, categoryHint, keywords string) (*
 
 // UpdateNoteTemplate updates an existing note template
 func (a *App) UpdateNoteTemplate(templateID uint, templateText, categoryHint, keywords string) (*model.NoteTemplate, error) {
+    if err := a.authorize(services.PermCatalogManage); err != nil {
+        return nil, err
+    }
+
     var template model.NoteTemplate
     if err := a.database.First(&template, templateID).Error; err != nil {
         return nil, fmt.Errorf("template tidak ditemukan: %w", err)
     }
+    before := template
     template.TemplateText = templateText
     template.CategoryHint = categoryHint
     template.Keywords = keywords
-    if err := a.database.Save(&template).Error; err != nil {
+    err := a.auditService.Transaction(a.database, func(tx *gorm.DB, trail *services.AuditTrail) error {
+        if err := tx.Save(&template).Error; err != nil {
+            return err
+        }
+        trail.Add(services.AuditActionUpdate, services.AuditEntityNoteTemplate, template.ID, before, template)
+        return nil
+    })
+    if err != nil {
         return nil, fmt.Errorf("gagal memperbarui template: %w", err)
     }
     return &template, nil
 }
 
-// DeleteNoteTemplate deletes a note template
-func (a *App) DeleteNoteTemplate(templateID uint) error {
-    if err := a.database.Delete(&model.NoteTemplate{}, templateID).Error; err != nil {
+// DeleteNoteTemplate deletes a note template
+func (a *App) DeleteNoteTemplate(templateID uint) error {
+    if err := a.authorize(services.PermCatalogManage); err != nil {
+        return err
+    }
+
+    var template model.NoteTemplate
+    if err := a.database.First(&template, templateID).Error; err != nil {
+        return fmt.Errorf("template tidak ditemukan: %w", err)
+    }
+    err := a.auditService.Transaction(a.database, func(tx *gorm.DB, trail *services.AuditTrail) error {
+        if err := tx.Delete(&template).Error; err != nil {
+            return err
+        }
+        trail.Add(services.AuditActionDelete, services.AuditEntityNoteTemplate, template.ID, template, nil)
+        return nil
+    })
+    if err != nil {
         return fmt.Errorf("gagal menghapus template: %w", err)
     }
     return nil
@@ -444,22 +3361,13 @@ func (a *App) DeleteNoteTemplate(templateID uint) error {
 
 // AddReward gives a reward to a child
 func (a *App) AddReward(childID uint, sessionID *uint, rewardType string, value int, notes string) (*model.Reward, error) {
-	reward := &model.Reward{
-		ChildID:   childID,
-		SessionID: sessionID,
-		Type:      rewardType,
-		Value:     value,
-		Timestamp: time.Now(),
-		Notes:     notes,
+	if err := a.authorizeChild(services.PermSessionRun, childID); err != nil {
+		return nil, err
 	}
 
-	if err := a.database.Create(reward).Error; err != nil {
-		return nil, fmt.Errorf("gagal memberikan reward: %w", err)
-	}
-
-	// Load relationships
-    if err := a.database.Preload("Child").First(reward, reward.ID).Error; err != nil {
-		return nil, fmt.Errorf("gagal memuat data reward: %w", err)
+	reward, err := a.rewardService.GiveReward(childID, sessionID, rewardType, value, notes, a.actingTherapistID())
+	if err != nil {
+		return nil, err
 	}
     // Emit reward event for real-time updates
     runtime.EventsEmit(a.ctx, "reward_updated", map[string]interface{}{
@@ -476,6 +3384,10 @@ func (a *App) AddReward(childID uint, sessionID *uint, rewardType string, value
 
 // DeleteReward removes a reward and emits an update event
 func (a *App) DeleteReward(rewardID uint) error {
+    if err := a.authorizeRecord(services.PermSessionRun, "rewards", rewardID); err != nil {
+        return err
+    }
+
     // Load reward details first for event payload
     var r model.Reward
     if err := a.database.First(&r, rewardID).Error; err != nil {
@@ -500,6 +3412,10 @@ func (a *App) DeleteReward(rewardID uint) error {
 
 // GetChildRewards retrieves all rewards for a specific child
 func (a *App) GetChildRewards(childID uint) ([]model.Reward, error) {
+	if err := a.authorizeChild(services.PermReportView, childID); err != nil {
+		return nil, err
+	}
+
 	var rewards []model.Reward
 	if err := a.database.Where("child_id = ?", childID).Order("timestamp DESC").Find(&rewards).Error; err != nil {
 		return nil, fmt.Errorf("gagal mengambil reward anak: %w", err)
@@ -509,6 +3425,10 @@ func (a *App) GetChildRewards(childID uint) ([]model.Reward, error) {
 
 // GetRewardSummary gets reward statistics for a child
 func (a *App) GetRewardSummary(childID uint) (map[string]interface{}, error) {
+	if err := a.authorizeChild(services.PermReportView, childID); err != nil {
+		return nil, err
+	}
+
 	var totalRewards int64
 	var rewardsByType map[string]int64
 
@@ -551,6 +3471,10 @@ func (a *App) GetRewardSummary(childID uint) (map[string]interface{}, error) {
 
 // CreateGoal creates a new therapy goal for a child
 func (a *App) CreateGoal(childID uint, name, description string, targetValue int, targetType string) (*model.Goal, error) {
+	if err := a.authorizeChild(services.PermNoteWrite, childID); err != nil {
+		return nil, err
+	}
+
 	if name == "" {
 		return nil, fmt.Errorf("nama tujuan harus diisi")
 	}
@@ -564,7 +3488,14 @@ func (a *App) CreateGoal(childID uint, name, description string, targetValue int
 		StartDate:   time.Now(),
 	}
 
-	if err := a.database.Create(goal).Error; err != nil {
+	err := a.auditService.Transaction(a.database, func(tx *gorm.DB, trail *services.AuditTrail) error {
+		if err := tx.Create(goal).Error; err != nil {
+			return err
+		}
+		trail.Add(services.AuditActionCreate, services.AuditEntityGoal, goal.ID, nil, goal)
+		return nil
+	})
+	if err != nil {
 		return nil, fmt.Errorf("gagal membuat tujuan: %w", err)
 	}
 
@@ -573,6 +3504,10 @@ func (a *App) CreateGoal(childID uint, name, description string, targetValue int
 
 // GetChildGoals retrieves all goals for a specific child
 func (a *App) GetChildGoals(childID uint) ([]model.Goal, error) {
+	if err := a.authorizeChild(services.PermNoteRead, childID); err != nil {
+		return nil, err
+	}
+
 	var goals []model.Goal
 	if err := a.database.Where("child_id = ?", childID).Order("start_date DESC").Find(&goals).Error; err != nil {
 		return nil, fmt.Errorf("gagal mengambil tujuan anak: %w", err)
@@ -582,6 +3517,10 @@ func (a *App) GetChildGoals(childID uint) ([]model.Goal, error) {
 
 // AchieveGoal marks a goal as achieved
 func (a *App) AchieveGoal(goalID uint) (*model.Goal, error) {
+	if err := a.authorizeRecord(services.PermNoteWrite, "goals", goalID); err != nil {
+		return nil, err
+	}
+
 	var goal model.Goal
 	if err := a.database.First(&goal, goalID).Error; err != nil {
 		return nil, fmt.Errorf("tujuan tidak ditemukan: %w", err)
@@ -591,11 +3530,19 @@ func (a *App) AchieveGoal(goalID uint) (*model.Goal, error) {
 		return nil, fmt.Errorf("tujuan sudah tercapai")
 	}
 
+	before := goal
 	now := time.Now()
 	goal.IsAchieved = true
 	goal.AchievedDate = &now
 
-	if err := a.database.Save(&goal).Error; err != nil {
+	err := a.auditService.Transaction(a.database, func(tx *gorm.DB, trail *services.AuditTrail) error {
+		if err := tx.Save(&goal).Error; err != nil {
+			return err
+		}
+		trail.Add(services.AuditActionUpdate, services.AuditEntityGoal, goal.ID, before, goal)
+		return nil
+	})
+	if err != nil {
 		return nil, fmt.Errorf("gagal menandai tujuan tercapai: %w", err)
 	}
 
@@ -606,32 +3553,114 @@ func (a *App) AchieveGoal(goalID uint) (*model.Goal, error) {
 
 // CreateFlashcard creates a new flashcard
 func (a *App) CreateFlashcard(category, textContent, imagePath, description string) (*model.Flashcard, error) {
+	if err := a.authorize(services.PermCatalogManage); err != nil {
+		return nil, err
+	}
+
+	if category == "" {
+		return nil, fmt.Errorf("kategori flashcard harus diisi")
+	}
+
+	flashcard := &model.Flashcard{
+		Category:    category,
+		TextContent: textContent,
+		ImagePath:   imagePath,
+		Description: description,
+	}
+
+	err := a.auditService.Transaction(a.database, func(tx *gorm.DB, trail *services.AuditTrail) error {
+		if err := tx.Create(flashcard).Error; err != nil {
+			return err
+		}
+		trail.Add(services.AuditActionCreate, services.AuditEntityFlashcard, flashcard.ID, nil, flashcard)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("gagal membuat flashcard: %w", err)
+	}
+
+	return flashcard, nil
+}
+
+// CreateChildPhotoFlashcard creates a flashcard whose image is a photo of the child;
+// it requires the family's photography consent
+func (a *App) CreateChildPhotoFlashcard(childID uint, category, textContent, imagePath, description string) (*model.Flashcard, error) {
+	if err := a.authorizeChild(services.PermNoteWrite, childID); err != nil {
+		return nil, err
+	}
+
 	if category == "" {
 		return nil, fmt.Errorf("kategori flashcard harus diisi")
 	}
+	if imagePath == "" {
+		return nil, fmt.Errorf("foto flashcard harus dipilih")
+	}
+	if err := a.consentService.RequireConsent(childID, services.ConsentTypePhotography); err != nil {
+		return nil, err
+	}
 
 	flashcard := &model.Flashcard{
 		Category:    category,
 		TextContent: textContent,
 		ImagePath:   imagePath,
 		Description: description,
+		ChildID:     &childID,
 	}
 
-	if err := a.database.Create(flashcard).Error; err != nil {
+	err := a.auditService.Transaction(a.database, func(tx *gorm.DB, trail *services.AuditTrail) error {
+		if err := tx.Create(flashcard).Error; err != nil {
+			return err
+		}
+		trail.Add(services.AuditActionCreate, services.AuditEntityFlashcard, flashcard.ID, nil, flashcard)
+		return nil
+	})
+	if err != nil {
 		return nil, fmt.Errorf("gagal membuat flashcard: %w", err)
 	}
 
 	return flashcard, nil
 }
 
-// GetFlashcardsByCategory retrieves flashcards by category
+// GetFlashcardsByCategory retrieves flashcards by category. Flashcards with a child's
+// photo are only listed while that child has photography consent and is in the caseload.
 func (a *App) GetFlashcardsByCategory(category string) ([]model.Flashcard, error) {
+	if err := a.authorize(services.PermCatalogView); err != nil {
+		return nil, err
+	}
+
 	var flashcards []model.Flashcard
 	query := a.database
 	if category != "" {
 		query = query.Where("category = ?", category)
 	}
 
+	consented, err := a.consentService.ChildrenWithValidConsent(services.ConsentTypePhotography)
+	if err != nil {
+		return nil, err
+	}
+	childIDs, scoped, err := a.caseloadChildIDs()
+	if err != nil {
+		return nil, err
+	}
+	if scoped {
+		inCaseload := make(map[uint]bool, len(childIDs))
+		for _, id := range childIDs {
+			inCaseload[id] = true
+		}
+		visible := consented[:0]
+		for _, id := range consented {
+			if inCaseload[id] {
+				visible = append(visible, id)
+			}
+		}
+		consented = visible
+	}
+	if len(consented) > 0 {
+		query = query.Where("child_id IS NULL OR child_id IN ?", consented)
+	} else {
+		query = query.Where("child_id IS NULL")
+	}
+
 	if err := query.Find(&flashcards).Error; err != nil {
 		return nil, fmt.Errorf("gagal mengambil flashcard: %w", err)
 	}
@@ -640,6 +3669,24 @@ func (a *App) GetFlashcardsByCategory(category string) ([]model.Flashcard, error
 
 // LogFlashcardResponse logs a child's response to a flashcard during a session
 func (a *App) LogFlashcardResponse(sessionID, flashcardID uint, responseTag, responseNotes string) (*model.SessionFlashcard, error) {
+	if err := a.authorizeRecord(services.PermSessionRun, "sessions", sessionID); err != nil {
+		return nil, err
+	}
+
+	if err := a.sessionService.EnsureSessionWritable(sessionID); err != nil {
+		return nil, err
+	}
+
+	var flashcard model.Flashcard
+	if err := a.database.First(&flashcard, flashcardID).Error; err != nil {
+		return nil, fmt.Errorf("flashcard tidak ditemukan: %w", err)
+	}
+	if flashcard.ChildID != nil {
+		if err := a.consentService.RequireConsent(*flashcard.ChildID, services.ConsentTypePhotography); err != nil {
+			return nil, err
+		}
+	}
+
 	sessionFlashcard := &model.SessionFlashcard{
 		SessionID:     sessionID,
 		FlashcardID:   flashcardID,
@@ -648,7 +3695,14 @@ func (a *App) LogFlashcardResponse(sessionID, flashcardID uint, responseTag, res
 		Timestamp:     time.Now(),
 	}
 
-	if err := a.database.Create(sessionFlashcard).Error; err != nil {
+	err := a.auditService.Transaction(a.database, func(tx *gorm.DB, trail *services.AuditTrail) error {
+		if err := tx.Create(sessionFlashcard).Error; err != nil {
+			return err
+		}
+		trail.Add(services.AuditActionCreate, services.AuditEntitySessionFlashcard, sessionFlashcard.ID, nil, sessionFlashcard)
+		return nil
+	})
+	if err != nil {
 		return nil, fmt.Errorf("gagal mencatat respons flashcard: %w", err)
 	}
 
@@ -664,27 +3718,42 @@ func (a *App) LogFlashcardResponse(sessionID, flashcardID uint, responseTag, res
 
 // GetChildProgressSummary provides comprehensive progress summary for a child
 func (a *App) GetChildProgressSummary(childID uint) (map[string]interface{}, error) {
-	// Get total sessions
+	if err := a.authorizeChild(services.PermReportView, childID); err != nil {
+		return nil, err
+	}
+
+	// Get total sessions, counting group sessions the child attended
+	attended := services.AttendedSessionIDs(a.database, childID)
 	var totalSessions int64
-	if err := a.database.Model(&model.Session{}).Where("child_id = ?", childID).Count(&totalSessions).Error; err != nil {
+	if err := a.database.Model(&model.Session{}).Where("id IN (?)", attended).Count(&totalSessions).Error; err != nil {
 		return nil, fmt.Errorf("gagal menghitung total sesi: %w", err)
 	}
+	var groupSessions int64
+	if err := a.database.Model(&model.Session{}).Where("id IN (?) AND is_group = ?", attended, true).Count(&groupSessions).Error; err != nil {
+		return nil, fmt.Errorf("gagal menghitung sesi kelompok: %w", err)
+	}
 
 	// Get completed sessions (with end time)
 	var completedSessions int64
-	if err := a.database.Model(&model.Session{}).Where("child_id = ? AND end_time IS NOT NULL", childID).Count(&completedSessions).Error; err != nil {
+	if err := a.database.Model(&model.Session{}).Where("id IN (?) AND end_time IS NOT NULL", attended).Count(&completedSessions).Error; err != nil {
 		return nil, fmt.Errorf("gagal menghitung sesi selesai: %w", err)
 	}
 
 	// Get average session duration
 	var avgDuration float64
 	if err := a.database.Model(&model.Session{}).
-		Where("child_id = ? AND end_time IS NOT NULL", childID).
+		Where("id IN (?) AND end_time IS NOT NULL", attended).
 		Select("AVG(duration_minutes)").
 		Scan(&avgDuration).Error; err != nil {
 		avgDuration = 0
 	}
 
+	// Attendance, including cancellations and no-shows
+	attendance, err := a.sessionService.GetChildAttendanceStats(childID, time.Time{}, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+
 	// Get goals progress
 	var totalGoals, achievedGoals int64
 	a.database.Model(&model.Goal{}).Where("child_id = ?", childID).Count(&totalGoals)
@@ -693,19 +3762,73 @@ func (a *App) GetChildProgressSummary(childID uint) (map[string]interface{}, err
 	// Get reward summary
 	rewardSummary, _ := a.GetRewardSummary(childID)
 
+	// Ages for developmental context: today and across the span of sessions
+	var child model.Child
+	if err := a.database.First(&child, childID).Error; err != nil {
+		return nil, fmt.Errorf("gagal mengambil data anak: %w", err)
+	}
+	var firstSession, lastSession model.Session
+	a.database.Where("id IN (?)", attended).Order("start_time ASC").Limit(1).Find(&firstSession)
+	a.database.Where("id IN (?)", attended).Order("start_time DESC").Limit(1).Find(&lastSession)
+	var ageAtFirstSession, ageAtLastSession *model.Age
+	if firstSession.ID != 0 {
+		ageAtFirstSession = services.ChildAgeAt(child, firstSession.StartTime)
+		ageAtLastSession = services.ChildAgeAt(child, lastSession.StartTime)
+	}
+
 	summary := map[string]interface{}{
 		"child_id":            childID,
 		"total_sessions":      totalSessions,
+		"group_sessions":      groupSessions,
 		"completed_sessions":  completedSessions,
+		"attendance":          attendance,
 		"avg_duration":        avgDuration,
 		"total_goals":         totalGoals,
 		"achieved_goals":      achievedGoals,
 		"reward_summary":      rewardSummary,
+		"current_age":         services.ChildAgeAt(child, time.Now()),
+		"age_at_first_session": ageAtFirstSession,
+		"age_at_last_session":  ageAtLastSession,
+	}
+
+	// Standardised assessment scores over time are clinical content
+	if a.can(services.PermNoteRead) {
+		assessments, err := a.assessmentService.CompareAllAdministrations(childID)
+		if err != nil {
+			return nil, err
+		}
+		summary["assessments"] = assessments
+
+		milestones, err := a.milestoneService.GetMilestoneSummary(childID)
+		if err != nil {
+			return nil, err
+		}
+		summary["milestones"] = milestones
 	}
 
 	return summary, nil
 }
 
+// ===== AUDIT LOG =====
+
+// QueryAuditLogs retrieves audit log entries matching the filter, newest first
+func (a *App) QueryAuditLogs(filter services.AuditFilter) ([]model.AuditLog, error) {
+    if err := a.authorize(services.PermAuditView); err != nil {
+        return nil, err
+    }
+
+    return a.auditService.QueryAuditLogs(filter)
+}
+
+// VerifyAuditChain checks that no audit log entry has been altered or removed
+func (a *App) VerifyAuditChain() (*services.AuditVerification, error) {
+    if err := a.authorize(services.PermAuditView); err != nil {
+        return nil, err
+    }
+
+    return a.auditService.VerifyAuditChain()
+}
+
 // ===== UTILITY METHODS =====
 
 // GetCurrentTime returns current timestamp (useful for frontend)
@@ -715,28 +3838,41 @@ func (a *App) GetCurrentTime() string {
 
 // ValidateSession checks if a session is valid and active
 func (a *App) ValidateSession(sessionID uint) (bool, error) {
+	if err := a.authorizeRecord(services.PermSessionView, "sessions", sessionID); err != nil {
+		return false, err
+	}
+
 	var session model.Session
 	if err := a.database.First(&session, sessionID).Error; err != nil {
 		return false, nil // Session doesn't exist
 	}
 
-	// Session is active if it has no end time
-	return session.EndTime == nil, nil
+	// Session is active if it took place and has no end time
+	return session.EndTime == nil && services.IsHeldStatus(session.Status), nil
 }
 
 // GenerateSessionSummary creates an auto-formatted summary of the session
 func (a *App) GenerateSessionSummary(sessionID uint) (map[string]interface{}, error) {
+    if err := a.authorizeRecord(services.PermNoteRead, "sessions", sessionID); err != nil {
+        return nil, err
+    }
+
     // Get session details
     var session model.Session
-    if err := a.database.Preload("Child").Preload("Notes").Preload("SessionActivities.Activity").Preload("Rewards").First(&session, sessionID).Error; err != nil {
+    if err := a.database.Preload("Child").Preload("Notes").Preload("SessionActivities.Activity").Preload("Rewards").Preload("NoteSections").Preload("Addenda").Preload("ReviewComments").Preload("Participants.Child").First(&session, sessionID).Error; err != nil {
         return nil, fmt.Errorf("gagal mengambil data sesi: %w", err)
     }
+    if err := a.hideOtherChildrenRecords(&session); err != nil {
+        return nil, err
+    }
+
+    a.auditRead(services.AuditEntitySession, session.ID)
 
-    // Calculate session duration
+    // Calculate session duration; a session that has not started has none
     var duration int
     if session.EndTime != nil {
         duration = int(session.EndTime.Sub(session.StartTime).Minutes())
-    } else {
+    } else if session.Status != services.SessionStatusScheduled {
         duration = int(time.Since(session.StartTime).Minutes())
     }
 
@@ -775,9 +3911,12 @@ func (a *App) GenerateSessionSummary(sessionID uint) (map[string]interface{}, er
         activitiesSummary = append(activitiesSummary, activitySummary)
     }
 
-    // Categorize notes
+    // Categorize notes; notes about one child of a group session are listed with that child
     notesByCategory := make(map[string][]model.Note)
     for _, note := range session.Notes {
+        if note.ChildID != nil {
+            continue
+        }
         category := note.Category
         if category == "" {
             category = "Umum"
@@ -793,12 +3932,43 @@ func (a *App) GenerateSessionSummary(sessionID uint) (map[string]interface{}, er
         totalRewards += reward.Value
     }
 
+    profiles, err := a.sessionClinicalProfiles(session)
+    if err != nil {
+        return nil, err
+    }
+    // A group session's child outside the caseload gets an empty profile
+    profile, ok := profiles[session.ChildID]
+    if !ok {
+        profile = &services.ClinicalProfile{ChildID: session.ChildID}
+    }
+
+    // Planned vs. actual activities; nil when the session was started without a plan
+    planComparison, err := a.sessionPlanService.ComparePlanWithSession(session.ID)
+    if err != nil {
+        return nil, err
+    }
+
     // Generate formatted summary text
-    summaryText := a.formatSessionSummaryText(session, duration, activitiesSummary, notesByCategory, rewardsByType)
+    summaryText := a.formatSessionSummaryText(session, duration, activitiesSummary, notesByCategory, rewardsByType, profile, profiles, planComparison)
 
     summary := map[string]interface{}{
         "session_id":               session.ID,
         "child_name":               session.Child.Name,
+        "status":                   session.Status,
+        "status_reason":            session.StatusReason,
+        "late_minutes":             session.LateMinutes,
+        "entered_afterwards":       session.EnteredAfterwards,
+        "therapy_type":             session.TherapyType,
+        "is_group":                 session.IsGroup,
+        "group_name":               session.GroupName,
+        "participants":             session.Participants,
+        "child_age_at_session":     services.ChildAgeAt(session.Child, session.StartTime),
+        "school":                   profile.School,
+        "referral_source":          profile.ReferralSource,
+        "diagnoses":                profile.ActiveDiagnoses(),
+        "medications":              profile.CurrentMedications(session.StartTime),
+        "sensitivities":            profile.Sensitivities,
+        "participant_profiles":     profiles,
         "start_time":               session.StartTime,
         "end_time":                 session.EndTime,
         "duration_minutes":         duration,
@@ -811,8 +3981,18 @@ func (a *App) GenerateSessionSummary(sessionID uint) (map[string]interface{}, er
         "total_rewards":            totalRewards,
         "rewards_by_type":          rewardsByType,
         "activities_summary":       activitiesSummary,
+        "plan_comparison":          planComparison,
         "formatted_summary":        summaryText,
         "summary_notes":            session.SummaryNotes,
+        "note_format":              session.NoteFormat,
+        "note_sections":            session.NoteSections,
+        "finalized_at":             session.FinalizedAt,
+        "finalized_by":             session.FinalizedBy,
+        "addenda":                  session.Addenda,
+        "review_status":            session.ReviewStatus,
+        "cosigned_at":              session.CosignedAt,
+        "cosigned_by":              session.CosignedBy,
+        "review_comments":          session.ReviewComments,
         "generated_at":             time.Now(),
     }
 
@@ -820,12 +4000,15 @@ func (a *App) GenerateSessionSummary(sessionID uint) (map[string]interface{}, er
 }
 
 // formatSessionSummaryText creates a formatted text summary
-func (a *App) formatSessionSummaryText(session model.Session, duration int, activities []map[string]interface{}, notesByCategory map[string][]model.Note, rewards map[string]int) string {
+func (a *App) formatSessionSummaryText(session model.Session, duration int, activities []map[string]interface{}, notesByCategory map[string][]model.Note, rewards map[string]int, profile *services.ClinicalProfile, profiles map[uint]*services.ClinicalProfile, plan *services.PlanComparison) string {
     var summary strings.Builder
     
 	summary.WriteString("RINGKASAN SESI TERAPI\n")
 	summary.WriteString("====================\n\n")
     summary.WriteString(fmt.Sprintf("Anak: %s\n", session.Child.Name))
+    if age := services.ChildAgeAt(session.Child, session.StartTime); age != nil {
+        summary.WriteString(fmt.Sprintf("Usia saat sesi: %s\n", age.Label))
+    }
     summary.WriteString(fmt.Sprintf("Tanggal: %s\n", session.StartTime.Format("02 January 2006")))
     summary.WriteString(fmt.Sprintf("Waktu: %s", session.StartTime.Format("15:04")))
     
@@ -835,7 +4018,37 @@ func (a *App) formatSessionSummaryText(session model.Session, duration int, acti
         summary.WriteString(" (Sesi masih berlangsung)\n")
     }
     
-    summary.WriteString(fmt.Sprintf("Durasi: %d menit\n\n", duration))
+    summary.WriteString(fmt.Sprintf("Durasi: %d menit\n", duration))
+    if session.Status == services.SessionStatusLate {
+        summary.WriteString(fmt.Sprintf("Kehadiran: terlambat %d menit", session.LateMinutes))
+        if session.StatusReason != "" {
+            summary.WriteString(fmt.Sprintf(" (%s)", session.StatusReason))
+        }
+        summary.WriteString("\n")
+    }
+    if session.EnteredAfterwards {
+        summary.WriteString("Catatan: sesi dicatat setelah berlangsung\n")
+    }
+    writeClinicalProfile(&summary, profile, session.StartTime)
+    writeGroupParticipants(&summary, session, profiles)
+    writePlanComparison(&summary, plan)
+
+    // Structured formats replace the activity/notes/reward layout with their own sections
+    if format, ok := services.LookupNoteFormat(session.NoteFormat); ok && format.IsStructured() {
+        summary.WriteString(fmt.Sprintf("Format: %s\n\n", format.Name))
+        summary.WriteString(services.RenderNoteSections(format, session.NoteSections))
+
+        if session.SummaryNotes != "" {
+            summary.WriteString("CATATAN RINGKASAN:\n")
+            summary.WriteString("------------------\n")
+            summary.WriteString(session.SummaryNotes)
+            summary.WriteString("\n")
+        }
+
+        writeSessionSignature(&summary, session)
+        return summary.String()
+    }
+    summary.WriteString("\n")
 
     // Activities section
     if len(activities) > 0 {
@@ -893,11 +4106,188 @@ func (a *App) formatSessionSummaryText(session model.Session, duration int, acti
         summary.WriteString("\n")
     }
 
+    writeSessionSignature(&summary, session)
     return summary.String()
 }
 
+// writeClinicalProfile adds the child's school, referral source, active diagnoses,
+// medications current at the session and allergies/sensitivities to a summary
+func writeClinicalProfile(summary *strings.Builder, profile *services.ClinicalProfile, at time.Time) {
+    if profile.School != "" {
+        summary.WriteString(fmt.Sprintf("Sekolah: %s\n", profile.School))
+    }
+    if profile.ReferralSource != "" {
+        summary.WriteString(fmt.Sprintf("Rujukan dari: %s\n", profile.ReferralSource))
+    }
+
+    items := clinicalProfileItems(profile, at)
+    if len(items) == 0 {
+        return
+    }
+
+    summary.WriteString("\nPROFIL KLINIS:\n")
+    summary.WriteString("--------------\n")
+    for _, item := range items {
+        summary.WriteString(fmt.Sprintf("• %s\n", item))
+    }
+}
+
+// clinicalProfileItems describes a child's active diagnoses, medications current at the
+// session and allergies/sensitivities, one line each
+func clinicalProfileItems(profile *services.ClinicalProfile, at time.Time) []string {
+    var items []string
+    for _, diagnosis := range profile.ActiveDiagnoses() {
+        item := fmt.Sprintf("Diagnosis: %s", diagnosis.Description)
+        if diagnosis.ICD10Code != "" {
+            item = fmt.Sprintf("Diagnosis: %s (%s)", diagnosis.Description, diagnosis.ICD10Code)
+        }
+        if diagnosis.DiagnosedOn != nil {
+            item += fmt.Sprintf(", sejak %s", diagnosis.DiagnosedOn.Format("02/01/2006"))
+        }
+        items = append(items, item)
+    }
+    for _, medication := range profile.CurrentMedications(at) {
+        details := strings.TrimSpace(strings.Join([]string{medication.Dosage, medication.Frequency}, " "))
+        if details != "" {
+            items = append(items, fmt.Sprintf("Obat: %s, %s", medication.Name, details))
+        } else {
+            items = append(items, fmt.Sprintf("Obat: %s", medication.Name))
+        }
+    }
+    for _, sensitivity := range profile.Sensitivities {
+        kind := "Alergi"
+        if sensitivity.Kind == services.SensitivityKindSensory {
+            kind = "Sensitivitas sensorik"
+        }
+        item := fmt.Sprintf("%s: %s (%s)", kind, sensitivity.Trigger, services.SeverityLabel(sensitivity.Severity))
+        if sensitivity.Reaction != "" {
+            item += fmt.Sprintf(", reaksi: %s", sensitivity.Reaction)
+        }
+        items = append(items, item)
+    }
+    return items
+}
+
+// attendanceLabels names the attendance of a group session participant
+var attendanceLabels = map[string]string{
+    services.AttendancePresent:   "hadir",
+    services.AttendanceLate:      "terlambat",
+    services.AttendanceLeftEarly: "pulang lebih awal",
+    services.AttendanceAbsent:    "tidak hadir",
+}
+
+// writeGroupParticipants lists the children of a group session with their attendance,
+// rewards and the notes written about each of them, and the clinical profile of the
+// other participants whose profile was loaded
+func writeGroupParticipants(summary *strings.Builder, session model.Session, profiles map[uint]*services.ClinicalProfile) {
+    if !session.IsGroup {
+        return
+    }
+    summary.WriteString(fmt.Sprintf("\nPESERTA KELOMPOK (%s):\n", session.GroupName))
+    summary.WriteString("-----------------\n")
+    for _, participant := range session.Participants {
+        summary.WriteString(fmt.Sprintf("• %s - %s", participant.Child.Name, attendanceLabels[participant.Attendance]))
+        if participant.ArrivedAt != nil && participant.Attendance == services.AttendanceLate {
+            summary.WriteString(fmt.Sprintf(" (datang %s)", participant.ArrivedAt.Format("15:04")))
+        }
+        if participant.LeftAt != nil {
+            summary.WriteString(fmt.Sprintf(" (pulang %s)", participant.LeftAt.Format("15:04")))
+        }
+        summary.WriteString("\n")
+        if participant.AttendanceNotes != "" {
+            summary.WriteString(fmt.Sprintf("  %s\n", participant.AttendanceNotes))
+        }
+        // The session's child has its profile at the top of the summary
+        if profile, ok := profiles[participant.ChildID]; ok && participant.ChildID != session.ChildID {
+            for _, item := range clinicalProfileItems(profile, session.StartTime) {
+                summary.WriteString(fmt.Sprintf("  %s\n", item))
+            }
+        }
+        rewardTotal := 0
+        for _, reward := range session.Rewards {
+            if reward.ChildID == participant.ChildID {
+                rewardTotal += reward.Value
+            }
+        }
+        if rewardTotal > 0 {
+            summary.WriteString(fmt.Sprintf("  Reward: %d\n", rewardTotal))
+        }
+        for _, note := range session.Notes {
+            if note.ChildID != nil && *note.ChildID == participant.ChildID {
+                summary.WriteString(fmt.Sprintf("  - [%s] %s\n", note.Timestamp.Format("15:04"), note.NoteText))
+            }
+        }
+    }
+}
+
+// writePlanComparison lists each planned activity with its target and actual duration,
+// followed by activities run outside the plan
+func writePlanComparison(summary *strings.Builder, plan *services.PlanComparison) {
+    if plan == nil {
+        return
+    }
+    summary.WriteString(fmt.Sprintf("\nRENCANA VS PELAKSANAAN (%s):\n", plan.Title))
+    summary.WriteString("-----------------------\n")
+    for _, item := range plan.Planned {
+        summary.WriteString(fmt.Sprintf("%d. %s - target %d menit", item.Position, item.ActivityName, item.TargetMinutes))
+        switch item.Status {
+        case services.PlanItemStatusCompleted:
+            summary.WriteString(fmt.Sprintf(", aktual %d menit (%+d) ✓", item.ActualMinutes, item.DifferenceMinutes))
+        case services.PlanItemStatusOngoing:
+            summary.WriteString(fmt.Sprintf(", berlangsung %d menit", item.ActualMinutes))
+        case services.PlanItemStatusSkipped:
+            summary.WriteString(", dilewati")
+            if item.SkipReason != "" {
+                summary.WriteString(fmt.Sprintf(": %s", item.SkipReason))
+            }
+        default:
+            summary.WriteString(", tidak dijalankan")
+        }
+        summary.WriteString("\n")
+        if item.GoalName != "" {
+            summary.WriteString(fmt.Sprintf("   Target: %s\n", item.GoalName))
+        } else if item.Objective != "" {
+            summary.WriteString(fmt.Sprintf("   Tujuan: %s\n", item.Objective))
+        }
+    }
+    for _, activity := range plan.Unplanned {
+        summary.WriteString(fmt.Sprintf("+ %s - di luar rencana, %d menit\n", activity.ActivityName, activity.ActualMinutes))
+    }
+}
+
+// writeSessionSignature appends the review status, co-signature, finalisation signature and any addenda to a summary
+func writeSessionSignature(summary *strings.Builder, session model.Session) {
+    switch session.ReviewStatus {
+    case services.ReviewStatusPending:
+        summary.WriteString("\nStatus review: menunggu review supervisor\n")
+    case services.ReviewStatusChangesRequested:
+        summary.WriteString("\nStatus review: perubahan diminta oleh supervisor\n")
+    case services.ReviewStatusCosigned:
+        summary.WriteString(fmt.Sprintf("\nDitandatangani bersama oleh %s pada %s\n", session.CosignedBy, session.CosignedAt.Format("02 January 2006 15:04")))
+    }
+
+    if session.FinalizedAt == nil {
+        return
+    }
+
+    summary.WriteString("\n")
+    summary.WriteString(fmt.Sprintf("Difinalisasi oleh %s pada %s\n", session.FinalizedBy, session.FinalizedAt.Format("02 January 2006 15:04")))
+
+    if len(session.Addenda) > 0 {
+        summary.WriteString("\nADDENDUM:\n")
+        summary.WriteString("---------\n")
+        for _, addendum := range session.Addenda {
+            summary.WriteString(fmt.Sprintf("• [%s, %s] %s\n", addendum.Timestamp.Format("02/01/2006 15:04"), addendum.Author, addendum.Text))
+        }
+    }
+}
+
 // GetSessionProgress gets real-time session progress
 func (a *App) GetSessionProgress(sessionID uint) (map[string]interface{}, error) {
+    if err := a.authorizeRecord(services.PermSessionView, "sessions", sessionID); err != nil {
+        return nil, err
+    }
+
     var session model.Session
     if err := a.database.Preload("Child").Preload("SessionActivities.Activity").First(&session, sessionID).Error; err != nil {
         return nil, fmt.Errorf("sesi tidak ditemukan: %w", err)
@@ -939,15 +4329,22 @@ func (a *App) GetSessionProgress(sessionID uint) (map[string]interface{}, error)
 
 // UpdateSessionSummaryNotes updates the summary notes for a session
 func (a *App) UpdateSessionSummaryNotes(sessionID uint, summaryNotes string) error {
-    var session model.Session
-    if err := a.database.First(&session, sessionID).Error; err != nil {
-        return fmt.Errorf("sesi tidak ditemukan: %w", err)
+    if err := a.authorizeRecord(services.PermNoteWrite, "sessions", sessionID); err != nil {
+        return err
     }
 
-    session.SummaryNotes = summaryNotes
-    
-    if err := a.database.Save(&session).Error; err != nil {
-        return fmt.Errorf("gagal memperbarui catatan ringkasan: %w", err)
+    return a.UpdateSessionSummaryNotesWithReason(sessionID, summaryNotes, "")
+}
+
+// UpdateSessionSummaryNotesWithReason updates the summary notes for a session, recording why they were amended
+func (a *App) UpdateSessionSummaryNotesWithReason(sessionID uint, summaryNotes, reason string) error {
+    if err := a.authorizeRecord(services.PermNoteWrite, "sessions", sessionID); err != nil {
+        return err
+    }
+
+    session, err := a.sessionService.UpdateSessionSummaryNotes(sessionID, summaryNotes, a.currentActor(), reason)
+    if err != nil {
+        return err
     }
 
     // Emit session update so UI can refresh summary-related views
@@ -962,6 +4359,14 @@ func (a *App) UpdateSessionSummaryNotes(sessionID uint, summaryNotes string) err
 
 // AutoPauseInactiveActivities pauses activities that have been running too long
 func (a *App) AutoPauseInactiveActivities(sessionID uint, maxDurationMinutes int) ([]model.SessionActivity, error) {
+    if err := a.authorizeRecord(services.PermSessionRun, "sessions", sessionID); err != nil {
+        return nil, err
+    }
+
+    if err := a.sessionService.EnsureSessionWritable(sessionID); err != nil {
+        return nil, err
+    }
+
     cutoffTime := time.Now().Add(-time.Duration(maxDurationMinutes) * time.Minute)
     
     var longRunningActivities []model.SessionActivity
@@ -975,10 +4380,18 @@ func (a *App) AutoPauseInactiveActivities(sessionID uint, maxDurationMinutes int
     now := time.Now()
     
     for _, activity := range longRunningActivities {
+        before := activity
         activity.EndTime = &now
         activity.Notes += fmt.Sprintf(" (Dihentikan otomatis setelah %d menit)", maxDurationMinutes)
         
-        if err := a.database.Save(&activity).Error; err != nil {
+        err := a.auditService.Transaction(a.database, func(tx *gorm.DB, trail *services.AuditTrail) error {
+            if err := tx.Save(&activity).Error; err != nil {
+                return err
+            }
+            trail.Add(services.AuditActionUpdate, services.AuditEntitySessionActivity, activity.ID, before, activity)
+            return nil
+        })
+        if err != nil {
             continue // Skip if error, but continue with others
         }
         
@@ -1011,6 +4424,10 @@ func (a *App) AutoPauseInactiveActivities(sessionID uint, maxDurationMinutes int
 
 // GetChildActivityFrequency returns the most frequent activities for a child
 func (a *App) GetChildActivityFrequency(childID uint) (map[string]int, error) {
+    if err := a.authorizeChild(services.PermReportView, childID); err != nil {
+        return nil, err
+    }
+
     var results []struct {
         Name  string
         Count int
@@ -1019,8 +4436,7 @@ func (a *App) GetChildActivityFrequency(childID uint) (map[string]int, error) {
         Table("session_activities").
         Select("activities.name as name, COUNT(*) as count").
         Joins("JOIN activities ON activities.id = session_activities.activity_id").
-        Joins("JOIN sessions ON sessions.id = session_activities.session_id").
-        Where("sessions.child_id = ?", childID).
+        Where("session_activities.session_id IN (?)", services.AttendedSessionIDs(a.database, childID)).
         Group("activities.name").
         Order("count DESC").
         Scan(&results).Error
@@ -1036,12 +4452,17 @@ func (a *App) GetChildActivityFrequency(childID uint) (map[string]int, error) {
 
 // GetChildNoteKeywordFrequency returns keyword frequency in notes for a child
 func (a *App) GetChildNoteKeywordFrequency(childID uint) (map[string]int, error) {
+    if err := a.authorizeChild(services.PermNoteRead, childID); err != nil {
+        return nil, err
+    }
+
     var notes []string
     err := a.database.
         Table("notes").
         Select("note_text").
-        Joins("JOIN sessions ON sessions.id = notes.session_id").
-        Where("sessions.child_id = ?", childID).
+        Where("notes.session_id IN (?)", services.AttendedSessionIDs(a.database, childID)).
+        // Notes about other children of a group session are not this child's
+        Where("notes.child_id IS NULL OR notes.child_id = ?", childID).
         Scan(&notes).Error
     if err != nil {
         return nil, err
@@ -1063,6 +4484,10 @@ func (a *App) GetChildNoteKeywordFrequency(childID uint) (map[string]int, error)
 
 // GetChildRewardTrends returns reward counts per month for a child
 func (a *App) GetChildRewardTrends(childID uint) ([]map[string]interface{}, error) {
+    if err := a.authorizeChild(services.PermReportView, childID); err != nil {
+        return nil, err
+    }
+
     rows, err := a.database.
         Table("rewards").
         Select("strftime('%Y-%m', timestamp) as month, type, SUM(value) as total").
@@ -1090,8 +4515,8 @@ func (a *App) GetChildRewardTrends(childID uint) ([]map[string]interface{}, erro
     return trends, nil
 }
 
-// ExportCSVFile exports CSV data to a file using Wails file dialog
-func (a *App) ExportCSVFile(csvData string, defaultFilename string) (string, error) {
+// saveCSVFile asks where to save CSV data and writes it there
+func (a *App) saveCSVFile(csvData string, defaultFilename string) (string, error) {
     // Get user's Downloads directory
     homeDir, err := os.UserHomeDir()
     if err != nil {
@@ -1130,8 +4555,32 @@ func (a *App) ExportCSVFile(csvData string, defaultFilename string) (string, err
     return filePath, nil
 }
 
-// ExportPDFFile exports PDF data to a file using Wails file dialog
-func (a *App) ExportPDFFile(pdfData []byte, defaultFilename string) (string, error) {
+// ExportChildReportPDF exports a child's report as PDF; sharing reports requires the
+// family's data sharing consent
+func (a *App) ExportChildReportPDF(childID uint, pdfData []byte, defaultFilename string) (string, error) {
+    if err := a.authorizeChild(services.PermReportView, childID); err != nil {
+        return "", err
+    }
+    if err := a.consentService.RequireConsent(childID, services.ConsentTypeDataSharing); err != nil {
+        return "", err
+    }
+    return a.savePDFFile(pdfData, defaultFilename)
+}
+
+// ExportChildReportCSV exports a child's data as CSV; sharing reports requires the
+// family's data sharing consent
+func (a *App) ExportChildReportCSV(childID uint, csvData string, defaultFilename string) (string, error) {
+    if err := a.authorizeChild(services.PermReportView, childID); err != nil {
+        return "", err
+    }
+    if err := a.consentService.RequireConsent(childID, services.ConsentTypeDataSharing); err != nil {
+        return "", err
+    }
+    return a.saveCSVFile(csvData, defaultFilename)
+}
+
+// savePDFFile asks where to save a PDF and writes it there
+func (a *App) savePDFFile(pdfData []byte, defaultFilename string) (string, error) {
     // Get user's Downloads directory
     homeDir, err := os.UserHomeDir()
     if err != nil {
@@ -1203,6 +4652,22 @@ func (a *App) OpenFileInExplorer(filePath string) error {
     return exec.Start()
 }
 
+// openWithDefaultApp opens a file with the application registered for its type
+func openWithDefaultApp(filePath string) error {
+    var cmd *exec.Cmd
+    switch sysruntime.GOOS {
+    case "windows":
+        cmd = exec.Command("cmd", "/c", "start", "", filePath)
+    case "darwin":
+        cmd = exec.Command("open", filePath)
+    case "linux":
+        cmd = exec.Command("xdg-open", filePath)
+    default:
+        return fmt.Errorf("platform tidak didukung")
+    }
+    return cmd.Start()
+}
+
 // ShowNotification shows a system notification and emits an event to frontend
 func (a *App) ShowNotification(title, message string) error {
     // Emit notification event to frontend
@@ -1223,12 +4688,30 @@ func (a *App) ShowErrorNotification(title, message string) {
     })
 }
 
+// caseloadSessions narrows a query on sessions to the current user's caseload, including
+// group sessions with a participant from it
+func (a *App) caseloadSessions(query *gorm.DB) (*gorm.DB, error) {
+    childIDs, scoped, err := a.caseloadChildIDs()
+    if err != nil {
+        return nil, err
+    }
+    if scoped {
+        participants := a.database.Model(&model.SessionParticipant{}).Select("session_id").Where("child_id IN ?", childIDs)
+        query = query.Where("sessions.child_id IN ? OR sessions.id IN (?)", childIDs, participants)
+    }
+    return query, nil
+}
+
 // GetActiveSessions returns count of active sessions today
 func (a *App) GetActiveSessions() (int64, error) {
     var count int64
     today := time.Now().Format("2006-01-02")
-    err := a.database.Model(&model.Session{}).
-        Where("DATE(start_time) = ? AND end_time IS NULL", today).
+    query, err := a.caseloadSessions(a.database.Model(&model.Session{}))
+    if err != nil {
+        return 0, err
+    }
+    err = query.
+        Where("DATE(start_time) = ? AND end_time IS NULL AND status IN ?", today, services.HeldSessionStatuses).
         Count(&count).Error
     if err != nil {
         return 0, fmt.Errorf("gagal menghitung sesi aktif: %w", err)
@@ -1247,7 +4730,11 @@ func (a *App) GetMostPopularActivity() (string, error) {
     now := time.Now()
     monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
     
-    err := a.database.
+    query, err := a.caseloadSessions(a.database)
+    if err != nil {
+        return "Tidak ada data", err
+    }
+    err = query.
         Table("session_activities").
         Select("activities.name as name, COUNT(*) as count").
         Joins("JOIN activities ON activities.id = session_activities.activity_id").
@@ -1273,8 +4760,12 @@ func (a *App) GetMostPopularActivity() (string, error) {
 func (a *App) GetTodaySessionsCount() (int64, error) {
     var count int64
     today := time.Now().Format("2006-01-02")
-    err := a.database.Model(&model.Session{}).
-        Where("DATE(start_time) = ?", today).
+    query, err := a.caseloadSessions(a.database.Model(&model.Session{}))
+    if err != nil {
+        return 0, err
+    }
+    err = query.
+        Where("DATE(start_time) = ? AND status IN ?", today, append([]string{services.SessionStatusScheduled}, services.HeldSessionStatuses...)).
         Count(&count).Error
     if err != nil {
         return 0, fmt.Errorf("gagal menghitung sesi hari ini: %w", err)
@@ -1290,7 +4781,15 @@ func (a *App) GetDashboardStats() (map[string]interface{}, error) {
     
     // Get total children count
     var childrenCount int64
-    if err := a.database.Model(&model.Child{}).Count(&childrenCount).Error; err != nil {
+    childIDs, scoped, err := a.caseloadChildIDs()
+    if err != nil {
+        return nil, err
+    }
+    childQuery := a.database.Model(&model.Child{})
+    if scoped {
+        childQuery = childQuery.Where("id IN ?", childIDs)
+    }
+    if err := childQuery.Count(&childrenCount).Error; err != nil {
         fmt.Printf("Error counting children: %v\n", err)
         childrenCount = 0
     }
@@ -1320,6 +4819,25 @@ func (a *App) GetDashboardStats() (map[string]interface{}, error) {
     stats["active_sessions"] = activeSessions
     stats["popular_activity"] = popularActivity
     stats["today_sessions"] = todaySessions
+
+    // Consents that need renewing soon
+    expiringConsents, err := a.expiringConsents(services.DefaultConsentExpiryWindowDays)
+    if err != nil {
+        fmt.Printf("Error getting expiring consents: %v\n", err)
+        expiringConsents = []services.ExpiringConsent{}
+    }
+    stats["expiring_consents"] = expiringConsents
+    stats["expiring_consents_count"] = len(expiringConsents)
+
+    // Sessions left open by a previous run that still need resuming or closing
+    if a.can(services.PermSessionRun) {
+        orphans, err := a.GetOrphanedSessions()
+        if err != nil {
+            fmt.Printf("Error getting sessions left open: %v\n", err)
+            orphans = []services.OrphanedSession{}
+        }
+        stats["orphaned_sessions_count"] = len(orphans)
+    }
     stats["last_updated"] = time.Now().Format("2006-01-02 15:04:05")
     
     fmt.Printf("Dashboard stats: %+v\n", stats)
@@ -2,6 +2,9 @@ package db
 
 import (
 	"childSessions/model"
+	"embed"
+	"encoding/json"
+	"errors"
 	"log"
 	"os"
 	"time"
@@ -84,6 +87,7 @@ func RunMigrations(db *gorm.DB) error {
 	// Auto-migrate all models in the correct order to handle foreign key constraints
 	err := db.AutoMigrate(
 		&model.Child{},
+		&model.Therapist{},
 		&model.Session{},
 		&model.Activity{},
 		&model.SessionActivity{},
@@ -93,6 +97,39 @@ func RunMigrations(db *gorm.DB) error {
 		&model.Goal{},
 		&model.Flashcard{},
 		&model.SessionFlashcard{},
+		&model.SessionNoteSection{},
+		&model.NoteRevision{},
+		&model.SessionAddendum{},
+		&model.AuditLog{},
+		&model.AppSetting{},
+		&model.ErasureReceipt{},
+		&model.SessionReviewComment{},
+		&model.Guardian{},
+		&model.ChildDiagnosis{},
+		&model.ChildMedication{},
+		&model.ChildSensitivity{},
+		&model.ClinicalProfileChange{},
+		&model.Attachment{},
+		&model.Consent{},
+		&model.AssessmentInstrument{},
+		&model.AssessmentAdministration{},
+		&model.Milestone{},
+		&model.ChildMilestone{},
+		&model.SessionPlan{},
+		&model.SessionPlanItem{},
+		&model.SessionTemplate{},
+		&model.SessionTemplateActivity{},
+		&model.SessionTemplateRewardRule{},
+		&model.SessionParticipant{},
+		&model.WaitlistEntry{},
+		&model.WaitlistAvailability{},
+		&model.WaitlistEvent{},
+		&model.RateCard{},
+		&model.Invoice{},
+		&model.InvoiceLine{},
+		&model.Payment{},
+		&model.SessionPackage{},
+		&model.PackageUsage{},
 	)
 	if err != nil {
 		return err
@@ -184,6 +221,143 @@ func SeedInitialData(db *gorm.DB) error {
 		}
 	}
 
+	// Seed built-in assessment instruments
+	if err := seedAssessmentInstruments(db); err != nil {
+		return err
+	}
+
+	// Seed the developmental milestone library
+	if err := seedMilestones(db); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+//go:embed instruments/*.json
+var instrumentFiles embed.FS
+
+// seedAssessmentInstruments adds built-in instruments whose code and version are not yet
+// stored. Existing rows are never changed; a revised instrument ships as a new version.
+func seedAssessmentInstruments(db *gorm.DB) error {
+	entries, err := instrumentFiles.ReadDir("instruments")
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		data, err := instrumentFiles.ReadFile("instruments/" + entry.Name())
+		if err != nil {
+			return err
+		}
+		var header struct {
+			Code        string `json:"code"`
+			Version     string `json:"version"`
+			Name        string `json:"name"`
+			Description string `json:"description"`
+		}
+		if err := json.Unmarshal(data, &header); err != nil {
+			return err
+		}
+
+		var existing model.AssessmentInstrument
+		err = db.Unscoped().Where("code = ? AND version = ?", header.Code, header.Version).First(&existing).Error
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		instrument := model.AssessmentInstrument{
+			Code:        header.Code,
+			Version:     header.Version,
+			Name:        header.Name,
+			Description: header.Description,
+			Definition:  string(data),
+			IsBuiltin:   true,
+			IsActive:    true,
+		}
+		if err := db.Create(&instrument).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// milestoneDomainActivities links each milestone domain to the seeded activity that
+// practises it; self-care skills such as buttoning and spoon use are fine motor work.
+var milestoneDomainActivities = map[string]string{
+	"speech":      "Terapi Bicara",
+	"fine_motor":  "Latihan Motorik Halus",
+	"gross_motor": "Latihan Motorik Kasar",
+	"social":      "Terapi Bermain",
+	"self_care":   "Latihan Motorik Halus",
+}
+
+// defaultMilestones is the seeded library, ordered by domain and expected age in months
+var defaultMilestones = []model.Milestone{
+	{Code: "speech_babble", Domain: "speech", Description: "Mengoceh dengan suku kata berulang (ba-ba, ma-ma)", MinAgeMonths: 6, ExpectedAgeMonths: 9},
+	{Code: "speech_first_words", Domain: "speech", Description: "Mengucapkan kata pertama yang bermakna", MinAgeMonths: 10, ExpectedAgeMonths: 15},
+	{Code: "speech_two_words", Domain: "speech", Description: "Menggabungkan dua kata (mis. \"mama makan\")", MinAgeMonths: 18, ExpectedAgeMonths: 24},
+	{Code: "speech_follow_two_step", Domain: "speech", Description: "Mengikuti instruksi dua langkah", MinAgeMonths: 24, ExpectedAgeMonths: 30},
+	{Code: "speech_sentences", Domain: "speech", Description: "Berbicara dengan kalimat 3-4 kata", MinAgeMonths: 30, ExpectedAgeMonths: 36},
+	{Code: "speech_intelligible", Domain: "speech", Description: "Ucapan dapat dipahami orang yang tidak dikenal", MinAgeMonths: 36, ExpectedAgeMonths: 48},
+	{Code: "speech_retell_story", Domain: "speech", Description: "Menceritakan kembali cerita pendek secara berurutan", MinAgeMonths: 48, ExpectedAgeMonths: 60},
+	{Code: "fine_pincer_grasp", Domain: "fine_motor", Description: "Mengambil benda kecil dengan ibu jari dan telunjuk", MinAgeMonths: 8, ExpectedAgeMonths: 12},
+	{Code: "fine_stack_blocks", Domain: "fine_motor", Description: "Menyusun menara 4 balok", MinAgeMonths: 15, ExpectedAgeMonths: 24},
+	{Code: "fine_copy_circle", Domain: "fine_motor", Description: "Meniru menggambar lingkaran", MinAgeMonths: 30, ExpectedAgeMonths: 36},
+	{Code: "fine_use_scissors", Domain: "fine_motor", Description: "Menggunting kertas mengikuti garis lurus", MinAgeMonths: 36, ExpectedAgeMonths: 48},
+	{Code: "fine_copy_triangle", Domain: "fine_motor", Description: "Meniru menggambar segitiga", MinAgeMonths: 48, ExpectedAgeMonths: 60},
+	{Code: "fine_write_name", Domain: "fine_motor", Description: "Menulis nama sendiri", MinAgeMonths: 54, ExpectedAgeMonths: 72},
+	{Code: "gross_sit_unsupported", Domain: "gross_motor", Description: "Duduk tanpa bantuan", MinAgeMonths: 5, ExpectedAgeMonths: 9},
+	{Code: "gross_walk_alone", Domain: "gross_motor", Description: "Berjalan sendiri tanpa berpegangan", MinAgeMonths: 10, ExpectedAgeMonths: 18},
+	{Code: "gross_kick_ball", Domain: "gross_motor", Description: "Menendang bola ke depan", MinAgeMonths: 18, ExpectedAgeMonths: 24},
+	{Code: "gross_jump_two_feet", Domain: "gross_motor", Description: "Melompat dengan dua kaki", MinAgeMonths: 24, ExpectedAgeMonths: 30},
+	{Code: "gross_pedal_tricycle", Domain: "gross_motor", Description: "Mengayuh sepeda roda tiga", MinAgeMonths: 30, ExpectedAgeMonths: 42},
+	{Code: "gross_hop_one_foot", Domain: "gross_motor", Description: "Melompat dengan satu kaki", MinAgeMonths: 42, ExpectedAgeMonths: 54},
+	{Code: "gross_skip", Domain: "gross_motor", Description: "Berjalan sambil melompat-lompat (skipping)", MinAgeMonths: 54, ExpectedAgeMonths: 72},
+	{Code: "social_smile", Domain: "social", Description: "Tersenyum membalas senyuman orang lain", MinAgeMonths: 1, ExpectedAgeMonths: 3},
+	{Code: "social_joint_attention", Domain: "social", Description: "Menunjuk untuk berbagi perhatian pada suatu benda", MinAgeMonths: 9, ExpectedAgeMonths: 15},
+	{Code: "social_pretend_play", Domain: "social", Description: "Bermain pura-pura sederhana (menyuapi boneka)", MinAgeMonths: 15, ExpectedAgeMonths: 24},
+	{Code: "social_parallel_play", Domain: "social", Description: "Bermain di samping anak lain", MinAgeMonths: 18, ExpectedAgeMonths: 30},
+	{Code: "social_take_turns", Domain: "social", Description: "Bergiliran dalam permainan", MinAgeMonths: 30, ExpectedAgeMonths: 42},
+	{Code: "social_cooperative_play", Domain: "social", Description: "Bermain bersama dengan aturan sederhana", MinAgeMonths: 42, ExpectedAgeMonths: 60},
+	{Code: "self_finger_feed", Domain: "self_care", Description: "Makan sendiri dengan jari", MinAgeMonths: 8, ExpectedAgeMonths: 12},
+	{Code: "self_drink_cup", Domain: "self_care", Description: "Minum dari gelas terbuka", MinAgeMonths: 12, ExpectedAgeMonths: 18},
+	{Code: "self_use_spoon", Domain: "self_care", Description: "Makan sendiri dengan sendok", MinAgeMonths: 15, ExpectedAgeMonths: 24},
+	{Code: "self_undress", Domain: "self_care", Description: "Melepas pakaian sederhana sendiri", MinAgeMonths: 24, ExpectedAgeMonths: 36},
+	{Code: "self_toilet_day", Domain: "self_care", Description: "Menggunakan toilet pada siang hari", MinAgeMonths: 24, ExpectedAgeMonths: 42},
+	{Code: "self_dress", Domain: "self_care", Description: "Berpakaian sendiri termasuk mengancingkan baju", MinAgeMonths: 42, ExpectedAgeMonths: 60},
+}
+
+// seedMilestones adds library milestones whose code is not yet stored
+func seedMilestones(db *gorm.DB) error {
+	activityIDs := make(map[string]uint)
+	var activities []model.Activity
+	if err := db.Find(&activities).Error; err != nil {
+		return err
+	}
+	for _, activity := range activities {
+		activityIDs[activity.Name] = activity.ID
+	}
+
+	for i, milestone := range defaultMilestones {
+		var count int64
+		if err := db.Unscoped().Model(&model.Milestone{}).Where("code = ?", milestone.Code).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+		if id, ok := activityIDs[milestoneDomainActivities[milestone.Domain]]; ok {
+			milestone.ActivityID = &id
+		}
+		milestone.SortOrder = i + 1
+		if err := db.Create(&milestone).Error; err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
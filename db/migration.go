@@ -4,6 +4,8 @@ import (
 	"childSessions/model"
 	"fmt"
 	"log"
+	"regexp"
+	"strings"
 
 	"gorm.io/gorm"
 )
@@ -57,6 +59,144 @@ func GetAllMigrations() []MigrationInfo {
             Up:          migration005Up,
             Down:        migration005Down,
         },
+        {
+            Version:     "006_add_structured_session_notes",
+            Description: "Add note format to sessions and structured note sections table",
+            Up:          migration006Up,
+            Down:        migration006Down,
+        },
+        {
+            Version:     "007_create_note_revisions",
+            Description: "Create immutable note revisions table",
+            Up:          migration007Up,
+            Down:        migration007Down,
+        },
+        {
+            Version:     "008_add_session_finalization",
+            Description: "Add finalisation columns to sessions and session addenda table",
+            Up:          migration008Up,
+            Down:        migration008Down,
+        },
+        {
+            Version:     "009_create_audit_logs",
+            Description: "Create append-only audit log table",
+            Up:          migration009Up,
+            Down:        migration009Down,
+        },
+        {
+            Version:     "010_create_app_settings",
+            Description: "Create app settings table",
+            Up:          migration010Up,
+            Down:        migration010Down,
+        },
+        {
+            Version:     "011_create_erasure_receipts",
+            Description: "Create erasure receipts table",
+            Up:          migration011Up,
+            Down:        migration011Down,
+        },
+        {
+            Version:     "012_create_therapists",
+            Description: "Create therapist accounts, caseload assignments and acting-therapist columns",
+            Up:          migration012Up,
+            Down:        migration012Down,
+        },
+        {
+            Version:     "013_add_session_review",
+            Description: "Add supervisor review columns and session review comments table",
+            Up:          migration013Up,
+            Down:        migration013Down,
+        },
+        {
+            Version:     "014_create_guardians",
+            Description: "Create guardians table and split legacy parent/contact fields into it",
+            Up:          migration014Up,
+            Down:        migration014Down,
+        },
+        {
+            Version:     "015_create_clinical_profile",
+            Description: "Add school/referral source to children and diagnoses, medications, sensitivities and profile history tables",
+            Up:          migration015Up,
+            Down:        migration015Down,
+        },
+        {
+            Version:     "016_create_attachments",
+            Description: "Create attachments table for documents linked to children and sessions",
+            Up:          migration016Up,
+            Down:        migration016Down,
+        },
+        {
+            Version:     "017_create_consents",
+            Description: "Create consents table and link flashcards to the child shown in their photo",
+            Up:          migration017Up,
+            Down:        migration017Down,
+        },
+        {
+            Version:     "018_create_assessments",
+            Description: "Create assessment instrument and administration tables",
+            Up:          migration018Up,
+            Down:        migration018Down,
+        },
+        {
+            Version:     "019_create_milestones",
+            Description: "Create milestone library and per-child milestone status tables",
+            Up:          migration019Up,
+            Down:        migration019Down,
+        },
+        {
+            Version:     "020_create_session_plans",
+            Description: "Create session plan and planned activity tables",
+            Up:          migration020Up,
+            Down:        migration020Down,
+        },
+        {
+            Version:     "021_create_session_templates",
+            Description: "Create versioned session template tables and link plans to their template",
+            Up:          migration021Up,
+            Down:        migration021Down,
+        },
+        {
+            Version:     "022_add_group_sessions",
+            Description: "Add group session columns, session participants and per-child notes",
+            Up:          migration022Up,
+            Down:        migration022Down,
+        },
+        {
+            Version:     "023_add_session_status",
+            Description: "Add session status, cancellation reasons and scheduled appointments",
+            Up:          migration023Up,
+            Down:        migration023Down,
+        },
+        {
+            Version:     "024_create_waitlist",
+            Description: "Create waitlist entry, availability and referral history tables",
+            Up:          migration024Up,
+            Down:        migration024Down,
+        },
+        {
+            Version:     "025_add_session_resumed_at",
+            Description: "Add resumed_at to sessions for recovering sessions left open",
+            Up:          migration025Up,
+            Down:        migration025Down,
+        },
+        {
+            Version:     "026_add_session_entered_afterwards",
+            Description: "Mark sessions recorded after they took place",
+            Up:          migration026Up,
+            Down:        migration026Down,
+        },
+        {
+            Version:     "027_create_billing",
+            Description: "Create rate card, invoice and payment tables and add therapy_type to sessions",
+            Up:          migration027Up,
+            Down:        migration027Down,
+        },
+        {
+            Version:     "028_create_session_packages",
+            Description: "Create prepaid session package and package usage tables",
+            Up:          migration028Up,
+            Down:        migration028Down,
+        },
     }
 }
 
@@ -406,4 +546,682 @@ func migration005Down(db *gorm.DB) error {
     }
 
     return nil
-}
\ No newline at end of file
+}
+
+// Migration 006: Structured (SOAP/DAP) session notes
+func migration006Up(db *gorm.DB) error {
+    // Add note_format column to sessions table
+    if err := db.AutoMigrate(&model.Session{}); err != nil {
+        return err
+    }
+
+    // Create session_note_sections table
+    if err := db.AutoMigrate(&model.SessionNoteSection{}); err != nil {
+        return err
+    }
+
+    // Existing sessions keep their free-text summary
+    if err := db.Exec("UPDATE sessions SET note_format = 'free' WHERE note_format IS NULL OR note_format = ''").Error; err != nil {
+        return err
+    }
+
+    return nil
+}
+
+func migration006Down(db *gorm.DB) error {
+    if err := db.Migrator().DropTable(&model.SessionNoteSection{}); err != nil {
+        return err
+    }
+    if db.Migrator().HasColumn(&model.Session{}, "note_format") {
+        if err := db.Migrator().DropColumn(&model.Session{}, "note_format"); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// Migration 007: Note revision history
+func migration007Up(db *gorm.DB) error {
+    // Create note_revisions table
+    if err := db.AutoMigrate(&model.NoteRevision{}); err != nil {
+        return err
+    }
+
+    // Revisions are immutable once written
+    if err := db.Exec(`CREATE TRIGGER IF NOT EXISTS trg_note_revisions_no_update
+        BEFORE UPDATE ON note_revisions
+        BEGIN
+            SELECT RAISE(ABORT, 'note revisions are immutable');
+        END`).Error; err != nil {
+        return err
+    }
+
+    // Seed a first revision for notes and summaries written before revisions existed
+    if err := db.Exec(`INSERT INTO note_revisions (created_at, entity_type, entity_id, session_id, revision, action, text, category, author, reason)
+        SELECT COALESCE(updated_at, created_at), 'note', id, session_id, 1, 'created', note_text, category, '', 'Revisi awal dari data yang sudah ada'
+        FROM notes WHERE deleted_at IS NULL`).Error; err != nil {
+        return err
+    }
+    if err := db.Exec(`INSERT INTO note_revisions (created_at, entity_type, entity_id, session_id, revision, action, text, category, author, reason)
+        SELECT COALESCE(updated_at, created_at), 'session_summary', id, id, 1, 'created', summary_notes, '', '', 'Revisi awal dari data yang sudah ada'
+        FROM sessions WHERE deleted_at IS NULL AND summary_notes IS NOT NULL AND summary_notes <> ''`).Error; err != nil {
+        return err
+    }
+
+    return nil
+}
+
+func migration007Down(db *gorm.DB) error {
+    if err := db.Exec("DROP TRIGGER IF EXISTS trg_note_revisions_no_update").Error; err != nil {
+        return err
+    }
+    if err := db.Migrator().DropTable(&model.NoteRevision{}); err != nil {
+        return err
+    }
+    return nil
+}
+
+// Migration 008: Session finalisation and addenda
+func migration008Up(db *gorm.DB) error {
+    // Add finalized_at and finalized_by columns to sessions table
+    if err := db.AutoMigrate(&model.Session{}); err != nil {
+        return err
+    }
+
+    // Create session_addenda table
+    if err := db.AutoMigrate(&model.SessionAddendum{}); err != nil {
+        return err
+    }
+
+    if err := db.Exec("CREATE INDEX IF NOT EXISTS idx_sessions_finalized_at ON sessions(finalized_at)").Error; err != nil {
+        return err
+    }
+
+    return nil
+}
+
+func migration008Down(db *gorm.DB) error {
+    if err := db.Exec("DROP INDEX IF EXISTS idx_sessions_finalized_at").Error; err != nil {
+        return err
+    }
+    if err := db.Migrator().DropTable(&model.SessionAddendum{}); err != nil {
+        return err
+    }
+    for _, column := range []string{"finalized_at", "finalized_by"} {
+        if db.Migrator().HasColumn(&model.Session{}, column) {
+            if err := db.Migrator().DropColumn(&model.Session{}, column); err != nil {
+                return err
+            }
+        }
+    }
+    return nil
+}
+
+// Migration 009: Append-only audit log
+func migration009Up(db *gorm.DB) error {
+    // Create audit_logs table
+    if err := db.AutoMigrate(&model.AuditLog{}); err != nil {
+        return err
+    }
+
+    // Entries can only be appended; the hash chain detects changes made around these triggers
+    if err := db.Exec(`CREATE TRIGGER IF NOT EXISTS trg_audit_logs_no_update
+        BEFORE UPDATE ON audit_logs
+        BEGIN
+            SELECT RAISE(ABORT, 'audit log is append-only');
+        END`).Error; err != nil {
+        return err
+    }
+    if err := db.Exec(`CREATE TRIGGER IF NOT EXISTS trg_audit_logs_no_delete
+        BEFORE DELETE ON audit_logs
+        BEGIN
+            SELECT RAISE(ABORT, 'audit log is append-only');
+        END`).Error; err != nil {
+        return err
+    }
+
+    return nil
+}
+
+func migration009Down(db *gorm.DB) error {
+    for _, trigger := range []string{"trg_audit_logs_no_update", "trg_audit_logs_no_delete"} {
+        if err := db.Exec(fmt.Sprintf("DROP TRIGGER IF EXISTS %s", trigger)).Error; err != nil {
+            return err
+        }
+    }
+    if err := db.Migrator().DropTable(&model.AuditLog{}); err != nil {
+        return err
+    }
+    return nil
+}
+
+// Migration 010: Application settings
+func migration010Up(db *gorm.DB) error {
+    // Create app_settings table
+    if err := db.AutoMigrate(&model.AppSetting{}); err != nil {
+        return err
+    }
+
+    // Soft-deleted children used to leave their sessions behind; hide those sessions too
+    if err := db.Exec(`UPDATE sessions SET deleted_at = (SELECT children.deleted_at FROM children WHERE children.id = sessions.child_id)
+        WHERE deleted_at IS NULL AND child_id IN (SELECT id FROM children WHERE deleted_at IS NOT NULL)`).Error; err != nil {
+        return err
+    }
+
+    return nil
+}
+
+func migration010Down(db *gorm.DB) error {
+    if err := db.Migrator().DropTable(&model.AppSetting{}); err != nil {
+        return err
+    }
+    return nil
+}
+
+// Migration 011: Erasure receipts
+func migration011Up(db *gorm.DB) error {
+    // Create erasure_receipts table
+    if err := db.AutoMigrate(&model.ErasureReceipt{}); err != nil {
+        return err
+    }
+
+    // Receipts are evidence; they are never edited or removed
+    if err := db.Exec(`CREATE TRIGGER IF NOT EXISTS trg_erasure_receipts_no_update
+        BEFORE UPDATE ON erasure_receipts
+        BEGIN
+            SELECT RAISE(ABORT, 'erasure receipts are immutable');
+        END`).Error; err != nil {
+        return err
+    }
+    if err := db.Exec(`CREATE TRIGGER IF NOT EXISTS trg_erasure_receipts_no_delete
+        BEFORE DELETE ON erasure_receipts
+        BEGIN
+            SELECT RAISE(ABORT, 'erasure receipts are immutable');
+        END`).Error; err != nil {
+        return err
+    }
+
+    return nil
+}
+
+func migration011Down(db *gorm.DB) error {
+    for _, trigger := range []string{"trg_erasure_receipts_no_update", "trg_erasure_receipts_no_delete"} {
+        if err := db.Exec(fmt.Sprintf("DROP TRIGGER IF EXISTS %s", trigger)).Error; err != nil {
+            return err
+        }
+    }
+    if err := db.Migrator().DropTable(&model.ErasureReceipt{}); err != nil {
+        return err
+    }
+    return nil
+}
+
+// Migration 012: Therapist accounts and caseloads
+func migration012Up(db *gorm.DB) error {
+    // Create therapists table
+    if err := db.AutoMigrate(&model.Therapist{}); err != nil {
+        return err
+    }
+
+    // Create child_therapists join table
+    if err := db.AutoMigrate(&model.Child{}); err != nil {
+        return err
+    }
+
+    // Add therapist_id columns; existing records predate accounts and stay unassigned
+    if err := db.AutoMigrate(&model.Session{}, &model.Note{}, &model.Reward{}); err != nil {
+        return err
+    }
+
+    if err := db.Exec("CREATE INDEX IF NOT EXISTS idx_child_therapists_therapist_id ON child_therapists(therapist_id)").Error; err != nil {
+        return err
+    }
+
+    return nil
+}
+
+func migration012Down(db *gorm.DB) error {
+    if err := db.Migrator().DropTable("child_therapists"); err != nil {
+        return err
+    }
+    for _, table := range []interface{}{&model.Session{}, &model.Note{}, &model.Reward{}} {
+        if db.Migrator().HasColumn(table, "therapist_id") {
+            if err := db.Migrator().DropColumn(table, "therapist_id"); err != nil {
+                return err
+            }
+        }
+    }
+    if err := db.Migrator().DropTable(&model.Therapist{}); err != nil {
+        return err
+    }
+    return nil
+}
+
+// Migration 013: Supervisor review and co-signature
+func migration013Up(db *gorm.DB) error {
+    // Add requires_review to therapists and review columns to sessions
+    if err := db.AutoMigrate(&model.Therapist{}, &model.Session{}); err != nil {
+        return err
+    }
+
+    // Create session_review_comments table
+    if err := db.AutoMigrate(&model.SessionReviewComment{}); err != nil {
+        return err
+    }
+
+    return nil
+}
+
+func migration013Down(db *gorm.DB) error {
+    if err := db.Migrator().DropTable(&model.SessionReviewComment{}); err != nil {
+        return err
+    }
+    for _, column := range []string{"review_status", "cosigned_at", "cosigned_by"} {
+        if db.Migrator().HasColumn(&model.Session{}, column) {
+            if err := db.Migrator().DropColumn(&model.Session{}, column); err != nil {
+                return err
+            }
+        }
+    }
+    if db.Migrator().HasColumn(&model.Therapist{}, "requires_review") {
+        if err := db.Migrator().DropColumn(&model.Therapist{}, "requires_review"); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// Migration 014: Structured guardian records
+func migration014Up(db *gorm.DB) error {
+    // Create guardians table
+    if err := db.AutoMigrate(&model.Guardian{}); err != nil {
+        return err
+    }
+
+    // Turn each child's ParentGuardianName/ContactInfo into a primary guardian record.
+    // Soft-deleted children are included so a restored child keeps its contacts.
+    var children []model.Child
+    if err := db.Unscoped().
+        Where("(parent_guardian_name IS NOT NULL AND parent_guardian_name <> '') OR (contact_info IS NOT NULL AND contact_info <> '')").
+        Where("id NOT IN (SELECT child_id FROM guardians)").
+        Find(&children).Error; err != nil {
+        return err
+    }
+    for _, child := range children {
+        guardian := splitLegacyGuardian(child)
+        if err := db.Create(&guardian).Error; err != nil {
+            return err
+        }
+    }
+
+    return nil
+}
+
+func migration014Down(db *gorm.DB) error {
+    if err := db.Migrator().DropTable(&model.Guardian{}); err != nil {
+        return err
+    }
+    return nil
+}
+
+// Migration 015: Structured clinical profile
+func migration015Up(db *gorm.DB) error {
+    // Add school and referral_source columns to children
+    if err := db.AutoMigrate(&model.Child{}); err != nil {
+        return err
+    }
+
+    if err := db.AutoMigrate(
+        &model.ChildDiagnosis{},
+        &model.ChildMedication{},
+        &model.ChildSensitivity{},
+        &model.ClinicalProfileChange{},
+    ); err != nil {
+        return err
+    }
+
+    // Profile history is immutable once written
+    if err := db.Exec(`CREATE TRIGGER IF NOT EXISTS trg_clinical_profile_changes_no_update
+        BEFORE UPDATE ON clinical_profile_changes
+        BEGIN
+            SELECT RAISE(ABORT, 'clinical profile history is immutable');
+        END`).Error; err != nil {
+        return err
+    }
+
+    return nil
+}
+
+func migration015Down(db *gorm.DB) error {
+    if err := db.Exec("DROP TRIGGER IF EXISTS trg_clinical_profile_changes_no_update").Error; err != nil {
+        return err
+    }
+    if err := db.Migrator().DropTable(
+        &model.ClinicalProfileChange{},
+        &model.ChildSensitivity{},
+        &model.ChildMedication{},
+        &model.ChildDiagnosis{},
+    ); err != nil {
+        return err
+    }
+    for _, column := range []string{"School", "ReferralSource"} {
+        if db.Migrator().HasColumn(&model.Child{}, column) {
+            if err := db.Migrator().DropColumn(&model.Child{}, column); err != nil {
+                return err
+            }
+        }
+    }
+    return nil
+}
+
+// Migration 016: Attachments
+func migration016Up(db *gorm.DB) error {
+    // Create attachments table
+    if err := db.AutoMigrate(&model.Attachment{}); err != nil {
+        return err
+    }
+    return nil
+}
+
+func migration016Down(db *gorm.DB) error {
+    if err := db.Migrator().DropTable(&model.Attachment{}); err != nil {
+        return err
+    }
+    return nil
+}
+
+// Migration 017: Consents
+func migration017Up(db *gorm.DB) error {
+    // Create consents table
+    if err := db.AutoMigrate(&model.Consent{}); err != nil {
+        return err
+    }
+
+    // Add child_id to flashcards for photos of a child
+    if err := db.AutoMigrate(&model.Flashcard{}); err != nil {
+        return err
+    }
+    return nil
+}
+
+func migration017Down(db *gorm.DB) error {
+    if db.Migrator().HasIndex(&model.Flashcard{}, "ChildID") {
+        if err := db.Migrator().DropIndex(&model.Flashcard{}, "ChildID"); err != nil {
+            return err
+        }
+    }
+    if db.Migrator().HasColumn(&model.Flashcard{}, "ChildID") {
+        if err := db.Migrator().DropColumn(&model.Flashcard{}, "ChildID"); err != nil {
+            return err
+        }
+    }
+    if err := db.Migrator().DropTable(&model.Consent{}); err != nil {
+        return err
+    }
+    return nil
+}
+
+// Migration 018: Assessment instruments and administrations
+func migration018Up(db *gorm.DB) error {
+    if err := db.AutoMigrate(&model.AssessmentInstrument{}, &model.AssessmentAdministration{}); err != nil {
+        return err
+    }
+    return nil
+}
+
+func migration018Down(db *gorm.DB) error {
+    if err := db.Migrator().DropTable(&model.AssessmentAdministration{}, &model.AssessmentInstrument{}); err != nil {
+        return err
+    }
+    return nil
+}
+
+// Migration 019: Developmental milestones
+func migration019Up(db *gorm.DB) error {
+    if err := db.AutoMigrate(&model.Milestone{}, &model.ChildMilestone{}); err != nil {
+        return err
+    }
+    return nil
+}
+
+func migration019Down(db *gorm.DB) error {
+    if err := db.Migrator().DropTable(&model.ChildMilestone{}, &model.Milestone{}); err != nil {
+        return err
+    }
+    return nil
+}
+
+// Migration 020: Session plans
+func migration020Up(db *gorm.DB) error {
+    if err := db.AutoMigrate(&model.SessionPlan{}, &model.SessionPlanItem{}); err != nil {
+        return err
+    }
+    return nil
+}
+
+func migration020Down(db *gorm.DB) error {
+    if err := db.Migrator().DropTable(&model.SessionPlanItem{}, &model.SessionPlan{}); err != nil {
+        return err
+    }
+    return nil
+}
+
+// Migration 021: Session templates
+func migration021Up(db *gorm.DB) error {
+    if err := db.AutoMigrate(&model.SessionTemplate{}, &model.SessionTemplateActivity{}, &model.SessionTemplateRewardRule{}); err != nil {
+        return err
+    }
+
+    // Add template_id to session plans
+    if err := db.AutoMigrate(&model.SessionPlan{}); err != nil {
+        return err
+    }
+    return nil
+}
+
+func migration021Down(db *gorm.DB) error {
+    if db.Migrator().HasIndex(&model.SessionPlan{}, "TemplateID") {
+        if err := db.Migrator().DropIndex(&model.SessionPlan{}, "TemplateID"); err != nil {
+            return err
+        }
+    }
+    if db.Migrator().HasColumn(&model.SessionPlan{}, "TemplateID") {
+        if err := db.Migrator().DropColumn(&model.SessionPlan{}, "TemplateID"); err != nil {
+            return err
+        }
+    }
+    if err := db.Migrator().DropTable("session_template_note_templates"); err != nil {
+        return err
+    }
+    if err := db.Migrator().DropTable(&model.SessionTemplateRewardRule{}, &model.SessionTemplateActivity{}, &model.SessionTemplate{}); err != nil {
+        return err
+    }
+    return nil
+}
+
+// Migration 022: Group sessions
+func migration022Up(db *gorm.DB) error {
+    // Add is_group and group_name to sessions; existing sessions are individual
+    if err := db.AutoMigrate(&model.Session{}); err != nil {
+        return err
+    }
+    if err := db.Exec("UPDATE sessions SET is_group = ? WHERE is_group IS NULL", false).Error; err != nil {
+        return err
+    }
+
+    // Create session participants table
+    if err := db.AutoMigrate(&model.SessionParticipant{}); err != nil {
+        return err
+    }
+
+    // Add child_id to notes for notes about one child in a group
+    if err := db.AutoMigrate(&model.Note{}); err != nil {
+        return err
+    }
+    return nil
+}
+
+func migration022Down(db *gorm.DB) error {
+    if db.Migrator().HasIndex(&model.Note{}, "ChildID") {
+        if err := db.Migrator().DropIndex(&model.Note{}, "ChildID"); err != nil {
+            return err
+        }
+    }
+    if db.Migrator().HasColumn(&model.Note{}, "ChildID") {
+        if err := db.Migrator().DropColumn(&model.Note{}, "ChildID"); err != nil {
+            return err
+        }
+    }
+    if err := db.Migrator().DropTable(&model.SessionParticipant{}); err != nil {
+        return err
+    }
+    for _, column := range []string{"IsGroup", "GroupName"} {
+        if db.Migrator().HasColumn(&model.Session{}, column) {
+            if err := db.Migrator().DropColumn(&model.Session{}, column); err != nil {
+                return err
+            }
+        }
+    }
+    return nil
+}
+
+// Migration 023: Add session status
+func migration023Up(db *gorm.DB) error {
+    if err := db.AutoMigrate(&model.Session{}); err != nil {
+        return err
+    }
+    // Every session recorded so far took place
+    return db.Exec("UPDATE sessions SET status = ? WHERE status IS NULL OR status = ''", "attended").Error
+}
+
+func migration023Down(db *gorm.DB) error {
+    if db.Migrator().HasIndex(&model.Session{}, "Status") {
+        if err := db.Migrator().DropIndex(&model.Session{}, "Status"); err != nil {
+            return err
+        }
+    }
+    for _, column := range []string{"Status", "StatusReason", "ScheduledFor", "LateMinutes"} {
+        if db.Migrator().HasColumn(&model.Session{}, column) {
+            if err := db.Migrator().DropColumn(&model.Session{}, column); err != nil {
+                return err
+            }
+        }
+    }
+    return nil
+}
+
+// Migration 024: Create waitlist
+func migration024Up(db *gorm.DB) error {
+    return db.AutoMigrate(&model.WaitlistEntry{}, &model.WaitlistAvailability{}, &model.WaitlistEvent{})
+}
+
+func migration024Down(db *gorm.DB) error {
+    for _, table := range []interface{}{&model.WaitlistEvent{}, &model.WaitlistAvailability{}, &model.WaitlistEntry{}} {
+        if db.Migrator().HasTable(table) {
+            if err := db.Migrator().DropTable(table); err != nil {
+                return err
+            }
+        }
+    }
+    return nil
+}
+
+// Migration 025: Add session resumed_at
+func migration025Up(db *gorm.DB) error {
+    return db.AutoMigrate(&model.Session{})
+}
+
+func migration025Down(db *gorm.DB) error {
+    if db.Migrator().HasColumn(&model.Session{}, "ResumedAt") {
+        return db.Migrator().DropColumn(&model.Session{}, "ResumedAt")
+    }
+    return nil
+}
+
+// Migration 026: Add session entered_afterwards
+func migration026Up(db *gorm.DB) error {
+    if err := db.AutoMigrate(&model.Session{}); err != nil {
+        return err
+    }
+    // Every session so far was recorded live
+    return db.Exec("UPDATE sessions SET entered_afterwards = ? WHERE entered_afterwards IS NULL", false).Error
+}
+
+func migration026Down(db *gorm.DB) error {
+    if db.Migrator().HasColumn(&model.Session{}, "EnteredAfterwards") {
+        return db.Migrator().DropColumn(&model.Session{}, "EnteredAfterwards")
+    }
+    return nil
+}
+
+// Migration 027: Create billing
+func migration027Up(db *gorm.DB) error {
+    // Add therapy_type to sessions; appointments made from a referral take its therapy type
+    if err := db.AutoMigrate(&model.Session{}); err != nil {
+        return err
+    }
+    if err := db.Exec(`UPDATE sessions SET therapy_type = (
+        SELECT waitlist_entries.therapy_type FROM waitlist_entries WHERE waitlist_entries.session_id = sessions.id
+    ) WHERE (therapy_type IS NULL OR therapy_type = '') AND id IN (SELECT session_id FROM waitlist_entries WHERE session_id IS NOT NULL)`).Error; err != nil {
+        return err
+    }
+
+    return db.AutoMigrate(&model.RateCard{}, &model.Invoice{}, &model.InvoiceLine{}, &model.Payment{})
+}
+
+func migration027Down(db *gorm.DB) error {
+    if err := db.Migrator().DropTable(&model.Payment{}, &model.InvoiceLine{}, &model.Invoice{}, &model.RateCard{}); err != nil {
+        return err
+    }
+    if db.Migrator().HasColumn(&model.Session{}, "TherapyType") {
+        return db.Migrator().DropColumn(&model.Session{}, "TherapyType")
+    }
+    return nil
+}
+
+// Migration 028: Create session packages
+func migration028Up(db *gorm.DB) error {
+    return db.AutoMigrate(&model.SessionPackage{}, &model.PackageUsage{})
+}
+
+func migration028Down(db *gorm.DB) error {
+    return db.Migrator().DropTable(&model.PackageUsage{}, &model.SessionPackage{})
+}
+
+var (
+    legacyEmailPattern     = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+    legacyPhonePattern     = regexp.MustCompile(`\+?[0-9][0-9\s\-().]{6,}[0-9]`)
+    legacyPhoneSeparators  = regexp.MustCompile(`[\s\-().]`)
+    legacyPhoneValidLength = regexp.MustCompile(`^\+?[0-9]{8,15}$`)
+)
+
+// splitLegacyGuardian pulls an email address and phone number out of the free-text
+// contact field; whatever remains is kept in the guardian's notes. The logic is a frozen
+// copy of what the services use so this migration never changes behaviour.
+func splitLegacyGuardian(child model.Child) model.Guardian {
+    guardian := model.Guardian{
+        ChildID:          child.ID,
+        Name:             strings.TrimSpace(child.ParentGuardianName),
+        Relationship:     "guardian",
+        IsPrimary:        true,
+        ConsentToContact: true,
+    }
+    if guardian.Name == "" {
+        guardian.Name = "Wali"
+    }
+
+    rest := child.ContactInfo
+    if match := legacyEmailPattern.FindString(rest); match != "" {
+        guardian.Email = strings.ToLower(match)
+        rest = strings.Replace(rest, match, "", 1)
+    }
+    if match := legacyPhonePattern.FindString(rest); match != "" {
+        phone := legacyPhoneSeparators.ReplaceAllString(match, "")
+        if legacyPhoneValidLength.MatchString(phone) {
+            guardian.Phone = phone
+            rest = strings.Replace(rest, match, "", 1)
+        }
+    }
+    guardian.Notes = strings.TrimSpace(strings.Trim(strings.TrimSpace(rest), ",;/|-"))
+    return guardian
+}
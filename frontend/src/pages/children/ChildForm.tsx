@@ -38,7 +38,8 @@ export function ChildForm({ child, onSuccess, onCancel }: ChildFormProps) {
           gender,
           parentGuardianName,
           contactInfo,
-          initialAssessment
+          initialAssessment,
+          dateOfBirth
         )
       } else {
         // Create new child
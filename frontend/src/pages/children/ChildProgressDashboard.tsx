@@ -15,8 +15,8 @@ import {
   GetChildNoteKeywordFrequency,
   GetChildRewardTrends,
   GetChildRewards,
-  ExportCSVFile,
-  ExportPDFFile,
+  ExportChildReportCSV,
+  ExportChildReportPDF,
   OpenFileInExplorer,
   ShowNotification,
 } from "../../../wailsjs/go/main/App"
@@ -429,7 +429,11 @@ export function ChildProgressDashboard() {
         /[^a-zA-Z0-9]/g,
         "_"
       )}_${new Date().toISOString().split("T")[0]}.pdf`
-      const savedPath = await ExportPDFFile(Array.from(uint8Array), filename)
+      const savedPath = await ExportChildReportPDF(
+        selectedChild.ID,
+        Array.from(uint8Array),
+        filename
+      )
 
       toast.dismiss()
       toast.success("PDF Berhasil Diekspor!", {
@@ -644,7 +648,11 @@ export function ChildProgressDashboard() {
       }.csv`
 
       // Use Wails export function
-      const savedPath = await ExportCSVFile(csv, filename)
+      const savedPath = await ExportChildReportCSV(
+        selectedChild.ID,
+        csv,
+        filename
+      )
 
       // Dismiss loading toast
       toast.dismiss(loadingToastId)
@@ -34,9 +34,9 @@ export function EndActivityInSession(arg1:number,arg2:string):Promise<model.Sess
 
 export function EndSession(arg1:number,arg2:string):Promise<model.Session>;
 
-export function ExportCSVFile(arg1:string,arg2:string):Promise<string>;
+export function ExportChildReportCSV(arg1:number,arg2:string,arg3:string):Promise<string>;
 
-export function ExportPDFFile(arg1:Array<number>,arg2:string):Promise<string>;
+export function ExportChildReportPDF(arg1:number,arg2:Array<number>,arg3:string):Promise<string>;
 
 export function GenerateSessionSummary(arg1:number):Promise<Record<string, any>>;
 
@@ -110,7 +110,7 @@ export function UpdateActivity(arg1:number,arg2:string,arg3:string,arg4:number,a
 
 export function UpdateActivityInSession(arg1:number,arg2:string):Promise<model.SessionActivity>;
 
-export function UpdateChild(arg1:number,arg2:string,arg3:string,arg4:string,arg5:string,arg6:string):Promise<model.Child>;
+export function UpdateChild(arg1:number,arg2:string,arg3:string,arg4:string,arg5:string,arg6:string,arg7:string):Promise<model.Child>;
 
 export function UpdateNote(arg1:number,arg2:string,arg3:string):Promise<model.Note>;
 
@@ -66,12 +66,12 @@ export function EndSession(arg1, arg2) {
   return window['go']['main']['App']['EndSession'](arg1, arg2);
 }
 
-export function ExportCSVFile(arg1, arg2) {
-  return window['go']['main']['App']['ExportCSVFile'](arg1, arg2);
+export function ExportChildReportCSV(arg1, arg2, arg3) {
+  return window['go']['main']['App']['ExportChildReportCSV'](arg1, arg2, arg3);
 }
 
-export function ExportPDFFile(arg1, arg2) {
-  return window['go']['main']['App']['ExportPDFFile'](arg1, arg2);
+export function ExportChildReportPDF(arg1, arg2, arg3) {
+  return window['go']['main']['App']['ExportChildReportPDF'](arg1, arg2, arg3);
 }
 
 export function GenerateSessionSummary(arg1) {
@@ -218,8 +218,8 @@ export function UpdateActivityInSession(arg1, arg2) {
   return window['go']['main']['App']['UpdateActivityInSession'](arg1, arg2);
 }
 
-export function UpdateChild(arg1, arg2, arg3, arg4, arg5, arg6) {
-  return window['go']['main']['App']['UpdateChild'](arg1, arg2, arg3, arg4, arg5, arg6);
+export function UpdateChild(arg1, arg2, arg3, arg4, arg5, arg6, arg7) {
+  return window['go']['main']['App']['UpdateChild'](arg1, arg2, arg3, arg4, arg5, arg6, arg7);
 }
 
 export function UpdateNote(arg1, arg2, arg3) {
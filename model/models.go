@@ -16,9 +16,447 @@ type Child struct {
 	ParentGuardianName  string
 	ContactInfo         string
 	InitialAssessment   string 
+	School              string
+	ReferralSource      string // Who referred the family, e.g. a paediatrician or school
 	Sessions            []Session `gorm:"foreignKey:ChildID"` 
 	Rewards             []Reward  `gorm:"foreignKey:ChildID"` 
 	Goals               []Goal    `gorm:"foreignKey:ChildID"` 
+	Therapists          []Therapist `gorm:"many2many:child_therapists"` // Therapists whose caseload includes this child
+	Guardians           []Guardian  `gorm:"foreignKey:ChildID"` // Parents, guardians and emergency contacts
+	Age                 *Age        `gorm:"-"` // Chronological age today; nil without a date of birth
+}
+
+// ChildDiagnosis represents the 'child_diagnoses' table.
+type ChildDiagnosis struct {
+	gorm.Model
+
+	ChildID     uint   `gorm:"not null;index"`
+	ICD10Code   string `gorm:"column:icd10_code"` // e.g., "F84.0"
+	Description string `gorm:"not null"`
+	DiagnosedOn *time.Time
+	DiagnosedBy string
+	Status      string `gorm:"not null;default:'active'"` // "active", "resolved" or "ruled_out"
+	Notes       string
+}
+
+// ChildMedication represents the 'child_medications' table; a medication is
+// current while EndedOn is empty.
+type ChildMedication struct {
+	gorm.Model
+
+	ChildID      uint   `gorm:"not null;index"`
+	Name         string `gorm:"not null"`
+	Dosage       string
+	Frequency    string
+	StartedOn    *time.Time
+	EndedOn      *time.Time
+	PrescribedBy string
+	Notes        string
+}
+
+// ChildSensitivity represents the 'child_sensitivities' table: allergies and
+// sensory sensitivities that therapists must plan around.
+type ChildSensitivity struct {
+	gorm.Model
+
+	ChildID  uint   `gorm:"not null;index"`
+	Kind     string `gorm:"not null"` // "allergy" or "sensory"
+	Trigger  string `gorm:"not null"` // e.g., "kacang", "suara keras"
+	Keywords string // Comma-separated extra words matched against activities, e.g. "musik,drum"
+	Severity string `gorm:"not null;default:'moderate'"` // "mild", "moderate" or "severe"
+	Reaction string
+	Notes    string
+}
+
+// ClinicalProfileChange represents the 'clinical_profile_changes' table, an
+// immutable history of every change to a child's clinical profile.
+type ClinicalProfileChange struct {
+	ID        uint      `gorm:"primaryKey"`
+	CreatedAt time.Time `gorm:"not null"`
+
+	ChildID    uint   `gorm:"not null;index"`
+	EntityType string `gorm:"not null"` // "background", "diagnosis", "medication" or "sensitivity"
+	EntityID   uint
+	Action     string `gorm:"not null"` // "created", "updated" or "deleted"
+	Before     string // JSON snapshot before the change
+	After      string // JSON snapshot after the change
+	Author     string
+}
+
+// Attachment represents the 'attachments' table: a document or photo stored in
+// the app data directory and linked to a child and optionally one session.
+type Attachment struct {
+	gorm.Model
+
+	ChildID      uint   `gorm:"not null;index"`
+	SessionID    *uint  `gorm:"index"`
+	Type         string `gorm:"not null"` // e.g., "referral_letter", "consent_form", "work_sample"
+	DocumentDate *time.Time
+	Description  string
+	FileName     string `gorm:"not null"`              // Original file name
+	StoredName   string `gorm:"not null;uniqueIndex"` // File name inside the attachments directory
+	MimeType     string
+	SizeBytes    int64
+	Checksum     string `gorm:"not null"` // SHA-256 of the original content, hex encoded
+	Encrypted    bool
+	UploadedBy   string
+}
+
+// Consent represents the 'consents' table: a signed consent given by a guardian
+// for treatment, photography or data sharing.
+type Consent struct {
+	gorm.Model
+
+	ChildID         uint   `gorm:"not null;index"`
+	Type            string `gorm:"not null"` // "treatment", "photography", "video_recording" or "data_sharing"
+	Status          string `gorm:"not null;default:'granted'"` // "granted" or "withdrawn"
+	GuardianID      *uint  // Guardian who signed, when recorded as a guardian
+	GrantedBy       string `gorm:"not null"`
+	SignedOn        *time.Time
+	ExpiresOn       *time.Time // Empty when the consent does not expire
+	WithdrawnAt     *time.Time
+	WithdrawnReason string
+	AttachmentID    *uint // Scanned consent form
+	Notes           string
+	RecordedBy      string
+}
+
+// AssessmentInstrument represents the 'assessment_instruments' table. Definition holds
+// the declarative JSON (items, response options, subscales, scoring and cut-offs);
+// a code and version pair never changes once administrations refer to it.
+type AssessmentInstrument struct {
+	gorm.Model
+
+	Code        string `gorm:"not null;uniqueIndex:idx_instrument_code_version"`
+	Version     string `gorm:"not null;uniqueIndex:idx_instrument_code_version"`
+	Name        string `gorm:"not null"`
+	Description string
+	Definition  string `gorm:"type:text;not null"`
+	IsBuiltin   bool
+	IsActive    bool
+}
+
+// AssessmentAdministration represents the 'assessment_administrations' table: one
+// completed instrument for a child, with the scores computed when it was recorded.
+type AssessmentAdministration struct {
+	gorm.Model
+
+	ChildID        uint  `gorm:"not null;index"`
+	InstrumentID   uint  `gorm:"not null;index"`
+	Instrument     AssessmentInstrument
+	SessionID      *uint `gorm:"index"`
+	TherapistID    *uint
+	AdministeredAt time.Time `gorm:"not null"`
+	AdministeredBy string
+	Informant      string // e.g., "parent", "teacher", "therapist"
+	Responses      string `gorm:"type:text"` // JSON object of item ID to response value
+	TotalScore     *float64
+	TotalBand      string
+	Scores         string `gorm:"type:text"` // JSON of the computed subscale and total scores
+	Notes          string
+}
+
+// Milestone represents the 'milestones' table, a developmental milestone from the
+// seeded library. Children usually reach it between MinAgeMonths and ExpectedAgeMonths.
+type Milestone struct {
+	gorm.Model
+
+	Code              string `gorm:"not null;uniqueIndex"`
+	Domain            string `gorm:"not null;index"` // "speech", "fine_motor", "gross_motor", "social" or "self_care"
+	Description       string `gorm:"not null"`
+	MinAgeMonths      int
+	ExpectedAgeMonths int   `gorm:"not null"` // Age by which most children have achieved it
+	ActivityID        *uint `gorm:"index"`    // Seeded activity that practises this domain
+	Activity          *Activity
+	SortOrder         int
+}
+
+// ChildMilestone represents the 'child_milestones' table, the status of one milestone
+// for one child. A milestone without a row has not been reached yet.
+type ChildMilestone struct {
+	gorm.Model
+
+	ChildID     uint   `gorm:"not null;uniqueIndex:idx_child_milestone"`
+	MilestoneID uint   `gorm:"not null;uniqueIndex:idx_child_milestone"`
+	Milestone   Milestone
+	Status      string `gorm:"not null;default:'not_yet'"` // "not_yet", "emerging" or "achieved"
+	EmergingOn  *time.Time
+	AchievedOn  *time.Time
+	Notes       string
+	UpdatedBy   string
+}
+
+// SessionPlan represents the 'session_plans' table, an ordered list of activities
+// prepared before a session. A plan is attached to one session when that session starts.
+type SessionPlan struct {
+	gorm.Model
+
+	ChildID     uint   `gorm:"not null;index"`
+	TherapistID *uint  `gorm:"index"`
+	Title       string `gorm:"not null"`
+	PlannedFor  *time.Time
+	SessionID   *uint `gorm:"index"` // Set when a session starts with this plan
+	TemplateID  *uint `gorm:"index"` // Template version the plan was cloned from
+	Notes       string
+	CreatedBy   string
+	Items       []SessionPlanItem `gorm:"foreignKey:PlanID"`
+}
+
+// SessionPlanItem represents the 'session_plan_items' table, one planned activity.
+// SessionActivityID links it to the activity actually run during the session.
+type SessionPlanItem struct {
+	gorm.Model
+
+	PlanID            uint `gorm:"not null;index"`
+	Position          int  `gorm:"not null"`
+	ActivityID        uint `gorm:"not null"`
+	Activity          Activity
+	TargetMinutes     int
+	GoalID            *uint
+	Goal              *Goal
+	Objective         string // What the activity should achieve when it is not a tracked goal
+	Materials         string // e.g., "kartu gambar hewan, cermin"
+	Notes             string
+	SessionActivityID *uint `gorm:"index"`
+	Skipped           bool
+	SkipReason        string
+}
+
+// SessionTemplate represents the 'session_templates' table, one version of a reusable
+// session structure. Versions sharing a TemplateKey are immutable; editing adds a version.
+type SessionTemplate struct {
+	gorm.Model
+
+	TemplateKey      string `gorm:"not null;uniqueIndex:idx_template_key_version"` // Shared by every version
+	Version          int    `gorm:"not null;uniqueIndex:idx_template_key_version"`
+	Name             string `gorm:"not null"`
+	Description      string
+	Programme        string // e.g., "Bicara", "Sensori integrasi"; empty for child templates
+	ChildID          *uint `gorm:"index"` // Set for a template tailored to one child
+	OwnerTherapistID *uint `gorm:"index"`
+	IsShared         bool  // Visible to every therapist, not just the owner
+	FlashcardDecks   string // Comma-separated flashcard categories
+	ChangeNote       string // What changed from the previous version
+	CreatedBy        string
+	Activities       []SessionTemplateActivity   `gorm:"foreignKey:TemplateID"`
+	RewardRules      []SessionTemplateRewardRule `gorm:"foreignKey:TemplateID"`
+	NoteTemplates    []NoteTemplate              `gorm:"many2many:session_template_note_templates"`
+}
+
+// SessionTemplateActivity represents the 'session_template_activities' table
+type SessionTemplateActivity struct {
+	gorm.Model
+
+	TemplateID    uint `gorm:"not null;index"`
+	Position      int  `gorm:"not null"`
+	ActivityID    uint `gorm:"not null"`
+	Activity      Activity
+	TargetMinutes int
+	Objective     string
+	Materials     string
+	Notes         string
+}
+
+// SessionTemplateRewardRule represents the 'session_template_reward_rules' table,
+// e.g. one sticker for every completed activity.
+type SessionTemplateRewardRule struct {
+	gorm.Model
+
+	TemplateID  uint   `gorm:"not null;index"`
+	Trigger     string `gorm:"not null"` // "activity_completed", "session_completed" or "flashcard_response"
+	RewardType  string `gorm:"not null"` // Same values as Reward.Type, e.g. "Sticker"
+	Value       int    `gorm:"not null"`
+	Description string
+}
+
+// WaitlistEntry represents the 'waitlist_entries' table, a referred child waiting for a
+// therapy slot. Once converted it links to the Child record and the initial appointment.
+type WaitlistEntry struct {
+	gorm.Model
+
+	ChildName      string `gorm:"not null"`
+	DateOfBirth    *time.Time
+	Gender         string
+	ContactName    string `gorm:"not null"` // Parent or guardian to call when a slot opens
+	Relationship   string // Same values as Guardian.Relationship
+	ContactPhone   string
+	ContactEmail   string
+	ReferralDate   time.Time `gorm:"not null;index"`
+	ReferralSource string    // e.g., a paediatrician or school
+	ReferralReason string
+	TherapyType    string    `gorm:"not null;index"` // e.g., "Terapi Wicara", "Terapi Okupasi"
+	Priority       string    `gorm:"not null;default:'normal'"` // "urgent", "high", "normal" or "low"
+	Status         string    `gorm:"not null;default:'waiting';index"` // "waiting", "offered", "converted" or "withdrawn"
+	Notes          string
+	ChildID        *uint     `gorm:"index"` // Set on conversion
+	SessionID      *uint     // Initial appointment booked on conversion
+	ConvertedAt    *time.Time
+	Availability   []WaitlistAvailability `gorm:"foreignKey:EntryID"`
+	Events         []WaitlistEvent        `gorm:"foreignKey:EntryID"`
+}
+
+// WaitlistAvailability represents the 'waitlist_availabilities' table, a weekly window
+// in which the family can attend.
+type WaitlistAvailability struct {
+	gorm.Model
+
+	EntryID   uint   `gorm:"not null;index"`
+	Weekday   int    // 0 = Sunday ... 6 = Saturday
+	StartTime string `gorm:"not null"` // "15:04"
+	EndTime   string `gorm:"not null"`
+}
+
+// WaitlistEvent represents the 'waitlist_events' table, the history of a referral from
+// intake to conversion. Rows are never edited.
+type WaitlistEvent struct {
+	gorm.Model
+
+	EntryID    uint      `gorm:"not null;index"`
+	Action     string    `gorm:"not null"` // "created", "updated", "status_changed" or "converted"
+	FromStatus string
+	ToStatus   string
+	Detail     string
+	Actor      string
+	Timestamp  time.Time `gorm:"not null"`
+}
+
+// RateCard represents the 'rate_cards' table, the fee charged for one kind of session.
+type RateCard struct {
+	gorm.Model
+
+	TherapyType     string     `gorm:"index"`    // e.g., "Terapi Wicara"; empty for the clinic's general rate
+	DurationMinutes int        `gorm:"not null"` // Session length the fee is for
+	IsGroup         bool       // Fee per child in a group session
+	Amount          int64      `gorm:"not null"` // In rupiah
+	Description     string
+	ArchivedAt      *time.Time // No longer charged; kept for the invoices that used it
+}
+
+// Invoice represents the 'invoices' table, a bill to a child's family for the sessions
+// attended in a period.
+type Invoice struct {
+	gorm.Model
+
+	Number      string        `gorm:"not null;uniqueIndex"` // e.g., "INV-2026-0001"
+	ChildID     uint          `gorm:"not null;index"`
+	Child       Child
+	BillTo      string        // Guardian the invoice is addressed to, as recorded when it was issued
+	PeriodStart time.Time     `gorm:"not null"`
+	PeriodEnd   time.Time     `gorm:"not null"` // Last day of the period, inclusive
+	IssuedAt    time.Time     `gorm:"not null"`
+	DueDate     time.Time     `gorm:"not null"`
+	Total       int64         `gorm:"not null"` // In rupiah
+	PaidAmount  int64
+	Status      string        `gorm:"not null;default:'unpaid';index"` // "unpaid", "partially_paid", "paid" or "void"
+	Notes       string
+	VoidedAt    *time.Time
+	VoidReason  string
+	Lines       []InvoiceLine `gorm:"foreignKey:InvoiceID"`
+	Payments    []Payment     `gorm:"foreignKey:InvoiceID"`
+}
+
+// InvoiceLine represents the 'invoice_lines' table, one session billed on an invoice
+// with the fee as it was charged.
+type InvoiceLine struct {
+	gorm.Model
+
+	InvoiceID       uint      `gorm:"not null;index"`
+	SessionID       uint      `gorm:"not null;index"`
+	RateCardID      *uint
+	SessionDate     time.Time `gorm:"not null"`
+	Description     string    `gorm:"not null"`
+	TherapyType     string
+	DurationMinutes int
+	Amount          int64     `gorm:"not null"`
+}
+
+// Payment represents the 'payments' table, money received against an invoice.
+type Payment struct {
+	gorm.Model
+
+	InvoiceID  uint      `gorm:"not null;index"`
+	Amount     int64     `gorm:"not null"` // In rupiah
+	PaidAt     time.Time `gorm:"not null"`
+	Method     string    `gorm:"not null"` // "cash", "transfer", "card" or "other"
+	Reference  string    // e.g., bank transfer reference
+	Notes      string
+	RecordedBy string
+}
+
+// SessionPackage represents the 'session_packages' table, a number of sessions a family
+// paid for in advance.
+type SessionPackage struct {
+	gorm.Model
+
+	ChildID           uint           `gorm:"not null;index"`
+	Child             Child
+	Name              string         `gorm:"not null"` // e.g., "Paket 12 sesi"
+	TherapyType       string         // Only sessions of this therapy type use the package; empty for any
+	SessionsPurchased int            `gorm:"not null"`
+	SessionsUsed      int
+	Price             int64          // In rupiah
+	PurchasedAt       time.Time      `gorm:"not null"`
+	ExpiresAt         *time.Time     // Last day sessions can be taken from the package; nil when it does not expire
+	Notes             string
+	CancelledAt       *time.Time
+	CancelReason      string
+	Usages            []PackageUsage `gorm:"foreignKey:PackageID"`
+}
+
+// PackageUsage represents the 'package_usages' table, a session taken from a package,
+// or given back to it when the child turned out not to have attended.
+type PackageUsage struct {
+	gorm.Model
+
+	PackageID      uint      `gorm:"not null;index"`
+	SessionID      uint      `gorm:"not null;index"`
+	ChildID        uint      `gorm:"not null;index"`
+	SessionDate    time.Time `gorm:"not null"`
+	Action         string    `gorm:"not null"` // "used" or "returned"
+	RemainingAfter int       // Sessions left in the package after this entry
+	Notes          string
+}
+
+// Age is a chronological age, computed from a date of birth and never stored.
+type Age struct {
+	Years       int    `json:"years"`
+	Months      int    `json:"months"`       // Months past the last birthday, 0-11
+	TotalMonths int    `json:"total_months"` // Used for developmental norms
+	Days        int    `json:"days"`         // Days past the last month boundary
+	Label       string `json:"label"`        // e.g., "4 tahun 7 bulan"
+}
+
+// Guardian represents the 'guardians' table, one parent, guardian or
+// emergency contact of a child. ParentGuardianName and ContactInfo on Child
+// are kept only for compatibility; these records are authoritative.
+type Guardian struct {
+	gorm.Model
+
+	ChildID            uint   `gorm:"not null;index"`
+	Name               string `gorm:"not null"`
+	Relationship       string // e.g., "mother", "father", "step_parent", "grandparent", "guardian"
+	Phone              string // Normalised: digits with an optional leading "+"
+	Email              string
+	IsPrimary          bool // Main contact for the family
+	IsEmergencyContact bool
+	HasLegalCustody    bool
+	ConsentToContact   bool // May be phoned or emailed about the child
+	ConsentToShareInfo bool // May receive reports and clinical information
+	Notes              string
+}
+
+// Therapist represents the 'therapists' table, a user account of the installation.
+type Therapist struct {
+	gorm.Model
+
+	Username       string `gorm:"not null;uniqueIndex"`
+	Name           string `gorm:"not null"`
+	PasswordHash   string `json:"-"`
+	Role           string `gorm:"not null;default:'therapist'"` // "therapist", "supervisor", "front_desk" or "admin"
+	IsActive       bool   `gorm:"default:true"`
+	RequiresReview bool   `gorm:"default:false"` // Sessions this therapist ends go to the supervisor review queue
+	LastLoginAt    *time.Time
+	Children       []Child `gorm:"many2many:child_therapists"` // Caseload
 }
 
 // Session represents the 'sessions' table.
@@ -27,14 +465,89 @@ type Session struct {
 
 	ChildID          uint `gorm:"not null"`
 	Child            Child // Belongs-to relationship with Child
+	TherapistID      *uint `gorm:"index"` // Who ran the session; nil for sessions recorded before accounts existed
+	Therapist        *Therapist
+	ChildAge         *Age `gorm:"-"` // The child's age when the session started
+	Warnings         []string `gorm:"-"` // Clinical warnings raised when the session starts
 	StartTime        time.Time `gorm:"not null"`
 	EndTime          *time.Time
 	DurationMinutes  int
 	SummaryNotes     string // Auto-formatted summary notes
+	NoteFormat       string `gorm:"default:'free'"` // "free", "soap" or "dap"
 	Notes            []Note `gorm:"foreignKey:SessionID"` // One-to-many relationship with Note
 	SessionActivities []SessionActivity `gorm:"foreignKey:SessionID"` // One-to-many relationship with SessionActivity
 	SessionFlashcards []SessionFlashcard `gorm:"foreignKey:SessionID"` // One-to-many relationship with SessionFlashcard
 	Rewards          []Reward `gorm:"foreignKey:SessionID"` // One-to-many relationship with Reward (optional)
+	NoteSections     []SessionNoteSection `gorm:"foreignKey:SessionID"` // Structured note sections (SOAP/DAP)
+	FinalizedAt      *time.Time // Set once the session is signed; the record is frozen afterwards
+	FinalizedBy      string
+	Addenda          []SessionAddendum `gorm:"foreignKey:SessionID"` // Additions made after finalisation
+	ReviewStatus     string `gorm:"index"` // "", "pending", "changes_requested" or "cosigned"
+	CosignedAt       *time.Time
+	CosignedBy       string
+	ReviewComments   []SessionReviewComment `gorm:"foreignKey:SessionID"` // Supervisor review thread
+	IsGroup          bool   // ChildID is then the primary child; every child is listed in Participants
+	GroupName        string // e.g., "Kelompok keterampilan sosial"
+	Participants     []SessionParticipant `gorm:"foreignKey:SessionID"`
+	Status           string     `gorm:"not null;default:'attended';index"` // "scheduled", "attended", "late", "cancelled_family", "cancelled_clinic" or "no_show"
+	StatusReason     string     // Why the session was cancelled, missed or started late
+	ScheduledFor     *time.Time // Booked start; nil for sessions started without an appointment
+	LateMinutes      int
+	ResumedAt        *time.Time // Set when a session left open by an app shutdown was picked up again
+	EnteredAfterwards bool      // Recorded after it took place rather than while it ran
+	TherapyType      string     // e.g., "Terapi Wicara"; decides the rate the session is billed at
+}
+
+// SessionParticipant represents the 'session_participants' table, one child taking
+// part in a group session, with that child's attendance.
+type SessionParticipant struct {
+	gorm.Model
+
+	SessionID       uint   `gorm:"not null;uniqueIndex:idx_session_participant"`
+	ChildID         uint   `gorm:"not null;uniqueIndex:idx_session_participant;index"`
+	Child           Child
+	Attendance      string `gorm:"not null;default:'present'"` // "present", "late", "left_early" or "absent"
+	ArrivedAt       *time.Time
+	LeftAt          *time.Time
+	AttendanceNotes string
+	Warnings        []string `gorm:"-"` // Clinical warnings raised when the child joins a running session
+}
+
+// SessionReviewComment represents the 'session_review_comments' table, a supervisor's
+// remark on a session under review, optionally about one specific note.
+type SessionReviewComment struct {
+	gorm.Model
+
+	SessionID uint   `gorm:"not null;index"`
+	NoteID    *uint  `gorm:"index"` // Nil when the comment is about the session as a whole
+	Kind      string `gorm:"not null"` // "comment", "changes_requested", "submitted", "resubmitted" or "cosigned"
+	Comment   string
+	Author    string
+	Timestamp time.Time `gorm:"not null"`
+}
+
+// SessionAddendum represents the 'session_addenda' table, the only way
+// to add information to a session after it has been finalised.
+type SessionAddendum struct {
+	gorm.Model
+
+	SessionID uint   `gorm:"not null;index"`
+	Text      string `gorm:"not null"`
+	Author    string
+	Timestamp time.Time `gorm:"not null"`
+}
+
+// SessionNoteSection represents the 'session_note_sections' table,
+// holding one section of a structured (SOAP/DAP) session note.
+type SessionNoteSection struct {
+	gorm.Model
+
+	SessionID  uint   `gorm:"not null;uniqueIndex:idx_session_note_section"`
+	Format     string `gorm:"not null;uniqueIndex:idx_session_note_section"` // e.g., "soap", "dap"
+	SectionKey string `gorm:"not null;uniqueIndex:idx_session_note_section"` // e.g., "subjective", "plan"
+	Title      string
+	Content    string
+	SortOrder  int
 }
 
 // Activity represents the 'activities' table.
@@ -61,6 +574,7 @@ type SessionActivity struct {
 	StartTime *time.Time
 	EndTime   *time.Time
 	Notes     string 
+	Warnings  []string `gorm:"-"` // Allergy or sensory warnings for this activity
 }
 
 // Note represents the 'notes' table for quick note-taking.
@@ -69,12 +583,31 @@ type Note struct {
 
 	SessionID   uint `gorm:"not null"`
 	Session     Session 
+	ChildID     *uint `gorm:"index"` // In a group session, the child the note is about; nil for the whole group
+	TherapistID *uint // Who wrote the note
 	NoteText    string `gorm:"not null"` 
 	Category    string
 	Timestamp   time.Time `gorm:"not null"`
 	IsEncrypted bool      `gorm:"default:false"` 
 }
 
+// NoteRevision represents the 'note_revisions' table, an immutable record
+// of every change made to a note, a session's summary notes or a structured note section.
+type NoteRevision struct {
+	ID        uint      `gorm:"primaryKey"`
+	CreatedAt time.Time `gorm:"not null"`
+
+	EntityType string `gorm:"not null;index:idx_note_revisions_entity"` // "note", "session_summary", "note_section" or "session_times"
+	EntityID   uint   `gorm:"not null;index:idx_note_revisions_entity"`
+	SessionID  uint   `gorm:"not null;index"`
+	Revision   int    `gorm:"not null"` // 1-based, per entity
+	Action     string `gorm:"not null"` // "created", "updated", "deleted" or "restored"
+	Text       string
+	Category   string // Note category or section key
+	Author     string
+	Reason     string
+}
+
 // NoteTemplate represents the 'note_templates' table for customizable templates.
 type NoteTemplate struct {
 	gorm.Model 
@@ -92,6 +625,7 @@ type Reward struct {
 	Child     Child // Belongs-to relationship with Child
 	SessionID *uint // Can be null if reward is given outside a specific session
 	Session   Session `gorm:"foreignKey:SessionID"` 
+	TherapistID *uint // Who gave the reward
 	Type      string `gorm:"not null"` // e.g., "Star", "Point", "Sticker"
 	Value     int    `gorm:"default:1"`
 	Timestamp time.Time `gorm:"not null"`
@@ -122,6 +656,7 @@ type Flashcard struct {
 	TextContent       string
 	ImagePath         string
 	Description       string
+	ChildID           *uint `gorm:"index"` // Set when the image is a photo of this child; use requires photography consent
 	SessionFlashcards []SessionFlashcard `gorm:"foreignKey:FlashcardID"` 
 }
 
@@ -139,4 +674,39 @@ type SessionFlashcard struct {
 	Timestamp    time.Time `gorm:"not null"`
 }
 
+// AuditLog represents the 'audit_logs' table, an append-only record of every
+// access and modification. Each entry hashes the previous one so tampering is detectable.
+type AuditLog struct {
+	ID         uint      `gorm:"primaryKey"`
+	Timestamp  time.Time `gorm:"not null;index"`
+	Actor      string    `gorm:"index"`
+	Action     string    `gorm:"not null;index"` // "create", "update", "delete", "read", "restore" or "purge"
+	EntityType string    `gorm:"not null;index:idx_audit_logs_entity"`
+	EntityID   uint      `gorm:"index:idx_audit_logs_entity"`
+	BeforeHash string    // SHA-256 of the record before the change
+	AfterHash  string    // SHA-256 of the record after the change
+	PrevHash   string    `gorm:"not null"`
+	Hash       string    `gorm:"not null;uniqueIndex"`
+}
+
+// AppSetting represents the 'app_settings' table, a key/value store for
+// installation-wide configuration such as retention periods.
+type AppSetting struct {
+	gorm.Model
 
+	Key   string `gorm:"not null;uniqueIndex"`
+	Value string
+}
+
+// ErasureReceipt represents the 'erasure_receipts' table, proof that a child's data
+// was permanently erased. It deliberately holds no personal data about the child.
+type ErasureReceipt struct {
+	ID          uint      `gorm:"primaryKey"`
+	ErasedAt    time.Time `gorm:"not null"`
+	ChildID     uint      `gorm:"not null;index"`
+	ErasedBy    string    `gorm:"not null"`
+	Reason      string
+	RowCounts   string    `gorm:"not null"` // JSON object of rows removed per table
+	AuditHash   string    `gorm:"not null"` // Hash of the audit log entry recording the erasure
+	Signature   string    `gorm:"not null"` // HMAC-SHA256 over the other fields
+}
@@ -9,11 +9,12 @@ import (
 )
 
 type ActivityService struct {
-    db *gorm.DB
+    db    *gorm.DB
+    audit *AuditService
 }
 
-func NewActivityService(db *gorm.DB) *ActivityService {
-    return &ActivityService{db: db}
+func NewActivityService(db *gorm.DB, audit *AuditService) *ActivityService {
+    return &ActivityService{db: db, audit: audit}
 }
 
 // GetAllActivities retrieves all activities
@@ -34,7 +35,14 @@ func (s *ActivityService) CreateActivity(name, description string, defaultDurati
         Category:               category,
         Objectives:             objectives,
     }
-    if err := s.db.Create(activity).Error; err != nil {
+    err := s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Create(activity).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionCreate, AuditEntityActivity, activity.ID, nil, activity)
+        return nil
+    })
+    if err != nil {
         return nil, fmt.Errorf("gagal membuat aktivitas: %w", err)
     }
     return activity, nil
@@ -58,13 +66,21 @@ func (s *ActivityService) UpdateActivity(id uint, name, description string, defa
     if err := s.db.First(&activity, id).Error; err != nil {
         return nil, fmt.Errorf("aktivitas tidak ditemukan: %w", err)
     }
+    before := activity
     activity.Name = name
     activity.Description = description
     activity.DefaultDurationMinutes = defaultDurationMinutes
     activity.Category = category
     activity.Objectives = objectives
 
-    if err := s.db.Save(&activity).Error; err != nil {
+    err := s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Save(&activity).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionUpdate, AuditEntityActivity, activity.ID, before, activity)
+        return nil
+    })
+    if err != nil {
         return nil, fmt.Errorf("gagal memperbarui aktivitas: %w", err)
     }
     return &activity, nil
@@ -72,7 +88,19 @@ func (s *ActivityService) UpdateActivity(id uint, name, description string, defa
 
 // DeleteActivity deletes an activity
 func (s *ActivityService) DeleteActivity(id uint) error {
-    if err := s.db.Delete(&model.Activity{}, id).Error; err != nil {
+    activity, err := s.GetActivityByID(id)
+    if err != nil {
+        return err
+    }
+
+    err = s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Delete(activity).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionDelete, AuditEntityActivity, activity.ID, activity, nil)
+        return nil
+    })
+    if err != nil {
         return fmt.Errorf("gagal menghapus aktivitas: %w", err)
     }
     return nil
@@ -0,0 +1,195 @@
+package services
+
+import (
+	"childSessions/model"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Age bands used to filter children, in months
+var AgeBands = []AgeBand{
+    {Key: "infant_toddler", Name: "Bayi & batita (0-2 tahun)", MinMonths: 0, MaxMonths: 35},
+    {Key: "preschool", Name: "Prasekolah (3-5 tahun)", MinMonths: 36, MaxMonths: 71},
+    {Key: "early_school", Name: "Usia sekolah awal (6-8 tahun)", MinMonths: 72, MaxMonths: 107},
+    {Key: "middle_childhood", Name: "Usia sekolah (9-12 tahun)", MinMonths: 108, MaxMonths: 155},
+    {Key: "adolescent", Name: "Remaja (13 tahun ke atas)", MinMonths: 156, MaxMonths: -1},
+}
+
+// AgeBand is a named range of ages; MaxMonths of -1 means no upper bound
+type AgeBand struct {
+    Key       string `json:"key"`
+    Name      string `json:"name"`
+    MinMonths int    `json:"min_months"`
+    MaxMonths int    `json:"max_months"`
+}
+
+// Contains reports whether an age falls inside the band
+func (b AgeBand) Contains(age model.Age) bool {
+    if age.TotalMonths < b.MinMonths {
+        return false
+    }
+    return b.MaxMonths < 0 || age.TotalMonths <= b.MaxMonths
+}
+
+// LookupAgeBand finds an age band by key
+func LookupAgeBand(key string) (AgeBand, bool) {
+    for _, band := range AgeBands {
+        if band.Key == key {
+            return band, true
+        }
+    }
+    return AgeBand{}, false
+}
+
+// dateOfBirthLayouts are tried in order; day-first numeric formats are used locally
+var dateOfBirthLayouts = []string{
+    "2006-01-02",
+    time.RFC3339,
+    "2006-01-02T15:04:05",
+    "02/01/2006",
+    "2/1/2006",
+    "02-01-2006",
+    "2-1-2006",
+    "02.01.2006",
+    "2006/01/02",
+    "02 January 2006",
+    "2 January 2006",
+    "02 Jan 2006",
+    "2 Jan 2006",
+    "January 2, 2006",
+    "Jan 2, 2006",
+}
+
+// indonesianMonths maps Indonesian month names and abbreviations to English ones
+var indonesianMonths = strings.NewReplacer(
+    "januari", "january", "februari", "february", "maret", "march",
+    "mei", "may", "juni", "june", "juli", "july",
+    "agustus", "august", "oktober", "october", "desember", "december",
+    "agu", "aug", "agt", "aug", "okt", "oct", "des", "dec",
+)
+
+// ParseDateOfBirth parses a date of birth in ISO, day-first numeric or written form,
+// including Indonesian month names, and rejects dates in the future
+func ParseDateOfBirth(value string) (*time.Time, error) {
+    value = strings.TrimSpace(value)
+    if value == "" {
+        return nil, nil
+    }
+
+    dob, ok := parseCalendarDate(value)
+    if !ok {
+        return nil, fmt.Errorf("format tanggal lahir tidak dikenali: %s (gunakan YYYY-MM-DD atau DD/MM/YYYY)", value)
+    }
+    if dob.After(time.Now()) {
+        return nil, errors.New("tanggal lahir tidak boleh di masa depan")
+    }
+    if dob.Year() < 1900 {
+        return nil, errors.New("tanggal lahir tidak valid")
+    }
+    return &dob, nil
+}
+
+// ParseCalendarDate parses a date in the same formats as ParseDateOfBirth
+// without restricting it to the past; an empty value yields nil
+func ParseCalendarDate(value string) (*time.Time, error) {
+    value = strings.TrimSpace(value)
+    if value == "" {
+        return nil, nil
+    }
+    date, ok := parseCalendarDate(value)
+    if !ok || date.Year() < 1900 {
+        return nil, fmt.Errorf("format tanggal tidak dikenali: %s (gunakan YYYY-MM-DD atau DD/MM/YYYY)", value)
+    }
+    return &date, nil
+}
+
+// parseCalendarDate tries every known layout and returns local midnight of the date
+func parseCalendarDate(value string) (time.Time, bool) {
+    // Month names are matched case-insensitively by the time package
+    normalized := indonesianMonths.Replace(strings.Join(strings.Fields(strings.ToLower(value)), " "))
+
+    for _, layout := range dateOfBirthLayouts {
+        parsed, err := time.ParseInLocation(layout, value, time.Local)
+        if err != nil {
+            parsed, err = time.ParseInLocation(layout, normalized, time.Local)
+        }
+        if err != nil {
+            continue
+        }
+        return time.Date(parsed.Year(), parsed.Month(), parsed.Day(), 0, 0, 0, 0, time.Local), true
+    }
+    return time.Time{}, false
+}
+
+// AgeAt computes the chronological age on a given date, the way clinicians do:
+// whole years, then whole months, then remaining days
+func AgeAt(dob, at time.Time) model.Age {
+    dob = time.Date(dob.Year(), dob.Month(), dob.Day(), 0, 0, 0, 0, time.Local)
+    at = at.In(time.Local)
+    at = time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, time.Local)
+    if at.Before(dob) {
+        return model.Age{Label: "belum lahir"}
+    }
+
+    // Count whole months, treating a birthday on the 31st as falling on the last day of shorter months
+    totalMonths := (at.Year()-dob.Year())*12 + int(at.Month()) - int(dob.Month())
+    if addMonthsClamped(dob, totalMonths).After(at) {
+        totalMonths--
+    }
+    days := int(at.Sub(addMonthsClamped(dob, totalMonths)).Hours()/24 + 0.5)
+    years, months := totalMonths/12, totalMonths%12
+
+    return model.Age{
+        Years:       years,
+        Months:      months,
+        TotalMonths: totalMonths,
+        Days:        days,
+        Label:       fmt.Sprintf("%d tahun %d bulan", years, months),
+    }
+}
+
+// ChildAgeAt returns a child's age on a given date, or nil without a date of birth
+func ChildAgeAt(child model.Child, at time.Time) *model.Age {
+    if child.DateOfBirth == nil {
+        return nil
+    }
+    age := AgeAt(*child.DateOfBirth, at)
+    return &age
+}
+
+// addMonthsClamped adds months to a date, clamping the day to the end of the target month
+func addMonthsClamped(t time.Time, months int) time.Time {
+    first := time.Date(t.Year(), t.Month()+time.Month(months), 1, 0, 0, 0, 0, time.Local)
+    lastDay := first.AddDate(0, 1, -1).Day()
+    day := t.Day()
+    if day > lastDay {
+        day = lastDay
+    }
+    return time.Date(first.Year(), first.Month(), day, 0, 0, 0, 0, time.Local)
+}
+
+// setChildAge fills in a child's current age
+func setChildAge(child *model.Child, now time.Time) {
+    child.Age = ChildAgeAt(*child, now)
+}
+
+// setSessionAges fills in the child's current age and their age when the session started
+func setSessionAges(session *model.Session, now time.Time) {
+    setChildAge(&session.Child, now)
+    session.ChildAge = ChildAgeAt(session.Child, session.StartTime)
+}
+
+// FilterChildrenByAge keeps the children whose current age is within [minMonths, maxMonths];
+// a negative maxMonths means no upper bound. Children without a date of birth are dropped.
+func FilterChildrenByAge(children []model.Child, minMonths, maxMonths int) []model.Child {
+    band := AgeBand{MinMonths: minMonths, MaxMonths: maxMonths}
+    filtered := make([]model.Child, 0, len(children))
+    for _, child := range children {
+        if child.Age != nil && band.Contains(*child.Age) {
+            filtered = append(filtered, child)
+        }
+    }
+    return filtered
+}
@@ -0,0 +1,828 @@
+package services
+
+import (
+	"childSessions/model"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Scoring methods
+const (
+    ScoringSum  = "sum"
+    ScoringMean = "mean"
+)
+
+// Score band levels, from least to most concern
+const (
+    ScoreLevelTypical = "typical"
+    ScoreLevelMonitor = "monitor"
+    ScoreLevelConcern = "concern"
+)
+
+// Directions of change between two administrations
+const (
+    ChangeImproved  = "improved"
+    ChangeWorsened  = "worsened"
+    ChangeUnchanged = "unchanged"
+)
+
+// ResponseOption is one answer an informant can give to an item
+type ResponseOption struct {
+    Value string  `json:"value"`
+    Label string  `json:"label"`
+    Score float64 `json:"score"`
+}
+
+// InstrumentItem is one question or checklist line
+type InstrumentItem struct {
+    ID              string           `json:"id"`
+    Text            string           `json:"text"`
+    Subscale        string           `json:"subscale"`
+    Reverse         bool             `json:"reverse"`          // Score is mirrored within the option range
+    Critical        bool             `json:"critical"`         // Counted by the critical rule when it gets the worst score
+    ResponseOptions []ResponseOption `json:"response_options"` // Overrides the instrument's options
+}
+
+// ScoreBand maps a score range, inclusive on both ends, to an interpretation
+type ScoreBand struct {
+    Min   float64 `json:"min"`
+    Max   float64 `json:"max"`
+    Label string  `json:"label"`
+    Level string  `json:"level"` // "typical", "monitor" or "concern"
+}
+
+// ScoreRule describes how a subscale or the total is computed and interpreted
+type ScoreRule struct {
+    ID      string      `json:"id"`
+    Name    string      `json:"name"`
+    Scoring string      `json:"scoring"` // "sum" or "mean"
+    Bands   []ScoreBand `json:"bands"`
+}
+
+// CriticalRule flags an administration when enough critical items get their worst score
+type CriticalRule struct {
+    Threshold int    `json:"threshold"`
+    Label     string `json:"label"`
+}
+
+// InstrumentDefinition is the declarative form of an assessment instrument
+type InstrumentDefinition struct {
+    Code            string           `json:"code"`
+    Version         string           `json:"version"`
+    Name            string           `json:"name"`
+    Description     string           `json:"description"`
+    MinAgeMonths    int              `json:"min_age_months"`
+    MaxAgeMonths    int              `json:"max_age_months"` // 0 means no upper limit
+    Informants      []string         `json:"informants"`
+    HigherIsBetter  bool             `json:"higher_is_better"`
+    MaxMissingItems int              `json:"max_missing_items"` // Per subscale; missing items are prorated
+    ResponseOptions []ResponseOption `json:"response_options"`
+    Items           []InstrumentItem `json:"items"`
+    Subscales       []ScoreRule      `json:"subscales"`
+    Total           *ScoreRule       `json:"total"`
+    CriticalRule    *CriticalRule    `json:"critical_rule"`
+}
+
+// ScoreResult is the computed score of a subscale or of the whole instrument
+type ScoreResult struct {
+    ID       string  `json:"id"`
+    Name     string  `json:"name"`
+    Raw      float64 `json:"raw"`
+    Answered int     `json:"answered"`
+    Items    int     `json:"items"`
+    Prorated bool    `json:"prorated"`
+    Band     string  `json:"band"`
+    Level    string  `json:"level"`
+}
+
+// AssessmentScore is the outcome of scoring one set of responses
+type AssessmentScore struct {
+    Subscales     []ScoreResult `json:"subscales"`
+    Total         *ScoreResult  `json:"total"`
+    CriticalItems []string      `json:"critical_items"`
+    CriticalAlert string        `json:"critical_alert"`
+}
+
+// AssessmentInstrumentView is an instrument with its parsed definition
+type AssessmentInstrumentView struct {
+    model.AssessmentInstrument
+    Parsed *InstrumentDefinition `json:"parsed"`
+}
+
+// AdministrationInput holds the responses of one administration
+type AdministrationInput struct {
+    InstrumentID   uint              `json:"instrument_id"`
+    SessionID      *uint             `json:"session_id"`
+    AdministeredAt string            `json:"administered_at"` // Empty means now
+    Informant      string            `json:"informant"`
+    Responses      map[string]string `json:"responses"` // Item ID to response value
+    Notes          string            `json:"notes"`
+}
+
+// AdministrationResult is an administration with its responses and scores decoded
+type AdministrationResult struct {
+    model.AssessmentAdministration
+    InstrumentCode string            `json:"instrument_code"`
+    InstrumentName string            `json:"instrument_name"`
+    ResponseValues map[string]string `json:"response_values"`
+    Score          AssessmentScore   `json:"score"`
+    ChildAge       *model.Age        `json:"child_age"`
+}
+
+// ScoreChange compares one score between the first and the latest administration
+type ScoreChange struct {
+    ID         string  `json:"id"`
+    Name       string  `json:"name"`
+    First      float64 `json:"first"`
+    Latest     float64 `json:"latest"`
+    Delta      float64 `json:"delta"`
+    FirstBand  string  `json:"first_band"`
+    LatestBand string  `json:"latest_band"`
+    Direction  string  `json:"direction"`
+}
+
+// AssessmentComparison lists a child's administrations of one instrument over time
+type AssessmentComparison struct {
+    InstrumentCode  string                 `json:"instrument_code"`
+    InstrumentName  string                 `json:"instrument_name"`
+    HigherIsBetter  bool                   `json:"higher_is_better"`
+    Administrations []AdministrationResult `json:"administrations"`
+    Changes         []ScoreChange          `json:"changes"`
+}
+
+// ParseInstrumentDefinition decodes and validates an instrument definition
+func ParseInstrumentDefinition(data string) (*InstrumentDefinition, error) {
+    var def InstrumentDefinition
+    decoder := json.NewDecoder(strings.NewReader(data))
+    decoder.DisallowUnknownFields()
+    if err := decoder.Decode(&def); err != nil {
+        return nil, fmt.Errorf("definisi instrumen tidak valid: %w", err)
+    }
+    if err := def.validate(); err != nil {
+        return nil, err
+    }
+    return &def, nil
+}
+
+func (def *InstrumentDefinition) validate() error {
+    if strings.TrimSpace(def.Code) == "" || strings.TrimSpace(def.Version) == "" || strings.TrimSpace(def.Name) == "" {
+        return errors.New("definisi instrumen harus memiliki code, version dan name")
+    }
+    if len(def.Items) == 0 {
+        return errors.New("definisi instrumen harus memiliki minimal satu butir")
+    }
+    if def.MaxMissingItems < 0 {
+        return errors.New("max_missing_items tidak boleh negatif")
+    }
+
+    subscales := make(map[string]bool)
+    for _, rule := range def.Subscales {
+        if rule.ID == "" || subscales[rule.ID] {
+            return fmt.Errorf("id subskala kosong atau ganda: %q", rule.ID)
+        }
+        subscales[rule.ID] = true
+        if err := rule.validate(); err != nil {
+            return err
+        }
+    }
+    if def.Total != nil {
+        if err := def.Total.validate(); err != nil {
+            return err
+        }
+    }
+
+    items := make(map[string]bool)
+    for _, item := range def.Items {
+        if item.ID == "" || items[item.ID] {
+            return fmt.Errorf("id butir kosong atau ganda: %q", item.ID)
+        }
+        items[item.ID] = true
+        if item.Subscale != "" && !subscales[item.Subscale] {
+            return fmt.Errorf("butir %s merujuk subskala yang tidak ada: %s", item.ID, item.Subscale)
+        }
+        options := def.optionsFor(item)
+        if len(options) < 2 {
+            return fmt.Errorf("butir %s harus memiliki minimal dua pilihan jawaban", item.ID)
+        }
+        values := make(map[string]bool)
+        for _, option := range options {
+            if option.Value == "" || values[option.Value] {
+                return fmt.Errorf("pilihan jawaban butir %s kosong atau ganda: %q", item.ID, option.Value)
+            }
+            values[option.Value] = true
+        }
+    }
+    if def.CriticalRule != nil && def.CriticalRule.Threshold < 1 {
+        return errors.New("ambang butir kritis minimal 1")
+    }
+    return nil
+}
+
+func (rule *ScoreRule) validate() error {
+    switch rule.Scoring {
+    case ScoringSum, ScoringMean:
+    default:
+        return fmt.Errorf("metode skor tidak dikenal: %q", rule.Scoring)
+    }
+    for _, band := range rule.Bands {
+        if band.Max < band.Min {
+            return fmt.Errorf("rentang skor %q tidak valid: %v-%v", band.Label, band.Min, band.Max)
+        }
+        switch band.Level {
+        case ScoreLevelTypical, ScoreLevelMonitor, ScoreLevelConcern:
+        default:
+            return fmt.Errorf("level rentang skor tidak dikenal: %q", band.Level)
+        }
+    }
+    return nil
+}
+
+func (def *InstrumentDefinition) optionsFor(item InstrumentItem) []ResponseOption {
+    if len(item.ResponseOptions) > 0 {
+        return item.ResponseOptions
+    }
+    return def.ResponseOptions
+}
+
+// Score runs the scoring engine: item scores (reversed where defined), subscale and
+// total scores with proration for up to MaxMissingItems unanswered items per subscale,
+// score bands, and the critical item rule
+func (def *InstrumentDefinition) Score(responses map[string]string) (*AssessmentScore, error) {
+    for itemID := range responses {
+        if !def.hasItem(itemID) {
+            return nil, fmt.Errorf("butir tidak dikenal: %s", itemID)
+        }
+    }
+
+    itemScores := make(map[string]float64)
+    var criticalItems []string
+    for _, item := range def.Items {
+        value, answered := responses[item.ID]
+        if !answered || value == "" {
+            continue
+        }
+        score, worst, err := def.scoreItem(item, value)
+        if err != nil {
+            return nil, err
+        }
+        itemScores[item.ID] = score
+        if item.Critical && worst {
+            criticalItems = append(criticalItems, item.ID)
+        }
+    }
+
+    result := &AssessmentScore{Subscales: make([]ScoreResult, 0, len(def.Subscales)), CriticalItems: criticalItems}
+    for _, rule := range def.Subscales {
+        var itemIDs []string
+        for _, item := range def.Items {
+            if item.Subscale == rule.ID {
+                itemIDs = append(itemIDs, item.ID)
+            }
+        }
+        score, err := computeScore(rule, itemIDs, itemScores, def.MaxMissingItems)
+        if err != nil {
+            return nil, err
+        }
+        result.Subscales = append(result.Subscales, *score)
+    }
+
+    if def.Total != nil {
+        itemIDs := make([]string, 0, len(def.Items))
+        for _, item := range def.Items {
+            itemIDs = append(itemIDs, item.ID)
+        }
+        // Each subscale already enforced its own limit, so the total allows their sum
+        maxMissing := def.MaxMissingItems * len(def.Subscales)
+        if len(def.Subscales) == 0 {
+            maxMissing = def.MaxMissingItems
+        }
+        rule := *def.Total
+        if rule.ID == "" {
+            rule.ID = "total"
+        }
+        total, err := computeScore(rule, itemIDs, itemScores, maxMissing)
+        if err != nil {
+            return nil, err
+        }
+        result.Total = total
+    }
+
+    if def.CriticalRule != nil && len(criticalItems) >= def.CriticalRule.Threshold {
+        result.CriticalAlert = def.CriticalRule.Label
+    }
+    return result, nil
+}
+
+func (def *InstrumentDefinition) hasItem(id string) bool {
+    for _, item := range def.Items {
+        if item.ID == id {
+            return true
+        }
+    }
+    return false
+}
+
+// scoreItem returns the score of a response and whether it is the worst possible score
+func (def *InstrumentDefinition) scoreItem(item InstrumentItem, value string) (float64, bool, error) {
+    options := def.optionsFor(item)
+    minScore, maxScore := options[0].Score, options[0].Score
+    for _, option := range options {
+        minScore = math.Min(minScore, option.Score)
+        maxScore = math.Max(maxScore, option.Score)
+    }
+
+    for _, option := range options {
+        if option.Value != value {
+            continue
+        }
+        score := option.Score
+        if item.Reverse {
+            score = maxScore + minScore - score
+        }
+        worst := score == maxScore
+        if def.HigherIsBetter {
+            worst = score == minScore
+        }
+        return score, worst, nil
+    }
+    return 0, false, fmt.Errorf("jawaban %q tidak valid untuk butir %s", value, item.ID)
+}
+
+func computeScore(rule ScoreRule, itemIDs []string, itemScores map[string]float64, maxMissing int) (*ScoreResult, error) {
+    result := &ScoreResult{ID: rule.ID, Name: rule.Name, Items: len(itemIDs)}
+    var sum float64
+    for _, id := range itemIDs {
+        if score, ok := itemScores[id]; ok {
+            sum += score
+            result.Answered++
+        }
+    }
+    missing := result.Items - result.Answered
+    if missing > maxMissing || result.Answered == 0 {
+        return nil, fmt.Errorf("%s tidak dapat diskor: %d butir belum dijawab (maksimal %d)", rule.Name, missing, maxMissing)
+    }
+
+    switch rule.Scoring {
+    case ScoringMean:
+        result.Raw = sum / float64(result.Answered)
+    default:
+        result.Raw = sum
+        if missing > 0 {
+            // Prorate so a skipped item does not make the score look better
+            result.Raw = sum * float64(result.Items) / float64(result.Answered)
+            result.Prorated = true
+        }
+    }
+    result.Raw = math.Round(result.Raw*100) / 100
+
+    for _, band := range rule.Bands {
+        if result.Raw >= band.Min && result.Raw <= band.Max {
+            result.Band = band.Label
+            result.Level = band.Level
+            break
+        }
+    }
+    // Prorated or mean scores can fall between two integer bands; use the nearest lower band
+    if result.Band == "" {
+        for _, band := range rule.Bands {
+            if result.Raw >= band.Min {
+                result.Band = band.Label
+                result.Level = band.Level
+            }
+        }
+    }
+    return result, nil
+}
+
+type AssessmentService struct {
+    db    *gorm.DB
+    audit *AuditService
+}
+
+func NewAssessmentService(db *gorm.DB, audit *AuditService) *AssessmentService {
+    return &AssessmentService{db: db, audit: audit}
+}
+
+// GetInstruments lists assessment instruments, optionally only active ones
+func (s *AssessmentService) GetInstruments(activeOnly bool) ([]AssessmentInstrumentView, error) {
+    var instruments []model.AssessmentInstrument
+    query := s.db.Order("name ASC, version DESC")
+    if activeOnly {
+        query = query.Where("is_active = ?", true)
+    }
+    if err := query.Find(&instruments).Error; err != nil {
+        return nil, fmt.Errorf("gagal mengambil instrumen asesmen: %w", err)
+    }
+
+    views := make([]AssessmentInstrumentView, 0, len(instruments))
+    for _, instrument := range instruments {
+        def, err := ParseInstrumentDefinition(instrument.Definition)
+        if err != nil {
+            return nil, fmt.Errorf("instrumen %s versi %s: %w", instrument.Code, instrument.Version, err)
+        }
+        views = append(views, AssessmentInstrumentView{AssessmentInstrument: instrument, Parsed: def})
+    }
+    return views, nil
+}
+
+// GetInstrument retrieves an instrument with its parsed definition
+func (s *AssessmentService) GetInstrument(id uint) (*AssessmentInstrumentView, error) {
+    var instrument model.AssessmentInstrument
+    if err := s.db.First(&instrument, id).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("instrumen asesmen tidak ditemukan")
+        }
+        return nil, fmt.Errorf("gagal mengambil instrumen asesmen: %w", err)
+    }
+    def, err := ParseInstrumentDefinition(instrument.Definition)
+    if err != nil {
+        return nil, err
+    }
+    return &AssessmentInstrumentView{AssessmentInstrument: instrument, Parsed: def}, nil
+}
+
+// ImportInstrument adds an instrument from its JSON definition. A code and version pair
+// can only be imported once; publish changes as a new version.
+func (s *AssessmentService) ImportInstrument(definition string) (*model.AssessmentInstrument, error) {
+    def, err := ParseInstrumentDefinition(definition)
+    if err != nil {
+        return nil, err
+    }
+
+    var count int64
+    if err := s.db.Unscoped().Model(&model.AssessmentInstrument{}).
+        Where("code = ? AND version = ?", def.Code, def.Version).
+        Count(&count).Error; err != nil {
+        return nil, fmt.Errorf("gagal memeriksa instrumen asesmen: %w", err)
+    }
+    if count > 0 {
+        return nil, fmt.Errorf("instrumen %s versi %s sudah ada, gunakan nomor versi baru", def.Code, def.Version)
+    }
+
+    instrument := &model.AssessmentInstrument{
+        Code:        def.Code,
+        Version:     def.Version,
+        Name:        def.Name,
+        Description: def.Description,
+        Definition:  definition,
+        IsActive:    true,
+    }
+    err = s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Create(instrument).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionCreate, AuditEntityAssessmentInstrument, instrument.ID, nil, instrument)
+        return nil
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal menyimpan instrumen asesmen: %w", err)
+    }
+    return instrument, nil
+}
+
+// SetInstrumentActive shows or hides an instrument for new administrations
+func (s *AssessmentService) SetInstrumentActive(id uint, active bool) error {
+    var instrument model.AssessmentInstrument
+    if err := s.db.First(&instrument, id).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return errors.New("instrumen asesmen tidak ditemukan")
+        }
+        return fmt.Errorf("gagal mengambil instrumen asesmen: %w", err)
+    }
+
+    before := instrument
+    err := s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Model(&instrument).Update("is_active", active).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionUpdate, AuditEntityAssessmentInstrument, instrument.ID, before, instrument)
+        return nil
+    })
+    if err != nil {
+        return fmt.Errorf("gagal memperbarui instrumen asesmen: %w", err)
+    }
+    return nil
+}
+
+// ScoreResponses scores responses without saving them, e.g. for a live preview
+func (s *AssessmentService) ScoreResponses(instrumentID uint, responses map[string]string) (*AssessmentScore, error) {
+    instrument, err := s.GetInstrument(instrumentID)
+    if err != nil {
+        return nil, err
+    }
+    return instrument.Parsed.Score(responses)
+}
+
+// RecordAdministration scores and stores a completed instrument for a child
+func (s *AssessmentService) RecordAdministration(childID uint, input AdministrationInput, administeredBy string, therapistID *uint) (*AdministrationResult, error) {
+    var child model.Child
+    if err := s.db.First(&child, childID).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("data anak tidak ditemukan")
+        }
+        return nil, fmt.Errorf("gagal mengambil data anak: %w", err)
+    }
+    instrument, err := s.GetInstrument(input.InstrumentID)
+    if err != nil {
+        return nil, err
+    }
+    if !instrument.IsActive {
+        return nil, errors.New("instrumen asesmen sudah tidak aktif")
+    }
+    def := instrument.Parsed
+
+    administeredAt := time.Now()
+    if strings.TrimSpace(input.AdministeredAt) != "" {
+        date, err := ParseCalendarDate(input.AdministeredAt)
+        if err != nil {
+            return nil, err
+        }
+        if date.After(time.Now()) {
+            return nil, errors.New("tanggal asesmen tidak boleh di masa depan")
+        }
+        administeredAt = *date
+    }
+
+    if input.SessionID != nil {
+        var session model.Session
+        if err := s.db.First(&session, *input.SessionID).Error; err != nil {
+            if errors.Is(err, gorm.ErrRecordNotFound) {
+                return nil, errors.New("sesi tidak ditemukan")
+            }
+            return nil, fmt.Errorf("gagal mengambil data sesi: %w", err)
+        }
+        if session.ChildID != childID {
+            return nil, errors.New("sesi bukan milik anak ini")
+        }
+        if err := ensureSessionWritable(s.db, session.ID); err != nil {
+            return nil, err
+        }
+    }
+
+    informant := strings.TrimSpace(input.Informant)
+    if len(def.Informants) > 0 {
+        allowed := false
+        for _, candidate := range def.Informants {
+            allowed = allowed || candidate == informant
+        }
+        if !allowed {
+            return nil, fmt.Errorf("informan %q tidak sesuai untuk instrumen ini (pilihan: %s)", informant, strings.Join(def.Informants, ", "))
+        }
+    }
+
+    score, err := def.Score(input.Responses)
+    if err != nil {
+        return nil, err
+    }
+    responsesJSON, err := json.Marshal(input.Responses)
+    if err != nil {
+        return nil, err
+    }
+    scoresJSON, err := json.Marshal(score)
+    if err != nil {
+        return nil, err
+    }
+
+    administration := &model.AssessmentAdministration{
+        ChildID:        childID,
+        InstrumentID:   instrument.ID,
+        SessionID:      input.SessionID,
+        TherapistID:    therapistID,
+        AdministeredAt: administeredAt,
+        AdministeredBy: administeredBy,
+        Informant:      informant,
+        Responses:      string(responsesJSON),
+        Scores:         string(scoresJSON),
+        Notes:          strings.TrimSpace(input.Notes),
+    }
+    if score.Total != nil {
+        raw := score.Total.Raw
+        administration.TotalScore = &raw
+        administration.TotalBand = score.Total.Band
+    }
+
+    err = s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Create(administration).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionCreate, AuditEntityAssessment, administration.ID, nil, administration)
+        return nil
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal menyimpan hasil asesmen: %w", err)
+    }
+
+    administration.Instrument = instrument.AssessmentInstrument
+    return decodeAdministration(*administration, &child)
+}
+
+// GetAdministration retrieves one administration with its scores
+func (s *AssessmentService) GetAdministration(id uint) (*AdministrationResult, error) {
+    var administration model.AssessmentAdministration
+    if err := s.db.Preload("Instrument").First(&administration, id).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("hasil asesmen tidak ditemukan")
+        }
+        return nil, fmt.Errorf("gagal mengambil hasil asesmen: %w", err)
+    }
+    var child model.Child
+    if err := s.db.First(&child, administration.ChildID).Error; err != nil {
+        return nil, fmt.Errorf("gagal mengambil data anak: %w", err)
+    }
+    return decodeAdministration(administration, &child)
+}
+
+// GetAdministrationsByChild lists a child's administrations, newest first; instrumentCode filters to one instrument
+func (s *AssessmentService) GetAdministrationsByChild(childID uint, instrumentCode string) ([]AdministrationResult, error) {
+    var child model.Child
+    if err := s.db.First(&child, childID).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("data anak tidak ditemukan")
+        }
+        return nil, fmt.Errorf("gagal mengambil data anak: %w", err)
+    }
+
+    query := s.db.Preload("Instrument").
+        Where("assessment_administrations.child_id = ?", childID).
+        Order("assessment_administrations.administered_at DESC, assessment_administrations.id DESC")
+    if instrumentCode != "" {
+        query = query.Joins("JOIN assessment_instruments ON assessment_instruments.id = assessment_administrations.instrument_id").
+            Where("assessment_instruments.code = ?", instrumentCode)
+    }
+    var administrations []model.AssessmentAdministration
+    if err := query.Find(&administrations).Error; err != nil {
+        return nil, fmt.Errorf("gagal mengambil hasil asesmen: %w", err)
+    }
+
+    results := make([]AdministrationResult, 0, len(administrations))
+    for _, administration := range administrations {
+        result, err := decodeAdministration(administration, &child)
+        if err != nil {
+            return nil, err
+        }
+        results = append(results, *result)
+    }
+    return results, nil
+}
+
+// DeleteAdministration removes an administration entered in error (soft delete)
+func (s *AssessmentService) DeleteAdministration(id uint) (*model.AssessmentAdministration, error) {
+    var administration model.AssessmentAdministration
+    if err := s.db.First(&administration, id).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("hasil asesmen tidak ditemukan")
+        }
+        return nil, fmt.Errorf("gagal mengambil hasil asesmen: %w", err)
+    }
+    if administration.SessionID != nil {
+        if err := ensureSessionWritable(s.db, *administration.SessionID); err != nil {
+            return nil, err
+        }
+    }
+
+    err := s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Delete(&administration).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionDelete, AuditEntityAssessment, administration.ID, administration, nil)
+        return nil
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal menghapus hasil asesmen: %w", err)
+    }
+    return &administration, nil
+}
+
+// CompareAdministrations lists a child's administrations of an instrument, oldest first,
+// and the change of every score between the first and the latest one
+func (s *AssessmentService) CompareAdministrations(childID uint, instrumentCode string) (*AssessmentComparison, error) {
+    if instrumentCode == "" {
+        return nil, errors.New("kode instrumen harus diisi")
+    }
+    results, err := s.GetAdministrationsByChild(childID, instrumentCode)
+    if err != nil {
+        return nil, err
+    }
+    if len(results) == 0 {
+        return nil, errors.New("belum ada hasil asesmen untuk instrumen ini")
+    }
+    return compareResults(results)
+}
+
+// CompareAllAdministrations compares every instrument a child has been assessed with
+func (s *AssessmentService) CompareAllAdministrations(childID uint) ([]AssessmentComparison, error) {
+    results, err := s.GetAdministrationsByChild(childID, "")
+    if err != nil {
+        return nil, err
+    }
+    byCode := make(map[string][]AdministrationResult)
+    var codes []string
+    for _, result := range results {
+        if _, seen := byCode[result.InstrumentCode]; !seen {
+            codes = append(codes, result.InstrumentCode)
+        }
+        byCode[result.InstrumentCode] = append(byCode[result.InstrumentCode], result)
+    }
+    sort.Strings(codes)
+
+    comparisons := make([]AssessmentComparison, 0, len(codes))
+    for _, code := range codes {
+        comparison, err := compareResults(byCode[code])
+        if err != nil {
+            return nil, err
+        }
+        comparisons = append(comparisons, *comparison)
+    }
+    return comparisons, nil
+}
+
+// compareResults expects the administrations of one instrument, newest first
+func compareResults(results []AdministrationResult) (*AssessmentComparison, error) {
+    ordered := make([]AdministrationResult, len(results))
+    for i, result := range results {
+        ordered[len(results)-1-i] = result
+    }
+
+    latestInstrument := ordered[len(ordered)-1].Instrument
+    def, err := ParseInstrumentDefinition(latestInstrument.Definition)
+    if err != nil {
+        return nil, err
+    }
+    comparison := &AssessmentComparison{
+        InstrumentCode:  latestInstrument.Code,
+        InstrumentName:  latestInstrument.Name,
+        HigherIsBetter:  def.HigherIsBetter,
+        Administrations: ordered,
+        Changes:         make([]ScoreChange, 0),
+    }
+    if len(ordered) < 2 {
+        return comparison, nil
+    }
+
+    first, latest := ordered[0].Score, ordered[len(ordered)-1].Score
+    firstScores := make(map[string]ScoreResult)
+    for _, score := range first.Subscales {
+        firstScores[score.ID] = score
+    }
+    if first.Total != nil {
+        firstScores[first.Total.ID] = *first.Total
+    }
+
+    latestScores := append([]ScoreResult{}, latest.Subscales...)
+    if latest.Total != nil {
+        latestScores = append(latestScores, *latest.Total)
+    }
+    for _, score := range latestScores {
+        before, ok := firstScores[score.ID]
+        if !ok {
+            continue
+        }
+        change := ScoreChange{
+            ID:         score.ID,
+            Name:       score.Name,
+            First:      before.Raw,
+            Latest:     score.Raw,
+            Delta:      math.Round((score.Raw-before.Raw)*100) / 100,
+            FirstBand:  before.Band,
+            LatestBand: score.Band,
+            Direction:  ChangeUnchanged,
+        }
+        if change.Delta != 0 {
+            if (change.Delta > 0) == def.HigherIsBetter {
+                change.Direction = ChangeImproved
+            } else {
+                change.Direction = ChangeWorsened
+            }
+        }
+        comparison.Changes = append(comparison.Changes, change)
+    }
+    return comparison, nil
+}
+
+func decodeAdministration(administration model.AssessmentAdministration, child *model.Child) (*AdministrationResult, error) {
+    result := &AdministrationResult{
+        AssessmentAdministration: administration,
+        InstrumentCode:           administration.Instrument.Code,
+        InstrumentName:           administration.Instrument.Name,
+        ResponseValues:           make(map[string]string),
+    }
+    if administration.Responses != "" {
+        if err := json.Unmarshal([]byte(administration.Responses), &result.ResponseValues); err != nil {
+            return nil, fmt.Errorf("jawaban asesmen #%d rusak: %w", administration.ID, err)
+        }
+    }
+    if administration.Scores != "" {
+        if err := json.Unmarshal([]byte(administration.Scores), &result.Score); err != nil {
+            return nil, fmt.Errorf("skor asesmen #%d rusak: %w", administration.ID, err)
+        }
+    }
+    result.ChildAge = ChildAgeAt(*child, administration.AdministeredAt)
+    return result, nil
+}
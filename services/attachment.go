@@ -0,0 +1,542 @@
+package services
+
+import (
+	"childSessions/model"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Attachment types
+const (
+    AttachmentTypeReferralLetter   = "referral_letter"
+    AttachmentTypeConsentForm      = "consent_form"
+    AttachmentTypeSchoolReport     = "school_report"
+    AttachmentTypeAssessmentReport = "assessment_report"
+    AttachmentTypeWorkSample       = "work_sample"
+    AttachmentTypePhoto            = "photo"
+    AttachmentTypeOther            = "other"
+)
+
+// administrativeAttachmentTypes are handled by front desk staff; every other type
+// is clinical content and follows the note permissions
+var administrativeAttachmentTypes = map[string]bool{
+    AttachmentTypeReferralLetter: true,
+    AttachmentTypeConsentForm:    true,
+}
+
+var validAttachmentTypes = map[string]bool{
+    AttachmentTypeReferralLetter:   true,
+    AttachmentTypeConsentForm:      true,
+    AttachmentTypeSchoolReport:     true,
+    AttachmentTypeAssessmentReport: true,
+    AttachmentTypeWorkSample:       true,
+    AttachmentTypePhoto:            true,
+    AttachmentTypeOther:            true,
+}
+
+// Setting keys
+const (
+    SettingAttachmentEncryption    = "attachment_encryption"
+    SettingAttachmentEncryptionKey = "attachment_encryption_key" // Name of the key in the OS keychain; older versions kept it here
+)
+
+// MaxAttachmentBytes is the largest file that can be attached
+const MaxAttachmentBytes = 50 << 20
+
+// orphanedFileGracePeriod protects files that were written moments ago, such as an
+// upload still in progress or one whose record is not committed yet, from being
+// taken for orphans
+const orphanedFileGracePeriod = time.Hour
+
+// AttachmentPermission returns the permission needed to read or write an attachment of the given type
+func AttachmentPermission(attachmentType string, write bool) string {
+    if administrativeAttachmentTypes[attachmentType] {
+        if write {
+            return PermChildEditContact
+        }
+        return PermChildView
+    }
+    if write {
+        return PermNoteWrite
+    }
+    return PermNoteRead
+}
+
+// AttachmentInput holds the metadata of a file being attached
+type AttachmentInput struct {
+    ChildID      uint   `json:"child_id"`
+    SessionID    *uint  `json:"session_id"`
+    Type         string `json:"type"`
+    DocumentDate string `json:"document_date"`
+    Description  string `json:"description"`
+}
+
+// AttachmentVerification is the result of checking a stored file against its checksum
+type AttachmentVerification struct {
+    AttachmentID uint   `json:"attachment_id"`
+    FileName     string `json:"file_name"`
+    Valid        bool   `json:"valid"`
+    Message      string `json:"message"`
+}
+
+type AttachmentService struct {
+    db       *gorm.DB
+    audit    *AuditService
+    settings *SettingService
+    dir      string
+}
+
+// NewAttachmentService stores attachment files in dir, which is created when missing
+func NewAttachmentService(db *gorm.DB, audit *AuditService, settings *SettingService, dir string) *AttachmentService {
+    return &AttachmentService{db: db, audit: audit, settings: settings, dir: dir}
+}
+
+// IsEncryptionEnabled reports whether newly attached files are encrypted at rest
+func (s *AttachmentService) IsEncryptionEnabled() (bool, error) {
+    value, err := s.settings.Get(SettingAttachmentEncryption, "")
+    if err != nil {
+        return false, err
+    }
+    return value == "1", nil
+}
+
+// SetEncryptionEnabled turns encryption at rest on or off for newly attached files;
+// existing files keep the form they were stored in
+func (s *AttachmentService) SetEncryptionEnabled(enabled bool) error {
+    if enabled {
+        // Create the key up front so a broken key store is reported now, not on the next upload
+        if _, err := s.encryptionKey(); err != nil {
+            return err
+        }
+        return s.settings.Set(SettingAttachmentEncryption, "1")
+    }
+    return s.settings.Set(SettingAttachmentEncryption, "0")
+}
+
+// AttachFile copies a file into the attachments directory and records its metadata
+func (s *AttachmentService) AttachFile(sourcePath string, input AttachmentInput, uploadedBy string) (*model.Attachment, error) {
+    attachment := &model.Attachment{
+        ChildID:    input.ChildID,
+        SessionID:  input.SessionID,
+        FileName:   filepath.Base(sourcePath),
+        UploadedBy: uploadedBy,
+    }
+    if err := s.applyAttachmentInput(attachment, input); err != nil {
+        return nil, err
+    }
+
+    info, err := os.Stat(sourcePath)
+    if err != nil {
+        return nil, fmt.Errorf("gagal membaca file: %w", err)
+    }
+    if info.IsDir() {
+        return nil, errors.New("yang dipilih adalah folder, bukan file")
+    }
+    if info.Size() > MaxAttachmentBytes {
+        return nil, fmt.Errorf("ukuran file melebihi batas %d MB", MaxAttachmentBytes>>20)
+    }
+    content, err := os.ReadFile(sourcePath)
+    if err != nil {
+        return nil, fmt.Errorf("gagal membaca file: %w", err)
+    }
+
+    sum := sha256.Sum256(content)
+    attachment.Checksum = hex.EncodeToString(sum[:])
+    attachment.SizeBytes = int64(len(content))
+    attachment.MimeType = detectMimeType(attachment.FileName, content)
+
+    encrypted, err := s.IsEncryptionEnabled()
+    if err != nil {
+        return nil, err
+    }
+    if encrypted {
+        if content, err = s.encrypt(content); err != nil {
+            return nil, err
+        }
+    }
+    attachment.Encrypted = encrypted
+
+    storedName, err := randomStoredName()
+    if err != nil {
+        return nil, err
+    }
+    attachment.StoredName = storedName
+    if err := s.writeStoredFile(storedName, content); err != nil {
+        return nil, err
+    }
+
+    err = s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Create(attachment).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionCreate, AuditEntityAttachment, attachment.ID, nil, attachment)
+        return nil
+    })
+    if err != nil {
+        os.Remove(s.storedPath(storedName))
+        return nil, fmt.Errorf("gagal menyimpan lampiran: %w", err)
+    }
+    return attachment, nil
+}
+
+// GetAttachmentsByChild lists every attachment of a child, newest document first
+func (s *AttachmentService) GetAttachmentsByChild(childID uint) ([]model.Attachment, error) {
+    var attachments []model.Attachment
+    if err := s.db.Where("child_id = ?", childID).
+        Order("COALESCE(document_date, created_at) DESC, id DESC").
+        Find(&attachments).Error; err != nil {
+        return nil, fmt.Errorf("gagal mengambil lampiran: %w", err)
+    }
+    return attachments, nil
+}
+
+// GetAttachmentsBySession lists the attachments linked to a session
+func (s *AttachmentService) GetAttachmentsBySession(sessionID uint) ([]model.Attachment, error) {
+    var attachments []model.Attachment
+    if err := s.db.Where("session_id = ?", sessionID).
+        Order("created_at ASC, id ASC").
+        Find(&attachments).Error; err != nil {
+        return nil, fmt.Errorf("gagal mengambil lampiran sesi: %w", err)
+    }
+    return attachments, nil
+}
+
+// GetAttachmentByID retrieves an attachment's metadata
+func (s *AttachmentService) GetAttachmentByID(id uint) (*model.Attachment, error) {
+    var attachment model.Attachment
+    if err := s.db.First(&attachment, id).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("lampiran tidak ditemukan")
+        }
+        return nil, fmt.Errorf("gagal mengambil lampiran: %w", err)
+    }
+    return &attachment, nil
+}
+
+// ReadAttachment returns the original content of an attachment after decrypting
+// it and verifying its checksum
+func (s *AttachmentService) ReadAttachment(id uint) (*model.Attachment, []byte, error) {
+    attachment, err := s.GetAttachmentByID(id)
+    if err != nil {
+        return nil, nil, err
+    }
+    content, err := s.readContent(attachment)
+    if err != nil {
+        return nil, nil, err
+    }
+    return attachment, content, nil
+}
+
+// ExportAttachment writes the original content of an attachment to dir and returns the file path
+func (s *AttachmentService) ExportAttachment(id uint, dir string) (string, error) {
+    attachment, content, err := s.ReadAttachment(id)
+    if err != nil {
+        return "", err
+    }
+    if err := os.MkdirAll(dir, 0700); err != nil {
+        return "", fmt.Errorf("gagal membuat folder: %w", err)
+    }
+    path := filepath.Join(dir, fmt.Sprintf("%d_%s", attachment.ID, safeFileName(attachment.FileName)))
+    if err := os.WriteFile(path, content, 0600); err != nil {
+        return "", fmt.Errorf("gagal menulis file: %w", err)
+    }
+    return path, nil
+}
+
+// VerifyAttachment checks a stored file against the checksum recorded when it was attached
+func (s *AttachmentService) VerifyAttachment(id uint) (*AttachmentVerification, error) {
+    attachment, err := s.GetAttachmentByID(id)
+    if err != nil {
+        return nil, err
+    }
+    return s.verify(attachment), nil
+}
+
+// VerifyAllAttachments checks every stored file, including those in the trash
+func (s *AttachmentService) VerifyAllAttachments() ([]AttachmentVerification, error) {
+    var attachments []model.Attachment
+    if err := s.db.Unscoped().Order("id ASC").Find(&attachments).Error; err != nil {
+        return nil, fmt.Errorf("gagal mengambil lampiran: %w", err)
+    }
+    results := make([]AttachmentVerification, 0, len(attachments))
+    for i := range attachments {
+        results = append(results, *s.verify(&attachments[i]))
+    }
+    return results, nil
+}
+
+// DeleteAttachment moves an attachment to the trash; the file is removed when the trash is purged
+func (s *AttachmentService) DeleteAttachment(id uint) (*model.Attachment, error) {
+    attachment, err := s.GetAttachmentByID(id)
+    if err != nil {
+        return nil, err
+    }
+    if attachment.SessionID != nil {
+        if err := ensureSessionWritable(s.db, *attachment.SessionID); err != nil {
+            return nil, err
+        }
+    }
+
+    err = s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Delete(attachment).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionDelete, AuditEntityAttachment, attachment.ID, attachment, nil)
+        return nil
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal menghapus lampiran: %w", err)
+    }
+    return attachment, nil
+}
+
+// StoredFileNames lists the files of every attachment of a child, including those in
+// the trash, so they can be removed once the child is erased
+func (s *AttachmentService) StoredFileNames(childID uint) ([]string, error) {
+    var storedNames []string
+    if err := s.db.Unscoped().Model(&model.Attachment{}).Where("child_id = ?", childID).Pluck("stored_name", &storedNames).Error; err != nil {
+        return nil, fmt.Errorf("gagal mengambil lampiran: %w", err)
+    }
+    return storedNames, nil
+}
+
+// RemoveStoredFiles deletes the given files from the attachments directory straight
+// away, whatever their age; files already gone are skipped
+func (s *AttachmentService) RemoveStoredFiles(storedNames []string) error {
+    for _, name := range storedNames {
+        if err := os.Remove(s.storedPath(name)); err != nil && !os.IsNotExist(err) {
+            return fmt.Errorf("gagal menghapus file lampiran: %w", err)
+        }
+    }
+    return nil
+}
+
+// RemoveOrphanedFiles deletes files in the attachments directory that no attachment
+// record refers to any more, e.g. after a child was erased or the trash was purged.
+// Files changed within the grace period are left alone; a temporary upload file older
+// than that was left behind by an upload that never finished.
+func (s *AttachmentService) RemoveOrphanedFiles() (int, error) {
+    entries, err := os.ReadDir(s.dir)
+    if err != nil {
+        if os.IsNotExist(err) {
+            return 0, nil
+        }
+        return 0, fmt.Errorf("gagal membaca folder lampiran: %w", err)
+    }
+
+    var storedNames []string
+    if err := s.db.Unscoped().Model(&model.Attachment{}).Pluck("stored_name", &storedNames).Error; err != nil {
+        return 0, fmt.Errorf("gagal mengambil lampiran: %w", err)
+    }
+    known := make(map[string]bool, len(storedNames))
+    for _, name := range storedNames {
+        known[name] = true
+    }
+
+    removed := 0
+    for _, entry := range entries {
+        if entry.IsDir() || known[entry.Name()] {
+            continue
+        }
+        info, err := entry.Info()
+        if err != nil || time.Since(info.ModTime()) < orphanedFileGracePeriod {
+            continue
+        }
+        if err := os.Remove(filepath.Join(s.dir, entry.Name())); err != nil {
+            return removed, fmt.Errorf("gagal menghapus file lampiran: %w", err)
+        }
+        removed++
+    }
+    return removed, nil
+}
+
+func (s *AttachmentService) applyAttachmentInput(attachment *model.Attachment, input AttachmentInput) error {
+    if !validAttachmentTypes[input.Type] {
+        return fmt.Errorf("jenis lampiran tidak dikenal: %s", input.Type)
+    }
+    if err := ensureChildExists(s.db, input.ChildID); err != nil {
+        return err
+    }
+    if input.SessionID != nil {
+        var session model.Session
+        if err := s.db.First(&session, *input.SessionID).Error; err != nil {
+            if errors.Is(err, gorm.ErrRecordNotFound) {
+                return errors.New("sesi tidak ditemukan")
+            }
+            return fmt.Errorf("gagal mengambil data sesi: %w", err)
+        }
+        if session.ChildID != input.ChildID {
+            return errors.New("sesi bukan milik anak ini")
+        }
+        if err := ensureSessionWritable(s.db, session.ID); err != nil {
+            return err
+        }
+    }
+    documentDate, err := ParseCalendarDate(input.DocumentDate)
+    if err != nil {
+        return err
+    }
+
+    attachment.Type = input.Type
+    attachment.DocumentDate = documentDate
+    attachment.Description = strings.TrimSpace(input.Description)
+    return nil
+}
+
+func (s *AttachmentService) verify(attachment *model.Attachment) *AttachmentVerification {
+    result := &AttachmentVerification{AttachmentID: attachment.ID, FileName: attachment.FileName}
+    if _, err := s.readContent(attachment); err != nil {
+        result.Message = err.Error()
+        return result
+    }
+    result.Valid = true
+    result.Message = "File utuh, checksum cocok"
+    return result
+}
+
+// readContent reads, decrypts and verifies the stored file of an attachment
+func (s *AttachmentService) readContent(attachment *model.Attachment) ([]byte, error) {
+    content, err := os.ReadFile(s.storedPath(attachment.StoredName))
+    if err != nil {
+        if os.IsNotExist(err) {
+            return nil, fmt.Errorf("file lampiran %s tidak ditemukan", attachment.FileName)
+        }
+        return nil, fmt.Errorf("gagal membaca file lampiran: %w", err)
+    }
+    if attachment.Encrypted {
+        if content, err = s.decrypt(content); err != nil {
+            return nil, err
+        }
+    }
+    sum := sha256.Sum256(content)
+    if hex.EncodeToString(sum[:]) != attachment.Checksum {
+        return nil, fmt.Errorf("checksum file lampiran %s tidak cocok, file mungkin rusak atau diubah", attachment.FileName)
+    }
+    return content, nil
+}
+
+func (s *AttachmentService) storedPath(storedName string) string {
+    return filepath.Join(s.dir, storedName)
+}
+
+// writeStoredFile writes through a temporary file so a crash never leaves a partial attachment
+func (s *AttachmentService) writeStoredFile(storedName string, content []byte) error {
+    if err := os.MkdirAll(s.dir, 0700); err != nil {
+        return fmt.Errorf("gagal membuat folder lampiran: %w", err)
+    }
+    tmp, err := os.CreateTemp(s.dir, ".upload-*")
+    if err != nil {
+        return fmt.Errorf("gagal menyimpan file lampiran: %w", err)
+    }
+    if _, err := tmp.Write(content); err != nil {
+        tmp.Close()
+        os.Remove(tmp.Name())
+        return fmt.Errorf("gagal menyimpan file lampiran: %w", err)
+    }
+    if err := tmp.Close(); err != nil {
+        os.Remove(tmp.Name())
+        return fmt.Errorf("gagal menyimpan file lampiran: %w", err)
+    }
+    if err := os.Rename(tmp.Name(), s.storedPath(storedName)); err != nil {
+        os.Remove(tmp.Name())
+        return fmt.Errorf("gagal menyimpan file lampiran: %w", err)
+    }
+    return nil
+}
+
+// encrypt seals content with AES-256-GCM; the random nonce is stored in front of the ciphertext
+func (s *AttachmentService) encrypt(content []byte) ([]byte, error) {
+    gcm, err := s.cipher()
+    if err != nil {
+        return nil, err
+    }
+    nonce := make([]byte, gcm.NonceSize())
+    if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+        return nil, fmt.Errorf("gagal mengenkripsi lampiran: %w", err)
+    }
+    return gcm.Seal(nonce, nonce, content, nil), nil
+}
+
+func (s *AttachmentService) decrypt(content []byte) ([]byte, error) {
+    gcm, err := s.cipher()
+    if err != nil {
+        return nil, err
+    }
+    if len(content) < gcm.NonceSize() {
+        return nil, errors.New("file lampiran terenkripsi rusak")
+    }
+    nonce, ciphertext := content[:gcm.NonceSize()], content[gcm.NonceSize():]
+    plain, err := gcm.Open(nil, nonce, ciphertext, nil)
+    if err != nil {
+        return nil, errors.New("gagal mendekripsi lampiran, file rusak atau kunci tidak cocok")
+    }
+    return plain, nil
+}
+
+func (s *AttachmentService) cipher() (cipher.AEAD, error) {
+    key, err := s.encryptionKey()
+    if err != nil {
+        return nil, err
+    }
+    block, err := aes.NewCipher(key)
+    if err != nil {
+        return nil, fmt.Errorf("kunci enkripsi lampiran tidak valid: %w", err)
+    }
+    return cipher.NewGCM(block)
+}
+
+// encryptionKey returns the per-install AES-256 key, creating it on first use. It is
+// kept in the OS keychain, not in the database next to the files it protects.
+func (s *AttachmentService) encryptionKey() ([]byte, error) {
+    key, err := installationSecret(s.settings, SettingAttachmentEncryptionKey)
+    if err != nil {
+        return nil, fmt.Errorf("kunci enkripsi lampiran tidak tersedia: %w", err)
+    }
+    return key, nil
+}
+
+// randomStoredName gives each stored file an unguessable name unrelated to the child
+func randomStoredName() (string, error) {
+    buf := make([]byte, 16)
+    if _, err := rand.Read(buf); err != nil {
+        return "", fmt.Errorf("gagal membuat nama file lampiran: %w", err)
+    }
+    return hex.EncodeToString(buf), nil
+}
+
+func detectMimeType(fileName string, content []byte) string {
+    if mimeType := mime.TypeByExtension(strings.ToLower(filepath.Ext(fileName))); mimeType != "" {
+        return mimeType
+    }
+    return http.DetectContentType(content)
+}
+
+// safeFileName keeps a file name usable on every platform when exporting
+func safeFileName(name string) string {
+    name = strings.Map(func(r rune) rune {
+        switch r {
+        case '/', '\\', ':', '*', '?', '"', '<', '>', '|':
+            return '_'
+        }
+        return r
+    }, name)
+    if strings.TrimSpace(name) == "" {
+        return "lampiran"
+    }
+    return name
+}
+
@@ -0,0 +1,319 @@
+package services
+
+import (
+	"childSessions/model"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Audit actions
+const (
+    AuditActionCreate  = "create"
+    AuditActionUpdate  = "update"
+    AuditActionDelete  = "delete"
+    AuditActionRead    = "read"
+    AuditActionRestore = "restore"
+    AuditActionPurge   = "purge"
+)
+
+// Audited entity types
+const (
+    AuditEntityChild                = "child"
+    AuditEntitySession              = "session"
+    AuditEntityNote                 = "note"
+    AuditEntityNoteSection          = "note_section"
+    AuditEntityNoteTemplate         = "note_template"
+    AuditEntitySessionAddendum      = "session_addendum"
+    AuditEntitySessionActivity      = "session_activity"
+    AuditEntityActivity             = "activity"
+    AuditEntityReward               = "reward"
+    AuditEntityGoal                 = "goal"
+    AuditEntityFlashcard            = "flashcard"
+    AuditEntitySessionFlashcard     = "session_flashcard"
+    AuditEntityTherapist            = "therapist"
+    AuditEntityChildTherapist       = "child_therapist"
+    AuditEntitySessionReviewComment = "session_review_comment"
+    AuditEntityGuardian             = "guardian"
+    AuditEntityDiagnosis            = "diagnosis"
+    AuditEntityMedication           = "medication"
+    AuditEntitySensitivity          = "sensitivity"
+    AuditEntityAttachment           = "attachment"
+    AuditEntityConsent              = "consent"
+    AuditEntityAssessmentInstrument = "assessment_instrument"
+    AuditEntityAssessment           = "assessment"
+    AuditEntityChildMilestone       = "child_milestone"
+    AuditEntitySessionPlan          = "session_plan"
+    AuditEntitySessionPlanItem      = "session_plan_item"
+    AuditEntitySessionTemplate      = "session_template"
+    AuditEntitySessionParticipant   = "session_participant"
+    AuditEntityWaitlistEntry        = "waitlist_entry"
+    AuditEntityRateCard             = "rate_card"
+    AuditEntityInvoice              = "invoice"
+    AuditEntityPayment              = "payment"
+    AuditEntitySessionPackage       = "session_package"
+    AuditEntityPackageUsage         = "package_usage"
+)
+
+// AuditFilter narrows an audit log query; zero values are ignored
+type AuditFilter struct {
+    Actor      string `json:"actor"`
+    Action     string `json:"action"`
+    EntityType string `json:"entity_type"`
+    EntityID   uint   `json:"entity_id"`
+    From       string `json:"from"` // "2006-01-02", inclusive
+    To         string `json:"to"`   // "2006-01-02", inclusive
+    Limit      int    `json:"limit"`
+    Offset     int    `json:"offset"`
+}
+
+// AuditVerification is the result of checking the audit hash chain
+type AuditVerification struct {
+    Valid         bool      `json:"valid"`
+    CheckedCount  int       `json:"checked_count"`
+    FirstBrokenID uint      `json:"first_broken_id"`
+    Message       string    `json:"message"`
+    VerifiedAt    time.Time `json:"verified_at"`
+}
+
+// AuditTrail collects the audit entries of one transaction
+type AuditTrail struct {
+    entries []model.AuditLog
+    after   []func(tx *gorm.DB, entries []model.AuditLog) error
+}
+
+// Add queues an audit entry; before and after are hashed immediately so later
+// mutations of the same values do not change what is recorded
+func (t *AuditTrail) Add(action, entityType string, entityID uint, before, after interface{}) {
+    t.entries = append(t.entries, model.AuditLog{
+        Action:     action,
+        EntityType: entityType,
+        EntityID:   entityID,
+        BeforeHash: hashAuditValue(before),
+        AfterHash:  hashAuditValue(after),
+    })
+}
+
+// After runs fn in the same transaction once the entries are written to the chain,
+// for records that must refer to the hashes of their own audit entries
+func (t *AuditTrail) After(fn func(tx *gorm.DB, entries []model.AuditLog) error) {
+    t.after = append(t.after, fn)
+}
+
+func (t *AuditTrail) runAfter(tx *gorm.DB) error {
+    for _, fn := range t.after {
+        if err := fn(tx, t.entries); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+type AuditService struct {
+    db    *gorm.DB
+    mu    sync.Mutex
+    actor string
+}
+
+func NewAuditService(db *gorm.DB) *AuditService {
+    return &AuditService{db: db}
+}
+
+// SetActor sets who is recorded as performing subsequent actions
+func (s *AuditService) SetActor(actor string) {
+    if s == nil {
+        return
+    }
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.actor = actor
+}
+
+// Actor returns who is currently recorded as performing actions
+func (s *AuditService) Actor() string {
+    if s == nil {
+        return ""
+    }
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return s.actor
+}
+
+// Transaction runs fn in a database transaction and appends the entries it adds to
+// the audit chain in that same transaction. Audited writes are serialised so the
+// chain stays linear. A nil AuditService runs fn without auditing.
+func (s *AuditService) Transaction(db *gorm.DB, fn func(tx *gorm.DB, trail *AuditTrail) error) error {
+    trail := &AuditTrail{}
+    if s == nil {
+        return db.Transaction(func(tx *gorm.DB) error {
+            if err := fn(tx, trail); err != nil {
+                return err
+            }
+            return trail.runAfter(tx)
+        })
+    }
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    return db.Transaction(func(tx *gorm.DB) error {
+        if err := fn(tx, trail); err != nil {
+            return err
+        }
+        if err := s.appendEntries(tx, trail.entries); err != nil {
+            return err
+        }
+        return trail.runAfter(tx)
+    })
+}
+
+// RecordRead logs that a sensitive record was viewed
+func (s *AuditService) RecordRead(entityType string, entityID uint) error {
+    if s == nil {
+        return nil
+    }
+    return s.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        trail.Add(AuditActionRead, entityType, entityID, nil, nil)
+        return nil
+    })
+}
+
+// appendEntries links entries to the end of the chain, filling in their IDs and
+// hashes; the caller must hold s.mu
+func (s *AuditService) appendEntries(tx *gorm.DB, entries []model.AuditLog) error {
+    if len(entries) == 0 {
+        return nil
+    }
+
+    var last model.AuditLog
+    prevHash := ""
+    err := tx.Order("id DESC").Limit(1).Find(&last).Error
+    if err != nil {
+        return fmt.Errorf("gagal membaca log audit terakhir: %w", err)
+    }
+    if last.ID != 0 {
+        prevHash = last.Hash
+    }
+
+    now := time.Now().UTC().Truncate(time.Microsecond)
+    for i := range entries {
+        entry := &entries[i]
+        entry.Timestamp = now
+        entry.Actor = s.actor
+        entry.PrevHash = prevHash
+        entry.Hash = computeAuditHash(*entry)
+        if err := tx.Create(entry).Error; err != nil {
+            return fmt.Errorf("gagal menulis log audit: %w", err)
+        }
+        prevHash = entry.Hash
+    }
+    return nil
+}
+
+// QueryAuditLogs retrieves audit entries matching the filter, newest first
+func (s *AuditService) QueryAuditLogs(filter AuditFilter) ([]model.AuditLog, error) {
+    query := s.db.Model(&model.AuditLog{})
+    if filter.Actor != "" {
+        query = query.Where("actor = ?", filter.Actor)
+    }
+    if filter.Action != "" {
+        query = query.Where("action = ?", filter.Action)
+    }
+    if filter.EntityType != "" {
+        query = query.Where("entity_type = ?", filter.EntityType)
+    }
+    if filter.EntityID != 0 {
+        query = query.Where("entity_id = ?", filter.EntityID)
+    }
+    if filter.From != "" {
+        from, err := time.ParseInLocation("2006-01-02", filter.From, time.Local)
+        if err != nil {
+            return nil, errors.New("format tanggal awal tidak valid (YYYY-MM-DD)")
+        }
+        query = query.Where("timestamp >= ?", from.UTC())
+    }
+    if filter.To != "" {
+        to, err := time.ParseInLocation("2006-01-02", filter.To, time.Local)
+        if err != nil {
+            return nil, errors.New("format tanggal akhir tidak valid (YYYY-MM-DD)")
+        }
+        query = query.Where("timestamp < ?", to.AddDate(0, 0, 1).UTC())
+    }
+
+    limit := filter.Limit
+    if limit <= 0 || limit > 1000 {
+        limit = 200
+    }
+
+    var logs []model.AuditLog
+    if err := query.Order("id DESC").Limit(limit).Offset(filter.Offset).Find(&logs).Error; err != nil {
+        return nil, fmt.Errorf("gagal mengambil log audit: %w", err)
+    }
+    return logs, nil
+}
+
+// VerifyAuditChain recomputes every hash in the audit log and checks the links between entries
+func (s *AuditService) VerifyAuditChain() (*AuditVerification, error) {
+    result := &AuditVerification{Valid: true, VerifiedAt: time.Now()}
+    prevHash := ""
+
+    var batch []model.AuditLog
+    err := s.db.Order("id ASC").FindInBatches(&batch, 500, func(tx *gorm.DB, _ int) error {
+        for _, entry := range batch {
+            result.CheckedCount++
+            if entry.PrevHash != prevHash || entry.Hash != computeAuditHash(entry) {
+                result.Valid = false
+                result.FirstBrokenID = entry.ID
+                result.Message = fmt.Sprintf("rantai hash log audit rusak pada entri #%d", entry.ID)
+                return errStopVerification
+            }
+            prevHash = entry.Hash
+        }
+        return nil
+    }).Error
+    if err != nil && !errors.Is(err, errStopVerification) {
+        return nil, fmt.Errorf("gagal memverifikasi log audit: %w", err)
+    }
+
+    if result.Valid {
+        result.Message = "log audit utuh"
+    }
+    return result, nil
+}
+
+var errStopVerification = errors.New("stop verification")
+
+// computeAuditHash hashes an entry together with the hash of the entry before it
+func computeAuditHash(entry model.AuditLog) string {
+    payload := fmt.Sprintf("%s|%s|%s|%s|%s|%d|%s|%s",
+        entry.PrevHash,
+        entry.Timestamp.UTC().Format(time.RFC3339Nano),
+        entry.Actor,
+        entry.Action,
+        entry.EntityType,
+        entry.EntityID,
+        entry.BeforeHash,
+        entry.AfterHash,
+    )
+    sum := sha256.Sum256([]byte(payload))
+    return hex.EncodeToString(sum[:])
+}
+
+// hashAuditValue hashes the JSON form of a record; nil hashes to an empty string
+func hashAuditValue(value interface{}) string {
+    if value == nil {
+        return ""
+    }
+    data, err := json.Marshal(value)
+    if err != nil {
+        return ""
+    }
+    sum := sha256.Sum256(data)
+    return hex.EncodeToString(sum[:])
+}
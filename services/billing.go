@@ -0,0 +1,854 @@
+package services
+
+import (
+	"childSessions/model"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Invoice statuses
+const (
+    InvoiceStatusUnpaid        = "unpaid"
+    InvoiceStatusPartiallyPaid = "partially_paid"
+    InvoiceStatusPaid          = "paid"
+    InvoiceStatusVoid          = "void"
+)
+
+// Payment methods
+const (
+    PaymentMethodCash     = "cash"
+    PaymentMethodTransfer = "transfer"
+    PaymentMethodCard     = "card"
+    PaymentMethodOther    = "other"
+)
+
+// Billing setting keys
+const (
+    SettingClinicName          = "clinic_name"
+    SettingClinicAddress       = "clinic_address"
+    SettingPaymentInstructions = "payment_instructions" // e.g., bank account details printed on invoices
+    SettingInvoicePrefix       = "invoice_prefix"
+    SettingInvoiceDueDays      = "invoice_due_days"
+)
+
+// DefaultInvoiceDueDays is how long families have to pay unless configured otherwise
+const DefaultInvoiceDueDays = 14
+
+// RateCardInput holds the editable fields of a rate
+type RateCardInput struct {
+    TherapyType     string `json:"therapy_type"` // Empty for the general rate
+    DurationMinutes int    `json:"duration_minutes"`
+    IsGroup         bool   `json:"is_group"`
+    Amount          int64  `json:"amount"`
+    Description     string `json:"description"`
+}
+
+// BillingSettings are printed on invoices and decide their numbering and due date
+type BillingSettings struct {
+    ClinicName          string `json:"clinic_name"`
+    ClinicAddress       string `json:"clinic_address"`
+    PaymentInstructions string `json:"payment_instructions"`
+    InvoicePrefix       string `json:"invoice_prefix"` // Numbers look like PREFIX-2026-0001
+    DueDays             int    `json:"due_days"`
+}
+
+// InvoiceDraft is what an invoice for a period would contain, before it is issued
+type InvoiceDraft struct {
+    ChildID     uint                `json:"child_id"`
+    PeriodStart time.Time           `json:"period_start"`
+    PeriodEnd   time.Time           `json:"period_end"`
+    Lines       []model.InvoiceLine `json:"lines"`
+    Total       int64               `json:"total"`
+    Unpriced    []UnpricedSession   `json:"unpriced"` // Sessions without a matching rate; they block issuing
+}
+
+// UnpricedSession is an attended session no rate card applies to
+type UnpricedSession struct {
+    SessionID       uint      `json:"session_id"`
+    Date            time.Time `json:"date"`
+    TherapyType     string    `json:"therapy_type"`
+    DurationMinutes int       `json:"duration_minutes"`
+    IsGroup         bool      `json:"is_group"`
+}
+
+// InvoiceFilter narrows the invoice list; zero values do not filter
+type InvoiceFilter struct {
+    ChildID  uint     `json:"child_id"`
+    Statuses []string `json:"statuses"`
+    From     string   `json:"from"` // Issue date, inclusive
+    To       string   `json:"to"`
+}
+
+// PaymentInput describes money received against an invoice
+type PaymentInput struct {
+    Amount    int64  `json:"amount"`
+    PaidAt    string `json:"paid_at"` // Date; defaults to today
+    Method    string `json:"method"`
+    Reference string `json:"reference"`
+    Notes     string `json:"notes"`
+}
+
+// OutstandingBalance is what a family still owes, split by how long it is overdue
+type OutstandingBalance struct {
+    ChildID       uint       `json:"child_id"`
+    ChildName     string     `json:"child_name"`
+    BillTo        string     `json:"bill_to"`
+    InvoiceCount  int        `json:"invoice_count"`
+    Invoiced      int64      `json:"invoiced"`
+    Paid          int64      `json:"paid"`
+    Outstanding   int64      `json:"outstanding"`
+    NotYetDue     int64      `json:"not_yet_due"`
+    Overdue1To30  int64      `json:"overdue_1_30"`
+    Overdue31To60 int64      `json:"overdue_31_60"`
+    Overdue61To90 int64      `json:"overdue_61_90"`
+    OverdueOver90 int64      `json:"overdue_over_90"`
+    OldestDueDate *time.Time `json:"oldest_due_date"`
+}
+
+type BillingService struct {
+    db       *gorm.DB
+    audit    *AuditService
+    settings *SettingService
+}
+
+func NewBillingService(db *gorm.DB, audit *AuditService, settings *SettingService) *BillingService {
+    return &BillingService{db: db, audit: audit, settings: settings}
+}
+
+// GetRateCards lists the rates, optionally with archived ones
+func (s *BillingService) GetRateCards(includeArchived bool) ([]model.RateCard, error) {
+    query := s.db.Order("therapy_type ASC, is_group ASC, duration_minutes ASC")
+    if !includeArchived {
+        query = query.Where("archived_at IS NULL")
+    }
+    var cards []model.RateCard
+    if err := query.Find(&cards).Error; err != nil {
+        return nil, fmt.Errorf("gagal mengambil daftar tarif: %w", err)
+    }
+    return cards, nil
+}
+
+// CreateRateCard adds a rate for a therapy type and session length
+func (s *BillingService) CreateRateCard(input RateCardInput) (*model.RateCard, error) {
+    card := &model.RateCard{}
+    if err := s.applyRateCardInput(card, input); err != nil {
+        return nil, err
+    }
+    err := s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Create(card).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionCreate, AuditEntityRateCard, card.ID, nil, card)
+        return nil
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal menyimpan tarif: %w", err)
+    }
+    return card, nil
+}
+
+// UpdateRateCard changes a rate. Invoices already issued keep the amounts they were
+// issued with.
+func (s *BillingService) UpdateRateCard(cardID uint, input RateCardInput) (*model.RateCard, error) {
+    card, err := s.getRateCard(cardID)
+    if err != nil {
+        return nil, err
+    }
+    before := *card
+    if err := s.applyRateCardInput(card, input); err != nil {
+        return nil, err
+    }
+    err = s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Save(card).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionUpdate, AuditEntityRateCard, card.ID, before, card)
+        return nil
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal menyimpan tarif: %w", err)
+    }
+    return card, nil
+}
+
+// ArchiveRateCard stops charging a rate
+func (s *BillingService) ArchiveRateCard(cardID uint) (*model.RateCard, error) {
+    card, err := s.getRateCard(cardID)
+    if err != nil {
+        return nil, err
+    }
+    if card.ArchivedAt != nil {
+        return card, nil
+    }
+    before := *card
+    now := time.Now()
+    card.ArchivedAt = &now
+    err = s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Model(card).Update("archived_at", now).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionUpdate, AuditEntityRateCard, card.ID, before, card)
+        return nil
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal mengarsipkan tarif: %w", err)
+    }
+    return card, nil
+}
+
+// SetSessionTherapyType sets the therapy type a session is billed as. Sessions already
+// on an invoice must have the invoice voided first.
+func (s *BillingService) SetSessionTherapyType(sessionID uint, therapyType string) (*model.Session, error) {
+    var session model.Session
+    if err := s.db.First(&session, sessionID).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("sesi tidak ditemukan")
+        }
+        return nil, fmt.Errorf("gagal mengambil data sesi: %w", err)
+    }
+    if err := ensureSessionNotInvoiced(s.db, sessionID); err != nil {
+        return nil, err
+    }
+
+    before := session
+    session.TherapyType = strings.TrimSpace(therapyType)
+    err := s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Model(&session).Update("therapy_type", session.TherapyType).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionUpdate, AuditEntitySession, session.ID, before, session)
+        return nil
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal menyimpan jenis terapi sesi: %w", err)
+    }
+    return &session, nil
+}
+
+// GetBillingSettings returns the clinic details and invoice options
+func (s *BillingService) GetBillingSettings() (*BillingSettings, error) {
+    settings := &BillingSettings{}
+    var err error
+    if settings.ClinicName, err = s.settings.Get(SettingClinicName, ""); err != nil {
+        return nil, err
+    }
+    if settings.ClinicAddress, err = s.settings.Get(SettingClinicAddress, ""); err != nil {
+        return nil, err
+    }
+    if settings.PaymentInstructions, err = s.settings.Get(SettingPaymentInstructions, ""); err != nil {
+        return nil, err
+    }
+    if settings.InvoicePrefix, err = s.settings.Get(SettingInvoicePrefix, "INV"); err != nil {
+        return nil, err
+    }
+    if settings.DueDays, err = s.settings.GetInt(SettingInvoiceDueDays, DefaultInvoiceDueDays); err != nil {
+        return nil, err
+    }
+    return settings, nil
+}
+
+// SaveBillingSettings stores the clinic details and invoice options
+func (s *BillingService) SaveBillingSettings(input BillingSettings) (*BillingSettings, error) {
+    prefix := strings.ToUpper(strings.TrimSpace(input.InvoicePrefix))
+    if prefix == "" {
+        prefix = "INV"
+    }
+    for _, r := range prefix {
+        if !(r >= 'A' && r <= 'Z') && !(r >= '0' && r <= '9') {
+            return nil, errors.New("awalan nomor tagihan hanya boleh berisi huruf dan angka")
+        }
+    }
+    if input.DueDays < 0 {
+        return nil, errors.New("jangka waktu pembayaran tidak boleh negatif")
+    }
+
+    values := map[string]string{
+        SettingClinicName:          strings.TrimSpace(input.ClinicName),
+        SettingClinicAddress:       strings.TrimSpace(input.ClinicAddress),
+        SettingPaymentInstructions: strings.TrimSpace(input.PaymentInstructions),
+        SettingInvoicePrefix:       prefix,
+    }
+    for key, value := range values {
+        if err := s.settings.Set(key, value); err != nil {
+            return nil, err
+        }
+    }
+    if err := s.settings.SetInt(SettingInvoiceDueDays, input.DueDays); err != nil {
+        return nil, err
+    }
+    return s.GetBillingSettings()
+}
+
+// PreviewInvoice works out what an invoice for a child's sessions between start and end
+// (exclusive) would contain. Sessions that were attended, have ended, are not on another
+// invoice and were not paid for from a prepaid package are billed.
+func (s *BillingService) PreviewInvoice(childID uint, start, end time.Time) (*InvoiceDraft, error) {
+    if err := ensureChildExists(s.db, childID); err != nil {
+        return nil, err
+    }
+    if start.IsZero() || end.IsZero() {
+        return nil, errors.New("periode tagihan harus diisi")
+    }
+
+    var sessions []model.Session
+    if err := s.db.Where("id IN (?) AND end_time IS NOT NULL AND start_time >= ? AND start_time < ?", AttendedSessionIDs(s.db, childID), start, end).
+        Where("id NOT IN (?)", s.db.Model(&model.InvoiceLine{}).Select("session_id").Where("invoice_id IN (?)", liveInvoiceIDs(s.db).Where("child_id = ?", childID))).
+        Where("id NOT IN (?)", PackageSessionIDs(s.db, childID)).
+        Order("start_time ASC").Find(&sessions).Error; err != nil {
+        return nil, fmt.Errorf("gagal mengambil sesi yang akan ditagihkan: %w", err)
+    }
+
+    cards, err := s.GetRateCards(false)
+    if err != nil {
+        return nil, err
+    }
+    referralType, err := s.referralTherapyType(childID)
+    if err != nil {
+        return nil, err
+    }
+
+    draft := &InvoiceDraft{
+        ChildID:     childID,
+        PeriodStart: start,
+        PeriodEnd:   end.AddDate(0, 0, -1),
+        Lines:       []model.InvoiceLine{},
+        Unpriced:    []UnpricedSession{},
+    }
+    for _, session := range sessions {
+        therapyType := session.TherapyType
+        if therapyType == "" {
+            therapyType = referralType
+        }
+        card := matchRateCard(cards, therapyType, session.DurationMinutes, session.IsGroup)
+        if card == nil {
+            draft.Unpriced = append(draft.Unpriced, UnpricedSession{
+                SessionID:       session.ID,
+                Date:            session.StartTime,
+                TherapyType:     therapyType,
+                DurationMinutes: session.DurationMinutes,
+                IsGroup:         session.IsGroup,
+            })
+            continue
+        }
+        cardID := card.ID
+        draft.Lines = append(draft.Lines, model.InvoiceLine{
+            SessionID:       session.ID,
+            RateCardID:      &cardID,
+            SessionDate:     session.StartTime,
+            Description:     invoiceLineDescription(session, therapyType, card),
+            TherapyType:     therapyType,
+            DurationMinutes: session.DurationMinutes,
+            Amount:          card.Amount,
+        })
+        draft.Total += card.Amount
+    }
+    return draft, nil
+}
+
+// GenerateInvoice issues an invoice for a child's sessions between start and end
+// (exclusive). Every session must have a matching rate.
+func (s *BillingService) GenerateInvoice(childID uint, start, end time.Time, notes, actor string) (*model.Invoice, error) {
+    draft, err := s.PreviewInvoice(childID, start, end)
+    if err != nil {
+        return nil, err
+    }
+    if len(draft.Unpriced) > 0 {
+        first := draft.Unpriced[0]
+        return nil, fmt.Errorf("%d sesi belum memiliki tarif, misalnya sesi %s (%s, %d menit)",
+            len(draft.Unpriced), first.Date.Format("02/01/2006"), therapyTypeLabel(first.TherapyType), first.DurationMinutes)
+    }
+    if len(draft.Lines) == 0 {
+        return nil, errors.New("tidak ada sesi yang dapat ditagihkan pada periode ini")
+    }
+    settings, err := s.GetBillingSettings()
+    if err != nil {
+        return nil, err
+    }
+    billTo, err := s.billTo(childID)
+    if err != nil {
+        return nil, err
+    }
+
+    sessionIDs := make([]uint, 0, len(draft.Lines))
+    for _, line := range draft.Lines {
+        sessionIDs = append(sessionIDs, line.SessionID)
+    }
+
+    now := time.Now()
+    invoice := &model.Invoice{
+        ChildID:     childID,
+        BillTo:      billTo,
+        PeriodStart: draft.PeriodStart,
+        PeriodEnd:   draft.PeriodEnd,
+        IssuedAt:    now,
+        DueDate:     startOfDay(now).AddDate(0, 0, settings.DueDays),
+        Total:       draft.Total,
+        Status:      InvoiceStatusUnpaid,
+        Notes:       strings.TrimSpace(notes),
+    }
+    err = s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        // The draft was worked out before the transaction; a request submitted twice must
+        // not bill the same sessions on a second invoice
+        var invoiced int64
+        if err := tx.Model(&model.InvoiceLine{}).
+            Where("session_id IN ? AND invoice_id IN (?)", sessionIDs, liveInvoiceIDs(tx).Where("child_id = ?", childID)).
+            Count(&invoiced).Error; err != nil {
+            return err
+        }
+        if invoiced > 0 {
+            return errors.New("sebagian sesi sudah ditagihkan; muat ulang pratinjau tagihan")
+        }
+
+        number, err := nextInvoiceNumber(tx, settings.InvoicePrefix, now.Year())
+        if err != nil {
+            return err
+        }
+        invoice.Number = number
+        if err := tx.Omit("Child", "Lines", "Payments").Create(invoice).Error; err != nil {
+            return err
+        }
+        for i := range draft.Lines {
+            draft.Lines[i].InvoiceID = invoice.ID
+        }
+        if err := tx.Create(&draft.Lines).Error; err != nil {
+            return err
+        }
+        invoice.Lines = draft.Lines
+        trail.Add(AuditActionCreate, AuditEntityInvoice, invoice.ID, nil, invoice)
+        return nil
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal membuat tagihan: %w", err)
+    }
+    return s.GetInvoice(invoice.ID)
+}
+
+// VoidInvoice cancels an invoice that has no payments; its sessions can be billed again
+func (s *BillingService) VoidInvoice(invoiceID uint, reason string) (*model.Invoice, error) {
+    reason = strings.TrimSpace(reason)
+    if reason == "" {
+        return nil, errors.New("alasan pembatalan tagihan harus diisi")
+    }
+    invoice, err := s.GetInvoice(invoiceID)
+    if err != nil {
+        return nil, err
+    }
+    if invoice.Status == InvoiceStatusVoid {
+        return nil, errors.New("tagihan sudah dibatalkan")
+    }
+    if len(invoice.Payments) > 0 {
+        return nil, errors.New("tagihan sudah memiliki pembayaran; hapus pembayarannya terlebih dahulu")
+    }
+
+    before := *invoice
+    now := time.Now()
+    invoice.Status = InvoiceStatusVoid
+    invoice.VoidedAt = &now
+    invoice.VoidReason = reason
+    err = s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Model(invoice).Updates(map[string]interface{}{
+            "status":      invoice.Status,
+            "voided_at":   now,
+            "void_reason": reason,
+        }).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionUpdate, AuditEntityInvoice, invoice.ID, before, invoice)
+        return nil
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal membatalkan tagihan: %w", err)
+    }
+    return invoice, nil
+}
+
+// RecordPayment records money received against an invoice; it cannot exceed what is
+// still owed
+func (s *BillingService) RecordPayment(invoiceID uint, input PaymentInput, actor string) (*model.Invoice, error) {
+    invoice, err := s.GetInvoice(invoiceID)
+    if err != nil {
+        return nil, err
+    }
+    if invoice.Status == InvoiceStatusVoid {
+        return nil, errors.New("tagihan sudah dibatalkan")
+    }
+    if input.Amount <= 0 {
+        return nil, errors.New("jumlah pembayaran harus lebih dari nol")
+    }
+    if outstanding := invoice.Total - invoice.PaidAmount; input.Amount > outstanding {
+        return nil, fmt.Errorf("pembayaran melebihi sisa tagihan (%s)", FormatRupiah(outstanding))
+    }
+    method := strings.TrimSpace(input.Method)
+    switch method {
+    case PaymentMethodCash, PaymentMethodTransfer, PaymentMethodCard, PaymentMethodOther:
+    default:
+        return nil, fmt.Errorf("metode pembayaran tidak valid: %q", input.Method)
+    }
+    paidAt := startOfDay(time.Now())
+    if date, err := ParseCalendarDate(input.PaidAt); err != nil {
+        return nil, err
+    } else if date != nil {
+        if date.After(time.Now()) {
+            return nil, errors.New("tanggal pembayaran tidak boleh di masa depan")
+        }
+        paidAt = *date
+    }
+
+    payment := &model.Payment{
+        InvoiceID:  invoice.ID,
+        Amount:     input.Amount,
+        PaidAt:     paidAt,
+        Method:     method,
+        Reference:  strings.TrimSpace(input.Reference),
+        Notes:      strings.TrimSpace(input.Notes),
+        RecordedBy: actor,
+    }
+    err = s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Create(payment).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionCreate, AuditEntityPayment, payment.ID, nil, payment)
+        return updateInvoicePaid(tx, trail, invoice)
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal mencatat pembayaran: %w", err)
+    }
+    return s.GetInvoice(invoice.ID)
+}
+
+// GetPayment returns a recorded payment
+func (s *BillingService) GetPayment(paymentID uint) (*model.Payment, error) {
+    var payment model.Payment
+    if err := s.db.First(&payment, paymentID).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("pembayaran tidak ditemukan")
+        }
+        return nil, fmt.Errorf("gagal mengambil pembayaran: %w", err)
+    }
+    return &payment, nil
+}
+
+// DeletePayment removes a payment recorded by mistake
+func (s *BillingService) DeletePayment(paymentID uint) (*model.Invoice, error) {
+    payment, err := s.GetPayment(paymentID)
+    if err != nil {
+        return nil, err
+    }
+    invoice, err := s.GetInvoice(payment.InvoiceID)
+    if err != nil {
+        return nil, err
+    }
+    err = s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Delete(payment).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionDelete, AuditEntityPayment, payment.ID, payment, nil)
+        return updateInvoicePaid(tx, trail, invoice)
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal menghapus pembayaran: %w", err)
+    }
+    return s.GetInvoice(invoice.ID)
+}
+
+// GetInvoice returns an invoice with its lines and payments
+func (s *BillingService) GetInvoice(invoiceID uint) (*model.Invoice, error) {
+    var invoice model.Invoice
+    err := s.db.Preload("Child").
+        Preload("Lines", func(db *gorm.DB) *gorm.DB { return db.Order("session_date ASC, id ASC") }).
+        Preload("Payments", func(db *gorm.DB) *gorm.DB { return db.Order("paid_at ASC, id ASC") }).
+        First(&invoice, invoiceID).Error
+    if err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("tagihan tidak ditemukan")
+        }
+        return nil, fmt.Errorf("gagal mengambil tagihan: %w", err)
+    }
+    return &invoice, nil
+}
+
+// GetInvoices lists invoices, newest first. childIDs limits the result when not nil.
+func (s *BillingService) GetInvoices(filter InvoiceFilter, childIDs []uint) ([]model.Invoice, error) {
+    start, end, err := ParseDateRange(filter.From, filter.To)
+    if err != nil {
+        return nil, err
+    }
+    query := s.db.Preload("Child")
+    if filter.ChildID != 0 {
+        query = query.Where("child_id = ?", filter.ChildID)
+    }
+    if len(filter.Statuses) > 0 {
+        query = query.Where("status IN ?", filter.Statuses)
+    }
+    if !start.IsZero() {
+        query = query.Where("issued_at >= ?", start)
+    }
+    if !end.IsZero() {
+        query = query.Where("issued_at < ?", end)
+    }
+    if childIDs != nil {
+        query = query.Where("child_id IN ?", childIDs)
+    }
+    var invoices []model.Invoice
+    if err := query.Order("issued_at DESC, id DESC").Find(&invoices).Error; err != nil {
+        return nil, fmt.Errorf("gagal mengambil daftar tagihan: %w", err)
+    }
+    return invoices, nil
+}
+
+// GetOutstandingBalances reports what each family still owes as of a date, largest first,
+// split by how long it has been overdue. childIDs limits the result when not nil.
+func (s *BillingService) GetOutstandingBalances(asOf time.Time, childIDs []uint) ([]OutstandingBalance, error) {
+    query := s.db.Preload("Child").Where("status IN ?", []string{InvoiceStatusUnpaid, InvoiceStatusPartiallyPaid})
+    if childIDs != nil {
+        query = query.Where("child_id IN ?", childIDs)
+    }
+    var invoices []model.Invoice
+    if err := query.Order("due_date ASC").Find(&invoices).Error; err != nil {
+        return nil, fmt.Errorf("gagal mengambil tagihan yang belum lunas: %w", err)
+    }
+
+    today := startOfDay(asOf)
+    balances := make(map[uint]*OutstandingBalance)
+    var order []uint
+    for _, invoice := range invoices {
+        balance, ok := balances[invoice.ChildID]
+        if !ok {
+            balance = &OutstandingBalance{ChildID: invoice.ChildID, ChildName: invoice.Child.Name, BillTo: invoice.BillTo}
+            balances[invoice.ChildID] = balance
+            order = append(order, invoice.ChildID)
+        }
+        owed := invoice.Total - invoice.PaidAmount
+        balance.InvoiceCount++
+        balance.Invoiced += invoice.Total
+        balance.Paid += invoice.PaidAmount
+        balance.Outstanding += owed
+        if balance.OldestDueDate == nil {
+            dueDate := invoice.DueDate
+            balance.OldestDueDate = &dueDate
+        }
+
+        overdueDays := int(today.Sub(startOfDay(invoice.DueDate)).Hours() / 24)
+        switch {
+        case overdueDays <= 0:
+            balance.NotYetDue += owed
+        case overdueDays <= 30:
+            balance.Overdue1To30 += owed
+        case overdueDays <= 60:
+            balance.Overdue31To60 += owed
+        case overdueDays <= 90:
+            balance.Overdue61To90 += owed
+        default:
+            balance.OverdueOver90 += owed
+        }
+    }
+
+    result := make([]OutstandingBalance, 0, len(order))
+    for _, childID := range order {
+        result = append(result, *balances[childID])
+    }
+    sort.SliceStable(result, func(i, j int) bool { return result[i].Outstanding > result[j].Outstanding })
+    return result, nil
+}
+
+// FormatRupiah formats an amount as "Rp 1.250.000"
+func FormatRupiah(amount int64) string {
+    sign := ""
+    if amount < 0 {
+        sign = "-"
+        amount = -amount
+    }
+    digits := strconv.FormatInt(amount, 10)
+    var b strings.Builder
+    for i, d := range digits {
+        if i > 0 && (len(digits)-i)%3 == 0 {
+            b.WriteByte('.')
+        }
+        b.WriteRune(d)
+    }
+    return sign + "Rp " + b.String()
+}
+
+func (s *BillingService) applyRateCardInput(card *model.RateCard, input RateCardInput) error {
+    if input.DurationMinutes <= 0 {
+        return errors.New("durasi sesi harus lebih dari nol")
+    }
+    if input.Amount <= 0 {
+        return errors.New("tarif harus lebih dari nol")
+    }
+    therapyType := strings.TrimSpace(input.TherapyType)
+
+    var duplicates int64
+    if err := s.db.Model(&model.RateCard{}).
+        Where("id <> ? AND archived_at IS NULL AND LOWER(therapy_type) = LOWER(?) AND duration_minutes = ? AND is_group = ?", card.ID, therapyType, input.DurationMinutes, input.IsGroup).
+        Count(&duplicates).Error; err != nil {
+        return fmt.Errorf("gagal memeriksa tarif: %w", err)
+    }
+    if duplicates > 0 {
+        return errors.New("tarif untuk jenis terapi dan durasi ini sudah ada")
+    }
+
+    card.TherapyType = therapyType
+    card.DurationMinutes = input.DurationMinutes
+    card.IsGroup = input.IsGroup
+    card.Amount = input.Amount
+    card.Description = strings.TrimSpace(input.Description)
+    return nil
+}
+
+func (s *BillingService) getRateCard(cardID uint) (*model.RateCard, error) {
+    var card model.RateCard
+    if err := s.db.First(&card, cardID).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("tarif tidak ditemukan")
+        }
+        return nil, fmt.Errorf("gagal mengambil tarif: %w", err)
+    }
+    return &card, nil
+}
+
+// liveInvoiceIDs selects invoices that have not been voided
+func liveInvoiceIDs(db *gorm.DB) *gorm.DB {
+    return db.Model(&model.Invoice{}).Select("id").Where("status <> ?", InvoiceStatusVoid)
+}
+
+// ensureSessionNotInvoiced rejects changes to what a session is billed as while it is
+// on an invoice that has not been voided
+func ensureSessionNotInvoiced(db *gorm.DB, sessionID uint) error {
+    var invoiced int64
+    if err := db.Model(&model.InvoiceLine{}).Where("session_id = ? AND invoice_id IN (?)", sessionID, liveInvoiceIDs(db)).
+        Count(&invoiced).Error; err != nil {
+        return fmt.Errorf("gagal memeriksa tagihan sesi: %w", err)
+    }
+    if invoiced > 0 {
+        return errors.New("sesi sudah ditagihkan; batalkan tagihannya terlebih dahulu")
+    }
+    return nil
+}
+
+// referralTherapyType is the therapy type of the referral a child was enrolled from, used
+// for sessions that do not record one
+func (s *BillingService) referralTherapyType(childID uint) (string, error) {
+    var types []string
+    if err := s.db.Model(&model.WaitlistEntry{}).Where("child_id = ?", childID).
+        Order("converted_at DESC").Limit(1).Pluck("therapy_type", &types).Error; err != nil {
+        return "", fmt.Errorf("gagal mengambil rujukan anak: %w", err)
+    }
+    if len(types) == 0 {
+        return "", nil
+    }
+    return types[0], nil
+}
+
+// billTo names the guardian an invoice is addressed to: the primary contact, otherwise
+// the first guardian recorded
+func (s *BillingService) billTo(childID uint) (string, error) {
+    var guardians []model.Guardian
+    if err := s.db.Where("child_id = ?", childID).Order("is_primary DESC, id ASC").Limit(1).Find(&guardians).Error; err != nil {
+        return "", fmt.Errorf("gagal mengambil data wali: %w", err)
+    }
+    if len(guardians) == 0 {
+        return "", nil
+    }
+    return guardians[0].Name, nil
+}
+
+// matchRateCard picks the rate for a session: rates for its therapy type, or the general
+// rates when the type has none, of the same kind (individual or group), with the length
+// closest to the session's. Ties go to the shorter rate.
+func matchRateCard(cards []model.RateCard, therapyType string, durationMinutes int, isGroup bool) *model.RateCard {
+    pick := func(wantType string) *model.RateCard {
+        var best *model.RateCard
+        bestDiff := 0
+        for i := range cards {
+            card := &cards[i]
+            if card.IsGroup != isGroup || !strings.EqualFold(card.TherapyType, wantType) {
+                continue
+            }
+            diff := card.DurationMinutes - durationMinutes
+            if diff < 0 {
+                diff = -diff
+            }
+            if best == nil || diff < bestDiff || (diff == bestDiff && card.DurationMinutes < best.DurationMinutes) {
+                best, bestDiff = card, diff
+            }
+        }
+        return best
+    }
+    if therapyType != "" {
+        if card := pick(therapyType); card != nil {
+            return card
+        }
+    }
+    return pick("")
+}
+
+func invoiceLineDescription(session model.Session, therapyType string, card *model.RateCard) string {
+    description := "Sesi " + therapyTypeLabel(therapyType)
+    if session.IsGroup {
+        description = "Sesi kelompok " + session.GroupName
+        if therapyType != "" {
+            description += " (" + therapyType + ")"
+        }
+    }
+    if card.Description != "" {
+        description += " - " + card.Description
+    }
+    return description
+}
+
+func therapyTypeLabel(therapyType string) string {
+    if therapyType == "" {
+        return "terapi"
+    }
+    return therapyType
+}
+
+// nextInvoiceNumber continues the year's sequence for a prefix. Numbers of voided and
+// deleted invoices are never reused.
+func nextInvoiceNumber(tx *gorm.DB, prefix string, year int) (string, error) {
+    base := fmt.Sprintf("%s-%d-", prefix, year)
+    var numbers []string
+    if err := tx.Unscoped().Model(&model.Invoice{}).Where("number LIKE ?", base+"%").Pluck("number", &numbers).Error; err != nil {
+        return "", fmt.Errorf("gagal membuat nomor tagihan: %w", err)
+    }
+    last := 0
+    for _, number := range numbers {
+        if n, err := strconv.Atoi(strings.TrimPrefix(number, base)); err == nil && n > last {
+            last = n
+        }
+    }
+    return fmt.Sprintf("%s%04d", base, last+1), nil
+}
+
+// updateInvoicePaid recomputes what has been paid on an invoice and its status
+func updateInvoicePaid(tx *gorm.DB, trail *AuditTrail, invoice *model.Invoice) error {
+    var paid int64
+    if err := tx.Model(&model.Payment{}).Where("invoice_id = ?", invoice.ID).Select("COALESCE(SUM(amount), 0)").Scan(&paid).Error; err != nil {
+        return err
+    }
+    before := *invoice
+    invoice.PaidAmount = paid
+    switch {
+    case paid >= invoice.Total:
+        invoice.Status = InvoiceStatusPaid
+    case paid > 0:
+        invoice.Status = InvoiceStatusPartiallyPaid
+    default:
+        invoice.Status = InvoiceStatusUnpaid
+    }
+    if err := tx.Model(invoice).Updates(map[string]interface{}{
+        "paid_amount": invoice.PaidAmount,
+        "status":      invoice.Status,
+    }).Error; err != nil {
+        return err
+    }
+    trail.Add(AuditActionUpdate, AuditEntityInvoice, invoice.ID, before, invoice)
+    return nil
+}
@@ -4,16 +4,19 @@ import (
 	"childSessions/model"
 	"errors"
 	"fmt"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type ChildService struct {
-    db *gorm.DB
+    db    *gorm.DB
+    audit *AuditService
 }
 
-func NewChildService(db *gorm.DB) *ChildService {
-    return &ChildService{db: db}
+func NewChildService(db *gorm.DB, audit *AuditService) *ChildService {
+    return &ChildService{db: db, audit: audit}
 }
 
 // CreateChild creates a new child record
@@ -32,61 +35,138 @@ func (s *ChildService) CreateChild(name, gender, parentGuardianName, contactInfo
 
     // Parse date of birth if provided
     if dateOfBirth != nil && *dateOfBirth != "" {
-        // You might want to add proper date parsing here
-        // For now, we'll skip the date parsing
+        dob, err := ParseDateOfBirth(*dateOfBirth)
+        if err != nil {
+            return nil, err
+        }
+        child.DateOfBirth = dob
     }
 
-    if err := s.db.Create(child).Error; err != nil {
+    err := s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Create(child).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionCreate, AuditEntityChild, child.ID, nil, child)
+
+        // Keep the structured guardian records in step with the legacy contact fields
+        if parentGuardianName == "" && contactInfo == "" {
+            return nil
+        }
+        return createGuardian(tx, trail, guardianFromLegacyContact(child.ID, parentGuardianName, contactInfo))
+    })
+    if err != nil {
         return nil, fmt.Errorf("gagal membuat data anak: %w", err)
     }
 
+    setChildAge(child, time.Now())
     return child, nil
 }
 
-// GetAllChildren retrieves all children
+// GetAllChildren retrieves all children with their current age
 func (s *ChildService) GetAllChildren() ([]model.Child, error) {
     var children []model.Child
     if err := s.db.Find(&children).Error; err != nil {
         return nil, fmt.Errorf("gagal mengambil data anak: %w", err)
     }
+    now := time.Now()
+    for i := range children {
+        setChildAge(&children[i], now)
+    }
+    return children, nil
+}
+
+// GetChildrenForTherapist retrieves the children in a therapist's caseload
+func (s *ChildService) GetChildrenForTherapist(therapistID uint) ([]model.Child, error) {
+    var children []model.Child
+    if err := s.db.Joins("JOIN child_therapists ON child_therapists.child_id = children.id").
+        Where("child_therapists.therapist_id = ?", therapistID).
+        Find(&children).Error; err != nil {
+        return nil, fmt.Errorf("gagal mengambil data anak: %w", err)
+    }
+    now := time.Now()
+    for i := range children {
+        setChildAge(&children[i], now)
+    }
     return children, nil
 }
 
 // GetChildByID retrieves a child by ID
 func (s *ChildService) GetChildByID(id uint) (*model.Child, error) {
     var child model.Child
-    if err := s.db.First(&child, id).Error; err != nil {
+    if err := s.db.Preload("Guardians", func(db *gorm.DB) *gorm.DB {
+        return db.Order("is_primary DESC, is_emergency_contact DESC, name ASC")
+    }).First(&child, id).Error; err != nil {
         if errors.Is(err, gorm.ErrRecordNotFound) {
             return nil, errors.New("data anak tidak ditemukan")
         }
         return nil, fmt.Errorf("gagal mengambil data anak: %w", err)
     }
+    setChildAge(&child, time.Now())
     return &child, nil
 }
 
-// UpdateChild updates a child record
-func (s *ChildService) UpdateChild(id uint, name, gender, parentGuardianName, contactInfo, initialAssessment string) (*model.Child, error) {
+// UpdateChild updates a child record; an empty dateOfBirth clears it
+func (s *ChildService) UpdateChild(id uint, name, gender, parentGuardianName, contactInfo, initialAssessment, dateOfBirth string) (*model.Child, error) {
+    if name == "" {
+        return nil, errors.New("nama anak harus diisi")
+    }
+    dob, err := ParseDateOfBirth(dateOfBirth)
+    if err != nil {
+        return nil, err
+    }
+
     child, err := s.GetChildByID(id)
     if err != nil {
         return nil, err
     }
 
+    before := *child
     child.Name = name
     child.Gender = gender
     child.ParentGuardianName = parentGuardianName
     child.ContactInfo = contactInfo
     child.InitialAssessment = initialAssessment
+    child.DateOfBirth = dob
 
-    if err := s.db.Save(child).Error; err != nil {
+    err = s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Omit(clause.Associations).Save(child).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionUpdate, AuditEntityChild, child.ID, before, child)
+
+        // Keep the primary guardian in step when the legacy contact fields are edited
+        if parentGuardianName == before.ParentGuardianName && contactInfo == before.ContactInfo {
+            return nil
+        }
+        return syncPrimaryGuardian(tx, trail, child.ID, parentGuardianName, contactInfo)
+    })
+    if err != nil {
         return nil, fmt.Errorf("gagal memperbarui data anak: %w", err)
     }
 
+    setChildAge(child, time.Now())
     return child, nil
 }
 
-// DeleteChild soft deletes a child record
+// DeleteChild soft deletes a child record together with its sessions. Group sessions stay
+// for the other children.
 func (s *ChildService) DeleteChild(id uint) error {
-    if err := s.db.Delete(&model.Child{}, id).Error; err != nil {
+    child, err := s.GetChildByID(id)
+    if err != nil {
+        return err
+    }
+
+    err = s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Where("child_id = ? AND is_group = ?", child.ID, false).Delete(&model.Session{}).Error; err != nil {
+            return err
+        }
+        if err := tx.Delete(child).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionDelete, AuditEntityChild, child.ID, child, nil)
+        return nil
+    })
+    if err != nil {
         return fmt.Errorf("gagal menghapus data anak: %w", err)
     }
     return nil
@@ -0,0 +1,682 @@
+package services
+
+import (
+	"childSessions/model"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Diagnosis statuses
+const (
+    DiagnosisStatusActive   = "active"
+    DiagnosisStatusResolved = "resolved"
+    DiagnosisStatusRuledOut = "ruled_out"
+)
+
+// Sensitivity kinds
+const (
+    SensitivityKindAllergy = "allergy"
+    SensitivityKindSensory = "sensory"
+)
+
+// Sensitivity severities
+const (
+    SeverityMild     = "mild"
+    SeverityModerate = "moderate"
+    SeveritySevere   = "severe"
+)
+
+// Clinical profile history entity types and actions
+const (
+    ClinicalEntityBackground  = "background"
+    ClinicalEntityDiagnosis   = "diagnosis"
+    ClinicalEntityMedication  = "medication"
+    ClinicalEntitySensitivity = "sensitivity"
+
+    ClinicalChangeCreated = "created"
+    ClinicalChangeUpdated = "updated"
+    ClinicalChangeDeleted = "deleted"
+)
+
+var validDiagnosisStatuses = map[string]bool{
+    DiagnosisStatusActive:   true,
+    DiagnosisStatusResolved: true,
+    DiagnosisStatusRuledOut: true,
+}
+
+var validSensitivityKinds = map[string]bool{
+    SensitivityKindAllergy: true,
+    SensitivityKindSensory: true,
+}
+
+var validSeverities = map[string]bool{
+    SeverityMild:     true,
+    SeverityModerate: true,
+    SeveritySevere:   true,
+}
+
+// icd10Pattern matches ICD-10 codes such as F84, F84.0 or F80.81 (U codes are reserved)
+var icd10Pattern = regexp.MustCompile(`^[A-TV-Z][0-9][0-9AB](\.[0-9A-TV-Z]{1,4})?$`)
+
+// NormalizeICD10Code validates an ICD-10 code and upper-cases it
+func NormalizeICD10Code(code string) (string, error) {
+    code = strings.ToUpper(strings.TrimSpace(code))
+    if code == "" {
+        return "", nil
+    }
+    if !icd10Pattern.MatchString(code) {
+        return "", fmt.Errorf("kode ICD-10 tidak valid: %s", code)
+    }
+    return code, nil
+}
+
+// DiagnosisInput holds the editable fields of a diagnosis
+type DiagnosisInput struct {
+    ICD10Code   string `json:"icd10_code"`
+    Description string `json:"description"`
+    DiagnosedOn string `json:"diagnosed_on"`
+    DiagnosedBy string `json:"diagnosed_by"`
+    Status      string `json:"status"`
+    Notes       string `json:"notes"`
+}
+
+// MedicationInput holds the editable fields of a medication
+type MedicationInput struct {
+    Name         string `json:"name"`
+    Dosage       string `json:"dosage"`
+    Frequency    string `json:"frequency"`
+    StartedOn    string `json:"started_on"`
+    EndedOn      string `json:"ended_on"`
+    PrescribedBy string `json:"prescribed_by"`
+    Notes        string `json:"notes"`
+}
+
+// SensitivityInput holds the editable fields of an allergy or sensory sensitivity
+type SensitivityInput struct {
+    Kind     string `json:"kind"`
+    Trigger  string `json:"trigger"`
+    Keywords string `json:"keywords"`
+    Severity string `json:"severity"`
+    Reaction string `json:"reaction"`
+    Notes    string `json:"notes"`
+}
+
+// ClinicalProfile gathers a child's structured clinical data
+type ClinicalProfile struct {
+    ChildID        uint                     `json:"child_id"`
+    School         string                   `json:"school"`
+    ReferralSource string                   `json:"referral_source"`
+    Diagnoses      []model.ChildDiagnosis   `json:"diagnoses"`
+    Medications    []model.ChildMedication  `json:"medications"`
+    Sensitivities  []model.ChildSensitivity `json:"sensitivities"`
+}
+
+// ActiveDiagnoses returns the diagnoses that are still active
+func (p *ClinicalProfile) ActiveDiagnoses() []model.ChildDiagnosis {
+    var active []model.ChildDiagnosis
+    for _, diagnosis := range p.Diagnoses {
+        if diagnosis.Status == DiagnosisStatusActive {
+            active = append(active, diagnosis)
+        }
+    }
+    return active
+}
+
+// CurrentMedications returns the medications that have not ended by the given time
+func (p *ClinicalProfile) CurrentMedications(at time.Time) []model.ChildMedication {
+    var current []model.ChildMedication
+    for _, medication := range p.Medications {
+        if medication.StartedOn != nil && medication.StartedOn.After(at) {
+            continue
+        }
+        if medication.EndedOn != nil && medication.EndedOn.Before(at) {
+            continue
+        }
+        current = append(current, medication)
+    }
+    return current
+}
+
+type ClinicalProfileService struct {
+    db    *gorm.DB
+    audit *AuditService
+}
+
+func NewClinicalProfileService(db *gorm.DB, audit *AuditService) *ClinicalProfileService {
+    return &ClinicalProfileService{db: db, audit: audit}
+}
+
+// GetClinicalProfile retrieves the structured clinical data of a child
+func (s *ClinicalProfileService) GetClinicalProfile(childID uint) (*ClinicalProfile, error) {
+    return loadClinicalProfile(s.db, childID)
+}
+
+// GetClinicalHistory lists every change made to a child's clinical profile, newest first
+func (s *ClinicalProfileService) GetClinicalHistory(childID uint) ([]model.ClinicalProfileChange, error) {
+    var changes []model.ClinicalProfileChange
+    if err := s.db.Where("child_id = ?", childID).
+        Order("created_at DESC, id DESC").
+        Find(&changes).Error; err != nil {
+        return nil, fmt.Errorf("gagal mengambil riwayat profil klinis: %w", err)
+    }
+    return changes, nil
+}
+
+// UpdateBackground updates the school and referral source of a child
+func (s *ClinicalProfileService) UpdateBackground(childID uint, school, referralSource, author string) (*model.Child, error) {
+    var child model.Child
+    if err := s.db.First(&child, childID).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("data anak tidak ditemukan")
+        }
+        return nil, fmt.Errorf("gagal mengambil data anak: %w", err)
+    }
+
+    before := clinicalBackground{School: child.School, ReferralSource: child.ReferralSource}
+    after := clinicalBackground{School: strings.TrimSpace(school), ReferralSource: strings.TrimSpace(referralSource)}
+    if before == after {
+        return &child, nil
+    }
+
+    beforeChild := child
+    err := s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Model(&child).Updates(map[string]interface{}{
+            "school":          after.School,
+            "referral_source": after.ReferralSource,
+        }).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionUpdate, AuditEntityChild, child.ID, beforeChild, child)
+        return recordClinicalChange(tx, childID, ClinicalEntityBackground, childID, ClinicalChangeUpdated, before, after, author)
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal memperbarui profil klinis: %w", err)
+    }
+    return &child, nil
+}
+
+// AddDiagnosis records a diagnosis for a child
+func (s *ClinicalProfileService) AddDiagnosis(childID uint, input DiagnosisInput, author string) (*model.ChildDiagnosis, error) {
+    if err := ensureChildExists(s.db, childID); err != nil {
+        return nil, err
+    }
+    diagnosis := &model.ChildDiagnosis{ChildID: childID}
+    if err := applyDiagnosisInput(diagnosis, input); err != nil {
+        return nil, err
+    }
+
+    err := s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Create(diagnosis).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionCreate, AuditEntityDiagnosis, diagnosis.ID, nil, diagnosis)
+        return recordClinicalChange(tx, childID, ClinicalEntityDiagnosis, diagnosis.ID, ClinicalChangeCreated, nil, diagnosis, author)
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal menyimpan diagnosis: %w", err)
+    }
+    return diagnosis, nil
+}
+
+// UpdateDiagnosis replaces the editable fields of a diagnosis
+func (s *ClinicalProfileService) UpdateDiagnosis(id uint, input DiagnosisInput, author string) (*model.ChildDiagnosis, error) {
+    var diagnosis model.ChildDiagnosis
+    if err := s.db.First(&diagnosis, id).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("diagnosis tidak ditemukan")
+        }
+        return nil, fmt.Errorf("gagal mengambil diagnosis: %w", err)
+    }
+
+    before := diagnosis
+    if err := applyDiagnosisInput(&diagnosis, input); err != nil {
+        return nil, err
+    }
+
+    err := s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Save(&diagnosis).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionUpdate, AuditEntityDiagnosis, diagnosis.ID, before, diagnosis)
+        return recordClinicalChange(tx, diagnosis.ChildID, ClinicalEntityDiagnosis, diagnosis.ID, ClinicalChangeUpdated, before, diagnosis, author)
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal memperbarui diagnosis: %w", err)
+    }
+    return &diagnosis, nil
+}
+
+// DeleteDiagnosis removes a diagnosis entered in error (soft delete) and returns it;
+// resolved diagnoses should be kept with status "resolved" instead
+func (s *ClinicalProfileService) DeleteDiagnosis(id uint, author string) (*model.ChildDiagnosis, error) {
+    var diagnosis model.ChildDiagnosis
+    if err := s.db.First(&diagnosis, id).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("diagnosis tidak ditemukan")
+        }
+        return nil, fmt.Errorf("gagal mengambil diagnosis: %w", err)
+    }
+
+    err := s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Delete(&diagnosis).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionDelete, AuditEntityDiagnosis, diagnosis.ID, diagnosis, nil)
+        return recordClinicalChange(tx, diagnosis.ChildID, ClinicalEntityDiagnosis, diagnosis.ID, ClinicalChangeDeleted, diagnosis, nil, author)
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal menghapus diagnosis: %w", err)
+    }
+    return &diagnosis, nil
+}
+
+// AddMedication records a medication for a child
+func (s *ClinicalProfileService) AddMedication(childID uint, input MedicationInput, author string) (*model.ChildMedication, error) {
+    if err := ensureChildExists(s.db, childID); err != nil {
+        return nil, err
+    }
+    medication := &model.ChildMedication{ChildID: childID}
+    if err := applyMedicationInput(medication, input); err != nil {
+        return nil, err
+    }
+
+    err := s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Create(medication).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionCreate, AuditEntityMedication, medication.ID, nil, medication)
+        return recordClinicalChange(tx, childID, ClinicalEntityMedication, medication.ID, ClinicalChangeCreated, nil, medication, author)
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal menyimpan obat: %w", err)
+    }
+    return medication, nil
+}
+
+// UpdateMedication replaces the editable fields of a medication; set EndedOn to stop it
+func (s *ClinicalProfileService) UpdateMedication(id uint, input MedicationInput, author string) (*model.ChildMedication, error) {
+    var medication model.ChildMedication
+    if err := s.db.First(&medication, id).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("data obat tidak ditemukan")
+        }
+        return nil, fmt.Errorf("gagal mengambil data obat: %w", err)
+    }
+
+    before := medication
+    if err := applyMedicationInput(&medication, input); err != nil {
+        return nil, err
+    }
+
+    err := s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Save(&medication).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionUpdate, AuditEntityMedication, medication.ID, before, medication)
+        return recordClinicalChange(tx, medication.ChildID, ClinicalEntityMedication, medication.ID, ClinicalChangeUpdated, before, medication, author)
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal memperbarui data obat: %w", err)
+    }
+    return &medication, nil
+}
+
+// DeleteMedication removes a medication entered in error (soft delete) and returns it
+func (s *ClinicalProfileService) DeleteMedication(id uint, author string) (*model.ChildMedication, error) {
+    var medication model.ChildMedication
+    if err := s.db.First(&medication, id).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("data obat tidak ditemukan")
+        }
+        return nil, fmt.Errorf("gagal mengambil data obat: %w", err)
+    }
+
+    err := s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Delete(&medication).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionDelete, AuditEntityMedication, medication.ID, medication, nil)
+        return recordClinicalChange(tx, medication.ChildID, ClinicalEntityMedication, medication.ID, ClinicalChangeDeleted, medication, nil, author)
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal menghapus data obat: %w", err)
+    }
+    return &medication, nil
+}
+
+// AddSensitivity records an allergy or sensory sensitivity for a child
+func (s *ClinicalProfileService) AddSensitivity(childID uint, input SensitivityInput, author string) (*model.ChildSensitivity, error) {
+    if err := ensureChildExists(s.db, childID); err != nil {
+        return nil, err
+    }
+    sensitivity := &model.ChildSensitivity{ChildID: childID}
+    if err := applySensitivityInput(sensitivity, input); err != nil {
+        return nil, err
+    }
+
+    err := s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Create(sensitivity).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionCreate, AuditEntitySensitivity, sensitivity.ID, nil, sensitivity)
+        return recordClinicalChange(tx, childID, ClinicalEntitySensitivity, sensitivity.ID, ClinicalChangeCreated, nil, sensitivity, author)
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal menyimpan alergi/sensitivitas: %w", err)
+    }
+    return sensitivity, nil
+}
+
+// UpdateSensitivity replaces the editable fields of an allergy or sensory sensitivity
+func (s *ClinicalProfileService) UpdateSensitivity(id uint, input SensitivityInput, author string) (*model.ChildSensitivity, error) {
+    var sensitivity model.ChildSensitivity
+    if err := s.db.First(&sensitivity, id).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("data alergi/sensitivitas tidak ditemukan")
+        }
+        return nil, fmt.Errorf("gagal mengambil data alergi/sensitivitas: %w", err)
+    }
+
+    before := sensitivity
+    if err := applySensitivityInput(&sensitivity, input); err != nil {
+        return nil, err
+    }
+
+    err := s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Save(&sensitivity).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionUpdate, AuditEntitySensitivity, sensitivity.ID, before, sensitivity)
+        return recordClinicalChange(tx, sensitivity.ChildID, ClinicalEntitySensitivity, sensitivity.ID, ClinicalChangeUpdated, before, sensitivity, author)
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal memperbarui data alergi/sensitivitas: %w", err)
+    }
+    return &sensitivity, nil
+}
+
+// DeleteSensitivity removes an allergy or sensory sensitivity (soft delete) and returns it
+func (s *ClinicalProfileService) DeleteSensitivity(id uint, author string) (*model.ChildSensitivity, error) {
+    var sensitivity model.ChildSensitivity
+    if err := s.db.First(&sensitivity, id).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("data alergi/sensitivitas tidak ditemukan")
+        }
+        return nil, fmt.Errorf("gagal mengambil data alergi/sensitivitas: %w", err)
+    }
+
+    err := s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Delete(&sensitivity).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionDelete, AuditEntitySensitivity, sensitivity.ID, sensitivity, nil)
+        return recordClinicalChange(tx, sensitivity.ChildID, ClinicalEntitySensitivity, sensitivity.ID, ClinicalChangeDeleted, sensitivity, nil, author)
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal menghapus data alergi/sensitivitas: %w", err)
+    }
+    return &sensitivity, nil
+}
+
+// GetActivityWarnings returns the allergy and sensory warnings of a child that
+// are relevant to the given activities
+func (s *ClinicalProfileService) GetActivityWarnings(childID uint, activities []model.Activity) ([]string, error) {
+    return clinicalWarnings(s.db, childID, activities)
+}
+
+// clinicalBackground is the history snapshot of the non-clinical intake fields
+type clinicalBackground struct {
+    School         string `json:"school"`
+    ReferralSource string `json:"referral_source"`
+}
+
+// loadClinicalProfile reads a child's clinical profile; used by reports as well
+func loadClinicalProfile(db *gorm.DB, childID uint) (*ClinicalProfile, error) {
+    var child model.Child
+    if err := db.First(&child, childID).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("data anak tidak ditemukan")
+        }
+        return nil, fmt.Errorf("gagal mengambil data anak: %w", err)
+    }
+
+    profile := &ClinicalProfile{
+        ChildID:        childID,
+        School:         child.School,
+        ReferralSource: child.ReferralSource,
+    }
+    if err := db.Where("child_id = ?", childID).
+        Order("CASE status WHEN 'active' THEN 0 ELSE 1 END, diagnosed_on DESC, id ASC").
+        Find(&profile.Diagnoses).Error; err != nil {
+        return nil, fmt.Errorf("gagal mengambil diagnosis: %w", err)
+    }
+    if err := db.Where("child_id = ?", childID).
+        Order("ended_on IS NOT NULL, started_on DESC, id ASC").
+        Find(&profile.Medications).Error; err != nil {
+        return nil, fmt.Errorf("gagal mengambil data obat: %w", err)
+    }
+    if err := db.Where("child_id = ?", childID).
+        Order("CASE severity WHEN 'severe' THEN 0 WHEN 'moderate' THEN 1 ELSE 2 END, kind ASC, id ASC").
+        Find(&profile.Sensitivities).Error; err != nil {
+        return nil, fmt.Errorf("gagal mengambil data alergi/sensitivitas: %w", err)
+    }
+    return profile, nil
+}
+
+// clinicalWarnings builds the warnings shown when a session or activity starts.
+// Without activities every allergy and sensory sensitivity is listed; with
+// activities only triggers matching an activity are listed, plus severe allergies
+// which are always shown.
+func clinicalWarnings(db *gorm.DB, childID uint, activities []model.Activity) ([]string, error) {
+    var sensitivities []model.ChildSensitivity
+    if err := db.Where("child_id = ?", childID).
+        Order("CASE severity WHEN 'severe' THEN 0 WHEN 'moderate' THEN 1 ELSE 2 END, id ASC").
+        Find(&sensitivities).Error; err != nil {
+        return nil, fmt.Errorf("gagal mengambil data alergi/sensitivitas: %w", err)
+    }
+
+    var warnings []string
+    for _, sensitivity := range sensitivities {
+        label := sensitivityLabel(sensitivity)
+        if len(activities) == 0 {
+            warnings = append(warnings, "Perhatian: "+label)
+            continue
+        }
+
+        matched := false
+        for _, activity := range activities {
+            if sensitivityMatchesActivity(sensitivity, activity) {
+                warnings = append(warnings, fmt.Sprintf("Perhatian: %s, relevan dengan aktivitas \"%s\"", label, activity.Name))
+                matched = true
+            }
+        }
+        if !matched && sensitivity.Kind == SensitivityKindAllergy && sensitivity.Severity == SeveritySevere {
+            warnings = append(warnings, "Perhatian: "+label)
+        }
+    }
+    return warnings, nil
+}
+
+// sensitivityLabel describes a sensitivity in one line, e.g. "alergi kacang (berat): sesak napas"
+func sensitivityLabel(sensitivity model.ChildSensitivity) string {
+    kind := "alergi"
+    if sensitivity.Kind == SensitivityKindSensory {
+        kind = "sensitivitas sensorik"
+    }
+    label := fmt.Sprintf("%s %s (%s)", kind, sensitivity.Trigger, SeverityLabel(sensitivity.Severity))
+    if sensitivity.Reaction != "" {
+        label += ": " + sensitivity.Reaction
+    }
+    return label
+}
+
+// SeverityLabel returns the Indonesian label of a severity
+func SeverityLabel(severity string) string {
+    switch severity {
+    case SeverityMild:
+        return "ringan"
+    case SeveritySevere:
+        return "berat"
+    default:
+        return "sedang"
+    }
+}
+
+// sensitivityMatchesActivity reports whether the trigger or any keyword of a
+// sensitivity appears in the activity's name, description, category or objectives
+func sensitivityMatchesActivity(sensitivity model.ChildSensitivity, activity model.Activity) bool {
+    text := strings.ToLower(strings.Join([]string{
+        activity.Name, activity.Description, activity.Category, activity.Objectives,
+    }, " "))
+
+    terms := []string{sensitivity.Trigger}
+    terms = append(terms, strings.Split(sensitivity.Keywords, ",")...)
+    for _, term := range terms {
+        term = strings.ToLower(strings.TrimSpace(term))
+        if term != "" && strings.Contains(text, term) {
+            return true
+        }
+    }
+    return false
+}
+
+// recordClinicalChange appends an entry to the clinical profile history
+func recordClinicalChange(tx *gorm.DB, childID uint, entityType string, entityID uint, action string, before, after interface{}, author string) error {
+    change := model.ClinicalProfileChange{
+        CreatedAt:  time.Now(),
+        ChildID:    childID,
+        EntityType: entityType,
+        EntityID:   entityID,
+        Action:     action,
+        Author:     author,
+    }
+    if before != nil {
+        data, err := json.Marshal(before)
+        if err != nil {
+            return err
+        }
+        change.Before = string(data)
+    }
+    if after != nil {
+        data, err := json.Marshal(after)
+        if err != nil {
+            return err
+        }
+        change.After = string(data)
+    }
+    return tx.Create(&change).Error
+}
+
+// ensureChildExists returns a user-facing error when the child does not exist
+func ensureChildExists(db *gorm.DB, childID uint) error {
+    var count int64
+    if err := db.Model(&model.Child{}).Where("id = ?", childID).Count(&count).Error; err != nil {
+        return fmt.Errorf("gagal mengambil data anak: %w", err)
+    }
+    if count == 0 {
+        return errors.New("data anak tidak ditemukan")
+    }
+    return nil
+}
+
+// applyDiagnosisInput validates input and copies it onto diagnosis
+func applyDiagnosisInput(diagnosis *model.ChildDiagnosis, input DiagnosisInput) error {
+    code, err := NormalizeICD10Code(input.ICD10Code)
+    if err != nil {
+        return err
+    }
+    description := strings.TrimSpace(input.Description)
+    if description == "" {
+        return errors.New("deskripsi diagnosis harus diisi")
+    }
+    diagnosedOn, err := ParseCalendarDate(input.DiagnosedOn)
+    if err != nil {
+        return err
+    }
+    if diagnosedOn != nil && diagnosedOn.After(time.Now()) {
+        return errors.New("tanggal diagnosis tidak boleh di masa depan")
+    }
+    status := strings.TrimSpace(input.Status)
+    if status == "" {
+        status = DiagnosisStatusActive
+    }
+    if !validDiagnosisStatuses[status] {
+        return fmt.Errorf("status diagnosis tidak dikenal: %s", status)
+    }
+
+    diagnosis.ICD10Code = code
+    diagnosis.Description = description
+    diagnosis.DiagnosedOn = diagnosedOn
+    diagnosis.DiagnosedBy = strings.TrimSpace(input.DiagnosedBy)
+    diagnosis.Status = status
+    diagnosis.Notes = strings.TrimSpace(input.Notes)
+    return nil
+}
+
+// applyMedicationInput validates input and copies it onto medication
+func applyMedicationInput(medication *model.ChildMedication, input MedicationInput) error {
+    name := strings.TrimSpace(input.Name)
+    if name == "" {
+        return errors.New("nama obat harus diisi")
+    }
+    startedOn, err := ParseCalendarDate(input.StartedOn)
+    if err != nil {
+        return err
+    }
+    endedOn, err := ParseCalendarDate(input.EndedOn)
+    if err != nil {
+        return err
+    }
+    if startedOn != nil && endedOn != nil && endedOn.Before(*startedOn) {
+        return errors.New("tanggal selesai obat tidak boleh sebelum tanggal mulai")
+    }
+
+    medication.Name = name
+    medication.Dosage = strings.TrimSpace(input.Dosage)
+    medication.Frequency = strings.TrimSpace(input.Frequency)
+    medication.StartedOn = startedOn
+    medication.EndedOn = endedOn
+    medication.PrescribedBy = strings.TrimSpace(input.PrescribedBy)
+    medication.Notes = strings.TrimSpace(input.Notes)
+    return nil
+}
+
+// applySensitivityInput validates input and copies it onto sensitivity
+func applySensitivityInput(sensitivity *model.ChildSensitivity, input SensitivityInput) error {
+    kind := strings.TrimSpace(input.Kind)
+    if !validSensitivityKinds[kind] {
+        return fmt.Errorf("jenis sensitivitas tidak dikenal: %s", kind)
+    }
+    trigger := strings.TrimSpace(input.Trigger)
+    if trigger == "" {
+        return errors.New("pemicu alergi/sensitivitas harus diisi")
+    }
+    severity := strings.TrimSpace(input.Severity)
+    if severity == "" {
+        severity = SeverityModerate
+    }
+    if !validSeverities[severity] {
+        return fmt.Errorf("tingkat keparahan tidak dikenal: %s", severity)
+    }
+
+    var keywords []string
+    for _, keyword := range strings.Split(input.Keywords, ",") {
+        if keyword = strings.TrimSpace(keyword); keyword != "" {
+            keywords = append(keywords, keyword)
+        }
+    }
+
+    sensitivity.Kind = kind
+    sensitivity.Trigger = trigger
+    sensitivity.Keywords = strings.Join(keywords, ",")
+    sensitivity.Severity = severity
+    sensitivity.Reaction = strings.TrimSpace(input.Reaction)
+    sensitivity.Notes = strings.TrimSpace(input.Notes)
+    return nil
+}
@@ -0,0 +1,346 @@
+package services
+
+import (
+	"childSessions/model"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Consent types
+const (
+    ConsentTypeTreatment      = "treatment"
+    ConsentTypePhotography    = "photography"
+    ConsentTypeVideoRecording = "video_recording"
+    ConsentTypeDataSharing    = "data_sharing"
+)
+
+// Consent statuses
+const (
+    ConsentStatusGranted   = "granted"
+    ConsentStatusWithdrawn = "withdrawn"
+)
+
+// consentTypeLabels are the Indonesian names used in messages
+var consentTypeLabels = map[string]string{
+    ConsentTypeTreatment:      "terapi",
+    ConsentTypePhotography:    "foto",
+    ConsentTypeVideoRecording: "rekaman video",
+    ConsentTypeDataSharing:    "berbagi data",
+}
+
+// DefaultConsentExpiryWindowDays is how far ahead the dashboard looks for expiring consents
+const DefaultConsentExpiryWindowDays = 30
+
+// ConsentInput holds the editable fields of a consent
+type ConsentInput struct {
+    Type         string `json:"type"`
+    GuardianID   *uint  `json:"guardian_id"`
+    GrantedBy    string `json:"granted_by"`
+    SignedOn     string `json:"signed_on"`
+    ExpiresOn    string `json:"expires_on"`
+    AttachmentID *uint  `json:"attachment_id"`
+    Notes        string `json:"notes"`
+}
+
+// ExpiringConsent is a granted consent that expires soon
+type ExpiringConsent struct {
+    ConsentID uint      `json:"consent_id"`
+    ChildID   uint      `json:"child_id"`
+    ChildName string    `json:"child_name"`
+    Type      string    `json:"type"`
+    ExpiresOn time.Time `json:"expires_on"`
+    DaysLeft  int       `json:"days_left"`
+}
+
+// ConsentRequiredError is returned when an action needs a consent the child does not have
+type ConsentRequiredError struct {
+    ChildID uint
+    Type    string
+}
+
+func (e *ConsentRequiredError) Error() string {
+    return fmt.Sprintf("persetujuan %s untuk anak ini belum ada, sudah ditarik, atau sudah kedaluwarsa", ConsentTypeLabel(e.Type))
+}
+
+// ConsentTypeLabel returns the Indonesian name of a consent type
+func ConsentTypeLabel(consentType string) string {
+    if label, ok := consentTypeLabels[consentType]; ok {
+        return label
+    }
+    return consentType
+}
+
+type ConsentService struct {
+    db    *gorm.DB
+    audit *AuditService
+}
+
+func NewConsentService(db *gorm.DB, audit *AuditService) *ConsentService {
+    return &ConsentService{db: db, audit: audit}
+}
+
+// GetConsentsByChild lists a child's consents, current ones first
+func (s *ConsentService) GetConsentsByChild(childID uint) ([]model.Consent, error) {
+    var consents []model.Consent
+    if err := s.db.Where("child_id = ?", childID).
+        Order("status = 'withdrawn', type ASC, signed_on DESC, id DESC").
+        Find(&consents).Error; err != nil {
+        return nil, fmt.Errorf("gagal mengambil data persetujuan: %w", err)
+    }
+    return consents, nil
+}
+
+// GetConsentByID retrieves a consent by ID
+func (s *ConsentService) GetConsentByID(id uint) (*model.Consent, error) {
+    var consent model.Consent
+    if err := s.db.First(&consent, id).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("data persetujuan tidak ditemukan")
+        }
+        return nil, fmt.Errorf("gagal mengambil data persetujuan: %w", err)
+    }
+    return &consent, nil
+}
+
+// RecordConsent records a consent granted for a child
+func (s *ConsentService) RecordConsent(childID uint, input ConsentInput, recordedBy string) (*model.Consent, error) {
+    if err := ensureChildExists(s.db, childID); err != nil {
+        return nil, err
+    }
+    consent := &model.Consent{
+        ChildID:    childID,
+        Status:     ConsentStatusGranted,
+        RecordedBy: recordedBy,
+    }
+    if err := s.applyConsentInput(consent, input); err != nil {
+        return nil, err
+    }
+
+    err := s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Create(consent).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionCreate, AuditEntityConsent, consent.ID, nil, consent)
+        return nil
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal menyimpan persetujuan: %w", err)
+    }
+    return consent, nil
+}
+
+// UpdateConsent corrects the details of a consent; withdrawn consents cannot be edited
+func (s *ConsentService) UpdateConsent(id uint, input ConsentInput) (*model.Consent, error) {
+    consent, err := s.GetConsentByID(id)
+    if err != nil {
+        return nil, err
+    }
+    if consent.Status == ConsentStatusWithdrawn {
+        return nil, errors.New("persetujuan yang sudah ditarik tidak dapat diubah, catat persetujuan baru")
+    }
+
+    before := *consent
+    if err := s.applyConsentInput(consent, input); err != nil {
+        return nil, err
+    }
+
+    err = s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Save(consent).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionUpdate, AuditEntityConsent, consent.ID, before, consent)
+        return nil
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal memperbarui persetujuan: %w", err)
+    }
+    return consent, nil
+}
+
+// WithdrawConsent records that a family has withdrawn a consent; the record is kept
+func (s *ConsentService) WithdrawConsent(id uint, reason string) (*model.Consent, error) {
+    consent, err := s.GetConsentByID(id)
+    if err != nil {
+        return nil, err
+    }
+    if consent.Status == ConsentStatusWithdrawn {
+        return nil, errors.New("persetujuan sudah ditarik")
+    }
+
+    before := *consent
+    now := time.Now()
+    consent.Status = ConsentStatusWithdrawn
+    consent.WithdrawnAt = &now
+    consent.WithdrawnReason = strings.TrimSpace(reason)
+
+    err = s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Save(consent).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionUpdate, AuditEntityConsent, consent.ID, before, consent)
+        return nil
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal menarik persetujuan: %w", err)
+    }
+    return consent, nil
+}
+
+// HasValidConsent reports whether a child has a granted, unexpired consent of a type
+func (s *ConsentService) HasValidConsent(childID uint, consentType string) (bool, error) {
+    return hasValidConsent(s.db, childID, consentType, time.Now())
+}
+
+// RequireConsent returns a *ConsentRequiredError when a child has no valid consent of a type
+func (s *ConsentService) RequireConsent(childID uint, consentType string) error {
+    ok, err := s.HasValidConsent(childID, consentType)
+    if err != nil {
+        return err
+    }
+    if !ok {
+        return &ConsentRequiredError{ChildID: childID, Type: consentType}
+    }
+    return nil
+}
+
+// ChildrenWithValidConsent returns the IDs of children holding a valid consent of a type
+func (s *ConsentService) ChildrenWithValidConsent(consentType string) ([]uint, error) {
+    var childIDs []uint
+    if err := validConsentQuery(s.db, consentType, time.Now()).
+        Distinct("child_id").
+        Pluck("child_id", &childIDs).Error; err != nil {
+        return nil, fmt.Errorf("gagal memeriksa persetujuan: %w", err)
+    }
+    return childIDs, nil
+}
+
+// GetExpiringConsents lists granted consents expiring within the given number of days,
+// soonest first. When childIDs is non-nil only those children are included.
+func (s *ConsentService) GetExpiringConsents(withinDays int, childIDs []uint) ([]ExpiringConsent, error) {
+    today := startOfDay(time.Now())
+    until := today.AddDate(0, 0, withinDays)
+
+    query := s.db.Table("consents").
+        Select("consents.id, consents.child_id, children.name, consents.type, consents.expires_on").
+        Joins("JOIN children ON children.id = consents.child_id AND children.deleted_at IS NULL").
+        Where("consents.deleted_at IS NULL AND consents.status = ?", ConsentStatusGranted).
+        Where("consents.expires_on IS NOT NULL AND consents.expires_on >= ? AND consents.expires_on < ?", today, until.AddDate(0, 0, 1)).
+        Order("consents.expires_on ASC, children.name ASC")
+    if childIDs != nil {
+        query = query.Where("consents.child_id IN ?", childIDs)
+    }
+
+    rows, err := query.Rows()
+    if err != nil {
+        return nil, fmt.Errorf("gagal mengambil persetujuan yang akan kedaluwarsa: %w", err)
+    }
+    defer rows.Close()
+
+    expiring := make([]ExpiringConsent, 0)
+    for rows.Next() {
+        var item ExpiringConsent
+        if err := rows.Scan(&item.ConsentID, &item.ChildID, &item.ChildName, &item.Type, &item.ExpiresOn); err != nil {
+            return nil, fmt.Errorf("gagal membaca persetujuan: %w", err)
+        }
+        item.DaysLeft = int(startOfDay(item.ExpiresOn).Sub(today).Hours() / 24)
+        expiring = append(expiring, item)
+    }
+    return expiring, nil
+}
+
+func (s *ConsentService) applyConsentInput(consent *model.Consent, input ConsentInput) error {
+    if _, ok := consentTypeLabels[input.Type]; !ok {
+        return fmt.Errorf("jenis persetujuan tidak dikenal: %s", input.Type)
+    }
+
+    grantedBy := strings.TrimSpace(input.GrantedBy)
+    if input.GuardianID != nil {
+        var guardian model.Guardian
+        if err := s.db.First(&guardian, *input.GuardianID).Error; err != nil {
+            if errors.Is(err, gorm.ErrRecordNotFound) {
+                return errors.New("data wali tidak ditemukan")
+            }
+            return fmt.Errorf("gagal mengambil data wali: %w", err)
+        }
+        if guardian.ChildID != consent.ChildID {
+            return errors.New("wali bukan milik anak ini")
+        }
+        if grantedBy == "" {
+            grantedBy = guardian.Name
+        }
+    }
+    if grantedBy == "" {
+        return errors.New("nama pemberi persetujuan harus diisi")
+    }
+
+    if input.AttachmentID != nil {
+        var attachment model.Attachment
+        if err := s.db.First(&attachment, *input.AttachmentID).Error; err != nil {
+            if errors.Is(err, gorm.ErrRecordNotFound) {
+                return errors.New("lampiran tidak ditemukan")
+            }
+            return fmt.Errorf("gagal mengambil lampiran: %w", err)
+        }
+        if attachment.ChildID != consent.ChildID {
+            return errors.New("lampiran bukan milik anak ini")
+        }
+    }
+
+    signedOn, err := ParseCalendarDate(input.SignedOn)
+    if err != nil {
+        return err
+    }
+    if signedOn == nil {
+        today := startOfDay(time.Now())
+        signedOn = &today
+    }
+    if signedOn.After(time.Now()) {
+        return errors.New("tanggal tanda tangan tidak boleh di masa depan")
+    }
+    expiresOn, err := ParseCalendarDate(input.ExpiresOn)
+    if err != nil {
+        return err
+    }
+    if expiresOn != nil && expiresOn.Before(*signedOn) {
+        return errors.New("tanggal kedaluwarsa tidak boleh sebelum tanggal tanda tangan")
+    }
+
+    consent.Type = input.Type
+    consent.GuardianID = input.GuardianID
+    consent.GrantedBy = grantedBy
+    consent.SignedOn = signedOn
+    consent.ExpiresOn = expiresOn
+    consent.AttachmentID = input.AttachmentID
+    consent.Notes = strings.TrimSpace(input.Notes)
+    return nil
+}
+
+// hasValidConsent reports whether a child held a granted, unexpired consent of a type at a time
+func hasValidConsent(db *gorm.DB, childID uint, consentType string, at time.Time) (bool, error) {
+    var count int64
+    if err := validConsentQuery(db, consentType, at).
+        Where("child_id = ?", childID).
+        Count(&count).Error; err != nil {
+        return false, fmt.Errorf("gagal memeriksa persetujuan: %w", err)
+    }
+    return count > 0, nil
+}
+
+// validConsentQuery selects consents of a type that are granted and in force at a time;
+// a consent stays valid through the whole day it expires
+func validConsentQuery(db *gorm.DB, consentType string, at time.Time) *gorm.DB {
+    day := startOfDay(at)
+    return db.Model(&model.Consent{}).
+        Where("type = ? AND status = ?", consentType, ConsentStatusGranted).
+        Where("signed_on IS NULL OR signed_on < ?", day.AddDate(0, 0, 1)).
+        Where("expires_on IS NULL OR expires_on >= ?", day)
+}
+
+func startOfDay(t time.Time) time.Time {
+    t = t.In(time.Local)
+    return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
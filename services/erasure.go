@@ -0,0 +1,173 @@
+package services
+
+import (
+	"childSessions/model"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SettingErasureSigningKey names the installation key used to sign erasure receipts.
+// Earlier versions kept it in app_settings; it now lives in the OS keychain.
+const SettingErasureSigningKey = "erasure_signing_key"
+
+// ErasureReceiptVerification is the result of checking an erasure receipt's signature
+type ErasureReceiptVerification struct {
+    Valid   bool                 `json:"valid"`
+    Receipt model.ErasureReceipt `json:"receipt"`
+    Message string               `json:"message"`
+}
+
+type ErasureService struct {
+    db       *gorm.DB
+    audit    *AuditService
+    settings *SettingService
+}
+
+func NewErasureService(db *gorm.DB, audit *AuditService, settings *SettingService) *ErasureService {
+    return &ErasureService{db: db, audit: audit, settings: settings}
+}
+
+// EraseChild permanently deletes a child, including one already in the trash, and every
+// row that depends on it in a single transaction. The freed database pages are then
+// rewritten so the erased data cannot be recovered from the file, and a signed receipt
+// is returned. The audit log keeps only hashes and IDs, so it is left intact.
+func (s *ErasureService) EraseChild(childID uint, erasedBy, reason string) (*model.ErasureReceipt, error) {
+    if erasedBy == "" {
+        return nil, errors.New("nama petugas yang menghapus harus diisi")
+    }
+
+    var child model.Child
+    if err := s.db.Unscoped().First(&child, childID).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("data anak tidak ditemukan")
+        }
+        return nil, fmt.Errorf("gagal mengambil data anak: %w", err)
+    }
+
+    key, err := s.signingKey()
+    if err != nil {
+        return nil, err
+    }
+
+    receipt := &model.ErasureReceipt{
+        ErasedAt: time.Now().UTC().Truncate(time.Microsecond),
+        ChildID:  child.ID,
+        ErasedBy: erasedBy,
+        Reason:   reason,
+    }
+
+    err = s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        counts, err := purgeChild(tx, child.ID)
+        if err != nil {
+            return err
+        }
+        rowCounts, err := json.Marshal(counts)
+        if err != nil {
+            return err
+        }
+        receipt.RowCounts = string(rowCounts)
+        trail.Add(AuditActionPurge, AuditEntityChild, child.ID, child, nil)
+
+        // The receipt is tied to the audit entry of the purge and committed with it, so
+        // an erasure never goes unrecorded
+        trail.After(func(tx *gorm.DB, entries []model.AuditLog) error {
+            for _, entry := range entries {
+                if entry.Action == AuditActionPurge && entry.EntityType == AuditEntityChild && entry.EntityID == child.ID {
+                    receipt.AuditHash = entry.Hash
+                }
+            }
+            receipt.Signature = signErasureReceipt(key, *receipt)
+            return tx.Create(receipt).Error
+        })
+        return nil
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal menghapus data anak secara permanen: %w", err)
+    }
+
+    if err := compactDatabase(s.db); err != nil {
+        return nil, err
+    }
+
+    return receipt, nil
+}
+
+// compactDatabase rewrites the database file so deleted rows cannot be recovered from it.
+// Deleted rows linger in free pages until the file is rebuilt, and the rebuilt pages
+// only reach the main file once the write-ahead log is checkpointed.
+func compactDatabase(db *gorm.DB) error {
+    if err := db.Exec("VACUUM").Error; err != nil {
+        return fmt.Errorf("gagal memadatkan basis data: %w", err)
+    }
+    if err := db.Exec("PRAGMA wal_checkpoint(TRUNCATE)").Error; err != nil {
+        return fmt.Errorf("gagal membersihkan log basis data: %w", err)
+    }
+    return nil
+}
+
+// GetErasureReceipts lists all erasure receipts, newest first
+func (s *ErasureService) GetErasureReceipts() ([]model.ErasureReceipt, error) {
+    var receipts []model.ErasureReceipt
+    if err := s.db.Order("erased_at DESC").Find(&receipts).Error; err != nil {
+        return nil, fmt.Errorf("gagal mengambil bukti penghapusan: %w", err)
+    }
+    return receipts, nil
+}
+
+// VerifyErasureReceipt checks that a stored receipt has not been altered since it was signed
+func (s *ErasureService) VerifyErasureReceipt(receiptID uint) (*ErasureReceiptVerification, error) {
+    var receipt model.ErasureReceipt
+    if err := s.db.First(&receipt, receiptID).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("bukti penghapusan tidak ditemukan")
+        }
+        return nil, fmt.Errorf("gagal mengambil bukti penghapusan: %w", err)
+    }
+
+    key, err := s.signingKey()
+    if err != nil {
+        return nil, err
+    }
+
+    result := &ErasureReceiptVerification{Receipt: receipt}
+    expected := signErasureReceipt(key, receipt)
+    if hmac.Equal([]byte(expected), []byte(receipt.Signature)) {
+        result.Valid = true
+        result.Message = "tanda tangan bukti penghapusan valid"
+    } else {
+        result.Message = "tanda tangan bukti penghapusan tidak valid"
+    }
+    return result, nil
+}
+
+// signingKey returns the installation's receipt signing key. It is kept in the OS
+// keychain rather than the database, so a copy of the database cannot forge receipts.
+func (s *ErasureService) signingKey() ([]byte, error) {
+    key, err := installationSecret(s.settings, SettingErasureSigningKey)
+    if err != nil {
+        return nil, fmt.Errorf("kunci tanda tangan bukti penghapusan tidak tersedia: %w", err)
+    }
+    return key, nil
+}
+
+// signErasureReceipt computes the HMAC of every receipt field except the signature
+func signErasureReceipt(key []byte, receipt model.ErasureReceipt) string {
+    payload := fmt.Sprintf("%s|%d|%s|%s|%s|%s",
+        receipt.ErasedAt.UTC().Format(time.RFC3339Nano),
+        receipt.ChildID,
+        receipt.ErasedBy,
+        receipt.Reason,
+        receipt.RowCounts,
+        receipt.AuditHash,
+    )
+    mac := hmac.New(sha256.New, key)
+    mac.Write([]byte(payload))
+    return hex.EncodeToString(mac.Sum(nil))
+}
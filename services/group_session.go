@@ -0,0 +1,339 @@
+package services
+
+import (
+	"childSessions/model"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Attendance of a child in a group session
+const (
+    AttendancePresent   = "present"
+    AttendanceLate      = "late"
+    AttendanceLeftEarly = "left_early"
+    AttendanceAbsent    = "absent"
+)
+
+// ParticipantAttendanceInput records how a child attended a group session
+type ParticipantAttendanceInput struct {
+    Attendance string `json:"attendance"`
+    ArrivedAt  string `json:"arrived_at"` // RFC 3339 or "15:04" on the session date; optional
+    LeftAt     string `json:"left_at"`
+    Notes      string `json:"notes"`
+}
+
+// ChildSessionIDs selects every session a child belongs to: individual sessions and
+// group sessions they were listed in, including ones they missed
+func ChildSessionIDs(db *gorm.DB, childID uint) *gorm.DB {
+    participants := db.Model(&model.SessionParticipant{}).Select("session_id").Where("child_id = ?", childID)
+    return db.Model(&model.Session{}).Select("sessions.id").
+        Where("(sessions.is_group = ? AND sessions.child_id = ?) OR sessions.id IN (?)", false, childID, participants)
+}
+
+// AttendedSessionIDs selects the sessions a child took part in, leaving out scheduled,
+// cancelled and missed sessions and group sessions where the child was absent. Progress
+// figures are based on these.
+func AttendedSessionIDs(db *gorm.DB, childID uint) *gorm.DB {
+    participants := db.Model(&model.SessionParticipant{}).Select("session_id").
+        Where("child_id = ? AND attendance <> ?", childID, AttendanceAbsent)
+    return db.Model(&model.Session{}).Select("sessions.id").
+        Where("sessions.status IN ? AND ((sessions.is_group = ? AND sessions.child_id = ?) OR sessions.id IN (?))", HeldSessionStatuses, false, childID, participants)
+}
+
+// activeSessionForChild finds an open session the child belongs to; nil when there is
+// none. Sessions that are only scheduled do not count.
+func activeSessionForChild(db *gorm.DB, childID uint) (*model.Session, error) {
+    var session model.Session
+    err := db.Where("end_time IS NULL AND status IN ? AND id IN (?)", HeldSessionStatuses, ChildSessionIDs(db, childID)).First(&session).Error
+    if errors.Is(err, gorm.ErrRecordNotFound) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("gagal memeriksa sesi aktif: %w", err)
+    }
+    return &session, nil
+}
+
+// ensureChildInSession checks that a child belongs to a session: the session's child, or
+// a listed participant of a group session
+func ensureChildInSession(db *gorm.DB, sessionID, childID uint) error {
+    var count int64
+    if err := db.Model(&model.Session{}).Where("id = ? AND id IN (?)", sessionID, ChildSessionIDs(db, childID)).Count(&count).Error; err != nil {
+        return fmt.Errorf("gagal memeriksa peserta sesi: %w", err)
+    }
+    if count == 0 {
+        return errors.New("anak bukan peserta sesi ini")
+    }
+    return nil
+}
+
+// StartGroupSession starts one session for several children. The first child is the
+// session's primary child; every child is listed as a participant.
+func (s *SessionService) StartGroupSession(childIDs []uint, groupName string, therapistID *uint) (*model.Session, error) {
+    childIDs = uniqueIDs(childIDs)
+    if len(childIDs) < 2 {
+        return nil, errors.New("sesi kelompok membutuhkan minimal dua anak")
+    }
+    groupName = strings.TrimSpace(groupName)
+    if groupName == "" {
+        return nil, errors.New("nama kelompok harus diisi")
+    }
+
+    var warnings []string
+    children := make([]model.Child, 0, len(childIDs))
+    for _, childID := range childIDs {
+        var child model.Child
+        if err := s.db.First(&child, childID).Error; err != nil {
+            if errors.Is(err, gorm.ErrRecordNotFound) {
+                return nil, errors.New("data anak tidak ditemukan")
+            }
+            return nil, fmt.Errorf("gagal mengambil data anak: %w", err)
+        }
+        active, err := activeSessionForChild(s.db, childID)
+        if err != nil {
+            return nil, err
+        }
+        if active != nil {
+            return nil, fmt.Errorf("masih ada sesi aktif untuk %s", child.Name)
+        }
+
+        childWarnings, err := s.childStartWarnings(childID, nil)
+        if err != nil {
+            return nil, err
+        }
+        for _, warning := range childWarnings {
+            warnings = append(warnings, fmt.Sprintf("%s: %s", child.Name, warning))
+        }
+        children = append(children, child)
+    }
+
+    now := time.Now()
+    session := &model.Session{
+        ChildID:     childIDs[0],
+        TherapistID: therapistID,
+        StartTime:   now,
+        IsGroup:     true,
+        GroupName:   groupName,
+        Status:      SessionStatusAttended,
+    }
+    err := s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Create(session).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionCreate, AuditEntitySession, session.ID, nil, session)
+        for _, child := range children {
+            participant := &model.SessionParticipant{
+                SessionID:  session.ID,
+                ChildID:    child.ID,
+                Attendance: AttendancePresent,
+                ArrivedAt:  &now,
+            }
+            if err := tx.Omit("Child").Create(participant).Error; err != nil {
+                return err
+            }
+            trail.Add(AuditActionCreate, AuditEntitySessionParticipant, participant.ID, nil, participant)
+        }
+        return nil
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal membuat sesi kelompok: %w", err)
+    }
+
+    if err := s.db.Preload("Child").Preload("Therapist").Preload("Participants.Child").First(session, session.ID).Error; err != nil {
+        return nil, fmt.Errorf("gagal memuat data sesi: %w", err)
+    }
+    setSessionAges(session, time.Now())
+    session.Warnings = warnings
+    return session, nil
+}
+
+// AddGroupParticipant adds a child who joins a group session that is already running
+func (s *SessionService) AddGroupParticipant(sessionID, childID uint) (*model.SessionParticipant, error) {
+    session, err := s.getGroupSession(sessionID)
+    if err != nil {
+        return nil, err
+    }
+    if session.EndTime != nil || !IsHeldStatus(session.Status) {
+        return nil, errors.New("sesi tidak sedang berlangsung")
+    }
+    var child model.Child
+    if err := s.db.First(&child, childID).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("data anak tidak ditemukan")
+        }
+        return nil, fmt.Errorf("gagal mengambil data anak: %w", err)
+    }
+    active, err := activeSessionForChild(s.db, childID)
+    if err != nil {
+        return nil, err
+    }
+    if active != nil {
+        if active.ID == sessionID {
+            return nil, errors.New("anak sudah menjadi peserta sesi ini")
+        }
+        return nil, fmt.Errorf("masih ada sesi aktif untuk %s", child.Name)
+    }
+    warnings, err := s.childStartWarnings(childID, nil)
+    if err != nil {
+        return nil, err
+    }
+
+    now := time.Now()
+    participant := &model.SessionParticipant{
+        SessionID:  sessionID,
+        ChildID:    childID,
+        Attendance: AttendanceLate,
+        ArrivedAt:  &now,
+    }
+    err = s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Omit("Child").Create(participant).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionCreate, AuditEntitySessionParticipant, participant.ID, nil, participant)
+        return nil
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal menambahkan peserta: %w", err)
+    }
+    participant.Child = child
+    participant.Warnings = warnings
+    return participant, nil
+}
+
+// SetParticipantAttendance records a child's attendance in a group session. Sessions
+// already on an invoice must have the invoice voided first.
+func (s *SessionService) SetParticipantAttendance(sessionID, childID uint, input ParticipantAttendanceInput) (*model.SessionParticipant, error) {
+    session, err := s.getGroupSession(sessionID)
+    if err != nil {
+        return nil, err
+    }
+    if err := ensureSessionWritable(s.db, sessionID); err != nil {
+        return nil, err
+    }
+    if err := ensureSessionNotInvoiced(s.db, sessionID); err != nil {
+        return nil, err
+    }
+
+    var participant model.SessionParticipant
+    if err := s.db.Where("session_id = ? AND child_id = ?", sessionID, childID).First(&participant).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("anak bukan peserta sesi ini")
+        }
+        return nil, fmt.Errorf("gagal mengambil peserta sesi: %w", err)
+    }
+
+    switch input.Attendance {
+    case AttendancePresent, AttendanceLate, AttendanceLeftEarly, AttendanceAbsent:
+    default:
+        return nil, fmt.Errorf("status kehadiran tidak valid: %q", input.Attendance)
+    }
+    arrivedAt, err := parseSessionClock(session, input.ArrivedAt)
+    if err != nil {
+        return nil, err
+    }
+    leftAt, err := parseSessionClock(session, input.LeftAt)
+    if err != nil {
+        return nil, err
+    }
+    if arrivedAt != nil && leftAt != nil && leftAt.Before(*arrivedAt) {
+        return nil, errors.New("waktu pulang tidak boleh sebelum waktu datang")
+    }
+
+    before := participant
+    participant.Attendance = input.Attendance
+    participant.AttendanceNotes = strings.TrimSpace(input.Notes)
+    if input.Attendance == AttendanceAbsent {
+        participant.ArrivedAt = nil
+        participant.LeftAt = nil
+    } else {
+        if arrivedAt != nil {
+            participant.ArrivedAt = arrivedAt
+        }
+        participant.LeftAt = leftAt
+    }
+
+    err = s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Omit("Child").Save(&participant).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionUpdate, AuditEntitySessionParticipant, participant.ID, before, participant)
+        if session.EndTime == nil {
+            return nil
+        }
+        // Once the session has ended, a child marked absent gets the session back on their package
+        if participant.Attendance == AttendanceAbsent {
+            return returnSessionPackage(tx, trail, childID, session, "Tidak hadir di sesi kelompok")
+        }
+        return useSessionPackage(tx, trail, childID, session)
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal menyimpan kehadiran: %w", err)
+    }
+    return &participant, nil
+}
+
+// GetSessionParticipants lists the children of a group session
+func (s *SessionService) GetSessionParticipants(sessionID uint) ([]model.SessionParticipant, error) {
+    var participants []model.SessionParticipant
+    if err := s.db.Preload("Child").Where("session_id = ?", sessionID).Order("id ASC").Find(&participants).Error; err != nil {
+        return nil, fmt.Errorf("gagal mengambil peserta sesi: %w", err)
+    }
+    return participants, nil
+}
+
+func (s *SessionService) getGroupSession(sessionID uint) (*model.Session, error) {
+    var session model.Session
+    if err := s.db.First(&session, sessionID).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("sesi tidak ditemukan")
+        }
+        return nil, fmt.Errorf("gagal mengambil data sesi: %w", err)
+    }
+    if !session.IsGroup {
+        return nil, errors.New("sesi ini bukan sesi kelompok")
+    }
+    return &session, nil
+}
+
+// childStartWarnings returns the clinical, treatment consent and prepaid package warnings
+// shown when a child's session starts
+func (s *SessionService) childStartWarnings(childID uint, activities []model.Activity) ([]string, error) {
+    warnings, err := clinicalWarnings(s.db, childID, activities)
+    if err != nil {
+        return nil, err
+    }
+    consented, err := hasValidConsent(s.db, childID, ConsentTypeTreatment, time.Now())
+    if err != nil {
+        return nil, err
+    }
+    if !consented {
+        warnings = append(warnings, "Perhatian: persetujuan terapi belum tercatat, sudah ditarik, atau sudah kedaluwarsa")
+    }
+    packageWarnings, err := packageWarnings(s.db, childID, time.Now())
+    if err != nil {
+        return nil, err
+    }
+    return append(warnings, packageWarnings...), nil
+}
+
+// parseSessionClock reads a full timestamp, or a time of day on the session's date
+func parseSessionClock(session *model.Session, value string) (*time.Time, error) {
+    value = strings.TrimSpace(value)
+    if value == "" {
+        return nil, nil
+    }
+    if t, err := time.Parse(time.RFC3339, value); err == nil {
+        return &t, nil
+    }
+    clock, err := time.Parse("15:04", value)
+    if err != nil {
+        return nil, fmt.Errorf("format waktu tidak valid: %s (gunakan JJ:MM)", value)
+    }
+    start := session.StartTime
+    t := time.Date(start.Year(), start.Month(), start.Day(), clock.Hour(), clock.Minute(), 0, 0, start.Location())
+    return &t, nil
+}
@@ -0,0 +1,319 @@
+package services
+
+import (
+	"childSessions/model"
+	"errors"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Guardian relationships
+const (
+    RelationshipMother      = "mother"
+    RelationshipFather      = "father"
+    RelationshipStepParent  = "step_parent"
+    RelationshipGrandparent = "grandparent"
+    RelationshipGuardian    = "guardian"
+    RelationshipSibling     = "sibling"
+    RelationshipOther       = "other"
+)
+
+var validRelationships = map[string]bool{
+    RelationshipMother:      true,
+    RelationshipFather:      true,
+    RelationshipStepParent:  true,
+    RelationshipGrandparent: true,
+    RelationshipGuardian:    true,
+    RelationshipSibling:     true,
+    RelationshipOther:       true,
+}
+
+// GuardianInput holds the editable fields of a guardian
+type GuardianInput struct {
+    Name               string `json:"name"`
+    Relationship       string `json:"relationship"`
+    Phone              string `json:"phone"`
+    Email              string `json:"email"`
+    IsPrimary          bool   `json:"is_primary"`
+    IsEmergencyContact bool   `json:"is_emergency_contact"`
+    HasLegalCustody    bool   `json:"has_legal_custody"`
+    ConsentToContact   bool   `json:"consent_to_contact"`
+    ConsentToShareInfo bool   `json:"consent_to_share_info"`
+    Notes              string `json:"notes"`
+}
+
+var phoneSeparators = regexp.MustCompile(`[\s\-().]`)
+var phonePattern = regexp.MustCompile(`^\+?[0-9]{8,15}$`)
+
+// NormalizePhone validates a phone number and strips spaces, dashes, dots and brackets
+func NormalizePhone(phone string) (string, error) {
+    phone = strings.TrimSpace(phone)
+    if phone == "" {
+        return "", nil
+    }
+    normalized := phoneSeparators.ReplaceAllString(phone, "")
+    if !phonePattern.MatchString(normalized) {
+        return "", fmt.Errorf("nomor telepon tidak valid: %s", phone)
+    }
+    return normalized, nil
+}
+
+// NormalizeEmail validates an email address and lower-cases it
+func NormalizeEmail(email string) (string, error) {
+    email = strings.TrimSpace(email)
+    if email == "" {
+        return "", nil
+    }
+    address, err := mail.ParseAddress(email)
+    if err != nil || address.Address != email || address.Name != "" {
+        return "", fmt.Errorf("alamat email tidak valid: %s", email)
+    }
+    at := strings.LastIndex(email, "@")
+    if at < 1 || !strings.Contains(email[at+1:], ".") {
+        return "", fmt.Errorf("alamat email tidak valid: %s", email)
+    }
+    return strings.ToLower(email), nil
+}
+
+type GuardianService struct {
+    db    *gorm.DB
+    audit *AuditService
+}
+
+func NewGuardianService(db *gorm.DB, audit *AuditService) *GuardianService {
+    return &GuardianService{db: db, audit: audit}
+}
+
+// GetGuardiansByChild lists a child's guardians, primary contact first
+func (s *GuardianService) GetGuardiansByChild(childID uint) ([]model.Guardian, error) {
+    var guardians []model.Guardian
+    if err := s.db.Where("child_id = ?", childID).
+        Order("is_primary DESC, is_emergency_contact DESC, name ASC").
+        Find(&guardians).Error; err != nil {
+        return nil, fmt.Errorf("gagal mengambil data wali: %w", err)
+    }
+    return guardians, nil
+}
+
+// GetGuardianByID retrieves a guardian by ID
+func (s *GuardianService) GetGuardianByID(id uint) (*model.Guardian, error) {
+    var guardian model.Guardian
+    if err := s.db.First(&guardian, id).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("data wali tidak ditemukan")
+        }
+        return nil, fmt.Errorf("gagal mengambil data wali: %w", err)
+    }
+    return &guardian, nil
+}
+
+// CreateGuardian adds a guardian to a child
+func (s *GuardianService) CreateGuardian(childID uint, input GuardianInput) (*model.Guardian, error) {
+    var child model.Child
+    if err := s.db.First(&child, childID).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("data anak tidak ditemukan")
+        }
+        return nil, fmt.Errorf("gagal mengambil data anak: %w", err)
+    }
+
+    guardian := &model.Guardian{ChildID: childID}
+    if err := applyGuardianInput(guardian, input); err != nil {
+        return nil, err
+    }
+
+    err := s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        return createGuardian(tx, trail, guardian)
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal menyimpan data wali: %w", err)
+    }
+    return guardian, nil
+}
+
+// UpdateGuardian replaces the editable fields of a guardian
+func (s *GuardianService) UpdateGuardian(id uint, input GuardianInput) (*model.Guardian, error) {
+    guardian, err := s.GetGuardianByID(id)
+    if err != nil {
+        return nil, err
+    }
+
+    before := *guardian
+    if err := applyGuardianInput(guardian, input); err != nil {
+        return nil, err
+    }
+
+    err = s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if guardian.IsPrimary {
+            if err := clearPrimaryGuardian(tx, trail, guardian.ChildID, guardian.ID); err != nil {
+                return err
+            }
+        }
+        if err := tx.Save(guardian).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionUpdate, AuditEntityGuardian, guardian.ID, before, guardian)
+        return nil
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal memperbarui data wali: %w", err)
+    }
+    return guardian, nil
+}
+
+// DeleteGuardian removes a guardian (soft delete)
+func (s *GuardianService) DeleteGuardian(id uint) error {
+    guardian, err := s.GetGuardianByID(id)
+    if err != nil {
+        return err
+    }
+
+    err = s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Delete(guardian).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionDelete, AuditEntityGuardian, guardian.ID, guardian, nil)
+        return nil
+    })
+    if err != nil {
+        return fmt.Errorf("gagal menghapus data wali: %w", err)
+    }
+    return nil
+}
+
+var legacyEmailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+var legacyPhonePattern = regexp.MustCompile(`\+?[0-9][0-9\s\-().]{6,}[0-9]`)
+
+// guardianFromLegacyContact builds a primary guardian from the free-text ParentGuardianName
+// and ContactInfo fields, keeping anything that is not a valid phone or email in Notes
+func guardianFromLegacyContact(childID uint, name, contactInfo string) *model.Guardian {
+    guardian := &model.Guardian{
+        ChildID:          childID,
+        Name:             strings.TrimSpace(name),
+        Relationship:     RelationshipGuardian,
+        IsPrimary:        true,
+        ConsentToContact: true,
+    }
+    if guardian.Name == "" {
+        guardian.Name = "Wali"
+    }
+
+    rest := contactInfo
+    if match := legacyEmailPattern.FindString(rest); match != "" {
+        if email, err := NormalizeEmail(match); err == nil {
+            guardian.Email = email
+            rest = strings.Replace(rest, match, "", 1)
+        }
+    }
+    if match := legacyPhonePattern.FindString(rest); match != "" {
+        if phone, err := NormalizePhone(match); err == nil {
+            guardian.Phone = phone
+            rest = strings.Replace(rest, match, "", 1)
+        }
+    }
+    guardian.Notes = strings.TrimSpace(strings.Trim(strings.TrimSpace(rest), ",;/|-"))
+    return guardian
+}
+
+// syncPrimaryGuardian applies edited legacy ParentGuardianName/ContactInfo fields to the
+// child's primary guardian, or creates one when the child has none. Clearing both fields
+// leaves the guardian records as they are.
+func syncPrimaryGuardian(tx *gorm.DB, trail *AuditTrail, childID uint, name, contactInfo string) error {
+    if strings.TrimSpace(name) == "" && strings.TrimSpace(contactInfo) == "" {
+        return nil
+    }
+    legacy := guardianFromLegacyContact(childID, name, contactInfo)
+
+    var guardian model.Guardian
+    err := tx.Where("child_id = ? AND is_primary = ?", childID, true).First(&guardian).Error
+    if errors.Is(err, gorm.ErrRecordNotFound) {
+        return createGuardian(tx, trail, legacy)
+    }
+    if err != nil {
+        return err
+    }
+
+    before := guardian
+    guardian.Name = legacy.Name
+    guardian.Phone = legacy.Phone
+    guardian.Email = legacy.Email
+    guardian.Notes = legacy.Notes
+    if err := tx.Save(&guardian).Error; err != nil {
+        return err
+    }
+    trail.Add(AuditActionUpdate, AuditEntityGuardian, guardian.ID, before, guardian)
+    return nil
+}
+
+// createGuardian inserts a validated guardian inside an audited transaction
+func createGuardian(tx *gorm.DB, trail *AuditTrail, guardian *model.Guardian) error {
+    if guardian.IsPrimary {
+        if err := clearPrimaryGuardian(tx, trail, guardian.ChildID, 0); err != nil {
+            return err
+        }
+    }
+    if err := tx.Create(guardian).Error; err != nil {
+        return err
+    }
+    trail.Add(AuditActionCreate, AuditEntityGuardian, guardian.ID, nil, guardian)
+    return nil
+}
+
+// clearPrimaryGuardian unmarks the child's current primary contact, except exceptID
+func clearPrimaryGuardian(tx *gorm.DB, trail *AuditTrail, childID, exceptID uint) error {
+    var previous []model.Guardian
+    if err := tx.Where("child_id = ? AND is_primary = ? AND id <> ?", childID, true, exceptID).Find(&previous).Error; err != nil {
+        return err
+    }
+    for _, guardian := range previous {
+        before := guardian
+        if err := tx.Model(&guardian).Update("is_primary", false).Error; err != nil {
+            return err
+        }
+        guardian.IsPrimary = false
+        trail.Add(AuditActionUpdate, AuditEntityGuardian, guardian.ID, before, guardian)
+    }
+    return nil
+}
+
+// applyGuardianInput validates input and copies it onto guardian
+func applyGuardianInput(guardian *model.Guardian, input GuardianInput) error {
+    name := strings.TrimSpace(input.Name)
+    if name == "" {
+        return errors.New("nama wali harus diisi")
+    }
+    relationship := strings.TrimSpace(input.Relationship)
+    if relationship == "" {
+        relationship = RelationshipGuardian
+    }
+    if !validRelationships[relationship] {
+        return fmt.Errorf("hubungan dengan anak tidak dikenal: %s", relationship)
+    }
+    phone, err := NormalizePhone(input.Phone)
+    if err != nil {
+        return err
+    }
+    email, err := NormalizeEmail(input.Email)
+    if err != nil {
+        return err
+    }
+    if input.IsEmergencyContact && phone == "" {
+        return errors.New("kontak darurat harus memiliki nomor telepon")
+    }
+
+    guardian.Name = name
+    guardian.Relationship = relationship
+    guardian.Phone = phone
+    guardian.Email = email
+    guardian.IsPrimary = input.IsPrimary
+    guardian.IsEmergencyContact = input.IsEmergencyContact
+    guardian.HasLegalCustody = input.HasLegalCustody
+    guardian.ConsentToContact = input.ConsentToContact
+    guardian.ConsentToShareInfo = input.ConsentToShareInfo
+    guardian.Notes = strings.TrimSpace(input.Notes)
+    return nil
+}
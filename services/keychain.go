@@ -0,0 +1,60 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/zalando/go-keyring"
+)
+
+// keychainService is the name the installation's secrets are stored under in the OS keychain
+const keychainService = "Child Sessions"
+
+// installationSecret returns a 32-byte key kept in the OS keychain under name, so a
+// copy of the database alone is not enough to read or forge what the key protects.
+// The key is created on first use. A key an earlier version kept in app_settings under
+// the same name is moved to the keychain and wiped from the database file.
+func installationSecret(settings *SettingService, name string) ([]byte, error) {
+    value, err := keyring.Get(keychainService, name)
+    if err != nil && !errors.Is(err, keyring.ErrNotFound) {
+        return nil, fmt.Errorf("gagal membaca kunci dari keychain sistem: %w", err)
+    }
+    if err == nil {
+        key, err := hex.DecodeString(value)
+        if err != nil || len(key) != 32 {
+            return nil, fmt.Errorf("kunci %s di keychain sistem rusak", name)
+        }
+        return key, nil
+    }
+
+    legacy, err := settings.Get(name, "")
+    if err != nil {
+        return nil, err
+    }
+    var key []byte
+    if legacy != "" {
+        if key, err = hex.DecodeString(legacy); err != nil || len(key) != 32 {
+            return nil, fmt.Errorf("kunci %s rusak", name)
+        }
+    } else {
+        key = make([]byte, 32)
+        if _, err := rand.Read(key); err != nil {
+            return nil, fmt.Errorf("gagal membuat kunci: %w", err)
+        }
+    }
+
+    if err := keyring.Set(keychainService, name, hex.EncodeToString(key)); err != nil {
+        return nil, fmt.Errorf("gagal menyimpan kunci ke keychain sistem: %w", err)
+    }
+    if legacy != "" {
+        if err := settings.Delete(name); err != nil {
+            return nil, err
+        }
+        if err := compactDatabase(settings.db); err != nil {
+            return nil, err
+        }
+    }
+    return key, nil
+}
@@ -0,0 +1,287 @@
+package services
+
+import (
+	"childSessions/model"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Milestone domains
+const (
+    MilestoneDomainSpeech     = "speech"
+    MilestoneDomainFineMotor  = "fine_motor"
+    MilestoneDomainGrossMotor = "gross_motor"
+    MilestoneDomainSocial     = "social"
+    MilestoneDomainSelfCare   = "self_care"
+)
+
+// Milestone statuses
+const (
+    MilestoneStatusNotYet   = "not_yet"
+    MilestoneStatusEmerging = "emerging"
+    MilestoneStatusAchieved = "achieved"
+)
+
+// MilestoneDomainLabels are the display names of the milestone domains
+var MilestoneDomainLabels = map[string]string{
+    MilestoneDomainSpeech:     "Bicara & bahasa",
+    MilestoneDomainFineMotor:  "Motorik halus",
+    MilestoneDomainGrossMotor: "Motorik kasar",
+    MilestoneDomainSocial:     "Sosial",
+    MilestoneDomainSelfCare:   "Bina diri",
+}
+
+// MilestoneStatusInput sets the status of one milestone for a child
+type MilestoneStatusInput struct {
+    Status string `json:"status"`
+    Date   string `json:"date"` // When the status was observed; empty means today
+    Notes  string `json:"notes"`
+}
+
+// ChildMilestoneView is a library milestone with one child's status
+type ChildMilestoneView struct {
+    Milestone   model.Milestone `json:"milestone"`
+    DomainLabel string          `json:"domain_label"`
+    Status      string          `json:"status"`
+    EmergingOn  *time.Time      `json:"emerging_on"`
+    AchievedOn  *time.Time      `json:"achieved_on"`
+    Notes       string          `json:"notes"`
+    UpdatedBy   string          `json:"updated_by"`
+    Overdue     bool            `json:"overdue"` // Expected by the child's current age but not achieved
+}
+
+// MissedMilestones lists milestones expected by a child's age that are not achieved
+type MissedMilestones struct {
+    ChildID    uint                 `json:"child_id"`
+    Age        model.Age            `json:"age"`
+    Milestones []ChildMilestoneView `json:"milestones"`
+    ByDomain   map[string]int       `json:"by_domain"`
+}
+
+// MilestoneSummary counts a child's milestones per status
+type MilestoneSummary struct {
+    Achieved int `json:"achieved"`
+    Emerging int `json:"emerging"`
+    Overdue  int `json:"overdue"` // Zero when the date of birth is unknown
+    Total    int `json:"total"`
+}
+
+type MilestoneService struct {
+    db    *gorm.DB
+    audit *AuditService
+}
+
+func NewMilestoneService(db *gorm.DB, audit *AuditService) *MilestoneService {
+    return &MilestoneService{db: db, audit: audit}
+}
+
+// GetMilestones lists the milestone library, optionally for one domain
+func (s *MilestoneService) GetMilestones(domain string) ([]model.Milestone, error) {
+    query := s.db.Preload("Activity").Order("sort_order ASC, id ASC")
+    if domain != "" {
+        if _, ok := MilestoneDomainLabels[domain]; !ok {
+            return nil, fmt.Errorf("domain milestone tidak dikenal: %s", domain)
+        }
+        query = query.Where("domain = ?", domain)
+    }
+    var milestones []model.Milestone
+    if err := query.Find(&milestones).Error; err != nil {
+        return nil, fmt.Errorf("gagal mengambil daftar milestone: %w", err)
+    }
+    return milestones, nil
+}
+
+// GetChildMilestones lists every library milestone with the child's status, optionally for one domain
+func (s *MilestoneService) GetChildMilestones(childID uint, domain string) ([]ChildMilestoneView, error) {
+    var child model.Child
+    if err := s.db.First(&child, childID).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("data anak tidak ditemukan")
+        }
+        return nil, fmt.Errorf("gagal mengambil data anak: %w", err)
+    }
+    return s.childMilestones(child, domain)
+}
+
+func (s *MilestoneService) childMilestones(child model.Child, domain string) ([]ChildMilestoneView, error) {
+    milestones, err := s.GetMilestones(domain)
+    if err != nil {
+        return nil, err
+    }
+
+    var statuses []model.ChildMilestone
+    if err := s.db.Where("child_id = ?", child.ID).Find(&statuses).Error; err != nil {
+        return nil, fmt.Errorf("gagal mengambil status milestone: %w", err)
+    }
+    byMilestone := make(map[uint]model.ChildMilestone, len(statuses))
+    for _, status := range statuses {
+        byMilestone[status.MilestoneID] = status
+    }
+
+    age := ChildAgeAt(child, time.Now())
+    views := make([]ChildMilestoneView, 0, len(milestones))
+    for _, milestone := range milestones {
+        view := ChildMilestoneView{
+            Milestone:   milestone,
+            DomainLabel: MilestoneDomainLabels[milestone.Domain],
+            Status:      MilestoneStatusNotYet,
+        }
+        if status, ok := byMilestone[milestone.ID]; ok {
+            view.Status = status.Status
+            view.EmergingOn = status.EmergingOn
+            view.AchievedOn = status.AchievedOn
+            view.Notes = status.Notes
+            view.UpdatedBy = status.UpdatedBy
+        }
+        view.Overdue = age != nil && view.Status != MilestoneStatusAchieved && milestone.ExpectedAgeMonths <= age.TotalMonths
+        views = append(views, view)
+    }
+    return views, nil
+}
+
+// GetMissedMilestones lists milestones expected by the child's current age that are not yet achieved
+func (s *MilestoneService) GetMissedMilestones(childID uint) (*MissedMilestones, error) {
+    var child model.Child
+    if err := s.db.First(&child, childID).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("data anak tidak ditemukan")
+        }
+        return nil, fmt.Errorf("gagal mengambil data anak: %w", err)
+    }
+    age := ChildAgeAt(child, time.Now())
+    if age == nil {
+        return nil, errors.New("tanggal lahir anak belum diisi, milestone sesuai usia tidak dapat ditentukan")
+    }
+
+    views, err := s.childMilestones(child, "")
+    if err != nil {
+        return nil, err
+    }
+    missed := &MissedMilestones{
+        ChildID:    childID,
+        Age:        *age,
+        Milestones: make([]ChildMilestoneView, 0),
+        ByDomain:   make(map[string]int),
+    }
+    for _, view := range views {
+        if view.Overdue {
+            missed.Milestones = append(missed.Milestones, view)
+            missed.ByDomain[view.Milestone.Domain]++
+        }
+    }
+    return missed, nil
+}
+
+// GetMilestoneSummary counts a child's achieved, emerging and overdue milestones
+func (s *MilestoneService) GetMilestoneSummary(childID uint) (*MilestoneSummary, error) {
+    views, err := s.GetChildMilestones(childID, "")
+    if err != nil {
+        return nil, err
+    }
+    summary := &MilestoneSummary{Total: len(views)}
+    for _, view := range views {
+        switch view.Status {
+        case MilestoneStatusAchieved:
+            summary.Achieved++
+        case MilestoneStatusEmerging:
+            summary.Emerging++
+        }
+        if view.Overdue {
+            summary.Overdue++
+        }
+    }
+    return summary, nil
+}
+
+// SetMilestoneStatus records that a child has not yet reached, is emerging in, or has
+// achieved a milestone. Setting "not_yet" clears the recorded dates.
+func (s *MilestoneService) SetMilestoneStatus(childID, milestoneID uint, input MilestoneStatusInput, updatedBy string) (*model.ChildMilestone, error) {
+    var child model.Child
+    if err := s.db.First(&child, childID).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("data anak tidak ditemukan")
+        }
+        return nil, fmt.Errorf("gagal mengambil data anak: %w", err)
+    }
+    var milestone model.Milestone
+    if err := s.db.First(&milestone, milestoneID).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("milestone tidak ditemukan")
+        }
+        return nil, fmt.Errorf("gagal mengambil milestone: %w", err)
+    }
+
+    status := strings.TrimSpace(input.Status)
+    switch status {
+    case MilestoneStatusNotYet, MilestoneStatusEmerging, MilestoneStatusAchieved:
+    default:
+        return nil, fmt.Errorf("status milestone tidak valid: %q", input.Status)
+    }
+
+    date := startOfDay(time.Now())
+    if strings.TrimSpace(input.Date) != "" {
+        parsed, err := ParseCalendarDate(input.Date)
+        if err != nil {
+            return nil, err
+        }
+        date = *parsed
+    }
+    if date.After(time.Now()) {
+        return nil, errors.New("tanggal milestone tidak boleh di masa depan")
+    }
+    if child.DateOfBirth != nil && date.Before(startOfDay(*child.DateOfBirth)) {
+        return nil, errors.New("tanggal milestone tidak boleh sebelum tanggal lahir anak")
+    }
+
+    var record model.ChildMilestone
+    err := s.db.Where("child_id = ? AND milestone_id = ?", childID, milestoneID).First(&record).Error
+    if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+        return nil, fmt.Errorf("gagal mengambil status milestone: %w", err)
+    }
+    isNew := record.ID == 0
+    before := record
+
+    record.ChildID = childID
+    record.MilestoneID = milestoneID
+    record.Status = status
+    record.Notes = strings.TrimSpace(input.Notes)
+    record.UpdatedBy = updatedBy
+    switch status {
+    case MilestoneStatusNotYet:
+        record.EmergingOn = nil
+        record.AchievedOn = nil
+    case MilestoneStatusEmerging:
+        record.EmergingOn = &date
+        record.AchievedOn = nil
+    case MilestoneStatusAchieved:
+        if record.EmergingOn != nil && record.EmergingOn.After(date) {
+            return nil, errors.New("tanggal tercapai tidak boleh sebelum tanggal mulai muncul")
+        }
+        record.AchievedOn = &date
+    }
+
+    err = s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if isNew {
+            if err := tx.Create(&record).Error; err != nil {
+                return err
+            }
+            trail.Add(AuditActionCreate, AuditEntityChildMilestone, record.ID, nil, record)
+            return nil
+        }
+        if err := tx.Save(&record).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionUpdate, AuditEntityChildMilestone, record.ID, before, record)
+        return nil
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal menyimpan status milestone: %w", err)
+    }
+
+    record.Milestone = milestone
+    return &record, nil
+}
@@ -10,27 +10,69 @@ import (
 )
 
 type NoteService struct {
-    db *gorm.DB
+    db    *gorm.DB
+    audit *AuditService
 }
 
-func NewNoteService(db *gorm.DB) *NoteService {
-    return &NoteService{db: db}
+func NewNoteService(db *gorm.DB, audit *AuditService) *NoteService {
+    return &NoteService{db: db, audit: audit}
 }
 
-// CreateNote creates a new note for a session
-func (s *NoteService) CreateNote(sessionID uint, noteText, category string) (*model.Note, error) {
+// CreateNote creates a new note for a session and records its first revision
+func (s *NoteService) CreateNote(sessionID uint, noteText, category, author string, therapistID *uint) (*model.Note, error) {
+    return s.createNote(sessionID, nil, noteText, category, author, therapistID, time.Now())
+}
+
+// CreateChildNote creates a note about one participant of a group session. It only shows
+// in that child's records.
+func (s *NoteService) CreateChildNote(sessionID, childID uint, noteText, category, author string, therapistID *uint) (*model.Note, error) {
+    if err := ensureChildInSession(s.db, sessionID, childID); err != nil {
+        return nil, err
+    }
+    return s.createNote(sessionID, &childID, noteText, category, author, therapistID, time.Now())
+}
+
+// CreatePastNote adds a note written down after the session, stamped with the time it
+// was made. The time must fall within the session. childID is optional, as in
+// CreateChildNote.
+func (s *NoteService) CreatePastNote(sessionID uint, childID *uint, noteText, category string, at time.Time, author string, therapistID *uint) (*model.Note, error) {
+    if childID != nil {
+        if err := ensureChildInSession(s.db, sessionID, *childID); err != nil {
+            return nil, err
+        }
+    }
+    if err := ensureWithinSession(s.db, sessionID, at); err != nil {
+        return nil, err
+    }
+    return s.createNote(sessionID, childID, noteText, category, author, therapistID, at)
+}
+
+func (s *NoteService) createNote(sessionID uint, childID *uint, noteText, category, author string, therapistID *uint, at time.Time) (*model.Note, error) {
     if noteText == "" {
         return nil, errors.New("teks catatan harus diisi")
     }
 
+    if err := ensureSessionWritable(s.db, sessionID); err != nil {
+        return nil, err
+    }
+
     note := &model.Note{
-        SessionID: sessionID,
-        NoteText:  noteText,
-        Category:  category,
-        Timestamp: time.Now(),
+        SessionID:   sessionID,
+        ChildID:     childID,
+        TherapistID: therapistID,
+        NoteText:    noteText,
+        Category:    category,
+        Timestamp:   at,
     }
 
-    if err := s.db.Create(note).Error; err != nil {
+    err := s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Create(note).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionCreate, AuditEntityNote, note.ID, nil, note)
+        return recordRevision(tx, RevisionEntityNote, note.ID, note.SessionID, RevisionActionCreated, note.NoteText, note.Category, author, "")
+    })
+    if err != nil {
         return nil, fmt.Errorf("gagal membuat catatan: %w", err)
     }
 
@@ -46,8 +88,12 @@ func (s *NoteService) GetNotesBySession(sessionID uint) ([]model.Note, error) {
     return notes, nil
 }
 
-// UpdateNote updates an existing note
-func (s *NoteService) UpdateNote(noteID uint, noteText, category string) (*model.Note, error) {
+// UpdateNote updates an existing note and records the amendment as a new revision
+func (s *NoteService) UpdateNote(noteID uint, noteText, category, author, reason string) (*model.Note, error) {
+    if noteText == "" {
+        return nil, errors.New("teks catatan harus diisi")
+    }
+
     var note model.Note
     if err := s.db.First(&note, noteID).Error; err != nil {
         if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -56,19 +102,50 @@ func (s *NoteService) UpdateNote(noteID uint, noteText, category string) (*model
         return nil, fmt.Errorf("gagal mengambil catatan: %w", err)
     }
 
+    if err := ensureSessionWritable(s.db, note.SessionID); err != nil {
+        return nil, err
+    }
+
+    before := note
     note.NoteText = noteText
     note.Category = category
 
-    if err := s.db.Save(&note).Error; err != nil {
+    err := s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Save(&note).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionUpdate, AuditEntityNote, note.ID, before, note)
+        return recordRevision(tx, RevisionEntityNote, note.ID, note.SessionID, RevisionActionUpdated, note.NoteText, note.Category, author, reason)
+    })
+    if err != nil {
         return nil, fmt.Errorf("gagal memperbarui catatan: %w", err)
     }
 
     return &note, nil
 }
 
-// DeleteNote soft deletes a note
-func (s *NoteService) DeleteNote(noteID uint) error {
-    if err := s.db.Delete(&model.Note{}, noteID).Error; err != nil {
+// DeleteNote soft deletes a note and records the deletion as a revision
+func (s *NoteService) DeleteNote(noteID uint, author, reason string) error {
+    var note model.Note
+    if err := s.db.First(&note, noteID).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return errors.New("catatan tidak ditemukan")
+        }
+        return fmt.Errorf("gagal mengambil catatan: %w", err)
+    }
+
+    if err := ensureSessionWritable(s.db, note.SessionID); err != nil {
+        return err
+    }
+
+    err := s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Delete(&note).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionDelete, AuditEntityNote, note.ID, note, nil)
+        return recordRevision(tx, RevisionEntityNote, note.ID, note.SessionID, RevisionActionDeleted, note.NoteText, note.Category, author, reason)
+    })
+    if err != nil {
         return fmt.Errorf("gagal menghapus catatan: %w", err)
     }
     return nil
@@ -95,7 +172,14 @@ func (s *NoteService) CreateNoteTemplate(templateText, categoryHint, keywords st
         Keywords:     keywords,
     }
 
-    if err := s.db.Create(template).Error; err != nil {
+    err := s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Create(template).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionCreate, AuditEntityNoteTemplate, template.ID, nil, template)
+        return nil
+    })
+    if err != nil {
         return nil, fmt.Errorf("gagal membuat template: %w", err)
     }
 
@@ -0,0 +1,370 @@
+package services
+
+import (
+	"childSessions/model"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Supported session note formats
+const (
+    NoteFormatFree = "free"
+    NoteFormatSOAP = "soap"
+    NoteFormatDAP  = "dap"
+)
+
+// NoteSectionDefinition describes one section of a structured note format
+// and which captured session data is used to prefill it
+type NoteSectionDefinition struct {
+    Key                string
+    Title              string
+    NoteCategories     []string // Note categories copied into this section
+    UncategorizedNotes bool     // Notes without a known category land here
+    IncludeActivities  bool
+    IncludeRewards     bool
+}
+
+// NoteFormatDefinition describes a selectable session note format
+type NoteFormatDefinition struct {
+    Key      string
+    Name     string
+    Sections []NoteSectionDefinition
+}
+
+var noteFormats = map[string]NoteFormatDefinition{
+    NoteFormatFree: {
+        Key:  NoteFormatFree,
+        Name: "Bebas",
+    },
+    NoteFormatSOAP: {
+        Key:  NoteFormatSOAP,
+        Name: "SOAP",
+        Sections: []NoteSectionDefinition{
+            {Key: "subjective", Title: "Subjektif", NoteCategories: []string{"Perilaku", "Emosi", "Komunikasi", "Sosial"}, UncategorizedNotes: true},
+            {Key: "objective", Title: "Objektif", NoteCategories: []string{"Motorik"}, IncludeActivities: true, IncludeRewards: true},
+            {Key: "assessment", Title: "Asesmen", NoteCategories: []string{"Kemajuan", "Tantangan"}},
+            {Key: "plan", Title: "Rencana"},
+        },
+    },
+    NoteFormatDAP: {
+        Key:  NoteFormatDAP,
+        Name: "DAP",
+        Sections: []NoteSectionDefinition{
+            {Key: "data", Title: "Data", NoteCategories: []string{"Perilaku", "Emosi", "Komunikasi", "Sosial", "Motorik"}, UncategorizedNotes: true, IncludeActivities: true, IncludeRewards: true},
+            {Key: "assessment", Title: "Asesmen", NoteCategories: []string{"Kemajuan", "Tantangan"}},
+            {Key: "plan", Title: "Rencana"},
+        },
+    },
+}
+
+// GetNoteFormats returns all selectable note formats
+func GetNoteFormats() []NoteFormatDefinition {
+    formats := []NoteFormatDefinition{
+        noteFormats[NoteFormatFree],
+        noteFormats[NoteFormatSOAP],
+        noteFormats[NoteFormatDAP],
+    }
+    return formats
+}
+
+// LookupNoteFormat returns the definition of a note format; an empty key means free text
+func LookupNoteFormat(key string) (NoteFormatDefinition, bool) {
+    if key == "" {
+        key = NoteFormatFree
+    }
+    def, ok := noteFormats[strings.ToLower(key)]
+    return def, ok
+}
+
+// IsStructured reports whether the format is made of sections
+func (d NoteFormatDefinition) IsStructured() bool {
+    return len(d.Sections) > 0
+}
+
+// SetSessionNoteFormat selects the note format of a session and creates its sections
+func (s *SessionService) SetSessionNoteFormat(sessionID uint, format, author string) (*model.Session, error) {
+    def, ok := LookupNoteFormat(format)
+    if !ok {
+        return nil, fmt.Errorf("format catatan tidak dikenal: %s", format)
+    }
+
+    var session model.Session
+    if err := s.db.First(&session, sessionID).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("sesi tidak ditemukan")
+        }
+        return nil, fmt.Errorf("gagal mengambil data sesi: %w", err)
+    }
+    if err := ensureSessionUnlocked(&session); err != nil {
+        return nil, err
+    }
+
+    before := session
+    err := s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Model(&session).Update("note_format", def.Key).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionUpdate, AuditEntitySession, session.ID, before, session)
+        for i, section := range def.Sections {
+            record := model.SessionNoteSection{
+                SessionID:  sessionID,
+                Format:     def.Key,
+                SectionKey: section.Key,
+            }
+            result := tx.Where(record).
+                Attrs(model.SessionNoteSection{Title: section.Title, SortOrder: i}).
+                FirstOrCreate(&record)
+            if result.Error != nil {
+                return result.Error
+            }
+            if result.RowsAffected > 0 {
+                trail.Add(AuditActionCreate, AuditEntityNoteSection, record.ID, nil, record)
+            }
+        }
+        if def.IsStructured() {
+            if _, err := prefillNoteSections(tx, trail, sessionID, false, author); err != nil {
+                return err
+            }
+        }
+        return nil
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal mengubah format catatan: %w", err)
+    }
+
+    return s.GetSessionByID(sessionID)
+}
+
+// GetSessionNoteSections retrieves the sections of the session's current note format
+func (s *SessionService) GetSessionNoteSections(sessionID uint) ([]model.SessionNoteSection, error) {
+    var session model.Session
+    if err := s.db.First(&session, sessionID).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("sesi tidak ditemukan")
+        }
+        return nil, fmt.Errorf("gagal mengambil data sesi: %w", err)
+    }
+
+    var sections []model.SessionNoteSection
+    if err := s.db.Where("session_id = ? AND format = ?", sessionID, session.NoteFormat).
+        Order("sort_order ASC").
+        Find(&sections).Error; err != nil {
+        return nil, fmt.Errorf("gagal mengambil bagian catatan: %w", err)
+    }
+    return sections, nil
+}
+
+// UpdateSessionNoteSection replaces the content of one section of the session's note
+// and records the amendment as a new revision
+func (s *SessionService) UpdateSessionNoteSection(sessionID uint, sectionKey, content, author, reason string) (*model.SessionNoteSection, error) {
+    var session model.Session
+    if err := s.db.First(&session, sessionID).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("sesi tidak ditemukan")
+        }
+        return nil, fmt.Errorf("gagal mengambil data sesi: %w", err)
+    }
+    if err := ensureSessionUnlocked(&session); err != nil {
+        return nil, err
+    }
+
+    var section model.SessionNoteSection
+    if err := s.db.Where("session_id = ? AND format = ? AND section_key = ?", sessionID, session.NoteFormat, sectionKey).
+        First(&section).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("bagian catatan tidak ditemukan")
+        }
+        return nil, fmt.Errorf("gagal mengambil bagian catatan: %w", err)
+    }
+
+    before := section
+    section.Content = content
+    err := s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Save(&section).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionUpdate, AuditEntityNoteSection, section.ID, before, section)
+        return recordRevision(tx, RevisionEntityNoteSection, section.ID, sessionID, RevisionActionUpdated, section.Content, section.SectionKey, author, reason)
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal memperbarui bagian catatan: %w", err)
+    }
+    return &section, nil
+}
+
+// PrefillSessionNoteSections fills the session's note sections from the notes, activities
+// and rewards captured during the session. Sections that already have content are only
+// replaced when overwrite is true. Every changed section gets a new revision.
+func (s *SessionService) PrefillSessionNoteSections(sessionID uint, overwrite bool, author string) ([]model.SessionNoteSection, error) {
+    if err := ensureSessionWritable(s.db, sessionID); err != nil {
+        return nil, err
+    }
+    var sections []model.SessionNoteSection
+    err := s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        var err error
+        sections, err = prefillNoteSections(tx, trail, sessionID, overwrite, author)
+        return err
+    })
+    if err != nil {
+        return nil, err
+    }
+    return sections, nil
+}
+
+// prefillNoteSections does the work of PrefillSessionNoteSections inside the caller's
+// transaction, so ending a session and prefilling its notes commit together
+func prefillNoteSections(tx *gorm.DB, trail *AuditTrail, sessionID uint, overwrite bool, author string) ([]model.SessionNoteSection, error) {
+    var session model.Session
+    if err := tx.Preload("Notes").Preload("SessionActivities.Activity").Preload("Rewards").
+        First(&session, sessionID).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("sesi tidak ditemukan")
+        }
+        return nil, fmt.Errorf("gagal mengambil data sesi: %w", err)
+    }
+    if session.FinalizedAt != nil {
+        return nil, ErrSessionFinalized
+    }
+
+    def, ok := LookupNoteFormat(session.NoteFormat)
+    if !ok || !def.IsStructured() {
+        return nil, errors.New("sesi tidak menggunakan format catatan terstruktur")
+    }
+
+    contents := BuildNoteSectionContents(def, session)
+
+    var sections []model.SessionNoteSection
+    if err := tx.Where("session_id = ? AND format = ?", sessionID, def.Key).
+        Order("sort_order ASC").
+        Find(&sections).Error; err != nil {
+        return nil, fmt.Errorf("gagal mengambil bagian catatan: %w", err)
+    }
+
+    for i := range sections {
+        if sections[i].Content != "" && !overwrite {
+            continue
+        }
+        content := contents[sections[i].SectionKey]
+        if content == sections[i].Content {
+            continue
+        }
+        before := sections[i]
+        sections[i].Content = content
+        if err := tx.Save(&sections[i]).Error; err != nil {
+            return nil, fmt.Errorf("gagal mengisi bagian catatan: %w", err)
+        }
+        trail.Add(AuditActionUpdate, AuditEntityNoteSection, sections[i].ID, before, sections[i])
+        if err := recordRevision(tx, RevisionEntityNoteSection, sections[i].ID, sessionID, RevisionActionUpdated, content, sections[i].SectionKey, author, "Diisi otomatis dari data sesi"); err != nil {
+            return nil, fmt.Errorf("gagal mengisi bagian catatan: %w", err)
+        }
+    }
+
+    return sections, nil
+}
+
+// BuildNoteSectionContents builds the prefilled text of every section of a structured
+// format. The session must have Notes, SessionActivities.Activity and Rewards loaded.
+// Notes written about a single participant of a group session are left out.
+func BuildNoteSectionContents(def NoteFormatDefinition, session model.Session) map[string]string {
+    known := make(map[string]bool)
+    for _, section := range def.Sections {
+        for _, category := range section.NoteCategories {
+            known[strings.ToLower(category)] = true
+        }
+    }
+
+    notes := make([]model.Note, len(session.Notes))
+    copy(notes, session.Notes)
+    sort.Slice(notes, func(i, j int) bool {
+        return notes[i].Timestamp.Before(notes[j].Timestamp)
+    })
+
+    contents := make(map[string]string)
+    for _, section := range def.Sections {
+        var b strings.Builder
+
+        categories := make(map[string]bool)
+        for _, category := range section.NoteCategories {
+            categories[strings.ToLower(category)] = true
+        }
+        for _, note := range notes {
+            // Notes about one child of a group session stay with that child; the sections
+            // are shared by everyone who can open the session, whatever their caseload
+            if note.ChildID != nil {
+                continue
+            }
+            category := strings.ToLower(note.Category)
+            if categories[category] || (section.UncategorizedNotes && !known[category]) {
+                if note.Category != "" {
+                    b.WriteString(fmt.Sprintf("• [%s] %s\n", note.Category, note.NoteText))
+                } else {
+                    b.WriteString(fmt.Sprintf("• %s\n", note.NoteText))
+                }
+            }
+        }
+
+        if section.IncludeActivities {
+            for _, activity := range session.SessionActivities {
+                b.WriteString(fmt.Sprintf("• Aktivitas: %s", activity.Activity.Name))
+                if activity.StartTime != nil && activity.EndTime != nil {
+                    b.WriteString(fmt.Sprintf(" (%d menit)", int(activity.EndTime.Sub(*activity.StartTime).Minutes())))
+                }
+                if activity.Notes != "" {
+                    b.WriteString(fmt.Sprintf(" - %s", activity.Notes))
+                }
+                b.WriteString("\n")
+            }
+        }
+
+        if section.IncludeRewards {
+            rewardsByType := make(map[string]int)
+            var types []string
+            for _, reward := range session.Rewards {
+                if _, ok := rewardsByType[reward.Type]; !ok {
+                    types = append(types, reward.Type)
+                }
+                rewardsByType[reward.Type] += reward.Value
+            }
+            for _, rewardType := range types {
+                b.WriteString(fmt.Sprintf("• Reward %s: %d\n", rewardType, rewardsByType[rewardType]))
+            }
+        }
+
+        contents[section.Key] = strings.TrimRight(b.String(), "\n")
+    }
+
+    return contents
+}
+
+// RenderNoteSections renders structured note sections as plain text
+func RenderNoteSections(def NoteFormatDefinition, sections []model.SessionNoteSection) string {
+    var b strings.Builder
+
+    ordered := make([]model.SessionNoteSection, 0, len(sections))
+    for _, section := range sections {
+        if section.Format == def.Key {
+            ordered = append(ordered, section)
+        }
+    }
+    sort.Slice(ordered, func(i, j int) bool {
+        return ordered[i].SortOrder < ordered[j].SortOrder
+    })
+
+    for _, section := range ordered {
+        heading := fmt.Sprintf("%s (%s)", strings.ToUpper(section.Title), strings.ToUpper(section.SectionKey[:1]))
+        b.WriteString(heading + ":\n")
+        b.WriteString(strings.Repeat("-", len([]rune(heading))+1) + "\n")
+        if strings.TrimSpace(section.Content) == "" {
+            b.WriteString("-\n\n")
+            continue
+        }
+        b.WriteString(section.Content)
+        b.WriteString("\n\n")
+    }
+
+    return b.String()
+}
@@ -0,0 +1,275 @@
+package services
+
+import (
+	"childSessions/model"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Permissions checked before an action is carried out
+const (
+    PermChildView         = "child.view"          // See a child's demographic and contact details
+    PermChildCreate       = "child.create"
+    PermChildEditContact  = "child.edit_contact"  // Edit name, gender, guardian and contact details
+    PermChildDelete       = "child.delete"
+    PermChildErase        = "child.erase"
+    PermCaseloadAll       = "caseload.all"        // Act on every child, not only assigned ones
+    PermSessionView       = "session.view"        // See when sessions happened, without clinical content
+    PermSessionRun        = "session.run"         // Start and end sessions, log activities, rewards and flashcards
+    PermSessionSchedule   = "session.schedule"    // Book appointments and record cancellations and no-shows
+    PermSessionFinalize   = "session.finalize"
+    PermSessionCosign     = "session.cosign"
+    PermNoteRead          = "note.read"           // Read notes and other clinical content
+    PermNoteWrite         = "note.write"          // Write notes and other clinical content
+    PermReportView        = "report.view"         // Progress summaries, trends and exports
+    PermCatalogView       = "catalog.view"        // Activities, note templates and flashcards
+    PermCatalogManage     = "catalog.manage"
+    PermStaffView         = "staff.view"
+    PermWaitlistManage    = "waitlist.manage"     // Referrals waiting for a slot, including families not yet enrolled
+    PermBillingManage     = "billing.manage"      // Rates, invoices, payments and outstanding balances
+    PermAccountManage     = "account.manage"
+    PermAuditView         = "audit.view"
+    PermTrashManage       = "trash.manage"
+    PermMigrationRun      = "migration.run"
+)
+
+var rolePermissions = map[string][]string{
+    RoleTherapist: {
+        PermChildView, PermChildCreate, PermChildEditContact,
+        PermSessionView, PermSessionRun, PermSessionSchedule, PermSessionFinalize,
+        PermNoteRead, PermNoteWrite, PermReportView,
+        PermCatalogView, PermCatalogManage, PermStaffView,
+    },
+    RoleSupervisor: {
+        PermChildView, PermChildCreate, PermChildEditContact, PermCaseloadAll,
+        PermSessionView, PermSessionRun, PermSessionSchedule, PermSessionFinalize, PermSessionCosign,
+        PermNoteRead, PermNoteWrite, PermReportView,
+        PermCatalogView, PermCatalogManage, PermStaffView, PermWaitlistManage, PermBillingManage,
+    },
+    RoleFrontDesk: {
+        PermChildView, PermChildCreate, PermChildEditContact, PermCaseloadAll,
+        PermSessionView, PermSessionSchedule, PermCatalogView, PermStaffView, PermWaitlistManage, PermBillingManage,
+    },
+}
+
+// ErrLoginRequired is returned when accounts exist but nobody is logged in
+var ErrLoginRequired = errors.New("silakan masuk terlebih dahulu")
+
+// ErrPermissionDenied is returned when the logged-in therapist's role does not allow an action
+var ErrPermissionDenied = errors.New("anda tidak memiliki izin untuk tindakan ini")
+
+// HasPermission reports whether a role grants a permission; admins hold every permission
+func HasPermission(role, permission string) bool {
+    if role == RoleAdmin {
+        return true
+    }
+    for _, p := range rolePermissions[role] {
+        if p == permission {
+            return true
+        }
+    }
+    return false
+}
+
+// GetRolePermissions returns the permissions granted to a role
+func GetRolePermissions(role string) []string {
+    if role == RoleAdmin {
+        var all []string
+        seen := make(map[string]bool)
+        for _, permissions := range rolePermissions {
+            for _, p := range permissions {
+                if !seen[p] {
+                    seen[p] = true
+                    all = append(all, p)
+                }
+            }
+        }
+        return append(all, PermChildDelete, PermChildErase, PermAccountManage, PermAuditView, PermTrashManage, PermMigrationRun)
+    }
+    return append([]string(nil), rolePermissions[role]...)
+}
+
+// AccessService decides whether the logged-in therapist may perform an action. A nil
+// therapist means nobody is logged in: everything is allowed while no accounts exist
+// (single-user mode), nothing once they do.
+type AccessService struct {
+    db *gorm.DB
+}
+
+func NewAccessService(db *gorm.DB) *AccessService {
+    return &AccessService{db: db}
+}
+
+// Can reports whether therapist holds a permission
+func (s *AccessService) Can(therapist *model.Therapist, permission string) bool {
+    return s.Authorize(therapist, permission) == nil
+}
+
+// Authorize checks that therapist holds a permission
+func (s *AccessService) Authorize(therapist *model.Therapist, permission string) error {
+    if therapist == nil {
+        var count int64
+        if err := s.db.Model(&model.Therapist{}).Count(&count).Error; err != nil {
+            return fmt.Errorf("gagal memeriksa akun terapis: %w", err)
+        }
+        if count > 0 {
+            return ErrLoginRequired
+        }
+        return nil
+    }
+    if !therapist.IsActive || !HasPermission(therapist.Role, permission) {
+        return ErrPermissionDenied
+    }
+    return nil
+}
+
+// AuthorizeChild checks a permission and, for roles limited to their caseload, that the child is assigned to therapist
+func (s *AccessService) AuthorizeChild(therapist *model.Therapist, permission string, childID uint) error {
+    if err := s.Authorize(therapist, permission); err != nil {
+        return err
+    }
+    if therapist == nil || HasPermission(therapist.Role, PermCaseloadAll) {
+        return nil
+    }
+
+    var count int64
+    if err := s.db.Table("child_therapists").
+        Where("child_id = ? AND therapist_id = ?", childID, therapist.ID).
+        Count(&count).Error; err != nil {
+        return fmt.Errorf("gagal memeriksa penugasan terapis: %w", err)
+    }
+    if count == 0 {
+        return ErrPermissionDenied
+    }
+    return nil
+}
+
+// AuthorizeRecord checks a permission against the child a record belongs to. table is
+// one of sessions, notes, session_activities, session_flashcards, note_revisions,
+// session_addendums, session_review_comments, session_participants, rewards, goals,
+// guardians, child_diagnoses, child_medications, child_sensitivities, attachments,
+// consents, assessment_administrations, child_milestones, session_plans or
+// session_plan_items. Records of a group session are allowed when any of its children
+// is; a note written about one participant belongs to that child alone.
+func (s *AccessService) AuthorizeRecord(therapist *model.Therapist, permission, table string, id uint) error {
+    if err := s.Authorize(therapist, permission); err != nil {
+        return err
+    }
+    if therapist == nil || HasPermission(therapist.Role, PermCaseloadAll) {
+        return nil
+    }
+
+    childIDs, err := s.recordChildIDs(table, id)
+    if err != nil {
+        return err
+    }
+    err = ErrPermissionDenied
+    for _, childID := range childIDs {
+        if err = s.AuthorizeChild(therapist, permission, childID); err == nil {
+            return nil
+        }
+    }
+    return err
+}
+
+// recordChildIDs finds the children a record belongs to, including soft-deleted records
+func (s *AccessService) recordChildIDs(table string, id uint) ([]uint, error) {
+    var query string
+    switch table {
+    case "sessions":
+        return s.sessionChildIDs(id)
+    case "notes":
+        var note struct {
+            SessionID uint
+            ChildID   *uint
+        }
+        if err := s.db.Raw("SELECT session_id, child_id FROM notes WHERE id = ?", id).Scan(&note).Error; err != nil {
+            return nil, fmt.Errorf("gagal memeriksa pemilik data: %w", err)
+        }
+        if note.SessionID == 0 {
+            return nil, errors.New("data tidak ditemukan")
+        }
+        if note.ChildID != nil {
+            return []uint{*note.ChildID}, nil
+        }
+        return s.sessionChildIDs(note.SessionID)
+    case "session_activities", "session_flashcards", "note_revisions", "session_addendums", "session_review_comments":
+        var sessionIDs []uint
+        if err := s.db.Raw(fmt.Sprintf("SELECT session_id FROM %s WHERE id = ?", table), id).Scan(&sessionIDs).Error; err != nil {
+            return nil, fmt.Errorf("gagal memeriksa pemilik data: %w", err)
+        }
+        if len(sessionIDs) == 0 {
+            return nil, errors.New("data tidak ditemukan")
+        }
+        return s.sessionChildIDs(sessionIDs[0])
+    case "rewards", "goals", "guardians", "child_diagnoses", "child_medications", "child_sensitivities", "attachments", "consents", "assessment_administrations", "child_milestones", "session_plans", "session_participants":
+        query = fmt.Sprintf("SELECT child_id FROM %s WHERE id = ?", table)
+    case "session_plan_items":
+        query = "SELECT session_plans.child_id FROM session_plan_items JOIN session_plans ON session_plans.id = session_plan_items.plan_id WHERE session_plan_items.id = ?"
+    default:
+        return nil, fmt.Errorf("jenis data tidak dikenal: %s", table)
+    }
+
+    var childIDs []uint
+    if err := s.db.Raw(query, id).Scan(&childIDs).Error; err != nil {
+        return nil, fmt.Errorf("gagal memeriksa pemilik data: %w", err)
+    }
+    if len(childIDs) == 0 {
+        return nil, errors.New("data tidak ditemukan")
+    }
+    return childIDs[:1], nil
+}
+
+// sessionChildIDs returns a session's child followed by the participants of a group session
+func (s *AccessService) sessionChildIDs(sessionID uint) ([]uint, error) {
+    var childIDs []uint
+    if err := s.db.Raw("SELECT child_id FROM sessions WHERE id = ?", sessionID).Scan(&childIDs).Error; err != nil {
+        return nil, fmt.Errorf("gagal memeriksa pemilik data: %w", err)
+    }
+    if len(childIDs) == 0 {
+        return nil, errors.New("data tidak ditemukan")
+    }
+    var participantIDs []uint
+    if err := s.db.Raw("SELECT child_id FROM session_participants WHERE session_id = ? AND child_id <> ?", sessionID, childIDs[0]).Scan(&participantIDs).Error; err != nil {
+        return nil, fmt.Errorf("gagal memeriksa pemilik data: %w", err)
+    }
+    return append(childIDs, participantIDs...), nil
+}
+
+// CaseloadChildIDs returns the children therapist may see. scoped is false when every
+// child is visible: in single-user mode and for roles not limited to a caseload.
+func (s *AccessService) CaseloadChildIDs(therapist *model.Therapist) (childIDs []uint, scoped bool, err error) {
+    if err := s.Authorize(therapist, PermChildView); err != nil {
+        return nil, false, err
+    }
+    if therapist == nil || HasPermission(therapist.Role, PermCaseloadAll) {
+        return nil, false, nil
+    }
+    if err := s.db.Table("child_therapists").
+        Where("therapist_id = ?", therapist.ID).
+        Pluck("child_id", &childIDs).Error; err != nil {
+        return nil, false, fmt.Errorf("gagal mengambil daftar anak terapis: %w", err)
+    }
+    return childIDs, true, nil
+}
+
+// RedactChild removes clinical content from a child for roles without PermNoteRead
+func RedactChild(child *model.Child) {
+    child.InitialAssessment = ""
+}
+
+// RedactSession removes clinical content from a session for roles without PermNoteRead,
+// leaving only when it took place and with whom
+func RedactSession(session *model.Session) {
+    session.SummaryNotes = ""
+    session.Notes = nil
+    session.NoteSections = nil
+    session.Addenda = nil
+    session.ReviewComments = nil
+    session.SessionActivities = nil
+    session.SessionFlashcards = nil
+    session.Rewards = nil
+    RedactChild(&session.Child)
+}
@@ -0,0 +1,197 @@
+package services
+
+import (
+	"childSessions/model"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Revision entity types
+const (
+    RevisionEntityNote           = "note"
+    RevisionEntitySessionSummary = "session_summary"
+    RevisionEntityNoteSection    = "note_section"
+    RevisionEntitySessionTimes   = "session_times" // Start and end of a session, with the reason for a correction
+)
+
+// Revision actions
+const (
+    RevisionActionCreated  = "created"
+    RevisionActionUpdated  = "updated"
+    RevisionActionDeleted  = "deleted"
+    RevisionActionRestored = "restored"
+)
+
+// DiffSegment is one run of text in a revision diff
+type DiffSegment struct {
+    Op   string `json:"op"` // "equal", "insert" or "delete"
+    Text string `json:"text"`
+}
+
+// RevisionDiff describes the changes between two revisions of the same entity
+type RevisionDiff struct {
+    From            model.NoteRevision `json:"from"`
+    To              model.NoteRevision `json:"to"`
+    CategoryChanged bool               `json:"category_changed"`
+    Segments        []DiffSegment      `json:"segments"`
+    Insertions      int                `json:"insertions"`
+    Deletions       int                `json:"deletions"`
+}
+
+type RevisionService struct {
+    db *gorm.DB
+}
+
+func NewRevisionService(db *gorm.DB) *RevisionService {
+    return &RevisionService{db: db}
+}
+
+// recordRevision appends a new revision for an entity. It must be called with the
+// same transaction that applies the change so the history cannot drift from the data.
+func recordRevision(tx *gorm.DB, entityType string, entityID, sessionID uint, action, text, category, author, reason string) error {
+    var last int
+    if err := tx.Model(&model.NoteRevision{}).
+        Where("entity_type = ? AND entity_id = ?", entityType, entityID).
+        Select("COALESCE(MAX(revision), 0)").
+        Scan(&last).Error; err != nil {
+        return fmt.Errorf("gagal membaca revisi terakhir: %w", err)
+    }
+
+    revision := &model.NoteRevision{
+        CreatedAt:  time.Now(),
+        EntityType: entityType,
+        EntityID:   entityID,
+        SessionID:  sessionID,
+        Revision:   last + 1,
+        Action:     action,
+        Text:       text,
+        Category:   category,
+        Author:     author,
+        Reason:     reason,
+    }
+    if err := tx.Create(revision).Error; err != nil {
+        return fmt.Errorf("gagal menyimpan revisi: %w", err)
+    }
+    return nil
+}
+
+// GetNoteRevisions lists all revisions of a note, oldest first
+func (s *RevisionService) GetNoteRevisions(noteID uint) ([]model.NoteRevision, error) {
+    var revisions []model.NoteRevision
+    if err := s.db.Where("entity_type = ? AND entity_id = ?", RevisionEntityNote, noteID).
+        Order("revision ASC").
+        Find(&revisions).Error; err != nil {
+        return nil, fmt.Errorf("gagal mengambil revisi catatan: %w", err)
+    }
+    return revisions, nil
+}
+
+// GetSessionRevisions lists all revisions of a session's notes, summary and note sections
+func (s *RevisionService) GetSessionRevisions(sessionID uint) ([]model.NoteRevision, error) {
+    var revisions []model.NoteRevision
+    if err := s.db.Where("session_id = ?", sessionID).
+        Order("created_at ASC, id ASC").
+        Find(&revisions).Error; err != nil {
+        return nil, fmt.Errorf("gagal mengambil revisi sesi: %w", err)
+    }
+    return revisions, nil
+}
+
+// DiffRevisions compares two revisions of the same entity word by word
+func (s *RevisionService) DiffRevisions(fromRevisionID, toRevisionID uint) (*RevisionDiff, error) {
+    var from, to model.NoteRevision
+    if err := s.db.First(&from, fromRevisionID).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("revisi tidak ditemukan")
+        }
+        return nil, fmt.Errorf("gagal mengambil revisi: %w", err)
+    }
+    if err := s.db.First(&to, toRevisionID).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("revisi tidak ditemukan")
+        }
+        return nil, fmt.Errorf("gagal mengambil revisi: %w", err)
+    }
+
+    if from.EntityType != to.EntityType || from.EntityID != to.EntityID {
+        return nil, errors.New("revisi yang dibandingkan harus berasal dari data yang sama")
+    }
+
+    diff := &RevisionDiff{
+        From:            from,
+        To:              to,
+        CategoryChanged: from.Category != to.Category,
+        Segments:        DiffText(from.Text, to.Text),
+    }
+    for _, segment := range diff.Segments {
+        switch segment.Op {
+        case "insert":
+            diff.Insertions++
+        case "delete":
+            diff.Deletions++
+        }
+    }
+    return diff, nil
+}
+
+var diffTokenPattern = regexp.MustCompile(`\s+|[^\s]+`)
+
+// DiffText computes a word-level diff between two texts using the longest common subsequence
+func DiffText(oldText, newText string) []DiffSegment {
+    a := diffTokenPattern.FindAllString(oldText, -1)
+    b := diffTokenPattern.FindAllString(newText, -1)
+
+    // lcs[i][j] holds the LCS length of a[i:] and b[j:]
+    lcs := make([][]int, len(a)+1)
+    for i := range lcs {
+        lcs[i] = make([]int, len(b)+1)
+    }
+    for i := len(a) - 1; i >= 0; i-- {
+        for j := len(b) - 1; j >= 0; j-- {
+            if a[i] == b[j] {
+                lcs[i][j] = lcs[i+1][j+1] + 1
+            } else if lcs[i+1][j] >= lcs[i][j+1] {
+                lcs[i][j] = lcs[i+1][j]
+            } else {
+                lcs[i][j] = lcs[i][j+1]
+            }
+        }
+    }
+
+    var segments []DiffSegment
+    appendSegment := func(op, text string) {
+        if n := len(segments); n > 0 && segments[n-1].Op == op {
+            segments[n-1].Text += text
+            return
+        }
+        segments = append(segments, DiffSegment{Op: op, Text: text})
+    }
+
+    i, j := 0, 0
+    for i < len(a) && j < len(b) {
+        switch {
+        case a[i] == b[j]:
+            appendSegment("equal", a[i])
+            i++
+            j++
+        case lcs[i+1][j] >= lcs[i][j+1]:
+            appendSegment("delete", a[i])
+            i++
+        default:
+            appendSegment("insert", b[j])
+            j++
+        }
+    }
+    for ; i < len(a); i++ {
+        appendSegment("delete", a[i])
+    }
+    for ; j < len(b); j++ {
+        appendSegment("insert", b[j])
+    }
+
+    return segments
+}
@@ -10,29 +10,61 @@ import (
 )
 
 type RewardService struct {
-    db *gorm.DB
+    db    *gorm.DB
+    audit *AuditService
 }
 
-func NewRewardService(db *gorm.DB) *RewardService {
-    return &RewardService{db: db}
+func NewRewardService(db *gorm.DB, audit *AuditService) *RewardService {
+    return &RewardService{db: db, audit: audit}
 }
 
 // GiveReward gives a reward to a child
-func (s *RewardService) GiveReward(childID uint, sessionID *uint, rewardType string, value int, notes string) (*model.Reward, error) {
+func (s *RewardService) GiveReward(childID uint, sessionID *uint, rewardType string, value int, notes string, therapistID *uint) (*model.Reward, error) {
+    return s.giveReward(childID, sessionID, rewardType, value, notes, therapistID, time.Now())
+}
+
+// GivePastReward records a reward given during a session that is being entered
+// afterwards, stamped with the time it was given. The time must fall within the session.
+func (s *RewardService) GivePastReward(childID, sessionID uint, rewardType string, value int, notes string, at time.Time, therapistID *uint) (*model.Reward, error) {
+    if err := ensureWithinSession(s.db, sessionID, at); err != nil {
+        return nil, err
+    }
+    return s.giveReward(childID, &sessionID, rewardType, value, notes, therapistID, at)
+}
+
+func (s *RewardService) giveReward(childID uint, sessionID *uint, rewardType string, value int, notes string, therapistID *uint, at time.Time) (*model.Reward, error) {
     if rewardType == "" {
         return nil, errors.New("tipe reward harus diisi")
     }
 
+    if sessionID != nil {
+        if err := ensureSessionWritable(s.db, *sessionID); err != nil {
+            return nil, err
+        }
+        // In a group session each participant earns their own rewards
+        if err := ensureChildInSession(s.db, *sessionID, childID); err != nil {
+            return nil, err
+        }
+    }
+
     reward := &model.Reward{
-        ChildID:   childID,
-        SessionID: sessionID,
-        Type:      rewardType,
-        Value:     value,
-        Timestamp: time.Now(),
-        Notes:     notes,
+        ChildID:     childID,
+        SessionID:   sessionID,
+        TherapistID: therapistID,
+        Type:        rewardType,
+        Value:       value,
+        Timestamp:   at,
+        Notes:       notes,
     }
 
-    if err := s.db.Create(reward).Error; err != nil {
+    err := s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Create(reward).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionCreate, AuditEntityReward, reward.ID, nil, reward)
+        return nil
+    })
+    if err != nil {
         return nil, fmt.Errorf("gagal memberikan reward: %w", err)
     }
 
@@ -120,7 +152,28 @@ func (s *RewardService) GetRewardStatistics(childID uint) (map[string]interface{
 
 // DeleteReward removes a reward record
 func (s *RewardService) DeleteReward(rewardID uint) error {
-    if err := s.db.Delete(&model.Reward{}, rewardID).Error; err != nil {
+    var reward model.Reward
+    if err := s.db.First(&reward, rewardID).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return errors.New("reward tidak ditemukan")
+        }
+        return fmt.Errorf("gagal mengambil reward: %w", err)
+    }
+
+    if reward.SessionID != nil {
+        if err := ensureSessionWritable(s.db, *reward.SessionID); err != nil {
+            return err
+        }
+    }
+
+    err := s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Delete(&reward).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionDelete, AuditEntityReward, reward.ID, reward, nil)
+        return nil
+    })
+    if err != nil {
         return fmt.Errorf("gagal menghapus reward: %w", err)
     }
     return nil
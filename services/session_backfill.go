@@ -0,0 +1,307 @@
+package services
+
+import (
+	"childSessions/model"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PastSessionInput describes a session that took place without the app, e.g. at a school visit
+type PastSessionInput struct {
+    ChildID      uint      `json:"child_id"`
+    StartTime    time.Time `json:"start_time"`
+    EndTime      time.Time `json:"end_time"`
+    SummaryNotes string    `json:"summary_notes"`
+}
+
+// RecordPastSession records a completed session after the fact, with the times it
+// actually started and ended. Activities, notes and rewards can then be added with
+// their own times. Sessions of therapists under supervision are sent for review with
+// SubmitPastSessionForReview once they are complete.
+func (s *SessionService) RecordPastSession(input PastSessionInput, therapistID *uint, author string) (*model.Session, error) {
+    if err := ensureChildExists(s.db, input.ChildID); err != nil {
+        return nil, err
+    }
+    if err := validateSessionTimes(input.StartTime, &input.EndTime); err != nil {
+        return nil, err
+    }
+    if err := s.ensureNoOverlap(input.ChildID, 0, input.StartTime, input.EndTime); err != nil {
+        return nil, err
+    }
+
+    session := &model.Session{
+        ChildID:           input.ChildID,
+        TherapistID:       therapistID,
+        StartTime:         input.StartTime,
+        EndTime:           &input.EndTime,
+        DurationMinutes:   int(input.EndTime.Sub(input.StartTime).Minutes()),
+        SummaryNotes:      input.SummaryNotes,
+        Status:            SessionStatusAttended,
+        EnteredAfterwards: true,
+    }
+    err := s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Create(session).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionCreate, AuditEntitySession, session.ID, nil, session)
+        if err := consumeSessionPackages(tx, trail, session); err != nil {
+            return err
+        }
+        if err := recordRevision(tx, RevisionEntitySessionTimes, session.ID, session.ID, RevisionActionCreated, sessionTimesText(session), "", author, "Dicatat setelah sesi berlangsung"); err != nil {
+            return err
+        }
+        if input.SummaryNotes == "" {
+            return nil
+        }
+        return recordRevision(tx, RevisionEntitySessionSummary, session.ID, session.ID, RevisionActionCreated, input.SummaryNotes, "", author, "")
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal mencatat sesi: %w", err)
+    }
+
+    if err := s.db.Preload("Child").Preload("Therapist").First(session, session.ID).Error; err != nil {
+        return nil, fmt.Errorf("gagal memuat data sesi: %w", err)
+    }
+    setSessionAges(session, session.StartTime)
+
+    // Consent is checked as it stood on the day of the session
+    consented, err := hasValidConsent(s.db, input.ChildID, ConsentTypeTreatment, input.StartTime)
+    if err != nil {
+        return nil, err
+    }
+    if !consented {
+        session.Warnings = append(session.Warnings, "Perhatian: persetujuan terapi belum tercatat, sudah ditarik, atau sudah kedaluwarsa pada tanggal sesi")
+    }
+    return session, nil
+}
+
+// AddPastActivity records an activity that took place in a session with the times it
+// started and ended. Both must fall within the session.
+func (s *SessionService) AddPastActivity(sessionID, activityID uint, startTime, endTime time.Time, notes string) (*model.SessionActivity, error) {
+    if err := ensureSessionWritable(s.db, sessionID); err != nil {
+        return nil, err
+    }
+    if !endTime.After(startTime) {
+        return nil, errors.New("waktu selesai aktivitas harus setelah waktu mulai")
+    }
+    if err := ensureWithinSession(s.db, sessionID, startTime); err != nil {
+        return nil, err
+    }
+    if err := ensureWithinSession(s.db, sessionID, endTime); err != nil {
+        return nil, err
+    }
+    var activity model.Activity
+    if err := s.db.First(&activity, activityID).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("aktivitas tidak ditemukan")
+        }
+        return nil, fmt.Errorf("gagal mengambil aktivitas: %w", err)
+    }
+
+    sessionActivity := &model.SessionActivity{
+        SessionID:  sessionID,
+        ActivityID: activityID,
+        StartTime:  &startTime,
+        EndTime:    &endTime,
+        Notes:      notes,
+    }
+    err := s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Create(sessionActivity).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionCreate, AuditEntitySessionActivity, sessionActivity.ID, nil, sessionActivity)
+        return LinkPlannedActivity(tx, trail, sessionActivity)
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal mencatat aktivitas: %w", err)
+    }
+    sessionActivity.Activity = activity
+    return sessionActivity, nil
+}
+
+// CorrectSessionTimes changes when a session started and ended, recomputing its
+// duration. A nil time is left unchanged; the end of a running session cannot be set.
+// Activities, notes and rewards already recorded must still fall within the new times.
+// The reason is kept with the session's amendment history. Sessions already on an
+// invoice must have the invoice voided first.
+func (s *SessionService) CorrectSessionTimes(sessionID uint, startTime, endTime *time.Time, reason, author string) (*model.Session, error) {
+    reason = strings.TrimSpace(reason)
+    if reason == "" {
+        return nil, errors.New("alasan koreksi waktu harus diisi")
+    }
+    if startTime == nil && endTime == nil {
+        return nil, errors.New("waktu mulai atau waktu selesai harus diisi")
+    }
+    if err := ensureSessionWritable(s.db, sessionID); err != nil {
+        return nil, err
+    }
+    if err := ensureSessionNotInvoiced(s.db, sessionID); err != nil {
+        return nil, err
+    }
+
+    var session model.Session
+    if err := s.db.First(&session, sessionID).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("sesi tidak ditemukan")
+        }
+        return nil, fmt.Errorf("gagal mengambil data sesi: %w", err)
+    }
+    if endTime != nil && session.EndTime == nil {
+        return nil, errors.New("sesi masih berlangsung; akhiri sesi terlebih dahulu")
+    }
+
+    newStart := session.StartTime
+    if startTime != nil {
+        newStart = *startTime
+    }
+    newEnd := session.EndTime
+    if endTime != nil {
+        newEnd = endTime
+    }
+    if err := validateSessionTimes(newStart, newEnd); err != nil {
+        return nil, err
+    }
+    overlapEnd := time.Now()
+    if newEnd != nil {
+        overlapEnd = *newEnd
+    }
+    if err := s.ensureNoOverlap(session.ChildID, session.ID, newStart, overlapEnd); err != nil {
+        return nil, err
+    }
+
+    first, last, err := recordedSpan(s.db, session.ID)
+    if err != nil {
+        return nil, err
+    }
+    if first != nil && first.Before(newStart) {
+        return nil, fmt.Errorf("waktu mulai tidak boleh setelah catatan pertama dalam sesi (%s)", first.Format("02/01/2006 15:04"))
+    }
+    if last != nil && newEnd != nil && last.After(*newEnd) {
+        return nil, fmt.Errorf("waktu selesai tidak boleh sebelum catatan terakhir dalam sesi (%s)", last.Format("02/01/2006 15:04"))
+    }
+
+    before := session
+    session.StartTime = newStart
+    session.EndTime = newEnd
+    if newEnd != nil {
+        session.DurationMinutes = int(newEnd.Sub(newStart).Minutes())
+    }
+
+    err = s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Model(&session).Updates(map[string]interface{}{
+            "start_time":       session.StartTime,
+            "end_time":         session.EndTime,
+            "duration_minutes": session.DurationMinutes,
+        }).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionUpdate, AuditEntitySession, session.ID, before, session)
+        return recordRevision(tx, RevisionEntitySessionTimes, session.ID, session.ID, RevisionActionUpdated, sessionTimesText(&session), "", author, reason)
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal mengoreksi waktu sesi: %w", err)
+    }
+    return &session, nil
+}
+
+// ensureWithinSession checks that a moment lies between a session's start and its end,
+// or now for a session still running
+func ensureWithinSession(db *gorm.DB, sessionID uint, at time.Time) error {
+    var session model.Session
+    if err := db.Select("id", "start_time", "end_time").First(&session, sessionID).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return errors.New("sesi tidak ditemukan")
+        }
+        return fmt.Errorf("gagal mengambil data sesi: %w", err)
+    }
+    end := time.Now()
+    if session.EndTime != nil {
+        end = *session.EndTime
+    }
+    if at.Before(session.StartTime) || at.After(end) {
+        return fmt.Errorf("waktu %s di luar waktu sesi (%s - %s)", at.Format("02/01/2006 15:04"), session.StartTime.Format("02/01/2006 15:04"), end.Format("15:04"))
+    }
+    return nil
+}
+
+// validateSessionTimes checks that a session ends after it starts and neither lies in the future
+func validateSessionTimes(start time.Time, end *time.Time) error {
+    now := time.Now()
+    if start.After(now) {
+        return errors.New("waktu mulai tidak boleh di masa depan")
+    }
+    if end == nil {
+        return nil
+    }
+    if !end.After(start) {
+        return errors.New("waktu selesai harus setelah waktu mulai")
+    }
+    if end.After(now) {
+        return errors.New("waktu selesai tidak boleh di masa depan")
+    }
+    return nil
+}
+
+// ensureNoOverlap rejects times that clash with another session the child took part in
+func (s *SessionService) ensureNoOverlap(childID, excludeID uint, start, end time.Time) error {
+    var other model.Session
+    err := s.db.Where("id <> ? AND status IN ? AND id IN (?)", excludeID, HeldSessionStatuses, ChildSessionIDs(s.db, childID)).
+        Where("start_time < ? AND (end_time IS NULL OR end_time > ?)", end, start).
+        First(&other).Error
+    if errors.Is(err, gorm.ErrRecordNotFound) {
+        return nil
+    }
+    if err != nil {
+        return fmt.Errorf("gagal memeriksa jadwal sesi: %w", err)
+    }
+    return fmt.Errorf("waktu sesi bertabrakan dengan sesi lain pada %s", other.StartTime.Format("02/01/2006 15:04"))
+}
+
+// recordedSpan returns the first and last moment anything was recorded in a session: an
+// activity starting or stopping, a note, a reward or a flashcard response. Both are nil
+// when nothing was recorded.
+func recordedSpan(db *gorm.DB, sessionID uint) (first, last *time.Time, err error) {
+    take := func(t time.Time) {
+        if first == nil || t.Before(*first) {
+            first = &t
+        }
+        if last == nil || t.After(*last) {
+            last = &t
+        }
+    }
+    for _, source := range []struct {
+        model   interface{}
+        columns []string
+    }{
+        {&model.SessionActivity{}, []string{"start_time", "end_time"}},
+        {&model.Note{}, []string{"timestamp"}},
+        {&model.Reward{}, []string{"timestamp"}},
+        {&model.SessionFlashcard{}, []string{"timestamp"}},
+    } {
+        for _, column := range source.columns {
+            for _, order := range []string{"ASC", "DESC"} {
+                var times []time.Time
+                if err := db.Model(source.model).Where("session_id = ? AND "+column+" IS NOT NULL", sessionID).
+                    Order(column+" "+order).Limit(1).Pluck(column, &times).Error; err != nil {
+                    return nil, nil, fmt.Errorf("gagal memeriksa catatan sesi: %w", err)
+                }
+                if len(times) > 0 {
+                    take(times[0])
+                }
+            }
+        }
+    }
+    return first, last, nil
+}
+
+// sessionTimesText describes a session's times for its amendment history
+func sessionTimesText(session *model.Session) string {
+    if session.EndTime == nil {
+        return fmt.Sprintf("Mulai %s", session.StartTime.Format("02/01/2006 15:04"))
+    }
+    return fmt.Sprintf("%s - %s (%d menit)", session.StartTime.Format("02/01/2006 15:04"), session.EndTime.Format("15:04"), session.DurationMinutes)
+}
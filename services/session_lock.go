@@ -0,0 +1,174 @@
+package services
+
+import (
+	"childSessions/model"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrSessionFinalized is returned for any write to a finalised session
+var ErrSessionFinalized = errors.New("sesi sudah difinalisasi dan tidak dapat diubah; gunakan addendum")
+
+// ErrSessionNotStarted is returned for writes to a session that is only scheduled
+var ErrSessionNotStarted = errors.New("sesi masih terjadwal dan belum dimulai")
+
+// ErrSessionNotHeld is returned for writes to a cancelled or missed session
+var ErrSessionNotHeld = errors.New("sesi dibatalkan atau tidak dihadiri")
+
+// ErrSessionCosigned is returned for writes to a session a supervisor has co-signed
+var ErrSessionCosigned = errors.New("sesi sudah ditandatangani bersama supervisor dan tidak dapat diubah; finalisasi sesi lalu gunakan addendum")
+
+// ensureSessionWritable rejects writes to a session that has been finalised, is awaiting
+// or has passed supervisor review, or did not take place
+func ensureSessionWritable(db *gorm.DB, sessionID uint) error {
+    var session model.Session
+    if err := db.Select("id", "finalized_at", "review_status", "status").First(&session, sessionID).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return errors.New("sesi tidak ditemukan")
+        }
+        return fmt.Errorf("gagal mengambil data sesi: %w", err)
+    }
+    if err := ensureSessionUnlocked(&session); err != nil {
+        return err
+    }
+    if session.Status == SessionStatusScheduled {
+        return ErrSessionNotStarted
+    }
+    if !IsHeldStatus(session.Status) {
+        return ErrSessionNotHeld
+    }
+    return nil
+}
+
+// ensureSessionUnlocked rejects changes to the content of a session that has been
+// finalised or that a supervisor is reviewing or has co-signed, so a signature always
+// covers what was signed. Sessions sent back for changes can be edited.
+func ensureSessionUnlocked(session *model.Session) error {
+    if session.FinalizedAt != nil {
+        return ErrSessionFinalized
+    }
+    switch session.ReviewStatus {
+    case ReviewStatusPending:
+        return ErrSessionUnderReview
+    case ReviewStatusCosigned:
+        return ErrSessionCosigned
+    }
+    return nil
+}
+
+// EnsureSessionWritable rejects writes to a session that has been finalised or is locked for review
+func (s *SessionService) EnsureSessionWritable(sessionID uint) error {
+    return ensureSessionWritable(s.db, sessionID)
+}
+
+// FinalizeSession signs an ended session and freezes its notes, activities, rewards and summary
+func (s *SessionService) FinalizeSession(sessionID uint, signer string) (*model.Session, error) {
+    if signer == "" {
+        return nil, errors.New("nama penanda tangan harus diisi")
+    }
+
+    var session model.Session
+    if err := s.db.First(&session, sessionID).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("sesi tidak ditemukan")
+        }
+        return nil, fmt.Errorf("gagal mengambil data sesi: %w", err)
+    }
+
+    if session.EndTime == nil {
+        return nil, errors.New("sesi harus diakhiri sebelum difinalisasi")
+    }
+    if session.FinalizedAt != nil {
+        return nil, errors.New("sesi sudah difinalisasi")
+    }
+    if session.ReviewStatus == ReviewStatusPending || session.ReviewStatus == ReviewStatusChangesRequested {
+        return nil, ErrSessionUnderReview
+    }
+    if session.ReviewStatus == ReviewStatusNone && session.EnteredAfterwards {
+        requiresReview, err := sessionRequiresReview(s.db, &session)
+        if err != nil {
+            return nil, err
+        }
+        if requiresReview {
+            return nil, errors.New("kirim sesi untuk direview supervisor sebelum difinalisasi")
+        }
+    }
+
+    var openActivities int64
+    if err := s.db.Model(&model.SessionActivity{}).
+        Where("session_id = ? AND end_time IS NULL", sessionID).
+        Count(&openActivities).Error; err != nil {
+        return nil, fmt.Errorf("gagal memeriksa aktivitas sesi: %w", err)
+    }
+    if openActivities > 0 {
+        return nil, errors.New("masih ada aktivitas yang berjalan dalam sesi ini")
+    }
+
+    before := session
+    now := time.Now()
+    err := s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Model(&session).Updates(map[string]interface{}{
+            "finalized_at": now,
+            "finalized_by": signer,
+        }).Error; err != nil {
+            return err
+        }
+        session.FinalizedAt = &now
+        session.FinalizedBy = signer
+        trail.Add(AuditActionUpdate, AuditEntitySession, session.ID, before, session)
+        return nil
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal memfinalisasi sesi: %w", err)
+    }
+
+    return s.GetSessionByID(sessionID)
+}
+
+// AddSessionAddendum appends an addendum to a finalised session
+func (s *SessionService) AddSessionAddendum(sessionID uint, text, author string) (*model.SessionAddendum, error) {
+    if text == "" {
+        return nil, errors.New("teks addendum harus diisi")
+    }
+
+    var session model.Session
+    if err := s.db.First(&session, sessionID).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("sesi tidak ditemukan")
+        }
+        return nil, fmt.Errorf("gagal mengambil data sesi: %w", err)
+    }
+    if session.FinalizedAt == nil {
+        return nil, errors.New("sesi belum difinalisasi; ubah catatan sesi secara langsung")
+    }
+
+    addendum := &model.SessionAddendum{
+        SessionID: sessionID,
+        Text:      text,
+        Author:    author,
+        Timestamp: time.Now(),
+    }
+    err := s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Create(addendum).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionCreate, AuditEntitySessionAddendum, addendum.ID, nil, addendum)
+        return nil
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal menyimpan addendum: %w", err)
+    }
+    return addendum, nil
+}
+
+// GetSessionAddenda lists the addenda of a session, oldest first
+func (s *SessionService) GetSessionAddenda(sessionID uint) ([]model.SessionAddendum, error) {
+    var addenda []model.SessionAddendum
+    if err := s.db.Where("session_id = ?", sessionID).Order("timestamp ASC").Find(&addenda).Error; err != nil {
+        return nil, fmt.Errorf("gagal mengambil addendum sesi: %w", err)
+    }
+    return addenda, nil
+}
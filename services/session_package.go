@@ -0,0 +1,412 @@
+package services
+
+import (
+	"childSessions/model"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Package statuses, derived from the package's balance, expiry and cancellation
+const (
+    PackageStatusActive    = "active"
+    PackageStatusExhausted = "exhausted"
+    PackageStatusExpired   = "expired"
+    PackageStatusCancelled = "cancelled"
+)
+
+// Package usage actions
+const (
+    PackageUsageUsed     = "used"
+    PackageUsageReturned = "returned" // The child turned out not to have attended
+)
+
+// PackageExpiryWarningDays is how close to its expiry a package is mentioned when a session starts
+const PackageExpiryWarningDays = 14
+
+// SessionPackageInput holds the editable fields of a package
+type SessionPackageInput struct {
+    ChildID           uint   `json:"child_id"`
+    Name              string `json:"name"`
+    TherapyType       string `json:"therapy_type"`
+    SessionsPurchased int    `json:"sessions_purchased"`
+    Price             int64  `json:"price"`
+    PurchasedAt       string `json:"purchased_at"` // Defaults to today
+    ExpiresAt         string `json:"expires_at"`   // Optional
+    Notes             string `json:"notes"`
+}
+
+// PackageBalance is a package with what is left of it
+type PackageBalance struct {
+    model.SessionPackage
+    Remaining int    `json:"remaining"`
+    Status    string `json:"status"`
+}
+
+type PackageService struct {
+    db    *gorm.DB
+    audit *AuditService
+}
+
+func NewPackageService(db *gorm.DB, audit *AuditService) *PackageService {
+    return &PackageService{db: db, audit: audit}
+}
+
+// CreatePackage records a package of sessions bought for a child
+func (s *PackageService) CreatePackage(input SessionPackageInput) (*PackageBalance, error) {
+    if err := ensureChildExists(s.db, input.ChildID); err != nil {
+        return nil, err
+    }
+    pkg := &model.SessionPackage{ChildID: input.ChildID}
+    if err := applyPackageInput(pkg, input); err != nil {
+        return nil, err
+    }
+    err := s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Omit("Child", "Usages").Create(pkg).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionCreate, AuditEntitySessionPackage, pkg.ID, nil, pkg)
+        return nil
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal menyimpan paket sesi: %w", err)
+    }
+    return s.GetPackage(pkg.ID)
+}
+
+// UpdatePackage corrects a package or extends it. It cannot hold fewer sessions than
+// have already been used.
+func (s *PackageService) UpdatePackage(packageID uint, input SessionPackageInput) (*PackageBalance, error) {
+    balance, err := s.GetPackage(packageID)
+    if err != nil {
+        return nil, err
+    }
+    pkg := balance.SessionPackage
+    if pkg.CancelledAt != nil {
+        return nil, errors.New("paket sudah dibatalkan")
+    }
+    before := pkg
+    if err := applyPackageInput(&pkg, input); err != nil {
+        return nil, err
+    }
+    if pkg.SessionsPurchased < pkg.SessionsUsed {
+        return nil, fmt.Errorf("jumlah sesi tidak boleh kurang dari sesi yang sudah terpakai (%d)", pkg.SessionsUsed)
+    }
+    err = s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Omit("Child", "Usages").Save(&pkg).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionUpdate, AuditEntitySessionPackage, pkg.ID, before, pkg)
+        return nil
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal menyimpan paket sesi: %w", err)
+    }
+    return s.GetPackage(pkg.ID)
+}
+
+// CancelPackage stops a package from being used, e.g. after a refund
+func (s *PackageService) CancelPackage(packageID uint, reason string) (*PackageBalance, error) {
+    reason = strings.TrimSpace(reason)
+    if reason == "" {
+        return nil, errors.New("alasan pembatalan paket harus diisi")
+    }
+    balance, err := s.GetPackage(packageID)
+    if err != nil {
+        return nil, err
+    }
+    pkg := balance.SessionPackage
+    if pkg.CancelledAt != nil {
+        return nil, errors.New("paket sudah dibatalkan")
+    }
+    before := pkg
+    now := time.Now()
+    pkg.CancelledAt = &now
+    pkg.CancelReason = reason
+    err = s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Model(&pkg).Updates(map[string]interface{}{
+            "cancelled_at":  now,
+            "cancel_reason": reason,
+        }).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionUpdate, AuditEntitySessionPackage, pkg.ID, before, pkg)
+        return nil
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal membatalkan paket sesi: %w", err)
+    }
+    return s.GetPackage(pkg.ID)
+}
+
+// GetPackage returns a package with its usage history, oldest first
+func (s *PackageService) GetPackage(packageID uint) (*PackageBalance, error) {
+    var pkg model.SessionPackage
+    err := s.db.Preload("Child").
+        Preload("Usages", func(db *gorm.DB) *gorm.DB { return db.Order("id ASC") }).
+        First(&pkg, packageID).Error
+    if err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("paket sesi tidak ditemukan")
+        }
+        return nil, fmt.Errorf("gagal mengambil paket sesi: %w", err)
+    }
+    return newPackageBalance(pkg, time.Now()), nil
+}
+
+// GetChildPackageHistory lists a child's packages, newest first, each with every
+// session taken from it and given back
+func (s *PackageService) GetChildPackageHistory(childID uint) ([]PackageBalance, error) {
+    if err := ensureChildExists(s.db, childID); err != nil {
+        return nil, err
+    }
+    var packages []model.SessionPackage
+    if err := s.db.Preload("Usages", func(db *gorm.DB) *gorm.DB { return db.Order("id ASC") }).
+        Where("child_id = ?", childID).Order("purchased_at DESC, id DESC").Find(&packages).Error; err != nil {
+        return nil, fmt.Errorf("gagal mengambil paket sesi: %w", err)
+    }
+    now := time.Now()
+    balances := make([]PackageBalance, 0, len(packages))
+    for _, pkg := range packages {
+        balances = append(balances, *newPackageBalance(pkg, now))
+    }
+    return balances, nil
+}
+
+// GetPackageReport lists packages across children with what is left of them, soonest
+// expiry first. Without statuses every package is listed. childIDs limits the result
+// when not nil.
+func (s *PackageService) GetPackageReport(statuses []string, childIDs []uint) ([]PackageBalance, error) {
+    query := s.db.Preload("Child")
+    if childIDs != nil {
+        query = query.Where("child_id IN ?", childIDs)
+    }
+    var packages []model.SessionPackage
+    if err := query.Order("expires_at IS NULL, expires_at ASC, purchased_at ASC").Find(&packages).Error; err != nil {
+        return nil, fmt.Errorf("gagal mengambil paket sesi: %w", err)
+    }
+    wanted := make(map[string]bool, len(statuses))
+    for _, status := range statuses {
+        wanted[status] = true
+    }
+    now := time.Now()
+    report := make([]PackageBalance, 0, len(packages))
+    for _, pkg := range packages {
+        balance := newPackageBalance(pkg, now)
+        if len(wanted) > 0 && !wanted[balance.Status] {
+            continue
+        }
+        report = append(report, *balance)
+    }
+    return report, nil
+}
+
+// PackageSessionIDs selects the sessions paid for from one of a child's packages; they
+// are not invoiced
+func PackageSessionIDs(db *gorm.DB, childID uint) *gorm.DB {
+    return db.Model(&model.PackageUsage{}).Select("session_id").Where("child_id = ?", childID).
+        Group("session_id").Having("SUM(CASE WHEN action = ? THEN 1 ELSE -1 END) > 0", PackageUsageUsed)
+}
+
+// consumeSessionPackages takes an ended session from the package of every child who
+// attended it. Children without a usable package are billed for the session instead.
+func consumeSessionPackages(tx *gorm.DB, trail *AuditTrail, session *model.Session) error {
+    if !IsHeldStatus(session.Status) || session.EndTime == nil {
+        return nil
+    }
+    childIDs := []uint{session.ChildID}
+    if session.IsGroup {
+        childIDs = nil
+        if err := tx.Model(&model.SessionParticipant{}).Where("session_id = ? AND attendance <> ?", session.ID, AttendanceAbsent).
+            Pluck("child_id", &childIDs).Error; err != nil {
+            return err
+        }
+    }
+    for _, childID := range childIDs {
+        if err := useSessionPackage(tx, trail, childID, session); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// useSessionPackage takes one session from the child's package that expires soonest.
+// Nothing happens when the session is already paid for from a package or no package applies.
+func useSessionPackage(tx *gorm.DB, trail *AuditTrail, childID uint, session *model.Session) error {
+    var covered int64
+    if err := tx.Model(&model.Session{}).Where("id = ? AND id IN (?)", session.ID, PackageSessionIDs(tx, childID)).Count(&covered).Error; err != nil {
+        return err
+    }
+    if covered > 0 {
+        return nil
+    }
+    pkg, err := usablePackage(tx, childID, session.StartTime, session.TherapyType)
+    if err != nil || pkg == nil {
+        return err
+    }
+    return changePackageBalance(tx, trail, pkg, childID, session, PackageUsageUsed, "")
+}
+
+// returnSessionPackage gives a session back to the package it was taken from
+func returnSessionPackage(tx *gorm.DB, trail *AuditTrail, childID uint, session *model.Session, notes string) error {
+    var usages []model.PackageUsage
+    if err := tx.Where("child_id = ? AND session_id = ? AND id IN (?)", childID, session.ID, tx.Model(&model.PackageUsage{}).Select("MAX(id)").Where("child_id = ? AND session_id = ?", childID, session.ID)).
+        Find(&usages).Error; err != nil {
+        return err
+    }
+    if len(usages) == 0 || usages[0].Action != PackageUsageUsed {
+        return nil
+    }
+    var pkg model.SessionPackage
+    if err := tx.First(&pkg, usages[0].PackageID).Error; err != nil {
+        return err
+    }
+    return changePackageBalance(tx, trail, &pkg, childID, session, PackageUsageReturned, notes)
+}
+
+func changePackageBalance(tx *gorm.DB, trail *AuditTrail, pkg *model.SessionPackage, childID uint, session *model.Session, action, notes string) error {
+    before := *pkg
+    if action == PackageUsageUsed {
+        pkg.SessionsUsed++
+    } else {
+        pkg.SessionsUsed--
+    }
+    if err := tx.Model(pkg).Update("sessions_used", pkg.SessionsUsed).Error; err != nil {
+        return err
+    }
+    trail.Add(AuditActionUpdate, AuditEntitySessionPackage, pkg.ID, before, pkg)
+
+    usage := &model.PackageUsage{
+        PackageID:      pkg.ID,
+        SessionID:      session.ID,
+        ChildID:        childID,
+        SessionDate:    session.StartTime,
+        Action:         action,
+        RemainingAfter: pkg.SessionsPurchased - pkg.SessionsUsed,
+        Notes:          notes,
+    }
+    if err := tx.Create(usage).Error; err != nil {
+        return err
+    }
+    trail.Add(AuditActionCreate, AuditEntityPackageUsage, usage.ID, nil, usage)
+    return nil
+}
+
+// usablePackage finds the package a session on the given date is taken from: bought by
+// then, not cancelled, exhausted or expired, and for the session's therapy type when
+// both record one. The package expiring soonest goes first. nil when none applies.
+func usablePackage(db *gorm.DB, childID uint, at time.Time, therapyType string) (*model.SessionPackage, error) {
+    day := startOfDay(at)
+    var packages []model.SessionPackage
+    if err := db.Where("child_id = ? AND cancelled_at IS NULL AND sessions_used < sessions_purchased AND purchased_at < ?", childID, day.AddDate(0, 0, 1)).
+        Where("expires_at IS NULL OR expires_at >= ?", day).
+        Order("expires_at IS NULL, expires_at ASC, purchased_at ASC, id ASC").Find(&packages).Error; err != nil {
+        return nil, fmt.Errorf("gagal mengambil paket sesi: %w", err)
+    }
+    for i := range packages {
+        pkg := &packages[i]
+        if pkg.TherapyType == "" || therapyType == "" || strings.EqualFold(pkg.TherapyType, therapyType) {
+            return pkg, nil
+        }
+    }
+    return nil, nil
+}
+
+// packageWarnings tells whoever starts a session when the child's prepaid sessions have
+// run out, expired or are not valid yet, or are about to run out or expire. Children who never bought a package pay per
+// session and get no warning.
+func packageWarnings(db *gorm.DB, childID uint, at time.Time) ([]string, error) {
+    var latest model.SessionPackage
+    err := db.Where("child_id = ? AND cancelled_at IS NULL", childID).Order("purchased_at DESC, id DESC").First(&latest).Error
+    if errors.Is(err, gorm.ErrRecordNotFound) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("gagal mengambil paket sesi: %w", err)
+    }
+
+    pkg, err := usablePackage(db, childID, at, "")
+    if err != nil {
+        return nil, err
+    }
+    if pkg == nil {
+        balance := newPackageBalance(latest, at)
+        switch balance.Status {
+        case PackageStatusExpired:
+            return []string{fmt.Sprintf("Perhatian: %s sudah kedaluwarsa sejak %s dengan sisa %d sesi; sesi ini akan ditagihkan",
+                latest.Name, latest.ExpiresAt.Format("02/01/2006"), balance.Remaining)}, nil
+        case PackageStatusExhausted:
+            return []string{fmt.Sprintf("Perhatian: %s sudah habis (%d sesi terpakai); sesi ini akan ditagihkan", latest.Name, latest.SessionsUsed)}, nil
+        case PackageStatusCancelled:
+            return []string{fmt.Sprintf("Perhatian: %s sudah dibatalkan; sesi ini akan ditagihkan", latest.Name)}, nil
+        case PackageStatusActive:
+            // Still has sessions left but is not usable yet, so it was bought after this date
+            return []string{fmt.Sprintf("Perhatian: %s belum berlaku (mulai %s); sesi ini akan ditagihkan",
+                latest.Name, latest.PurchasedAt.Format("02/01/2006"))}, nil
+        }
+        return nil, nil
+    }
+
+    var warnings []string
+    remaining := pkg.SessionsPurchased - pkg.SessionsUsed
+    if remaining == 1 {
+        warnings = append(warnings, fmt.Sprintf("Ini sesi terakhir dari %s", pkg.Name))
+    }
+    if pkg.ExpiresAt != nil && pkg.ExpiresAt.Before(startOfDay(at).AddDate(0, 0, PackageExpiryWarningDays)) {
+        warnings = append(warnings, fmt.Sprintf("%s berakhir pada %s dengan sisa %d sesi", pkg.Name, pkg.ExpiresAt.Format("02/01/2006"), remaining))
+    }
+    return warnings, nil
+}
+
+func newPackageBalance(pkg model.SessionPackage, now time.Time) *PackageBalance {
+    balance := &PackageBalance{SessionPackage: pkg, Remaining: pkg.SessionsPurchased - pkg.SessionsUsed}
+    switch {
+    case pkg.CancelledAt != nil:
+        balance.Status = PackageStatusCancelled
+    case balance.Remaining <= 0:
+        balance.Status = PackageStatusExhausted
+    case pkg.ExpiresAt != nil && pkg.ExpiresAt.Before(startOfDay(now)):
+        balance.Status = PackageStatusExpired
+    default:
+        balance.Status = PackageStatusActive
+    }
+    return balance
+}
+
+func applyPackageInput(pkg *model.SessionPackage, input SessionPackageInput) error {
+    name := strings.TrimSpace(input.Name)
+    if input.SessionsPurchased <= 0 {
+        return errors.New("jumlah sesi paket harus lebih dari nol")
+    }
+    if name == "" {
+        name = fmt.Sprintf("Paket %d sesi", input.SessionsPurchased)
+    }
+    if input.Price < 0 {
+        return errors.New("harga paket tidak boleh negatif")
+    }
+    purchasedAt := startOfDay(time.Now())
+    if date, err := ParseCalendarDate(input.PurchasedAt); err != nil {
+        return err
+    } else if date != nil {
+        purchasedAt = *date
+    }
+    expiresAt, err := ParseCalendarDate(input.ExpiresAt)
+    if err != nil {
+        return err
+    }
+    if expiresAt != nil && expiresAt.Before(purchasedAt) {
+        return errors.New("tanggal kedaluwarsa tidak boleh sebelum tanggal pembelian")
+    }
+
+    pkg.Name = name
+    pkg.TherapyType = strings.TrimSpace(input.TherapyType)
+    pkg.SessionsPurchased = input.SessionsPurchased
+    pkg.Price = input.Price
+    pkg.PurchasedAt = purchasedAt
+    pkg.ExpiresAt = expiresAt
+    pkg.Notes = strings.TrimSpace(input.Notes)
+    return nil
+}
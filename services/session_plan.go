@@ -0,0 +1,522 @@
+package services
+
+import (
+	"childSessions/model"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Planned activity statuses in a plan comparison
+const (
+    PlanItemStatusNotStarted = "not_started"
+    PlanItemStatusOngoing    = "ongoing"
+    PlanItemStatusCompleted  = "completed"
+    PlanItemStatusSkipped    = "skipped"
+)
+
+// SessionPlanInput describes a plan and its activities in the order they are run
+type SessionPlanInput struct {
+    Title       string                 `json:"title"`
+    PlannedFor  string                 `json:"planned_for"` // Optional date of the session
+    TherapistID *uint                  `json:"therapist_id"`
+    Notes       string                 `json:"notes"`
+    Items       []SessionPlanItemInput `json:"items"`
+}
+
+// SessionPlanItemInput is one planned activity
+type SessionPlanItemInput struct {
+    ActivityID    uint   `json:"activity_id"`
+    TargetMinutes int    `json:"target_minutes"` // 0 uses the activity's default duration
+    GoalID        *uint  `json:"goal_id"`
+    Objective     string `json:"objective"`
+    Materials     string `json:"materials"`
+    Notes         string `json:"notes"`
+}
+
+// PlannedActivityResult compares one planned activity with what happened in the session
+type PlannedActivityResult struct {
+    ItemID            uint       `json:"item_id"`
+    Position          int        `json:"position"`
+    ActivityName      string     `json:"activity_name"`
+    GoalName          string     `json:"goal_name"`
+    Objective         string     `json:"objective"`
+    Materials         string     `json:"materials"`
+    Status            string     `json:"status"`
+    SkipReason        string     `json:"skip_reason"`
+    TargetMinutes     int        `json:"target_minutes"`
+    ActualMinutes     int        `json:"actual_minutes"`
+    DifferenceMinutes int        `json:"difference_minutes"` // Actual minus target
+    StartTime         *time.Time `json:"start_time"`
+}
+
+// UnplannedActivityResult is an activity run in the session that was not in the plan
+type UnplannedActivityResult struct {
+    SessionActivityID uint   `json:"session_activity_id"`
+    ActivityName      string `json:"activity_name"`
+    ActualMinutes     int    `json:"actual_minutes"`
+}
+
+// PlanComparison sets a session's plan against the activities actually run
+type PlanComparison struct {
+    PlanID              uint                      `json:"plan_id"`
+    Title               string                    `json:"title"`
+    Planned             []PlannedActivityResult   `json:"planned"`
+    Unplanned           []UnplannedActivityResult `json:"unplanned"`
+    CompletedCount      int                       `json:"completed_count"`
+    SkippedCount        int                       `json:"skipped_count"`
+    NotStartedCount     int                       `json:"not_started_count"`
+    TotalTargetMinutes  int                       `json:"total_target_minutes"`
+    TotalPlannedMinutes int                       `json:"total_planned_minutes"` // Actual minutes spent on planned activities
+}
+
+type SessionPlanService struct {
+    db    *gorm.DB
+    audit *AuditService
+}
+
+func NewSessionPlanService(db *gorm.DB, audit *AuditService) *SessionPlanService {
+    return &SessionPlanService{db: db, audit: audit}
+}
+
+// CreatePlan prepares a plan for a child's upcoming session
+func (s *SessionPlanService) CreatePlan(childID uint, input SessionPlanInput, createdBy string) (*model.SessionPlan, error) {
+    return s.createPlan(childID, input, createdBy, nil)
+}
+
+// CreatePlanFromTemplate clones a template version's activities into a new plan for a
+// child. The plan keeps a link to the version for its note templates, decks and reward rules.
+func (s *SessionPlanService) CreatePlanFromTemplate(templateID, childID uint, title, plannedFor string, therapistID *uint, createdBy string) (*model.SessionPlan, error) {
+    template, err := NewSessionTemplateService(s.db, s.audit).GetTemplate(templateID)
+    if err != nil {
+        return nil, err
+    }
+    if template.DeletedAt.Valid {
+        return nil, errors.New("templat sesi sudah dihapus")
+    }
+
+    input := SessionPlanInput{
+        Title:       strings.TrimSpace(title),
+        PlannedFor:  plannedFor,
+        TherapistID: therapistID,
+        Notes:       template.Description,
+        Items:       make([]SessionPlanItemInput, 0, len(template.Activities)),
+    }
+    if input.Title == "" {
+        input.Title = template.Name
+    }
+    for _, activity := range template.Activities {
+        input.Items = append(input.Items, SessionPlanItemInput{
+            ActivityID:    activity.ActivityID,
+            TargetMinutes: activity.TargetMinutes,
+            Objective:     activity.Objective,
+            Materials:     activity.Materials,
+            Notes:         activity.Notes,
+        })
+    }
+    return s.createPlan(childID, input, createdBy, &template.ID)
+}
+
+func (s *SessionPlanService) createPlan(childID uint, input SessionPlanInput, createdBy string, templateID *uint) (*model.SessionPlan, error) {
+    if err := ensureChildExists(s.db, childID); err != nil {
+        return nil, err
+    }
+    plan := &model.SessionPlan{ChildID: childID, CreatedBy: createdBy, TemplateID: templateID}
+    items, err := s.applyPlanInput(plan, input)
+    if err != nil {
+        return nil, err
+    }
+
+    err = s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Omit("Items").Create(plan).Error; err != nil {
+            return err
+        }
+        if err := createPlanItems(tx, plan.ID, items); err != nil {
+            return err
+        }
+        plan.Items = items
+        trail.Add(AuditActionCreate, AuditEntitySessionPlan, plan.ID, nil, plan)
+        return nil
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal menyimpan rencana sesi: %w", err)
+    }
+    return s.GetPlan(plan.ID)
+}
+
+// UpdatePlan replaces the details and activities of a plan that has not been used yet
+func (s *SessionPlanService) UpdatePlan(planID uint, input SessionPlanInput) (*model.SessionPlan, error) {
+    plan, err := s.GetPlan(planID)
+    if err != nil {
+        return nil, err
+    }
+    if plan.SessionID != nil {
+        return nil, errors.New("rencana sudah dipakai dalam sesi dan tidak dapat diubah")
+    }
+
+    before := *plan
+    items, err := s.applyPlanInput(plan, input)
+    if err != nil {
+        return nil, err
+    }
+
+    err = s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Omit("Items").Save(plan).Error; err != nil {
+            return err
+        }
+        // Items of an unused plan carry no history, so they are simply replaced
+        if err := tx.Unscoped().Where("plan_id = ?", plan.ID).Delete(&model.SessionPlanItem{}).Error; err != nil {
+            return err
+        }
+        if err := createPlanItems(tx, plan.ID, items); err != nil {
+            return err
+        }
+        plan.Items = items
+        trail.Add(AuditActionUpdate, AuditEntitySessionPlan, plan.ID, before, plan)
+        return nil
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal memperbarui rencana sesi: %w", err)
+    }
+    return s.GetPlan(plan.ID)
+}
+
+// DeletePlan removes a plan that has not been used in a session (soft delete)
+func (s *SessionPlanService) DeletePlan(planID uint) (*model.SessionPlan, error) {
+    plan, err := s.GetPlan(planID)
+    if err != nil {
+        return nil, err
+    }
+    if plan.SessionID != nil {
+        return nil, errors.New("rencana sudah dipakai dalam sesi dan tidak dapat dihapus")
+    }
+
+    err = s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Where("plan_id = ?", plan.ID).Delete(&model.SessionPlanItem{}).Error; err != nil {
+            return err
+        }
+        if err := tx.Omit("Items").Delete(plan).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionDelete, AuditEntitySessionPlan, plan.ID, plan, nil)
+        return nil
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal menghapus rencana sesi: %w", err)
+    }
+    return plan, nil
+}
+
+// GetPlan retrieves a plan with its activities in order
+func (s *SessionPlanService) GetPlan(planID uint) (*model.SessionPlan, error) {
+    var plan model.SessionPlan
+    err := s.db.Preload("Items", func(db *gorm.DB) *gorm.DB {
+        return db.Order("position ASC")
+    }).Preload("Items.Activity").Preload("Items.Goal").First(&plan, planID).Error
+    if err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("rencana sesi tidak ditemukan")
+        }
+        return nil, fmt.Errorf("gagal mengambil rencana sesi: %w", err)
+    }
+    return &plan, nil
+}
+
+// GetPlansByChild lists a child's plans, newest first; used plans are included on request
+func (s *SessionPlanService) GetPlansByChild(childID uint, includeUsed bool) ([]model.SessionPlan, error) {
+    query := s.db.Preload("Items", func(db *gorm.DB) *gorm.DB {
+        return db.Order("position ASC")
+    }).Preload("Items.Activity").Preload("Items.Goal").
+        Where("child_id = ?", childID).
+        Order("planned_for IS NULL, planned_for DESC, id DESC")
+    if !includeUsed {
+        query = query.Where("session_id IS NULL")
+    }
+    var plans []model.SessionPlan
+    if err := query.Find(&plans).Error; err != nil {
+        return nil, fmt.Errorf("gagal mengambil rencana sesi: %w", err)
+    }
+    return plans, nil
+}
+
+// GetSessionPlan retrieves the plan a session was started with; nil when it had none
+func (s *SessionPlanService) GetSessionPlan(sessionID uint) (*model.SessionPlan, error) {
+    var plan model.SessionPlan
+    err := s.db.Where("session_id = ?", sessionID).First(&plan).Error
+    if errors.Is(err, gorm.ErrRecordNotFound) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("gagal mengambil rencana sesi: %w", err)
+    }
+    return s.GetPlan(plan.ID)
+}
+
+// GetNextPlannedActivity returns the first planned activity that has been neither started
+// nor skipped; nil when the session has no plan or the plan is done
+func (s *SessionPlanService) GetNextPlannedActivity(sessionID uint) (*model.SessionPlanItem, error) {
+    plan, err := s.GetSessionPlan(sessionID)
+    if err != nil || plan == nil {
+        return nil, err
+    }
+    for _, item := range plan.Items {
+        if item.SessionActivityID == nil && !item.Skipped {
+            return &item, nil
+        }
+    }
+    return nil, nil
+}
+
+// SkipPlannedActivity marks a planned activity as not run in this session
+func (s *SessionPlanService) SkipPlannedActivity(itemID uint, reason string) (*model.SessionPlanItem, error) {
+    var item model.SessionPlanItem
+    if err := s.db.First(&item, itemID).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("aktivitas rencana tidak ditemukan")
+        }
+        return nil, fmt.Errorf("gagal mengambil aktivitas rencana: %w", err)
+    }
+    var plan model.SessionPlan
+    if err := s.db.First(&plan, item.PlanID).Error; err != nil {
+        return nil, fmt.Errorf("gagal mengambil rencana sesi: %w", err)
+    }
+    if plan.SessionID == nil {
+        return nil, errors.New("rencana belum dipakai dalam sesi")
+    }
+    if err := ensureSessionWritable(s.db, *plan.SessionID); err != nil {
+        return nil, err
+    }
+    if item.SessionActivityID != nil {
+        return nil, errors.New("aktivitas rencana sudah dimulai")
+    }
+
+    before := item
+    item.Skipped = true
+    item.SkipReason = strings.TrimSpace(reason)
+    err := s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Save(&item).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionUpdate, AuditEntitySessionPlanItem, item.ID, before, item)
+        return nil
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal melewati aktivitas rencana: %w", err)
+    }
+    return &item, nil
+}
+
+// ComparePlanWithSession sets each planned activity against the activity actually run;
+// nil when the session was started without a plan
+func (s *SessionPlanService) ComparePlanWithSession(sessionID uint) (*PlanComparison, error) {
+    plan, err := s.GetSessionPlan(sessionID)
+    if err != nil || plan == nil {
+        return nil, err
+    }
+    var activities []model.SessionActivity
+    if err := s.db.Preload("Activity").Where("session_id = ?", sessionID).Order("start_time ASC").Find(&activities).Error; err != nil {
+        return nil, fmt.Errorf("gagal mengambil aktivitas sesi: %w", err)
+    }
+    return comparePlan(plan, activities, time.Now()), nil
+}
+
+func comparePlan(plan *model.SessionPlan, activities []model.SessionActivity, now time.Time) *PlanComparison {
+    byID := make(map[uint]model.SessionActivity, len(activities))
+    for _, activity := range activities {
+        byID[activity.ID] = activity
+    }
+
+    comparison := &PlanComparison{
+        PlanID:    plan.ID,
+        Title:     plan.Title,
+        Planned:   make([]PlannedActivityResult, 0, len(plan.Items)),
+        Unplanned: make([]UnplannedActivityResult, 0),
+    }
+    linked := make(map[uint]bool)
+    for _, item := range plan.Items {
+        result := PlannedActivityResult{
+            ItemID:        item.ID,
+            Position:      item.Position,
+            ActivityName:  item.Activity.Name,
+            Objective:     item.Objective,
+            Materials:     item.Materials,
+            Status:        PlanItemStatusNotStarted,
+            TargetMinutes: item.TargetMinutes,
+        }
+        if item.Goal != nil {
+            result.GoalName = item.Goal.Name
+        }
+        comparison.TotalTargetMinutes += item.TargetMinutes
+
+        activity, ok := model.SessionActivity{}, false
+        if item.SessionActivityID != nil {
+            activity, ok = byID[*item.SessionActivityID]
+        }
+        switch {
+        case ok:
+            linked[activity.ID] = true
+            result.StartTime = activity.StartTime
+            result.ActualMinutes = activityMinutes(activity, now)
+            result.DifferenceMinutes = result.ActualMinutes - result.TargetMinutes
+            comparison.TotalPlannedMinutes += result.ActualMinutes
+            if activity.EndTime != nil {
+                result.Status = PlanItemStatusCompleted
+                comparison.CompletedCount++
+            } else {
+                result.Status = PlanItemStatusOngoing
+            }
+        case item.Skipped:
+            result.Status = PlanItemStatusSkipped
+            result.SkipReason = item.SkipReason
+            comparison.SkippedCount++
+        default:
+            comparison.NotStartedCount++
+        }
+        comparison.Planned = append(comparison.Planned, result)
+    }
+
+    for _, activity := range activities {
+        if linked[activity.ID] {
+            continue
+        }
+        comparison.Unplanned = append(comparison.Unplanned, UnplannedActivityResult{
+            SessionActivityID: activity.ID,
+            ActivityName:      activity.Activity.Name,
+            ActualMinutes:     activityMinutes(activity, now),
+        })
+    }
+    return comparison
+}
+
+func activityMinutes(activity model.SessionActivity, now time.Time) int {
+    if activity.StartTime == nil {
+        return 0
+    }
+    end := now
+    if activity.EndTime != nil {
+        end = *activity.EndTime
+    }
+    return int(end.Sub(*activity.StartTime).Minutes())
+}
+
+// applyPlanInput validates input onto plan and returns the items to create
+func (s *SessionPlanService) applyPlanInput(plan *model.SessionPlan, input SessionPlanInput) ([]model.SessionPlanItem, error) {
+    title := strings.TrimSpace(input.Title)
+    if title == "" {
+        return nil, errors.New("judul rencana sesi harus diisi")
+    }
+    if len(input.Items) == 0 {
+        return nil, errors.New("rencana sesi harus memiliki minimal satu aktivitas")
+    }
+    plan.Title = title
+    plan.Notes = strings.TrimSpace(input.Notes)
+    plan.TherapistID = input.TherapistID
+    plan.PlannedFor = nil
+    if strings.TrimSpace(input.PlannedFor) != "" {
+        date, err := ParseCalendarDate(input.PlannedFor)
+        if err != nil {
+            return nil, err
+        }
+        plan.PlannedFor = date
+    }
+
+    items := make([]model.SessionPlanItem, 0, len(input.Items))
+    for i, itemInput := range input.Items {
+        var activity model.Activity
+        if err := s.db.First(&activity, itemInput.ActivityID).Error; err != nil {
+            if errors.Is(err, gorm.ErrRecordNotFound) {
+                return nil, fmt.Errorf("aktivitas ke-%d tidak ditemukan", i+1)
+            }
+            return nil, fmt.Errorf("gagal mengambil aktivitas: %w", err)
+        }
+        if itemInput.TargetMinutes < 0 {
+            return nil, fmt.Errorf("durasi target aktivitas ke-%d tidak boleh negatif", i+1)
+        }
+        if itemInput.GoalID != nil {
+            var goal model.Goal
+            if err := s.db.First(&goal, *itemInput.GoalID).Error; err != nil {
+                if errors.Is(err, gorm.ErrRecordNotFound) {
+                    return nil, fmt.Errorf("target aktivitas ke-%d tidak ditemukan", i+1)
+                }
+                return nil, fmt.Errorf("gagal mengambil target: %w", err)
+            }
+            if goal.ChildID != plan.ChildID {
+                return nil, fmt.Errorf("target aktivitas ke-%d bukan milik anak ini", i+1)
+            }
+        }
+
+        targetMinutes := itemInput.TargetMinutes
+        if targetMinutes == 0 {
+            targetMinutes = activity.DefaultDurationMinutes
+        }
+        items = append(items, model.SessionPlanItem{
+            Position:      i + 1,
+            ActivityID:    activity.ID,
+            TargetMinutes: targetMinutes,
+            GoalID:        itemInput.GoalID,
+            Objective:     strings.TrimSpace(itemInput.Objective),
+            Materials:     strings.TrimSpace(itemInput.Materials),
+            Notes:         strings.TrimSpace(itemInput.Notes),
+        })
+    }
+    return items, nil
+}
+
+func createPlanItems(tx *gorm.DB, planID uint, items []model.SessionPlanItem) error {
+    for i := range items {
+        items[i].PlanID = planID
+        if err := tx.Omit("Activity", "Goal").Create(&items[i]).Error; err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// loadPlanForSession checks that a plan can be used to start a session for childID
+func loadPlanForSession(db *gorm.DB, planID, childID uint) (*model.SessionPlan, error) {
+    var plan model.SessionPlan
+    err := db.Preload("Items", func(db *gorm.DB) *gorm.DB {
+        return db.Order("position ASC")
+    }).Preload("Items.Activity").First(&plan, planID).Error
+    if err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("rencana sesi tidak ditemukan")
+        }
+        return nil, fmt.Errorf("gagal mengambil rencana sesi: %w", err)
+    }
+    if plan.ChildID != childID {
+        return nil, errors.New("rencana sesi bukan milik anak ini")
+    }
+    if plan.SessionID != nil {
+        return nil, errors.New("rencana sesi sudah dipakai dalam sesi lain")
+    }
+    return &plan, nil
+}
+
+// LinkPlannedActivity ties a newly started session activity to the first planned item
+// for the same activity that is still open. Activities outside the plan are left unlinked.
+func LinkPlannedActivity(tx *gorm.DB, trail *AuditTrail, sessionActivity *model.SessionActivity) error {
+    var item model.SessionPlanItem
+    err := tx.Joins("JOIN session_plans ON session_plans.id = session_plan_items.plan_id AND session_plans.deleted_at IS NULL").
+        Where("session_plans.session_id = ? AND session_plan_items.activity_id = ?", sessionActivity.SessionID, sessionActivity.ActivityID).
+        Where("session_plan_items.session_activity_id IS NULL AND session_plan_items.skipped = ?", false).
+        Order("session_plan_items.position ASC").
+        First(&item).Error
+    if errors.Is(err, gorm.ErrRecordNotFound) {
+        return nil
+    }
+    if err != nil {
+        return err
+    }
+
+    before := item
+    item.SessionActivityID = &sessionActivity.ID
+    if err := tx.Save(&item).Error; err != nil {
+        return err
+    }
+    trail.Add(AuditActionUpdate, AuditEntitySessionPlanItem, item.ID, before, item)
+    return nil
+}
@@ -0,0 +1,241 @@
+package services
+
+import (
+	"childSessions/model"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// OrphanedSession is a session or activity left running when the app was closed or
+// crashed, with the last moment something was recorded in it
+type OrphanedSession struct {
+    Session        model.Session           `json:"session"`
+    OpenActivities []model.SessionActivity `json:"open_activities"`
+    SessionOpen    bool                    `json:"session_open"` // false when only activities were left running
+    LastActivityAt time.Time               `json:"last_activity_at"`
+    IdleMinutes    int                     `json:"idle_minutes"` // Time since the last recorded activity
+}
+
+// GetOrphanedSessions finds sessions started before the given moment (normally when the
+// app started) that are still open and were not resumed since, and ended sessions that
+// still have activities running. childIDs limits the result when not nil.
+func (s *SessionService) GetOrphanedSessions(before time.Time, childIDs []uint) ([]OrphanedSession, error) {
+    openActivities := s.db.Model(&model.SessionActivity{}).Select("session_id").Where("end_time IS NULL")
+    query := s.db.Preload("Child").Preload("Therapist").
+        Where("status IN ? AND start_time < ?", HeldSessionStatuses, before).
+        Where("(end_time IS NULL AND (resumed_at IS NULL OR resumed_at < ?)) OR (end_time IS NOT NULL AND id IN (?))", before, openActivities)
+    if childIDs != nil {
+        query = query.Where("child_id IN ? OR id IN (?)", childIDs,
+            s.db.Model(&model.SessionParticipant{}).Select("session_id").Where("child_id IN ?", childIDs))
+    }
+
+    var sessions []model.Session
+    if err := query.Order("start_time ASC").Find(&sessions).Error; err != nil {
+        return nil, fmt.Errorf("gagal mencari sesi yang tertinggal: %w", err)
+    }
+
+    now := time.Now()
+    orphans := make([]OrphanedSession, 0, len(sessions))
+    for _, session := range sessions {
+        orphan, err := s.orphanedSession(session, now)
+        if err != nil {
+            return nil, err
+        }
+        orphans = append(orphans, *orphan)
+    }
+    return orphans, nil
+}
+
+// ResumeOrphanedSession keeps a session left open before the given moment running; it is
+// no longer reported as orphaned. Activities still running are left as they are.
+func (s *SessionService) ResumeOrphanedSession(sessionID uint, before time.Time) (*model.Session, error) {
+    session, err := s.getOrphanedSession(sessionID, before)
+    if err != nil {
+        return nil, err
+    }
+    if session.EndTime != nil {
+        return nil, errors.New("sesi sudah berakhir; tutup aktivitas yang masih berjalan")
+    }
+
+    previous := *session
+    now := time.Now()
+    session.ResumedAt = &now
+    err = s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Model(session).Update("resumed_at", now).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionUpdate, AuditEntitySession, session.ID, previous, session)
+        return nil
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal melanjutkan sesi: %w", err)
+    }
+    return session, nil
+}
+
+// CloseOrphanedSession ends a session left open before the given moment at endTime, or
+// at its last recorded activity when endTime is nil, and recomputes its duration.
+// Activities still running are stopped at the same moment. For a session that already
+// ended only its running activities are closed, no later than the session's end.
+// Sessions started or resumed since are ended normally instead.
+func (s *SessionService) CloseOrphanedSession(sessionID uint, before time.Time, endTime *time.Time, author string) (*model.Session, error) {
+    session, err := s.getOrphanedSession(sessionID, before)
+    if err != nil {
+        return nil, err
+    }
+    if err := ensureSessionUnlocked(session); err != nil {
+        return nil, err
+    }
+
+    orphan, err := s.orphanedSession(*session, time.Now())
+    if err != nil {
+        return nil, err
+    }
+
+    closeAt := orphan.LastActivityAt
+    if endTime != nil {
+        if endTime.Before(session.StartTime) {
+            return nil, errors.New("waktu selesai tidak boleh sebelum sesi dimulai")
+        }
+        if endTime.After(time.Now()) {
+            return nil, errors.New("waktu selesai tidak boleh di masa depan")
+        }
+        if endTime.Before(orphan.LastActivityAt) {
+            return nil, fmt.Errorf("waktu selesai tidak boleh sebelum aktivitas terakhir yang tercatat (%s)", orphan.LastActivityAt.Format("02/01/2006 15:04"))
+        }
+        closeAt = *endTime
+    }
+    if session.EndTime != nil && closeAt.After(*session.EndTime) {
+        closeAt = *session.EndTime
+    }
+
+    sessionOpen := session.EndTime == nil
+    requiresReview := false
+    if sessionOpen {
+        if requiresReview, err = sessionRequiresReview(s.db, session); err != nil {
+            return nil, err
+        }
+    }
+
+    previous := *session
+    err = s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        for _, activity := range orphan.OpenActivities {
+            activityBefore := activity
+            stoppedAt := closeAt
+            if activity.StartTime != nil && activity.StartTime.After(stoppedAt) {
+                stoppedAt = *activity.StartTime
+            }
+            activity.EndTime = &stoppedAt
+            if activity.Notes != "" {
+                activity.Notes += " "
+            }
+            activity.Notes += "(Ditutup setelah aplikasi tertutup)"
+            if err := tx.Omit("Session", "Activity").Save(&activity).Error; err != nil {
+                return err
+            }
+            trail.Add(AuditActionUpdate, AuditEntitySessionActivity, activity.ID, activityBefore, activity)
+        }
+        if !sessionOpen {
+            return nil
+        }
+
+        session.EndTime = &closeAt
+        session.DurationMinutes = int(closeAt.Sub(session.StartTime).Minutes())
+        if requiresReview {
+            session.ReviewStatus = ReviewStatusPending
+        }
+        if err := tx.Omit("Child", "Therapist").Save(session).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionUpdate, AuditEntitySession, session.ID, previous, session)
+        if err := consumeSessionPackages(tx, trail, session); err != nil {
+            return err
+        }
+        if def, ok := LookupNoteFormat(session.NoteFormat); ok && def.IsStructured() {
+            if _, err := prefillNoteSections(tx, trail, session.ID, false, author); err != nil {
+                return err
+            }
+        }
+        return nil
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal menutup sesi: %w", err)
+    }
+    return session, nil
+}
+
+// getOrphanedSession loads a session that GetOrphanedSessions would report for the same
+// moment: started before it and, when still open, not resumed since
+func (s *SessionService) getOrphanedSession(sessionID uint, before time.Time) (*model.Session, error) {
+    var session model.Session
+    if err := s.db.Preload("Child").Preload("Therapist").First(&session, sessionID).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("sesi tidak ditemukan")
+        }
+        return nil, fmt.Errorf("gagal mengambil data sesi: %w", err)
+    }
+    if !IsHeldStatus(session.Status) {
+        return nil, ErrSessionNotHeld
+    }
+    if !session.StartTime.Before(before) {
+        return nil, errors.New("sesi dimulai setelah aplikasi dibuka dan tidak tertinggal; akhiri sesi seperti biasa")
+    }
+
+    if session.EndTime == nil {
+        if session.ResumedAt != nil && !session.ResumedAt.Before(before) {
+            return nil, errors.New("sesi sudah dilanjutkan; akhiri sesi seperti biasa")
+        }
+        return &session, nil
+    }
+    var running int64
+    if err := s.db.Model(&model.SessionActivity{}).Where("session_id = ? AND end_time IS NULL", sessionID).Count(&running).Error; err != nil {
+        return nil, fmt.Errorf("gagal memeriksa aktivitas sesi: %w", err)
+    }
+    if running == 0 {
+        return nil, errors.New("sesi sudah berakhir dan tidak ada aktivitas yang masih berjalan")
+    }
+    return &session, nil
+}
+
+// orphanedSession collects the running activities of a session and the last moment
+// anything was recorded in it. A session with nothing recorded falls back to its start.
+func (s *SessionService) orphanedSession(session model.Session, now time.Time) (*OrphanedSession, error) {
+    var activities []model.SessionActivity
+    if err := s.db.Preload("Activity").Where("session_id = ?", session.ID).Order("id ASC").Find(&activities).Error; err != nil {
+        return nil, fmt.Errorf("gagal mengambil aktivitas sesi: %w", err)
+    }
+
+    open := []model.SessionActivity{}
+    for _, activity := range activities {
+        if activity.EndTime == nil {
+            open = append(open, activity)
+        }
+    }
+
+    last := session.StartTime
+    _, recorded, err := recordedSpan(s.db, session.ID)
+    if err != nil {
+        return nil, err
+    }
+    if recorded != nil && recorded.After(last) {
+        last = *recorded
+    }
+    if session.EndTime != nil && last.After(*session.EndTime) {
+        last = *session.EndTime
+    }
+
+    idle := int(now.Sub(last).Minutes())
+    if idle < 0 {
+        idle = 0
+    }
+    return &OrphanedSession{
+        Session:        session,
+        OpenActivities: open,
+        SessionOpen:    session.EndTime == nil,
+        LastActivityAt: last,
+        IdleMinutes:    idle,
+    }, nil
+}
@@ -0,0 +1,256 @@
+package services
+
+import (
+	"childSessions/model"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Session review statuses
+const (
+    ReviewStatusNone             = ""
+    ReviewStatusPending          = "pending"
+    ReviewStatusChangesRequested = "changes_requested"
+    ReviewStatusCosigned         = "cosigned"
+)
+
+// Review comment kinds
+const (
+    ReviewCommentComment          = "comment"
+    ReviewCommentChangesRequested = "changes_requested"
+    ReviewCommentSubmitted        = "submitted"
+    ReviewCommentResubmitted      = "resubmitted"
+    ReviewCommentCosigned         = "cosigned"
+)
+
+// ErrSessionUnderReview is returned when a session awaiting supervisor review is changed or finalised
+var ErrSessionUnderReview = errors.New("sesi masih menunggu review supervisor")
+
+// sessionRequiresReview reports whether the therapist who ran a session is designated for review
+func sessionRequiresReview(db *gorm.DB, session *model.Session) (bool, error) {
+    if session.TherapistID == nil {
+        return false, nil
+    }
+    var therapist model.Therapist
+    if err := db.Select("id", "requires_review").First(&therapist, *session.TherapistID).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return false, nil
+        }
+        return false, fmt.Errorf("gagal mengambil data terapis: %w", err)
+    }
+    return therapist.RequiresReview, nil
+}
+
+// GetReviewQueue lists ended sessions waiting for a supervisor, oldest first. Sessions sent
+// back for changes are included when includeChangesRequested is set.
+func (s *SessionService) GetReviewQueue(includeChangesRequested bool) ([]model.Session, error) {
+    statuses := []string{ReviewStatusPending}
+    if includeChangesRequested {
+        statuses = append(statuses, ReviewStatusChangesRequested)
+    }
+
+    var sessions []model.Session
+    if err := s.db.Preload("Child").Preload("Therapist").
+        Where("review_status IN ?", statuses).
+        Order("end_time ASC").
+        Find(&sessions).Error; err != nil {
+        return nil, fmt.Errorf("gagal mengambil antrean review: %w", err)
+    }
+    return sessions, nil
+}
+
+// GetSessionReviewComments lists the review thread of a session, oldest first
+func (s *SessionService) GetSessionReviewComments(sessionID uint) ([]model.SessionReviewComment, error) {
+    var comments []model.SessionReviewComment
+    if err := s.db.Where("session_id = ?", sessionID).Order("timestamp ASC, id ASC").Find(&comments).Error; err != nil {
+        return nil, fmt.Errorf("gagal mengambil komentar review: %w", err)
+    }
+    return comments, nil
+}
+
+// AddReviewComment adds a supervisor comment to a session under review; noteID targets one note
+func (s *SessionService) AddReviewComment(sessionID uint, noteID *uint, comment, author string) (*model.SessionReviewComment, error) {
+    if strings.TrimSpace(comment) == "" {
+        return nil, errors.New("komentar review harus diisi")
+    }
+
+    session, err := s.getSessionUnderReview(sessionID)
+    if err != nil {
+        return nil, err
+    }
+    if noteID != nil {
+        var count int64
+        if err := s.db.Model(&model.Note{}).Where("id = ? AND session_id = ?", *noteID, session.ID).Count(&count).Error; err != nil {
+            return nil, fmt.Errorf("gagal memeriksa catatan: %w", err)
+        }
+        if count == 0 {
+            return nil, errors.New("catatan tidak ditemukan dalam sesi ini")
+        }
+    }
+
+    entry := &model.SessionReviewComment{
+        SessionID: session.ID,
+        NoteID:    noteID,
+        Kind:      ReviewCommentComment,
+        Comment:   comment,
+        Author:    author,
+        Timestamp: time.Now(),
+    }
+    err = s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Create(entry).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionCreate, AuditEntitySessionReviewComment, entry.ID, nil, entry)
+        return nil
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal menyimpan komentar review: %w", err)
+    }
+    return entry, nil
+}
+
+// RequestSessionChanges sends a pending session back to its therapist with a reason
+func (s *SessionService) RequestSessionChanges(sessionID uint, comment, author string) (*model.Session, error) {
+    if strings.TrimSpace(comment) == "" {
+        return nil, errors.New("jelaskan perubahan yang diminta")
+    }
+    session, err := s.getSessionUnderReview(sessionID)
+    if err != nil {
+        return nil, err
+    }
+    if session.ReviewStatus != ReviewStatusPending {
+        return nil, errors.New("hanya sesi yang menunggu review yang dapat dikembalikan")
+    }
+    return s.changeReviewStatus(session, ReviewStatusChangesRequested, ReviewCommentChangesRequested, comment, author)
+}
+
+// SubmitPastSessionForReview puts a session recorded afterwards in the review queue once
+// its activities, notes and rewards are filled in. Sessions run in the app go there when
+// they end.
+func (s *SessionService) SubmitPastSessionForReview(sessionID uint, comment, author string) (*model.Session, error) {
+    var session model.Session
+    if err := s.db.First(&session, sessionID).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("sesi tidak ditemukan")
+        }
+        return nil, fmt.Errorf("gagal mengambil data sesi: %w", err)
+    }
+    if !session.EnteredAfterwards {
+        return nil, errors.New("hanya sesi yang dicatat setelah berlangsung yang dikirim untuk review secara manual")
+    }
+    if session.FinalizedAt != nil {
+        return nil, ErrSessionFinalized
+    }
+    if session.ReviewStatus != ReviewStatusNone {
+        return nil, errors.New("sesi sudah dikirim untuk direview")
+    }
+    requiresReview, err := sessionRequiresReview(s.db, &session)
+    if err != nil {
+        return nil, err
+    }
+    if !requiresReview {
+        return nil, errors.New("sesi ini tidak memerlukan review")
+    }
+    return s.changeReviewStatus(&session, ReviewStatusPending, ReviewCommentSubmitted, comment, author)
+}
+
+// ResubmitSessionForReview returns a session to the review queue after the requested changes were made
+func (s *SessionService) ResubmitSessionForReview(sessionID uint, comment, author string) (*model.Session, error) {
+    session, err := s.getSessionUnderReview(sessionID)
+    if err != nil {
+        return nil, err
+    }
+    if session.ReviewStatus != ReviewStatusChangesRequested {
+        return nil, errors.New("sesi ini tidak sedang diminta perubahan")
+    }
+    return s.changeReviewStatus(session, ReviewStatusPending, ReviewCommentResubmitted, comment, author)
+}
+
+// CosignSession records a supervisor's co-signature on a pending session; it can then be finalised
+func (s *SessionService) CosignSession(sessionID uint, comment, signer string) (*model.Session, error) {
+    if signer == "" {
+        return nil, errors.New("nama supervisor harus diisi")
+    }
+    session, err := s.getSessionUnderReview(sessionID)
+    if err != nil {
+        return nil, err
+    }
+    if session.ReviewStatus != ReviewStatusPending {
+        return nil, errors.New("hanya sesi yang menunggu review yang dapat ditandatangani bersama")
+    }
+    var openActivities int64
+    if err := s.db.Model(&model.SessionActivity{}).
+        Where("session_id = ? AND end_time IS NULL", sessionID).
+        Count(&openActivities).Error; err != nil {
+        return nil, fmt.Errorf("gagal memeriksa aktivitas sesi: %w", err)
+    }
+    if openActivities > 0 {
+        return nil, errors.New("masih ada aktivitas yang berjalan dalam sesi ini; minta perubahan agar terapis dapat mengakhirinya")
+    }
+    return s.changeReviewStatus(session, ReviewStatusCosigned, ReviewCommentCosigned, comment, signer)
+}
+
+// getSessionUnderReview loads a session that is in the review workflow and not yet finalised
+func (s *SessionService) getSessionUnderReview(sessionID uint) (*model.Session, error) {
+    var session model.Session
+    if err := s.db.First(&session, sessionID).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("sesi tidak ditemukan")
+        }
+        return nil, fmt.Errorf("gagal mengambil data sesi: %w", err)
+    }
+    if session.ReviewStatus == ReviewStatusNone {
+        return nil, errors.New("sesi ini tidak memerlukan review")
+    }
+    if session.ReviewStatus == ReviewStatusCosigned {
+        return nil, errors.New("sesi sudah ditandatangani bersama")
+    }
+    if session.FinalizedAt != nil {
+        return nil, ErrSessionFinalized
+    }
+    return &session, nil
+}
+
+// changeReviewStatus moves a session to a new review status and records the step in its review thread
+func (s *SessionService) changeReviewStatus(session *model.Session, status, kind, comment, author string) (*model.Session, error) {
+    before := *session
+    now := time.Now()
+    updates := map[string]interface{}{"review_status": status}
+    if status == ReviewStatusCosigned {
+        updates["cosigned_at"] = now
+        updates["cosigned_by"] = author
+    }
+
+    entry := &model.SessionReviewComment{
+        SessionID: session.ID,
+        Kind:      kind,
+        Comment:   comment,
+        Author:    author,
+        Timestamp: now,
+    }
+    err := s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Model(session).Updates(updates).Error; err != nil {
+            return err
+        }
+        session.ReviewStatus = status
+        if status == ReviewStatusCosigned {
+            session.CosignedAt = &now
+            session.CosignedBy = author
+        }
+        trail.Add(AuditActionUpdate, AuditEntitySession, session.ID, before, session)
+
+        if err := tx.Create(entry).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionCreate, AuditEntitySessionReviewComment, entry.ID, nil, entry)
+        return nil
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal memperbarui status review: %w", err)
+    }
+    return s.GetSessionByID(session.ID)
+}
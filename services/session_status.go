@@ -0,0 +1,494 @@
+package services
+
+import (
+	"childSessions/model"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Status of a session: booked, held, or not held and why
+const (
+    SessionStatusScheduled       = "scheduled"
+    SessionStatusAttended        = "attended"
+    SessionStatusLate            = "late"
+    SessionStatusCancelledFamily = "cancelled_family"
+    SessionStatusCancelledClinic = "cancelled_clinic"
+    SessionStatusNoShow          = "no_show"
+)
+
+// HeldSessionStatuses are the statuses of sessions that took place
+var HeldSessionStatuses = []string{SessionStatusAttended, SessionStatusLate}
+
+// lateGraceMinutes is how long after the booked time a child may arrive without being marked late
+const lateGraceMinutes = 10
+
+// DefaultNoShowThreshold is how many no-shows in the lookback window flag a family
+const DefaultNoShowThreshold = 2
+
+// AttendanceCounts tallies sessions per status. AttendanceRate and NoShowRate are
+// percentages of the sessions the family was expected at: held sessions, no-shows and
+// family cancellations. Clinic cancellations and sessions still scheduled are left out.
+type AttendanceCounts struct {
+    Scheduled       int     `json:"scheduled"`
+    Attended        int     `json:"attended"`
+    Late            int     `json:"late"`
+    CancelledFamily int     `json:"cancelled_family"`
+    CancelledClinic int     `json:"cancelled_clinic"`
+    NoShow          int     `json:"no_show"`
+    AttendanceRate  float64 `json:"attendance_rate"`
+    NoShowRate      float64 `json:"no_show_rate"`
+}
+
+// MonthlyAttendance is the attendance of one calendar month ("2006-01")
+type MonthlyAttendance struct {
+    Month string `json:"month"`
+    AttendanceCounts
+}
+
+// ChildAttendanceStats is a child's attendance overall and per month, oldest month first
+type ChildAttendanceStats struct {
+    ChildID uint `json:"child_id"`
+    AttendanceCounts
+    Months []MonthlyAttendance `json:"months"`
+}
+
+// NoShowAlert flags a child whose family repeatedly missed sessions
+type NoShowAlert struct {
+    ChildID        uint      `json:"child_id"`
+    ChildName      string    `json:"child_name"`
+    NoShows        int       `json:"no_shows"`
+    LastNoShow     time.Time `json:"last_no_show"`
+    AttendanceRate float64   `json:"attendance_rate"`
+}
+
+// attendanceRecord is one child's appointment: an individual session, or their place in a group session
+type attendanceRecord struct {
+    ChildID   uint
+    StartTime time.Time
+    Status    string
+}
+
+// IsHeldStatus reports whether a session with this status took place
+func IsHeldStatus(status string) bool {
+    return status == SessionStatusAttended || status == SessionStatusLate
+}
+
+// ScheduleSession books a session for a child; it is started later with CheckInSession
+func (s *SessionService) ScheduleSession(childID uint, therapistID *uint, scheduledFor time.Time) (*model.Session, error) {
+    if err := ensureChildExists(s.db, childID); err != nil {
+        return nil, err
+    }
+    if scheduledFor.IsZero() {
+        return nil, errors.New("waktu jadwal harus diisi")
+    }
+
+    session := &model.Session{
+        ChildID:      childID,
+        TherapistID:  therapistID,
+        StartTime:    scheduledFor,
+        ScheduledFor: &scheduledFor,
+        Status:       SessionStatusScheduled,
+    }
+    err := s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Create(session).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionCreate, AuditEntitySession, session.ID, nil, session)
+        return nil
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal menjadwalkan sesi: %w", err)
+    }
+
+    if err := s.db.Preload("Child").Preload("Therapist").First(session, session.ID).Error; err != nil {
+        return nil, fmt.Errorf("gagal memuat data sesi: %w", err)
+    }
+    setSessionAges(session, time.Now())
+    return session, nil
+}
+
+// GetScheduledSessions lists booked sessions that have not started yet, soonest first.
+// childIDs limits the list; nil means every child.
+func (s *SessionService) GetScheduledSessions(from, to time.Time, childIDs []uint) ([]model.Session, error) {
+    query := s.db.Preload("Child").Preload("Therapist").
+        Where("status = ? AND start_time >= ? AND start_time < ?", SessionStatusScheduled, from, to)
+    if childIDs != nil {
+        query = query.Where("child_id IN ?", childIDs)
+    }
+    var sessions []model.Session
+    if err := query.Order("start_time ASC").Find(&sessions).Error; err != nil {
+        return nil, fmt.Errorf("gagal mengambil jadwal sesi: %w", err)
+    }
+    now := time.Now()
+    for i := range sessions {
+        setSessionAges(&sessions[i], now)
+    }
+    return sessions, nil
+}
+
+// CheckInSession starts a scheduled session when the child arrives. Arriving more than
+// lateGraceMinutes after the booked time marks the session late.
+func (s *SessionService) CheckInSession(sessionID uint, therapistID *uint) (*model.Session, error) {
+    var session model.Session
+    if err := s.db.First(&session, sessionID).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("sesi tidak ditemukan")
+        }
+        return nil, fmt.Errorf("gagal mengambil data sesi: %w", err)
+    }
+    if session.Status != SessionStatusScheduled {
+        return nil, errors.New("hanya sesi terjadwal yang dapat dimulai")
+    }
+
+    active, err := activeSessionForChild(s.db, session.ChildID)
+    if err != nil {
+        return nil, err
+    }
+    if active != nil {
+        return nil, errors.New("masih ada sesi aktif untuk anak ini")
+    }
+    warnings, err := s.childStartWarnings(session.ChildID, nil)
+    if err != nil {
+        return nil, err
+    }
+
+    before := session
+    now := time.Now()
+    session.StartTime = now
+    session.Status = SessionStatusAttended
+    if therapistID != nil {
+        session.TherapistID = therapistID
+    }
+    if session.ScheduledFor != nil {
+        if late := int(now.Sub(*session.ScheduledFor).Minutes()); late > lateGraceMinutes {
+            session.Status = SessionStatusLate
+            session.LateMinutes = late
+        }
+    }
+
+    err = s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Save(&session).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionUpdate, AuditEntitySession, session.ID, before, session)
+        return nil
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal memulai sesi terjadwal: %w", err)
+    }
+
+    if err := s.db.Preload("Child").Preload("Therapist").First(&session, session.ID).Error; err != nil {
+        return nil, fmt.Errorf("gagal memuat data sesi: %w", err)
+    }
+    setSessionAges(&session, time.Now())
+    session.Warnings = warnings
+    return &session, nil
+}
+
+// SetSessionStatus records what became of a session. A scheduled session can be
+// cancelled by the family or the clinic, or marked as a no-show; cancellations need a
+// reason. A session that took place can be marked late or back to attended. A cancelled
+// or missed session can be restored to scheduled when it was recorded by mistake.
+func (s *SessionService) SetSessionStatus(sessionID uint, status, reason string, lateMinutes int) (*model.Session, error) {
+    reason = strings.TrimSpace(reason)
+
+    var session model.Session
+    if err := s.db.First(&session, sessionID).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("sesi tidak ditemukan")
+        }
+        return nil, fmt.Errorf("gagal mengambil data sesi: %w", err)
+    }
+    if err := ensureSessionUnlocked(&session); err != nil {
+        return nil, err
+    }
+
+    before := session
+    switch status {
+    case SessionStatusCancelledFamily, SessionStatusCancelledClinic, SessionStatusNoShow:
+        if session.Status != SessionStatusScheduled {
+            return nil, errors.New("hanya sesi terjadwal yang dapat dibatalkan atau ditandai tidak hadir")
+        }
+        if reason == "" && status != SessionStatusNoShow {
+            return nil, errors.New("alasan pembatalan harus diisi")
+        }
+        // Closed without taking place, so it never counts as an active session
+        endTime := session.StartTime
+        session.EndTime = &endTime
+        session.DurationMinutes = 0
+    case SessionStatusLate:
+        if !IsHeldStatus(session.Status) {
+            return nil, errors.New("hanya sesi yang berlangsung yang dapat ditandai terlambat")
+        }
+        if lateMinutes < 0 {
+            return nil, errors.New("menit keterlambatan tidak boleh negatif")
+        }
+        session.LateMinutes = lateMinutes
+    case SessionStatusAttended:
+        if !IsHeldStatus(session.Status) {
+            return nil, errors.New("sesi terjadwal dimulai dengan check-in, bukan dengan mengubah status")
+        }
+        session.LateMinutes = 0
+    case SessionStatusScheduled:
+        if session.Status != SessionStatusCancelledFamily && session.Status != SessionStatusCancelledClinic && session.Status != SessionStatusNoShow {
+            return nil, errors.New("hanya sesi yang dibatalkan atau tidak dihadiri yang dapat dijadwalkan ulang")
+        }
+        session.EndTime = nil
+    default:
+        return nil, fmt.Errorf("status sesi tidak valid: %q", status)
+    }
+    session.Status = status
+    session.StatusReason = reason
+
+    err := s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Save(&session).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionUpdate, AuditEntitySession, session.ID, before, session)
+        return nil
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal mengubah status sesi: %w", err)
+    }
+    return &session, nil
+}
+
+// GetChildAttendanceStats tallies a child's sessions by status, overall and per month,
+// including their place in group sessions. from and to may be zero for no bound.
+func (s *SessionService) GetChildAttendanceStats(childID uint, from, to time.Time) (*ChildAttendanceStats, error) {
+    if err := ensureChildExists(s.db, childID); err != nil {
+        return nil, err
+    }
+    records, err := loadAttendanceRecords(s.db, []uint{childID}, from, to)
+    if err != nil {
+        return nil, err
+    }
+
+    stats := &ChildAttendanceStats{ChildID: childID}
+    months := make(map[string]*MonthlyAttendance)
+    for _, record := range records {
+        stats.add(record.Status)
+        month := record.StartTime.Format("2006-01")
+        if months[month] == nil {
+            months[month] = &MonthlyAttendance{Month: month}
+        }
+        months[month].add(record.Status)
+    }
+    stats.computeRates()
+    stats.Months = sortedMonths(months)
+    return stats, nil
+}
+
+// GetMonthlyAttendance tallies sessions by status per month across children. childIDs
+// limits the tally; nil means every child.
+func (s *SessionService) GetMonthlyAttendance(from, to time.Time, childIDs []uint) ([]MonthlyAttendance, error) {
+    records, err := loadAttendanceRecords(s.db, childIDs, from, to)
+    if err != nil {
+        return nil, err
+    }
+    months := make(map[string]*MonthlyAttendance)
+    for _, record := range records {
+        month := record.StartTime.Format("2006-01")
+        if months[month] == nil {
+            months[month] = &MonthlyAttendance{Month: month}
+        }
+        months[month].add(record.Status)
+    }
+    return sortedMonths(months), nil
+}
+
+// GetRepeatedNoShows lists children with at least threshold no-shows since the given
+// time, most no-shows first. childIDs limits the list; nil means every child.
+func (s *SessionService) GetRepeatedNoShows(since time.Time, threshold int, childIDs []uint) ([]NoShowAlert, error) {
+    if threshold <= 0 {
+        threshold = DefaultNoShowThreshold
+    }
+    records, err := loadAttendanceRecords(s.db, childIDs, since, time.Time{})
+    if err != nil {
+        return nil, err
+    }
+
+    counts := make(map[uint]*AttendanceCounts)
+    lastNoShow := make(map[uint]time.Time)
+    for _, record := range records {
+        if counts[record.ChildID] == nil {
+            counts[record.ChildID] = &AttendanceCounts{}
+        }
+        counts[record.ChildID].add(record.Status)
+        if record.Status == SessionStatusNoShow && record.StartTime.After(lastNoShow[record.ChildID]) {
+            lastNoShow[record.ChildID] = record.StartTime
+        }
+    }
+
+    var flagged []uint
+    for childID, c := range counts {
+        if c.NoShow >= threshold {
+            flagged = append(flagged, childID)
+        }
+    }
+    if len(flagged) == 0 {
+        return []NoShowAlert{}, nil
+    }
+    var children []model.Child
+    if err := s.db.Where("id IN ?", flagged).Find(&children).Error; err != nil {
+        return nil, fmt.Errorf("gagal mengambil data anak: %w", err)
+    }
+
+    alerts := make([]NoShowAlert, 0, len(children))
+    for _, child := range children {
+        c := counts[child.ID]
+        c.computeRates()
+        alerts = append(alerts, NoShowAlert{
+            ChildID:        child.ID,
+            ChildName:      child.Name,
+            NoShows:        c.NoShow,
+            LastNoShow:     lastNoShow[child.ID],
+            AttendanceRate: c.AttendanceRate,
+        })
+    }
+    sort.Slice(alerts, func(i, j int) bool {
+        if alerts[i].NoShows != alerts[j].NoShows {
+            return alerts[i].NoShows > alerts[j].NoShows
+        }
+        return alerts[i].LastNoShow.After(alerts[j].LastNoShow)
+    })
+    return alerts, nil
+}
+
+// loadAttendanceRecords collects each child's appointments. In a group session that took
+// place a child marked absent counts as a no-show and one who came late as late.
+func loadAttendanceRecords(db *gorm.DB, childIDs []uint, from, to time.Time) ([]attendanceRecord, error) {
+    individual := db.Model(&model.Session{}).
+        Select("child_id, start_time, status").
+        Where("is_group = ?", false)
+    group := db.Model(&model.SessionParticipant{}).
+        Select("session_participants.child_id, sessions.start_time, sessions.status, session_participants.attendance").
+        Joins("JOIN sessions ON sessions.id = session_participants.session_id AND sessions.deleted_at IS NULL").
+        Where("sessions.is_group = ?", true)
+    if childIDs != nil {
+        individual = individual.Where("child_id IN ?", childIDs)
+        group = group.Where("session_participants.child_id IN ?", childIDs)
+    }
+    if !from.IsZero() {
+        individual = individual.Where("start_time >= ?", from)
+        group = group.Where("sessions.start_time >= ?", from)
+    }
+    if !to.IsZero() {
+        individual = individual.Where("start_time < ?", to)
+        group = group.Where("sessions.start_time < ?", to)
+    }
+
+    var records []attendanceRecord
+    if err := individual.Scan(&records).Error; err != nil {
+        return nil, fmt.Errorf("gagal mengambil data kehadiran: %w", err)
+    }
+    var participations []struct {
+        ChildID    uint
+        StartTime  time.Time
+        Status     string
+        Attendance string
+    }
+    if err := group.Scan(&participations).Error; err != nil {
+        return nil, fmt.Errorf("gagal mengambil data kehadiran kelompok: %w", err)
+    }
+    for _, p := range participations {
+        record := attendanceRecord{ChildID: p.ChildID, StartTime: p.StartTime, Status: p.Status}
+        if IsHeldStatus(record.Status) {
+            switch p.Attendance {
+            case AttendanceAbsent:
+                record.Status = SessionStatusNoShow
+            case AttendanceLate:
+                record.Status = SessionStatusLate
+            default:
+                record.Status = SessionStatusAttended
+            }
+        }
+        records = append(records, record)
+    }
+    return records, nil
+}
+
+func (c *AttendanceCounts) add(status string) {
+    switch status {
+    case SessionStatusScheduled:
+        c.Scheduled++
+    case SessionStatusAttended:
+        c.Attended++
+    case SessionStatusLate:
+        c.Late++
+    case SessionStatusCancelledFamily:
+        c.CancelledFamily++
+    case SessionStatusCancelledClinic:
+        c.CancelledClinic++
+    case SessionStatusNoShow:
+        c.NoShow++
+    }
+}
+
+func (c *AttendanceCounts) computeRates() {
+    expected := c.Attended + c.Late + c.NoShow + c.CancelledFamily
+    if expected == 0 {
+        c.AttendanceRate, c.NoShowRate = 0, 0
+        return
+    }
+    c.AttendanceRate = math.Round(float64(c.Attended+c.Late)*1000/float64(expected)) / 10
+    c.NoShowRate = math.Round(float64(c.NoShow)*1000/float64(expected)) / 10
+}
+
+func sortedMonths(months map[string]*MonthlyAttendance) []MonthlyAttendance {
+    result := make([]MonthlyAttendance, 0, len(months))
+    for _, m := range months {
+        m.computeRates()
+        result = append(result, *m)
+    }
+    sort.Slice(result, func(i, j int) bool { return result[i].Month < result[j].Month })
+    return result
+}
+
+// ParseSessionDateTime reads a date and time entered for a session: RFC 3339, or
+// "2006-01-02 15:04" in local time
+func ParseSessionDateTime(value string) (time.Time, error) {
+    value = strings.TrimSpace(value)
+    if value == "" {
+        return time.Time{}, errors.New("tanggal dan waktu harus diisi")
+    }
+    if t, err := time.Parse(time.RFC3339, value); err == nil {
+        return t, nil
+    }
+    for _, layout := range []string{"2006-01-02 15:04", "2006-01-02T15:04"} {
+        if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+            return t, nil
+        }
+    }
+    return time.Time{}, fmt.Errorf("format tanggal dan waktu tidak valid: %s (gunakan YYYY-MM-DD JJ:MM)", value)
+}
+
+// ParseDateRange reads an inclusive calendar date range; either end may be empty for no
+// bound. The returned end is the start of the day after to.
+func ParseDateRange(from, to string) (time.Time, time.Time, error) {
+    var start, end time.Time
+    fromDate, err := ParseCalendarDate(from)
+    if err != nil {
+        return start, end, err
+    }
+    toDate, err := ParseCalendarDate(to)
+    if err != nil {
+        return start, end, err
+    }
+    if fromDate != nil {
+        start = startOfDay(*fromDate)
+    }
+    if toDate != nil {
+        end = startOfDay(*toDate).AddDate(0, 0, 1)
+    }
+    if !start.IsZero() && !end.IsZero() && !end.After(start) {
+        return start, end, errors.New("tanggal akhir tidak boleh sebelum tanggal awal")
+    }
+    return start, end, nil
+}
@@ -0,0 +1,431 @@
+package services
+
+import (
+	"childSessions/model"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Reward rule triggers
+const (
+    RewardTriggerActivityCompleted = "activity_completed"
+    RewardTriggerSessionCompleted  = "session_completed"
+    RewardTriggerFlashcardResponse = "flashcard_response"
+)
+
+// SessionTemplateInput describes the content of a template version
+type SessionTemplateInput struct {
+    Name            string                    `json:"name"`
+    Description     string                    `json:"description"`
+    Programme       string                    `json:"programme"`
+    ChildID         *uint                     `json:"child_id"` // Set for a template tailored to one child
+    IsShared        bool                      `json:"is_shared"`
+    ChangeNote      string                    `json:"change_note"`
+    Activities      []TemplateActivityInput   `json:"activities"`
+    NoteTemplateIDs []uint                    `json:"note_template_ids"`
+    FlashcardDecks  []string                  `json:"flashcard_decks"`
+    RewardRules     []TemplateRewardRuleInput `json:"reward_rules"`
+}
+
+// TemplateActivityInput is one activity of a template, in running order
+type TemplateActivityInput struct {
+    ActivityID    uint   `json:"activity_id"`
+    TargetMinutes int    `json:"target_minutes"` // 0 uses the activity's default duration
+    Objective     string `json:"objective"`
+    Materials     string `json:"materials"`
+    Notes         string `json:"notes"`
+}
+
+// TemplateRewardRuleInput is one reward rule of a template
+type TemplateRewardRuleInput struct {
+    Trigger     string `json:"trigger"`
+    RewardType  string `json:"reward_type"`
+    Value       int    `json:"value"`
+    Description string `json:"description"`
+}
+
+// TemplateViewer is who is looking at templates: their own and shared templates are
+// visible, and child templates only for children in ChildIDs (nil means every child).
+// SeeAll also allows editing templates owned by others.
+type TemplateViewer struct {
+    TherapistID *uint
+    SeeAll      bool
+    ChildIDs    []uint
+}
+
+// PlanResources are the note templates, flashcard decks and reward rules of the
+// template a plan was cloned from
+type PlanResources struct {
+    Template       *model.SessionTemplate `json:"template"`
+    FlashcardDecks []string               `json:"flashcard_decks"`
+}
+
+type SessionTemplateService struct {
+    db    *gorm.DB
+    audit *AuditService
+}
+
+func NewSessionTemplateService(db *gorm.DB, audit *AuditService) *SessionTemplateService {
+    return &SessionTemplateService{db: db, audit: audit}
+}
+
+// CreateTemplate saves the first version of a new template owned by the viewer
+func (s *SessionTemplateService) CreateTemplate(input SessionTemplateInput, viewer TemplateViewer, createdBy string) (*model.SessionTemplate, error) {
+    key, err := newTemplateKey()
+    if err != nil {
+        return nil, err
+    }
+    template := &model.SessionTemplate{
+        TemplateKey:      key,
+        Version:          1,
+        OwnerTherapistID: viewer.TherapistID,
+        CreatedBy:        createdBy,
+    }
+    return s.saveVersion(template, input, nil)
+}
+
+// UpdateTemplate saves input as a new version of the template; earlier versions and the
+// plans cloned from them are left unchanged
+func (s *SessionTemplateService) UpdateTemplate(templateID uint, input SessionTemplateInput, viewer TemplateViewer, createdBy string) (*model.SessionTemplate, error) {
+    current, err := s.GetTemplate(templateID)
+    if err != nil {
+        return nil, err
+    }
+    if current.DeletedAt.Valid {
+        return nil, errors.New("templat sesi sudah dihapus")
+    }
+    if err := canEditTemplate(current, viewer); err != nil {
+        return nil, err
+    }
+
+    var latest int
+    if err := s.db.Unscoped().Model(&model.SessionTemplate{}).
+        Where("template_key = ?", current.TemplateKey).
+        Select("COALESCE(MAX(version), 0)").Scan(&latest).Error; err != nil {
+        return nil, fmt.Errorf("gagal mengambil versi templat: %w", err)
+    }
+    template := &model.SessionTemplate{
+        TemplateKey:      current.TemplateKey,
+        Version:          latest + 1,
+        OwnerTherapistID: current.OwnerTherapistID,
+        CreatedBy:        createdBy,
+    }
+    return s.saveVersion(template, input, current)
+}
+
+func (s *SessionTemplateService) saveVersion(template *model.SessionTemplate, input SessionTemplateInput, previous *model.SessionTemplate) (*model.SessionTemplate, error) {
+    name := strings.TrimSpace(input.Name)
+    if name == "" {
+        return nil, errors.New("nama templat harus diisi")
+    }
+    if len(input.Activities) == 0 {
+        return nil, errors.New("templat harus memiliki minimal satu aktivitas")
+    }
+    if input.ChildID != nil {
+        if err := ensureChildExists(s.db, *input.ChildID); err != nil {
+            return nil, err
+        }
+    }
+    template.Name = name
+    template.Description = strings.TrimSpace(input.Description)
+    template.Programme = strings.TrimSpace(input.Programme)
+    template.ChildID = input.ChildID
+    template.IsShared = input.IsShared
+    template.ChangeNote = strings.TrimSpace(input.ChangeNote)
+
+    var decks []string
+    for _, deck := range input.FlashcardDecks {
+        if deck = strings.TrimSpace(deck); deck != "" {
+            decks = append(decks, deck)
+        }
+    }
+    template.FlashcardDecks = strings.Join(decks, ",")
+
+    activities := make([]model.SessionTemplateActivity, 0, len(input.Activities))
+    for i, activityInput := range input.Activities {
+        var activity model.Activity
+        if err := s.db.First(&activity, activityInput.ActivityID).Error; err != nil {
+            if errors.Is(err, gorm.ErrRecordNotFound) {
+                return nil, fmt.Errorf("aktivitas ke-%d tidak ditemukan", i+1)
+            }
+            return nil, fmt.Errorf("gagal mengambil aktivitas: %w", err)
+        }
+        if activityInput.TargetMinutes < 0 {
+            return nil, fmt.Errorf("durasi target aktivitas ke-%d tidak boleh negatif", i+1)
+        }
+        targetMinutes := activityInput.TargetMinutes
+        if targetMinutes == 0 {
+            targetMinutes = activity.DefaultDurationMinutes
+        }
+        activities = append(activities, model.SessionTemplateActivity{
+            Position:      i + 1,
+            ActivityID:    activity.ID,
+            TargetMinutes: targetMinutes,
+            Objective:     strings.TrimSpace(activityInput.Objective),
+            Materials:     strings.TrimSpace(activityInput.Materials),
+            Notes:         strings.TrimSpace(activityInput.Notes),
+        })
+    }
+
+    rules := make([]model.SessionTemplateRewardRule, 0, len(input.RewardRules))
+    for i, ruleInput := range input.RewardRules {
+        switch ruleInput.Trigger {
+        case RewardTriggerActivityCompleted, RewardTriggerSessionCompleted, RewardTriggerFlashcardResponse:
+        default:
+            return nil, fmt.Errorf("pemicu aturan reward ke-%d tidak dikenal: %q", i+1, ruleInput.Trigger)
+        }
+        if strings.TrimSpace(ruleInput.RewardType) == "" || ruleInput.Value <= 0 {
+            return nil, fmt.Errorf("aturan reward ke-%d harus memiliki jenis dan nilai lebih dari 0", i+1)
+        }
+        rules = append(rules, model.SessionTemplateRewardRule{
+            Trigger:     ruleInput.Trigger,
+            RewardType:  strings.TrimSpace(ruleInput.RewardType),
+            Value:       ruleInput.Value,
+            Description: strings.TrimSpace(ruleInput.Description),
+        })
+    }
+
+    var noteTemplates []model.NoteTemplate
+    if len(input.NoteTemplateIDs) > 0 {
+        if err := s.db.Where("id IN ?", input.NoteTemplateIDs).Find(&noteTemplates).Error; err != nil {
+            return nil, fmt.Errorf("gagal mengambil templat catatan: %w", err)
+        }
+        if len(noteTemplates) != len(uniqueIDs(input.NoteTemplateIDs)) {
+            return nil, errors.New("templat catatan tidak ditemukan")
+        }
+    }
+
+    err := s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Omit("Activities", "RewardRules", "NoteTemplates").Create(template).Error; err != nil {
+            return err
+        }
+        for i := range activities {
+            activities[i].TemplateID = template.ID
+            if err := tx.Omit("Activity").Create(&activities[i]).Error; err != nil {
+                return err
+            }
+        }
+        for i := range rules {
+            rules[i].TemplateID = template.ID
+            if err := tx.Create(&rules[i]).Error; err != nil {
+                return err
+            }
+        }
+        if len(noteTemplates) > 0 {
+            if err := tx.Model(template).Omit("NoteTemplates.*").Association("NoteTemplates").Append(noteTemplates); err != nil {
+                return err
+            }
+        }
+        template.Activities = activities
+        template.RewardRules = rules
+        template.NoteTemplates = noteTemplates
+        trail.Add(AuditActionCreate, AuditEntitySessionTemplate, template.ID, previous, template)
+        return nil
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal menyimpan templat sesi: %w", err)
+    }
+    return s.GetTemplate(template.ID)
+}
+
+// GetTemplate retrieves one template version with its content, including deleted
+// versions that plans were cloned from
+func (s *SessionTemplateService) GetTemplate(templateID uint) (*model.SessionTemplate, error) {
+    var template model.SessionTemplate
+    err := s.db.Unscoped().
+        Preload("Activities", func(db *gorm.DB) *gorm.DB {
+            return db.Order("position ASC")
+        }).
+        Preload("Activities.Activity").
+        Preload("RewardRules").
+        Preload("NoteTemplates").
+        First(&template, templateID).Error
+    if err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("templat sesi tidak ditemukan")
+        }
+        return nil, fmt.Errorf("gagal mengambil templat sesi: %w", err)
+    }
+    return &template, nil
+}
+
+// GetTemplates lists the latest version of every template the viewer may see,
+// optionally for one programme
+func (s *SessionTemplateService) GetTemplates(viewer TemplateViewer, programme string) ([]model.SessionTemplate, error) {
+    latest := s.db.Model(&model.SessionTemplate{}).Select("MAX(id)").Group("template_key")
+    query := s.db.Preload("Activities", func(db *gorm.DB) *gorm.DB {
+        return db.Order("position ASC")
+    }).Preload("Activities.Activity").Preload("RewardRules").Preload("NoteTemplates").
+        Where("id IN (?)", latest).
+        Order("programme ASC, name ASC")
+    if programme != "" {
+        query = query.Where("programme = ?", programme)
+    }
+    var templates []model.SessionTemplate
+    if err := query.Find(&templates).Error; err != nil {
+        return nil, fmt.Errorf("gagal mengambil templat sesi: %w", err)
+    }
+
+    visible := templates[:0]
+    for _, template := range templates {
+        if CanViewTemplate(&template, viewer) {
+            visible = append(visible, template)
+        }
+    }
+    return visible, nil
+}
+
+// GetTemplateVersions lists every version of a template, newest first
+func (s *SessionTemplateService) GetTemplateVersions(templateID uint) ([]model.SessionTemplate, error) {
+    template, err := s.GetTemplate(templateID)
+    if err != nil {
+        return nil, err
+    }
+    var versions []model.SessionTemplate
+    if err := s.db.Preload("Activities", func(db *gorm.DB) *gorm.DB {
+        return db.Order("position ASC")
+    }).Preload("Activities.Activity").Preload("RewardRules").Preload("NoteTemplates").
+        Where("template_key = ?", template.TemplateKey).
+        Order("version DESC").
+        Find(&versions).Error; err != nil {
+        return nil, fmt.Errorf("gagal mengambil versi templat: %w", err)
+    }
+    return versions, nil
+}
+
+// SetTemplateShared shares every version of a template with all therapists, or makes it private again
+func (s *SessionTemplateService) SetTemplateShared(templateID uint, shared bool, viewer TemplateViewer) error {
+    template, err := s.GetTemplate(templateID)
+    if err != nil {
+        return err
+    }
+    if template.DeletedAt.Valid {
+        return errors.New("templat sesi sudah dihapus")
+    }
+    if err := canEditTemplate(template, viewer); err != nil {
+        return err
+    }
+
+    err = s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Model(&model.SessionTemplate{}).Where("template_key = ?", template.TemplateKey).Update("is_shared", shared).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionUpdate, AuditEntitySessionTemplate, template.ID,
+            map[string]interface{}{"template_key": template.TemplateKey, "is_shared": template.IsShared},
+            map[string]interface{}{"template_key": template.TemplateKey, "is_shared": shared})
+        return nil
+    })
+    if err != nil {
+        return fmt.Errorf("gagal mengubah berbagi templat: %w", err)
+    }
+    return nil
+}
+
+// DeleteTemplate removes every version of a template (soft delete). Plans already cloned
+// from it keep their activities and resources.
+func (s *SessionTemplateService) DeleteTemplate(templateID uint, viewer TemplateViewer) (*model.SessionTemplate, error) {
+    template, err := s.GetTemplate(templateID)
+    if err != nil {
+        return nil, err
+    }
+    if err := canEditTemplate(template, viewer); err != nil {
+        return nil, err
+    }
+
+    err = s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Where("template_key = ?", template.TemplateKey).Delete(&model.SessionTemplate{}).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionDelete, AuditEntitySessionTemplate, template.ID, template, nil)
+        return nil
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal menghapus templat sesi: %w", err)
+    }
+    return template, nil
+}
+
+// GetPlanResources returns the note templates, flashcard decks and reward rules of the
+// template a plan was cloned from; nil when the plan was not cloned from a template
+func (s *SessionTemplateService) GetPlanResources(planID uint) (*PlanResources, error) {
+    var plan model.SessionPlan
+    if err := s.db.First(&plan, planID).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("rencana sesi tidak ditemukan")
+        }
+        return nil, fmt.Errorf("gagal mengambil rencana sesi: %w", err)
+    }
+    if plan.TemplateID == nil {
+        return nil, nil
+    }
+    template, err := s.GetTemplate(*plan.TemplateID)
+    if err != nil {
+        return nil, err
+    }
+    return &PlanResources{Template: template, FlashcardDecks: TemplateFlashcardDecks(template)}, nil
+}
+
+// TemplateFlashcardDecks splits a template's flashcard categories
+func TemplateFlashcardDecks(template *model.SessionTemplate) []string {
+    decks := make([]string, 0)
+    for _, deck := range strings.Split(template.FlashcardDecks, ",") {
+        if deck = strings.TrimSpace(deck); deck != "" {
+            decks = append(decks, deck)
+        }
+    }
+    return decks
+}
+
+// CanViewTemplate reports whether the viewer may see and clone a template
+func CanViewTemplate(template *model.SessionTemplate, viewer TemplateViewer) bool {
+    if template.ChildID != nil && viewer.ChildIDs != nil {
+        inCaseload := false
+        for _, id := range viewer.ChildIDs {
+            inCaseload = inCaseload || id == *template.ChildID
+        }
+        if !inCaseload {
+            return false
+        }
+    }
+    return viewer.SeeAll || template.IsShared || isTemplateOwner(template, viewer)
+}
+
+func canEditTemplate(template *model.SessionTemplate, viewer TemplateViewer) error {
+    if viewer.SeeAll || isTemplateOwner(template, viewer) {
+        return nil
+    }
+    return errors.New("hanya pembuat templat yang dapat mengubahnya")
+}
+
+// isTemplateOwner is true for templates created before accounts existed or in single-user mode
+func isTemplateOwner(template *model.SessionTemplate, viewer TemplateViewer) bool {
+    if template.OwnerTherapistID == nil || viewer.TherapistID == nil {
+        return true
+    }
+    return *template.OwnerTherapistID == *viewer.TherapistID
+}
+
+func newTemplateKey() (string, error) {
+    buf := make([]byte, 8)
+    if _, err := rand.Read(buf); err != nil {
+        return "", fmt.Errorf("gagal membuat kunci templat: %w", err)
+    }
+    return hex.EncodeToString(buf), nil
+}
+
+func uniqueIDs(ids []uint) []uint {
+    seen := make(map[uint]bool, len(ids))
+    unique := make([]uint, 0, len(ids))
+    for _, id := range ids {
+        if !seen[id] {
+            seen[id] = true
+            unique = append(unique, id)
+        }
+    }
+    return unique
+}
@@ -10,15 +10,17 @@ import (
 )
 
 type SessionService struct {
-    db *gorm.DB
+    db    *gorm.DB
+    audit *AuditService
 }
 
-func NewSessionService(db *gorm.DB) *SessionService {
-    return &SessionService{db: db}
+func NewSessionService(db *gorm.DB, audit *AuditService) *SessionService {
+    return &SessionService{db: db, audit: audit}
 }
 
-// StartSession creates a new session for a child
-func (s *SessionService) StartSession(childID uint) (*model.Session, error) {
+// StartSession creates a new session for a child, run by therapistID when accounts are in use.
+// planID optionally attaches a prepared session plan.
+func (s *SessionService) StartSession(childID uint, therapistID *uint, planID *uint) (*model.Session, error) {
     // Check if child exists
     var child model.Child
     if err := s.db.First(&child, childID).Error; err != nil {
@@ -28,35 +30,74 @@ func (s *SessionService) StartSession(childID uint) (*model.Session, error) {
         return nil, fmt.Errorf("gagal mengambil data anak: %w", err)
     }
 
-    // Check if there's an active session for this child
-    var activeSession model.Session
-    err := s.db.Where("child_id = ? AND end_time IS NULL", childID).First(&activeSession).Error
-    if err == nil {
+    // Check if there's an active session for this child, including group sessions
+    activeSession, err := activeSessionForChild(s.db, childID)
+    if err != nil {
+        return nil, err
+    }
+    if activeSession != nil {
         return nil, errors.New("masih ada sesi aktif untuk anak ini")
     }
-    if !errors.Is(err, gorm.ErrRecordNotFound) {
-        return nil, fmt.Errorf("gagal memeriksa sesi aktif: %w", err)
+
+    // Without a plan no activities are known yet, so every allergy and sensory trigger is shown
+    var plan *model.SessionPlan
+    var plannedActivities []model.Activity
+    if planID != nil {
+        plan, err = loadPlanForSession(s.db, *planID, childID)
+        if err != nil {
+            return nil, err
+        }
+        for _, item := range plan.Items {
+            plannedActivities = append(plannedActivities, item.Activity)
+        }
+    }
+    warnings, err := s.childStartWarnings(childID, plannedActivities)
+    if err != nil {
+        return nil, err
     }
 
     session := &model.Session{
-        ChildID:   childID,
-        StartTime: time.Now(),
+        ChildID:     childID,
+        TherapistID: therapistID,
+        StartTime:   time.Now(),
+        Status:      SessionStatusAttended,
     }
 
-    if err := s.db.Create(session).Error; err != nil {
+    err = s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Create(session).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionCreate, AuditEntitySession, session.ID, nil, session)
+        if plan == nil {
+            return nil
+        }
+        // Guard against the plan being attached by another session in the meantime
+        result := tx.Model(&model.SessionPlan{}).Where("id = ? AND session_id IS NULL", plan.ID).Update("session_id", session.ID)
+        if result.Error != nil {
+            return result.Error
+        }
+        if result.RowsAffected == 0 {
+            return errors.New("rencana sesi sudah dipakai dalam sesi lain")
+        }
+        trail.Add(AuditActionUpdate, AuditEntitySessionPlan, plan.ID, map[string]interface{}{"session_id": nil}, map[string]interface{}{"session_id": session.ID})
+        return nil
+    })
+    if err != nil {
         return nil, fmt.Errorf("gagal membuat sesi: %w", err)
     }
 
     // Load the child relationship
-    if err := s.db.Preload("Child").First(session, session.ID).Error; err != nil {
+    if err := s.db.Preload("Child").Preload("Therapist").First(session, session.ID).Error; err != nil {
         return nil, fmt.Errorf("gagal memuat data sesi: %w", err)
     }
 
+    setSessionAges(session, time.Now())
+    session.Warnings = warnings
     return session, nil
 }
 
-// EndSession ends an active session
-func (s *SessionService) EndSession(sessionID uint, summaryNotes string) (*model.Session, error) {
+// EndSession ends an active session and stops the activities still running in it
+func (s *SessionService) EndSession(sessionID uint, summaryNotes, author string) (*model.Session, error) {
     var session model.Session
     if err := s.db.First(&session, sessionID).Error; err != nil {
         if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -68,50 +109,161 @@ func (s *SessionService) EndSession(sessionID uint, summaryNotes string) (*model
     if session.EndTime != nil {
         return nil, errors.New("sesi sudah berakhir")
     }
+    if session.Status == SessionStatusScheduled {
+        return nil, ErrSessionNotStarted
+    }
 
+    // Sessions run by therapists designated for supervision wait for a co-signature
+    requiresReview, err := sessionRequiresReview(s.db, &session)
+    if err != nil {
+        return nil, err
+    }
+
+    before := session
     endTime := time.Now()
     session.EndTime = &endTime
     session.DurationMinutes = int(endTime.Sub(session.StartTime).Minutes())
     session.SummaryNotes = summaryNotes
+    if requiresReview {
+        session.ReviewStatus = ReviewStatusPending
+    }
 
-    if err := s.db.Save(&session).Error; err != nil {
+    err = s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Save(&session).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionUpdate, AuditEntitySession, session.ID, before, session)
+        // Activities still running end with the session; once it is locked for review
+        // they could not be stopped any more
+        if err := stopOpenActivities(tx, trail, session.ID, endTime); err != nil {
+            return err
+        }
+        if err := consumeSessionPackages(tx, trail, &session); err != nil {
+            return err
+        }
+        if summaryNotes != "" {
+            if err := recordRevision(tx, RevisionEntitySessionSummary, session.ID, session.ID, RevisionActionCreated, summaryNotes, "", author, ""); err != nil {
+                return err
+            }
+        }
+        // Prefill empty structured note sections with what was captured during the session
+        if def, ok := LookupNoteFormat(session.NoteFormat); ok && def.IsStructured() {
+            if _, err := prefillNoteSections(tx, trail, session.ID, false, author); err != nil {
+                return err
+            }
+        }
+        return nil
+    })
+    if err != nil {
         return nil, fmt.Errorf("gagal mengakhiri sesi: %w", err)
     }
 
     return &session, nil
 }
 
-// GetActiveSession gets the active session for a child
+// stopOpenActivities ends the activities of a session that are still running at the
+// given moment, or at their start when they started later
+func stopOpenActivities(tx *gorm.DB, trail *AuditTrail, sessionID uint, at time.Time) error {
+    var open []model.SessionActivity
+    if err := tx.Where("session_id = ? AND end_time IS NULL", sessionID).Find(&open).Error; err != nil {
+        return err
+    }
+    for _, activity := range open {
+        before := activity
+        stoppedAt := at
+        if activity.StartTime != nil && activity.StartTime.After(stoppedAt) {
+            stoppedAt = *activity.StartTime
+        }
+        activity.EndTime = &stoppedAt
+        if err := tx.Model(&activity).Update("end_time", stoppedAt).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionUpdate, AuditEntitySessionActivity, activity.ID, before, activity)
+    }
+    return nil
+}
+
+// UpdateSessionSummaryNotes rewrites the summary notes of a session and records the amendment
+func (s *SessionService) UpdateSessionSummaryNotes(sessionID uint, summaryNotes, author, reason string) (*model.Session, error) {
+    var session model.Session
+    if err := s.db.First(&session, sessionID).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("sesi tidak ditemukan")
+        }
+        return nil, fmt.Errorf("gagal mengambil data sesi: %w", err)
+    }
+
+    if err := ensureSessionUnlocked(&session); err != nil {
+        return nil, err
+    }
+
+    before := session
+    session.SummaryNotes = summaryNotes
+
+    err := s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Model(&session).Update("summary_notes", summaryNotes).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionUpdate, AuditEntitySession, session.ID, before, session)
+        return recordRevision(tx, RevisionEntitySessionSummary, session.ID, session.ID, RevisionActionUpdated, summaryNotes, "", author, reason)
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal memperbarui catatan ringkasan: %w", err)
+    }
+
+    return &session, nil
+}
+
+// GetActiveSession gets the active session for a child, which may be a group session
 func (s *SessionService) GetActiveSession(childID uint) (*model.Session, error) {
     var session model.Session
-    err := s.db.Preload("Child").Where("child_id = ? AND end_time IS NULL", childID).First(&session).Error
+    err := s.db.Preload("Child").Preload("Participants.Child").
+        Where("end_time IS NULL AND status IN ? AND id IN (?)", HeldSessionStatuses, ChildSessionIDs(s.db, childID)).First(&session).Error
     if err != nil {
         if errors.Is(err, gorm.ErrRecordNotFound) {
             return nil, nil // No active session
         }
         return nil, fmt.Errorf("gagal mengambil sesi aktif: %w", err)
     }
+    setSessionAges(&session, time.Now())
     return &session, nil
 }
 
-// GetSessionsByChild gets all sessions for a child
+// GetSessionsByChild gets all sessions for a child, group sessions included, each with
+// the child's age at the time
 func (s *SessionService) GetSessionsByChild(childID uint) ([]model.Session, error) {
     var sessions []model.Session
-    if err := s.db.Where("child_id = ?", childID).Order("start_time DESC").Find(&sessions).Error; err != nil {
+    if err := s.db.Preload("Child").Preload("Participants.Child").Where("id IN (?)", ChildSessionIDs(s.db, childID)).Order("start_time DESC").Find(&sessions).Error; err != nil {
         return nil, fmt.Errorf("gagal mengambil riwayat sesi: %w", err)
     }
+    now := time.Now()
+    for i := range sessions {
+        setSessionAges(&sessions[i], now)
+    }
     return sessions, nil
 }
 
 // GetSessionByID gets a session by ID
 func (s *SessionService) GetSessionByID(sessionID uint) (*model.Session, error) {
     var session model.Session
-    if err := s.db.Preload("Child").Preload("Notes").Preload("SessionActivities.Activity").First(&session, sessionID).Error; err != nil {
+    if err := s.db.Preload("Child").Preload("Therapist").Preload("Notes").Preload("SessionActivities.Activity").
+        Preload("Participants.Child").
+        Preload("NoteSections", func(db *gorm.DB) *gorm.DB {
+            return db.Order("sort_order ASC")
+        }).
+        Preload("Addenda", func(db *gorm.DB) *gorm.DB {
+            return db.Order("timestamp ASC")
+        }).
+        Preload("ReviewComments", func(db *gorm.DB) *gorm.DB {
+            return db.Order("timestamp ASC, id ASC")
+        }).
+        First(&session, sessionID).Error; err != nil {
         if errors.Is(err, gorm.ErrRecordNotFound) {
             return nil, errors.New("sesi tidak ditemukan")
         }
         return nil, fmt.Errorf("gagal mengambil data sesi: %w", err)
     }
+    setSessionAges(&session, time.Now())
     return &session, nil
 }
 
@@ -121,7 +273,7 @@ func (s *SessionService) GetSessionActivityHistoryByChild(childID uint) ([]model
         Joins("JOIN sessions ON sessions.id = session_activities.session_id").
         Preload("Activity").
         Preload("Session").
-        Where("sessions.child_id = ?", childID).
+        Where("sessions.id IN (?)", AttendedSessionIDs(s.db, childID)).
         Order("sessions.start_time DESC, session_activities.start_time DESC").
         Find(&activities).Error
     if err != nil {
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"childSessions/model"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"gorm.io/gorm"
+)
+
+// Setting keys
+const (
+    SettingTrashRetentionDays = "trash_retention_days"
+)
+
+type SettingService struct {
+    db *gorm.DB
+}
+
+func NewSettingService(db *gorm.DB) *SettingService {
+    return &SettingService{db: db}
+}
+
+// Get returns the value of a setting, or defaultValue when it has not been set
+func (s *SettingService) Get(key, defaultValue string) (string, error) {
+    var setting model.AppSetting
+    if err := s.db.Where("key = ?", key).First(&setting).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return defaultValue, nil
+        }
+        return "", fmt.Errorf("gagal mengambil pengaturan %s: %w", key, err)
+    }
+    return setting.Value, nil
+}
+
+// Set stores the value of a setting
+func (s *SettingService) Set(key, value string) error {
+    setting := model.AppSetting{Key: key}
+    if err := s.db.Where("key = ?", key).
+        Assign(model.AppSetting{Value: value}).
+        FirstOrCreate(&setting).Error; err != nil {
+        return fmt.Errorf("gagal menyimpan pengaturan %s: %w", key, err)
+    }
+    return nil
+}
+
+// GetInt returns an integer setting, or defaultValue when it is unset or invalid
+func (s *SettingService) GetInt(key string, defaultValue int) (int, error) {
+    value, err := s.Get(key, "")
+    if err != nil {
+        return defaultValue, err
+    }
+    if value == "" {
+        return defaultValue, nil
+    }
+    n, err := strconv.Atoi(value)
+    if err != nil {
+        return defaultValue, nil
+    }
+    return n, nil
+}
+
+// SetInt stores an integer setting
+func (s *SettingService) SetInt(key string, value int) error {
+    return s.Set(key, strconv.Itoa(value))
+}
+
+// Delete removes a setting so it falls back to its default again. The row is deleted
+// outright, so the key can be set again later.
+func (s *SettingService) Delete(key string) error {
+    if err := s.db.Unscoped().Where("key = ?", key).Delete(&model.AppSetting{}).Error; err != nil {
+        return fmt.Errorf("gagal menghapus pengaturan %s: %w", key, err)
+    }
+    return nil
+}
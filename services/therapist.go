@@ -0,0 +1,360 @@
+package services
+
+import (
+	"childSessions/model"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/pbkdf2"
+	"gorm.io/gorm"
+)
+
+// Therapist roles
+const (
+    RoleTherapist  = "therapist"
+    RoleSupervisor = "supervisor"
+    RoleFrontDesk  = "front_desk"
+    RoleAdmin      = "admin"
+)
+
+const (
+    passwordHashIterations = 210000
+    minPasswordLength      = 8
+)
+
+// ErrInvalidCredentials is returned when a username and password do not match an active account
+var ErrInvalidCredentials = errors.New("nama pengguna atau kata sandi salah")
+
+type TherapistService struct {
+    db    *gorm.DB
+    audit *AuditService
+}
+
+func NewTherapistService(db *gorm.DB, audit *AuditService) *TherapistService {
+    return &TherapistService{db: db, audit: audit}
+}
+
+// IsValidRole reports whether role is a known therapist role
+func IsValidRole(role string) bool {
+    switch role {
+    case RoleTherapist, RoleSupervisor, RoleFrontDesk, RoleAdmin:
+        return true
+    }
+    return false
+}
+
+// HasTherapists reports whether any account exists; without accounts the app runs in single-user mode
+func (s *TherapistService) HasTherapists() (bool, error) {
+    var count int64
+    if err := s.db.Model(&model.Therapist{}).Count(&count).Error; err != nil {
+        return false, fmt.Errorf("gagal memeriksa akun terapis: %w", err)
+    }
+    return count > 0, nil
+}
+
+// CreateTherapist creates an account. The first account is always an admin so the
+// installation cannot end up without someone able to manage accounts.
+func (s *TherapistService) CreateTherapist(username, name, password, role string) (*model.Therapist, error) {
+    username = strings.ToLower(strings.TrimSpace(username))
+    name = strings.TrimSpace(name)
+    if username == "" {
+        return nil, errors.New("nama pengguna harus diisi")
+    }
+    if name == "" {
+        return nil, errors.New("nama terapis harus diisi")
+    }
+    if role == "" {
+        role = RoleTherapist
+    }
+    if !IsValidRole(role) {
+        return nil, fmt.Errorf("peran tidak dikenal: %s", role)
+    }
+
+    exists, err := s.HasTherapists()
+    if err != nil {
+        return nil, err
+    }
+    if !exists {
+        role = RoleAdmin
+    }
+
+    hash, err := hashPassword(password)
+    if err != nil {
+        return nil, err
+    }
+
+    var existing int64
+    if err := s.db.Unscoped().Model(&model.Therapist{}).Where("username = ?", username).Count(&existing).Error; err != nil {
+        return nil, fmt.Errorf("gagal memeriksa nama pengguna: %w", err)
+    }
+    if existing > 0 {
+        return nil, errors.New("nama pengguna sudah digunakan")
+    }
+
+    therapist := &model.Therapist{
+        Username:     username,
+        Name:         name,
+        PasswordHash: hash,
+        Role:         role,
+        IsActive:     true,
+    }
+    err = s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Create(therapist).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionCreate, AuditEntityTherapist, therapist.ID, nil, therapist)
+        return nil
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal membuat akun terapis: %w", err)
+    }
+    return therapist, nil
+}
+
+// GetAllTherapists lists all accounts, including deactivated ones
+func (s *TherapistService) GetAllTherapists() ([]model.Therapist, error) {
+    var therapists []model.Therapist
+    if err := s.db.Order("name ASC").Find(&therapists).Error; err != nil {
+        return nil, fmt.Errorf("gagal mengambil data terapis: %w", err)
+    }
+    return therapists, nil
+}
+
+// GetTherapistByID retrieves an account by ID
+func (s *TherapistService) GetTherapistByID(id uint) (*model.Therapist, error) {
+    var therapist model.Therapist
+    if err := s.db.First(&therapist, id).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("terapis tidak ditemukan")
+        }
+        return nil, fmt.Errorf("gagal mengambil data terapis: %w", err)
+    }
+    return &therapist, nil
+}
+
+// UpdateTherapist changes an account's name, role and active flag
+func (s *TherapistService) UpdateTherapist(id uint, name, role string, isActive bool) (*model.Therapist, error) {
+    therapist, err := s.GetTherapistByID(id)
+    if err != nil {
+        return nil, err
+    }
+    name = strings.TrimSpace(name)
+    if name == "" {
+        return nil, errors.New("nama terapis harus diisi")
+    }
+    if !IsValidRole(role) {
+        return nil, fmt.Errorf("peran tidak dikenal: %s", role)
+    }
+
+    // Never leave the installation without an active admin
+    if therapist.Role == RoleAdmin && therapist.IsActive && (role != RoleAdmin || !isActive) {
+        var admins int64
+        if err := s.db.Model(&model.Therapist{}).
+            Where("role = ? AND is_active = ? AND id <> ?", RoleAdmin, true, id).
+            Count(&admins).Error; err != nil {
+            return nil, fmt.Errorf("gagal memeriksa akun admin: %w", err)
+        }
+        if admins == 0 {
+            return nil, errors.New("harus ada setidaknya satu admin aktif")
+        }
+    }
+
+    before := *therapist
+    therapist.Name = name
+    therapist.Role = role
+    therapist.IsActive = isActive
+
+    err = s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Model(therapist).Updates(map[string]interface{}{
+            "name":      name,
+            "role":      role,
+            "is_active": isActive,
+        }).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionUpdate, AuditEntityTherapist, therapist.ID, before, therapist)
+        return nil
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal memperbarui data terapis: %w", err)
+    }
+    return therapist, nil
+}
+
+// SetTherapistRequiresReview designates whether a therapist's sessions need a supervisor's co-signature
+func (s *TherapistService) SetTherapistRequiresReview(id uint, requiresReview bool) (*model.Therapist, error) {
+    therapist, err := s.GetTherapistByID(id)
+    if err != nil {
+        return nil, err
+    }
+
+    before := *therapist
+    therapist.RequiresReview = requiresReview
+    err = s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Model(therapist).Update("requires_review", requiresReview).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionUpdate, AuditEntityTherapist, therapist.ID, before, therapist)
+        return nil
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal memperbarui data terapis: %w", err)
+    }
+    return therapist, nil
+}
+
+// SetTherapistPassword replaces an account's password
+func (s *TherapistService) SetTherapistPassword(id uint, password string) error {
+    therapist, err := s.GetTherapistByID(id)
+    if err != nil {
+        return err
+    }
+    hash, err := hashPassword(password)
+    if err != nil {
+        return err
+    }
+
+    err = s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Model(therapist).Update("password_hash", hash).Error; err != nil {
+            return err
+        }
+        // Only record that the password changed; hashes of secrets do not belong in the log
+        trail.Add(AuditActionUpdate, AuditEntityTherapist, therapist.ID, nil, nil)
+        return nil
+    })
+    if err != nil {
+        return fmt.Errorf("gagal mengubah kata sandi: %w", err)
+    }
+    return nil
+}
+
+// Authenticate checks a username and password and returns the matching active account
+func (s *TherapistService) Authenticate(username, password string) (*model.Therapist, error) {
+    username = strings.ToLower(strings.TrimSpace(username))
+
+    var therapist model.Therapist
+    if err := s.db.Where("username = ?", username).First(&therapist).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, ErrInvalidCredentials
+        }
+        return nil, fmt.Errorf("gagal mengambil data terapis: %w", err)
+    }
+    if !therapist.IsActive || !checkPassword(therapist.PasswordHash, password) {
+        return nil, ErrInvalidCredentials
+    }
+
+    now := time.Now()
+    if err := s.db.Model(&therapist).Update("last_login_at", now).Error; err != nil {
+        return nil, fmt.Errorf("gagal menyimpan waktu masuk: %w", err)
+    }
+    therapist.LastLoginAt = &now
+    return &therapist, nil
+}
+
+// AssignChild adds a child to a therapist's caseload
+func (s *TherapistService) AssignChild(childID, therapistID uint) error {
+    var child model.Child
+    if err := s.db.First(&child, childID).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return errors.New("data anak tidak ditemukan")
+        }
+        return fmt.Errorf("gagal mengambil data anak: %w", err)
+    }
+    therapist, err := s.GetTherapistByID(therapistID)
+    if err != nil {
+        return err
+    }
+
+    err = s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Model(&child).Association("Therapists").Append(therapist); err != nil {
+            return err
+        }
+        trail.Add(AuditActionCreate, AuditEntityChildTherapist, child.ID, nil, map[string]uint{"child_id": child.ID, "therapist_id": therapist.ID})
+        return nil
+    })
+    if err != nil {
+        return fmt.Errorf("gagal menugaskan terapis: %w", err)
+    }
+    return nil
+}
+
+// UnassignChild removes a child from a therapist's caseload
+func (s *TherapistService) UnassignChild(childID, therapistID uint) error {
+    var child model.Child
+    if err := s.db.First(&child, childID).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return errors.New("data anak tidak ditemukan")
+        }
+        return fmt.Errorf("gagal mengambil data anak: %w", err)
+    }
+    therapist, err := s.GetTherapistByID(therapistID)
+    if err != nil {
+        return err
+    }
+
+    err = s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Model(&child).Association("Therapists").Delete(therapist); err != nil {
+            return err
+        }
+        trail.Add(AuditActionDelete, AuditEntityChildTherapist, child.ID, map[string]uint{"child_id": child.ID, "therapist_id": therapist.ID}, nil)
+        return nil
+    })
+    if err != nil {
+        return fmt.Errorf("gagal melepas penugasan terapis: %w", err)
+    }
+    return nil
+}
+
+// GetChildTherapists lists the therapists a child is assigned to
+func (s *TherapistService) GetChildTherapists(childID uint) ([]model.Therapist, error) {
+    var child model.Child
+    if err := s.db.Preload("Therapists").First(&child, childID).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("data anak tidak ditemukan")
+        }
+        return nil, fmt.Errorf("gagal mengambil terapis anak: %w", err)
+    }
+    return child.Therapists, nil
+}
+
+// hashPassword derives a salted PBKDF2-HMAC-SHA256 hash, encoded as "pbkdf2-sha256$iterations$salt$hash"
+func hashPassword(password string) (string, error) {
+    if len(password) < minPasswordLength {
+        return "", fmt.Errorf("kata sandi minimal %d karakter", minPasswordLength)
+    }
+    salt := make([]byte, 16)
+    if _, err := rand.Read(salt); err != nil {
+        return "", fmt.Errorf("gagal membuat salt kata sandi: %w", err)
+    }
+    key := pbkdf2.Key([]byte(password), salt, passwordHashIterations, 32, sha256.New)
+    return fmt.Sprintf("pbkdf2-sha256$%d$%s$%s", passwordHashIterations, hex.EncodeToString(salt), hex.EncodeToString(key)), nil
+}
+
+// checkPassword reports whether password matches an encoded hash from hashPassword
+func checkPassword(encoded, password string) bool {
+    parts := strings.Split(encoded, "$")
+    if len(parts) != 4 || parts[0] != "pbkdf2-sha256" {
+        return false
+    }
+    iterations, err := strconv.Atoi(parts[1])
+    if err != nil || iterations < 1 {
+        return false
+    }
+    salt, err := hex.DecodeString(parts[2])
+    if err != nil {
+        return false
+    }
+    want, err := hex.DecodeString(parts[3])
+    if err != nil {
+        return false
+    }
+    got := pbkdf2.Key([]byte(password), salt, iterations, len(want), sha256.New)
+    return subtle.ConstantTimeCompare(got, want) == 1
+}
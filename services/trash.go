@@ -0,0 +1,536 @@
+package services
+
+import (
+	"childSessions/model"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DefaultTrashRetentionDays is how long soft-deleted records stay restorable when no setting exists
+const DefaultTrashRetentionDays = 30
+
+// TrashItem is one soft-deleted record shown in the trash bin
+type TrashItem struct {
+    EntityType string    `json:"entity_type"` // "child", "note", "reward", "activity" or "attachment"
+    ID         uint      `json:"id"`
+    Label      string    `json:"label"`
+    Details    string    `json:"details"`
+    DeletedAt  time.Time `json:"deleted_at"`
+    PurgeAfter time.Time `json:"purge_after"`
+}
+
+// TrashPurgeResult counts the rows permanently removed by a purge, per table
+type TrashPurgeResult struct {
+    RetentionDays int              `json:"retention_days"`
+    Cutoff        time.Time        `json:"cutoff"`
+    Deleted       map[string]int64 `json:"deleted"`
+}
+
+type TrashService struct {
+    db       *gorm.DB
+    audit    *AuditService
+    settings *SettingService
+}
+
+func NewTrashService(db *gorm.DB, audit *AuditService, settings *SettingService) *TrashService {
+    return &TrashService{db: db, audit: audit, settings: settings}
+}
+
+// GetRetentionDays returns how many days deleted records stay in the trash
+func (s *TrashService) GetRetentionDays() (int, error) {
+    return s.settings.GetInt(SettingTrashRetentionDays, DefaultTrashRetentionDays)
+}
+
+// SetRetentionDays changes how many days deleted records stay in the trash
+func (s *TrashService) SetRetentionDays(days int) error {
+    if days < 1 {
+        return errors.New("masa simpan tempat sampah minimal 1 hari")
+    }
+    return s.settings.SetInt(SettingTrashRetentionDays, days)
+}
+
+// ListTrash lists soft-deleted records of one type, or of every type when entityType is empty
+func (s *TrashService) ListTrash(entityType string) ([]TrashItem, error) {
+    days, err := s.GetRetentionDays()
+    if err != nil {
+        return nil, err
+    }
+    retention := time.Duration(days) * 24 * time.Hour
+
+    items := make([]TrashItem, 0)
+    add := func(item TrashItem) {
+        item.PurgeAfter = item.DeletedAt.Add(retention)
+        items = append(items, item)
+    }
+
+    if entityType == "" || entityType == AuditEntityChild {
+        var children []model.Child
+        if err := s.db.Unscoped().Where("deleted_at IS NOT NULL").Order("deleted_at DESC").Find(&children).Error; err != nil {
+            return nil, fmt.Errorf("gagal mengambil data anak yang dihapus: %w", err)
+        }
+        for _, child := range children {
+            add(TrashItem{EntityType: AuditEntityChild, ID: child.ID, Label: child.Name, Details: child.ParentGuardianName, DeletedAt: child.DeletedAt.Time})
+        }
+    }
+
+    if entityType == "" || entityType == AuditEntityNote {
+        var notes []model.Note
+        if err := s.db.Unscoped().Where("deleted_at IS NOT NULL").Order("deleted_at DESC").Find(&notes).Error; err != nil {
+            return nil, fmt.Errorf("gagal mengambil catatan yang dihapus: %w", err)
+        }
+        for _, note := range notes {
+            add(TrashItem{EntityType: AuditEntityNote, ID: note.ID, Label: note.NoteText, Details: fmt.Sprintf("Sesi #%d", note.SessionID), DeletedAt: note.DeletedAt.Time})
+        }
+    }
+
+    if entityType == "" || entityType == AuditEntityReward {
+        var rewards []model.Reward
+        if err := s.db.Unscoped().Where("deleted_at IS NOT NULL").Order("deleted_at DESC").Find(&rewards).Error; err != nil {
+            return nil, fmt.Errorf("gagal mengambil reward yang dihapus: %w", err)
+        }
+        for _, reward := range rewards {
+            add(TrashItem{EntityType: AuditEntityReward, ID: reward.ID, Label: fmt.Sprintf("%s (%d)", reward.Type, reward.Value), Details: fmt.Sprintf("Anak #%d", reward.ChildID), DeletedAt: reward.DeletedAt.Time})
+        }
+    }
+
+    if entityType == "" || entityType == AuditEntityActivity {
+        var activities []model.Activity
+        if err := s.db.Unscoped().Where("deleted_at IS NOT NULL").Order("deleted_at DESC").Find(&activities).Error; err != nil {
+            return nil, fmt.Errorf("gagal mengambil aktivitas yang dihapus: %w", err)
+        }
+        for _, activity := range activities {
+            add(TrashItem{EntityType: AuditEntityActivity, ID: activity.ID, Label: activity.Name, Details: activity.Category, DeletedAt: activity.DeletedAt.Time})
+        }
+    }
+
+    if entityType == "" || entityType == AuditEntityAttachment {
+        var attachments []model.Attachment
+        if err := s.db.Unscoped().Where("deleted_at IS NOT NULL").Order("deleted_at DESC").Find(&attachments).Error; err != nil {
+            return nil, fmt.Errorf("gagal mengambil lampiran yang dihapus: %w", err)
+        }
+        for _, attachment := range attachments {
+            add(TrashItem{EntityType: AuditEntityAttachment, ID: attachment.ID, Label: attachment.FileName, Details: fmt.Sprintf("Anak #%d", attachment.ChildID), DeletedAt: attachment.DeletedAt.Time})
+        }
+    }
+
+    return items, nil
+}
+
+// RestoreFromTrash undoes a soft delete. Restoring a child also restores its sessions,
+// which are only ever deleted together with the child.
+func (s *TrashService) RestoreFromTrash(entityType string, id uint) error {
+    switch entityType {
+    case AuditEntityChild:
+        var child model.Child
+        if err := s.findDeleted(&child, id); err != nil {
+            return err
+        }
+        return s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+            if err := tx.Model(&model.Session{}).Unscoped().
+                Where("child_id = ? AND deleted_at IS NOT NULL", id).
+                Update("deleted_at", nil).Error; err != nil {
+                return fmt.Errorf("gagal memulihkan sesi anak: %w", err)
+            }
+            if err := tx.Model(&child).Unscoped().Update("deleted_at", nil).Error; err != nil {
+                return fmt.Errorf("gagal memulihkan data anak: %w", err)
+            }
+            trail.Add(AuditActionRestore, AuditEntityChild, id, nil, child)
+            return nil
+        })
+
+    case AuditEntityNote:
+        var note model.Note
+        if err := s.findDeleted(&note, id); err != nil {
+            return err
+        }
+        if err := ensureSessionWritable(s.db, note.SessionID); err != nil {
+            return err
+        }
+        actor := s.audit.Actor()
+        return s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+            if err := tx.Model(&note).Unscoped().Update("deleted_at", nil).Error; err != nil {
+                return fmt.Errorf("gagal memulihkan catatan: %w", err)
+            }
+            trail.Add(AuditActionRestore, AuditEntityNote, id, nil, note)
+            return recordRevision(tx, RevisionEntityNote, note.ID, note.SessionID, RevisionActionRestored, note.NoteText, note.Category, actor, "")
+        })
+
+    case AuditEntityReward:
+        var reward model.Reward
+        if err := s.findDeleted(&reward, id); err != nil {
+            return err
+        }
+        if reward.SessionID != nil {
+            if err := ensureSessionWritable(s.db, *reward.SessionID); err != nil {
+                return err
+            }
+        }
+        return s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+            if err := tx.Model(&reward).Unscoped().Update("deleted_at", nil).Error; err != nil {
+                return fmt.Errorf("gagal memulihkan reward: %w", err)
+            }
+            trail.Add(AuditActionRestore, AuditEntityReward, id, nil, reward)
+            return nil
+        })
+
+    case AuditEntityActivity:
+        var activity model.Activity
+        if err := s.findDeleted(&activity, id); err != nil {
+            return err
+        }
+        return s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+            if err := tx.Model(&activity).Unscoped().Update("deleted_at", nil).Error; err != nil {
+                return fmt.Errorf("gagal memulihkan aktivitas: %w", err)
+            }
+            trail.Add(AuditActionRestore, AuditEntityActivity, id, nil, activity)
+            return nil
+        })
+
+    case AuditEntityAttachment:
+        var attachment model.Attachment
+        if err := s.findDeleted(&attachment, id); err != nil {
+            return err
+        }
+        if attachment.SessionID != nil {
+            if err := ensureSessionWritable(s.db, *attachment.SessionID); err != nil {
+                return err
+            }
+        }
+        return s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+            if err := tx.Model(&attachment).Unscoped().Update("deleted_at", nil).Error; err != nil {
+                return fmt.Errorf("gagal memulihkan lampiran: %w", err)
+            }
+            trail.Add(AuditActionRestore, AuditEntityAttachment, id, nil, attachment)
+            return nil
+        })
+    }
+
+    return fmt.Errorf("jenis data tidak dikenal: %s", entityType)
+}
+
+// findDeleted loads a soft-deleted record by ID
+func (s *TrashService) findDeleted(dest interface{}, id uint) error {
+    if err := s.db.Unscoped().Where("deleted_at IS NOT NULL").First(dest, id).Error; err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return errors.New("data tidak ditemukan di tempat sampah")
+        }
+        return fmt.Errorf("gagal mengambil data dari tempat sampah: %w", err)
+    }
+    return nil
+}
+
+// PurgeExpiredTrash permanently deletes records that have been in the trash longer than the retention period
+func (s *TrashService) PurgeExpiredTrash() (*TrashPurgeResult, error) {
+    days, err := s.GetRetentionDays()
+    if err != nil {
+        return nil, err
+    }
+
+    result := &TrashPurgeResult{
+        RetentionDays: days,
+        Cutoff:        time.Now().AddDate(0, 0, -days),
+        Deleted:       make(map[string]int64),
+    }
+
+    err = s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        var childIDs []uint
+        if err := tx.Unscoped().Model(&model.Child{}).
+            Where("deleted_at IS NOT NULL AND deleted_at < ?", result.Cutoff).
+            Pluck("id", &childIDs).Error; err != nil {
+            return err
+        }
+        for _, childID := range childIDs {
+            counts, err := purgeChild(tx, childID)
+            if err != nil {
+                return err
+            }
+            for table, n := range counts {
+                result.Deleted[table] += n
+            }
+            trail.Add(AuditActionPurge, AuditEntityChild, childID, nil, nil)
+        }
+
+        var noteIDs []uint
+        if err := tx.Unscoped().Model(&model.Note{}).
+            Where("deleted_at IS NOT NULL AND deleted_at < ?", result.Cutoff).
+            Pluck("id", &noteIDs).Error; err != nil {
+            return err
+        }
+        if len(noteIDs) > 0 {
+            revisions := tx.Where("entity_type = ? AND entity_id IN ?", RevisionEntityNote, noteIDs).Delete(&model.NoteRevision{})
+            if revisions.Error != nil {
+                return revisions.Error
+            }
+            result.Deleted["note_revisions"] += revisions.RowsAffected
+
+            notes := tx.Unscoped().Where("id IN ?", noteIDs).Delete(&model.Note{})
+            if notes.Error != nil {
+                return notes.Error
+            }
+            result.Deleted["notes"] += notes.RowsAffected
+            for _, noteID := range noteIDs {
+                trail.Add(AuditActionPurge, AuditEntityNote, noteID, nil, nil)
+            }
+        }
+
+        var rewardIDs []uint
+        if err := tx.Unscoped().Model(&model.Reward{}).
+            Where("deleted_at IS NOT NULL AND deleted_at < ?", result.Cutoff).
+            Pluck("id", &rewardIDs).Error; err != nil {
+            return err
+        }
+        if len(rewardIDs) > 0 {
+            rewards := tx.Unscoped().Where("id IN ?", rewardIDs).Delete(&model.Reward{})
+            if rewards.Error != nil {
+                return rewards.Error
+            }
+            result.Deleted["rewards"] += rewards.RowsAffected
+            for _, rewardID := range rewardIDs {
+                trail.Add(AuditActionPurge, AuditEntityReward, rewardID, nil, nil)
+            }
+        }
+
+        // Activities still referenced by session history are kept so past sessions stay readable
+        var activityIDs []uint
+        if err := tx.Unscoped().Model(&model.Activity{}).
+            Where("deleted_at IS NOT NULL AND deleted_at < ?", result.Cutoff).
+            Where("id NOT IN (SELECT activity_id FROM session_activities)").
+            Pluck("id", &activityIDs).Error; err != nil {
+            return err
+        }
+        if len(activityIDs) > 0 {
+            activities := tx.Unscoped().Where("id IN ?", activityIDs).Delete(&model.Activity{})
+            if activities.Error != nil {
+                return activities.Error
+            }
+            result.Deleted["activities"] += activities.RowsAffected
+            for _, activityID := range activityIDs {
+                trail.Add(AuditActionPurge, AuditEntityActivity, activityID, nil, nil)
+            }
+        }
+
+        // Only the records are removed here; AttachmentService.RemoveOrphanedFiles deletes the files
+        var attachmentIDs []uint
+        if err := tx.Unscoped().Model(&model.Attachment{}).
+            Where("deleted_at IS NOT NULL AND deleted_at < ?", result.Cutoff).
+            Pluck("id", &attachmentIDs).Error; err != nil {
+            return err
+        }
+        if len(attachmentIDs) > 0 {
+            attachments := tx.Unscoped().Where("id IN ?", attachmentIDs).Delete(&model.Attachment{})
+            if attachments.Error != nil {
+                return attachments.Error
+            }
+            result.Deleted["attachments"] += attachments.RowsAffected
+            for _, attachmentID := range attachmentIDs {
+                trail.Add(AuditActionPurge, AuditEntityAttachment, attachmentID, nil, nil)
+            }
+        }
+
+        return nil
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal mengosongkan tempat sampah: %w", err)
+    }
+
+    return result, nil
+}
+
+// purgeChild permanently deletes a child and every row that depends on it,
+// returning the number of rows removed per table
+func purgeChild(tx *gorm.DB, childID uint) (map[string]int64, error) {
+    counts := make(map[string]int64)
+
+    // Group sessions led under the child's name carry on for the other children
+    var groupSessionIDs []uint
+    if err := tx.Unscoped().Model(&model.Session{}).Where("child_id = ? AND is_group = ?", childID, true).Pluck("id", &groupSessionIDs).Error; err != nil {
+        return nil, err
+    }
+    for _, sessionID := range groupSessionIDs {
+        var otherChildIDs []uint
+        if err := tx.Unscoped().Model(&model.SessionParticipant{}).
+            Where("session_id = ? AND child_id <> ?", sessionID, childID).
+            Order("id ASC").Limit(1).Pluck("child_id", &otherChildIDs).Error; err != nil {
+            return nil, err
+        }
+        if len(otherChildIDs) == 0 {
+            continue
+        }
+        if err := tx.Unscoped().Model(&model.Session{}).Where("id = ?", sessionID).Update("child_id", otherChildIDs[0]).Error; err != nil {
+            return nil, fmt.Errorf("gagal memindahkan sesi kelompok: %w", err)
+        }
+    }
+
+    var sessionIDs []uint
+    if err := tx.Unscoped().Model(&model.Session{}).Where("child_id = ?", childID).Pluck("id", &sessionIDs).Error; err != nil {
+        return nil, err
+    }
+
+    deleteWhere := func(table string, value interface{}, query string, args ...interface{}) error {
+        result := tx.Unscoped().Where(query, args...).Delete(value)
+        if result.Error != nil {
+            return fmt.Errorf("gagal menghapus %s: %w", table, result.Error)
+        }
+        counts[table] += result.RowsAffected
+        return nil
+    }
+
+    if len(sessionIDs) > 0 {
+        sessionTables := []struct {
+            table string
+            value interface{}
+        }{
+            {"note_revisions", &model.NoteRevision{}},
+            {"notes", &model.Note{}},
+            {"session_note_sections", &model.SessionNoteSection{}},
+            {"session_addendums", &model.SessionAddendum{}},
+            {"session_review_comments", &model.SessionReviewComment{}},
+            {"session_activities", &model.SessionActivity{}},
+            {"session_flashcards", &model.SessionFlashcard{}},
+            {"session_participants", &model.SessionParticipant{}},
+        }
+        for _, t := range sessionTables {
+            if err := deleteWhere(t.table, t.value, "session_id IN ?", sessionIDs); err != nil {
+                return nil, err
+            }
+        }
+        if err := deleteWhere("rewards", &model.Reward{}, "session_id IN ?", sessionIDs); err != nil {
+            return nil, err
+        }
+    }
+
+    // Flashcards showing the child's photo go too, with every response logged against them
+    var photoFlashcardIDs []uint
+    if err := tx.Unscoped().Model(&model.Flashcard{}).Where("child_id = ?", childID).Pluck("id", &photoFlashcardIDs).Error; err != nil {
+        return nil, err
+    }
+    if len(photoFlashcardIDs) > 0 {
+        if err := deleteWhere("session_flashcards", &model.SessionFlashcard{}, "flashcard_id IN ?", photoFlashcardIDs); err != nil {
+            return nil, err
+        }
+        if err := deleteWhere("flashcards", &model.Flashcard{}, "id IN ?", photoFlashcardIDs); err != nil {
+            return nil, err
+        }
+    }
+
+    // Templates tailored to the child go with every version's content; plans for other
+    // children cloned from them keep their activities but lose the link
+    var templateIDs []uint
+    if err := tx.Unscoped().Model(&model.SessionTemplate{}).Where("child_id = ?", childID).Pluck("id", &templateIDs).Error; err != nil {
+        return nil, err
+    }
+    if len(templateIDs) > 0 {
+        if err := tx.Model(&model.SessionPlan{}).Unscoped().Where("template_id IN ?", templateIDs).Update("template_id", nil).Error; err != nil {
+            return nil, fmt.Errorf("gagal melepas templat dari rencana sesi: %w", err)
+        }
+        if err := deleteWhere("session_template_activities", &model.SessionTemplateActivity{}, "template_id IN ?", templateIDs); err != nil {
+            return nil, err
+        }
+        if err := deleteWhere("session_template_reward_rules", &model.SessionTemplateRewardRule{}, "template_id IN ?", templateIDs); err != nil {
+            return nil, err
+        }
+        links := tx.Exec("DELETE FROM session_template_note_templates WHERE session_template_id IN ?", templateIDs)
+        if links.Error != nil {
+            return nil, fmt.Errorf("gagal menghapus session_template_note_templates: %w", links.Error)
+        }
+        counts["session_template_note_templates"] += links.RowsAffected
+        if err := deleteWhere("session_templates", &model.SessionTemplate{}, "id IN ?", templateIDs); err != nil {
+            return nil, err
+        }
+    }
+
+    // Plan items reference goals, so plans go before the goals
+    planIDs := tx.Unscoped().Model(&model.SessionPlan{}).Select("id").Where("child_id = ?", childID)
+    if err := deleteWhere("session_plan_items", &model.SessionPlanItem{}, "plan_id IN (?)", planIDs); err != nil {
+        return nil, err
+    }
+    if err := deleteWhere("session_plans", &model.SessionPlan{}, "child_id = ?", childID); err != nil {
+        return nil, err
+    }
+
+    // What was written about the child in other children's group sessions
+    childNoteIDs := tx.Unscoped().Model(&model.Note{}).Select("id").Where("child_id = ?", childID)
+    if err := deleteWhere("note_revisions", &model.NoteRevision{}, "entity_type = ? AND entity_id IN (?)", RevisionEntityNote, childNoteIDs); err != nil {
+        return nil, err
+    }
+    if err := deleteWhere("notes", &model.Note{}, "child_id = ?", childID); err != nil {
+        return nil, err
+    }
+    if err := deleteWhere("session_participants", &model.SessionParticipant{}, "child_id = ?", childID); err != nil {
+        return nil, err
+    }
+
+    // The referral the child was enrolled from holds the same personal details
+    entryIDs := tx.Unscoped().Model(&model.WaitlistEntry{}).Select("id").Where("child_id = ?", childID)
+    if err := deleteWhere("waitlist_availabilities", &model.WaitlistAvailability{}, "entry_id IN (?)", entryIDs); err != nil {
+        return nil, err
+    }
+    if err := deleteWhere("waitlist_events", &model.WaitlistEvent{}, "entry_id IN (?)", entryIDs); err != nil {
+        return nil, err
+    }
+    if err := deleteWhere("waitlist_entries", &model.WaitlistEntry{}, "child_id = ?", childID); err != nil {
+        return nil, err
+    }
+
+    // Invoices carry the family's name and the sessions billed
+    invoiceIDs := tx.Unscoped().Model(&model.Invoice{}).Select("id").Where("child_id = ?", childID)
+    if err := deleteWhere("payments", &model.Payment{}, "invoice_id IN (?)", invoiceIDs); err != nil {
+        return nil, err
+    }
+    if err := deleteWhere("invoice_lines", &model.InvoiceLine{}, "invoice_id IN (?)", invoiceIDs); err != nil {
+        return nil, err
+    }
+    if err := deleteWhere("invoices", &model.Invoice{}, "child_id = ?", childID); err != nil {
+        return nil, err
+    }
+    if err := deleteWhere("package_usages", &model.PackageUsage{}, "child_id = ?", childID); err != nil {
+        return nil, err
+    }
+    if err := deleteWhere("session_packages", &model.SessionPackage{}, "child_id = ?", childID); err != nil {
+        return nil, err
+    }
+
+    if err := deleteWhere("rewards", &model.Reward{}, "child_id = ?", childID); err != nil {
+        return nil, err
+    }
+    if err := deleteWhere("goals", &model.Goal{}, "child_id = ?", childID); err != nil {
+        return nil, err
+    }
+    if err := deleteWhere("guardians", &model.Guardian{}, "child_id = ?", childID); err != nil {
+        return nil, err
+    }
+    childTables := []struct {
+        table string
+        value interface{}
+    }{
+        {"child_diagnoses", &model.ChildDiagnosis{}},
+        {"child_medications", &model.ChildMedication{}},
+        {"child_sensitivities", &model.ChildSensitivity{}},
+        {"clinical_profile_changes", &model.ClinicalProfileChange{}},
+        {"attachments", &model.Attachment{}},
+        {"consents", &model.Consent{}},
+        {"assessment_administrations", &model.AssessmentAdministration{}},
+        {"child_milestones", &model.ChildMilestone{}},
+    }
+    for _, t := range childTables {
+        if err := deleteWhere(t.table, t.value, "child_id = ?", childID); err != nil {
+            return nil, err
+        }
+    }
+    assignments := tx.Exec("DELETE FROM child_therapists WHERE child_id = ?", childID)
+    if assignments.Error != nil {
+        return nil, fmt.Errorf("gagal menghapus child_therapists: %w", assignments.Error)
+    }
+    counts["child_therapists"] += assignments.RowsAffected
+    if err := deleteWhere("sessions", &model.Session{}, "child_id = ?", childID); err != nil {
+        return nil, err
+    }
+    if err := deleteWhere("children", &model.Child{}, "id = ?", childID); err != nil {
+        return nil, err
+    }
+
+    return counts, nil
+}
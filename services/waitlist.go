@@ -0,0 +1,600 @@
+package services
+
+import (
+	"childSessions/model"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Waitlist priorities, most urgent first
+const (
+    WaitlistPriorityUrgent = "urgent"
+    WaitlistPriorityHigh   = "high"
+    WaitlistPriorityNormal = "normal"
+    WaitlistPriorityLow    = "low"
+)
+
+// Waitlist statuses
+const (
+    WaitlistStatusWaiting   = "waiting"
+    WaitlistStatusOffered   = "offered"   // A slot was offered and the family has not answered yet
+    WaitlistStatusConverted = "converted" // Enrolled as a child with a first appointment
+    WaitlistStatusWithdrawn = "withdrawn"
+)
+
+// Waitlist history actions
+const (
+    WaitlistEventCreated       = "created"
+    WaitlistEventUpdated       = "updated"
+    WaitlistEventStatusChanged = "status_changed"
+    WaitlistEventConverted     = "converted"
+)
+
+var waitlistPriorityRank = map[string]int{
+    WaitlistPriorityUrgent: 0,
+    WaitlistPriorityHigh:   1,
+    WaitlistPriorityNormal: 2,
+    WaitlistPriorityLow:    3,
+}
+
+// WaitlistEscalationDays is how long a referral waits before it is ranked one priority
+// level higher, so low-priority families are not left waiting indefinitely
+const WaitlistEscalationDays = 90
+
+// WaitlistEntryInput holds the editable fields of a referral
+type WaitlistEntryInput struct {
+    ChildName      string                      `json:"child_name"`
+    DateOfBirth    string                      `json:"date_of_birth"`
+    Gender         string                      `json:"gender"`
+    ContactName    string                      `json:"contact_name"`
+    Relationship   string                      `json:"relationship"`
+    ContactPhone   string                      `json:"contact_phone"`
+    ContactEmail   string                      `json:"contact_email"`
+    ReferralDate   string                      `json:"referral_date"` // Defaults to today
+    ReferralSource string                      `json:"referral_source"`
+    ReferralReason string                      `json:"referral_reason"`
+    TherapyType    string                      `json:"therapy_type"`
+    Priority       string                      `json:"priority"` // Defaults to normal
+    Notes          string                      `json:"notes"`
+    Availability   []WaitlistAvailabilityInput `json:"availability"`
+}
+
+// WaitlistAvailabilityInput is a weekly window in which the family can attend
+type WaitlistAvailabilityInput struct {
+    Weekday   int    `json:"weekday"` // 0 = Sunday ... 6 = Saturday
+    StartTime string `json:"start_time"`
+    EndTime   string `json:"end_time"`
+}
+
+// WaitlistFilter narrows the waitlist. Without statuses, waiting and offered referrals
+// are listed. Weekday and Time together keep only families available for that slot;
+// families without recorded availability are treated as flexible.
+type WaitlistFilter struct {
+    TherapyType string   `json:"therapy_type"`
+    Statuses    []string `json:"statuses"`
+    Weekday     *int     `json:"weekday"`
+    Time        string   `json:"time"` // "15:04"
+}
+
+// WaitlistPosition is a referral with its place in the queue
+type WaitlistPosition struct {
+    model.WaitlistEntry
+    Position          int    `json:"position"`
+    WaitingDays       int    `json:"waiting_days"`
+    EffectivePriority string `json:"effective_priority"` // Priority after escalation for long waits
+}
+
+// ConvertWaitlistInput describes the enrolment of a referral
+type ConvertWaitlistInput struct {
+    AppointmentAt string `json:"appointment_at"` // "2006-01-02 15:04"
+    TherapistID   *uint  `json:"therapist_id"`   // Added to the child's caseload and runs the appointment
+}
+
+type WaitlistService struct {
+    db    *gorm.DB
+    audit *AuditService
+}
+
+func NewWaitlistService(db *gorm.DB, audit *AuditService) *WaitlistService {
+    return &WaitlistService{db: db, audit: audit}
+}
+
+// CreateEntry adds a referral to the waitlist
+func (s *WaitlistService) CreateEntry(input WaitlistEntryInput, actor string) (*model.WaitlistEntry, error) {
+    entry := &model.WaitlistEntry{Status: WaitlistStatusWaiting}
+    availability, err := applyWaitlistInput(entry, input)
+    if err != nil {
+        return nil, err
+    }
+
+    err = s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Omit("Availability", "Events").Create(entry).Error; err != nil {
+            return err
+        }
+        if err := createWaitlistAvailability(tx, entry.ID, availability); err != nil {
+            return err
+        }
+        trail.Add(AuditActionCreate, AuditEntityWaitlistEntry, entry.ID, nil, entry)
+        return addWaitlistEvent(tx, entry.ID, WaitlistEventCreated, "", entry.Status, fmt.Sprintf("Prioritas %s", entry.Priority), actor)
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal menambahkan rujukan ke daftar tunggu: %w", err)
+    }
+    return s.GetEntry(entry.ID)
+}
+
+// UpdateEntry edits a referral that has not been converted yet
+func (s *WaitlistService) UpdateEntry(entryID uint, input WaitlistEntryInput, actor string) (*model.WaitlistEntry, error) {
+    entry, err := s.GetEntry(entryID)
+    if err != nil {
+        return nil, err
+    }
+    if entry.Status == WaitlistStatusConverted {
+        return nil, errors.New("rujukan sudah menjadi data anak dan tidak dapat diubah")
+    }
+
+    before := *entry
+    availability, err := applyWaitlistInput(entry, input)
+    if err != nil {
+        return nil, err
+    }
+    detail := "Data rujukan diperbarui"
+    if before.Priority != entry.Priority {
+        detail = fmt.Sprintf("Prioritas %s → %s", before.Priority, entry.Priority)
+    }
+
+    err = s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Omit("Availability", "Events").Save(entry).Error; err != nil {
+            return err
+        }
+        if err := tx.Where("entry_id = ?", entry.ID).Delete(&model.WaitlistAvailability{}).Error; err != nil {
+            return err
+        }
+        if err := createWaitlistAvailability(tx, entry.ID, availability); err != nil {
+            return err
+        }
+        trail.Add(AuditActionUpdate, AuditEntityWaitlistEntry, entry.ID, before, entry)
+        return addWaitlistEvent(tx, entry.ID, WaitlistEventUpdated, entry.Status, entry.Status, detail, actor)
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal memperbarui rujukan: %w", err)
+    }
+    return s.GetEntry(entry.ID)
+}
+
+// SetStatus records that a slot was offered, that the family withdrew, or puts the
+// referral back on the waitlist
+func (s *WaitlistService) SetStatus(entryID uint, status, note, actor string) (*model.WaitlistEntry, error) {
+    switch status {
+    case WaitlistStatusWaiting, WaitlistStatusOffered, WaitlistStatusWithdrawn:
+    case WaitlistStatusConverted:
+        return nil, errors.New("gunakan konversi untuk mendaftarkan anak dari daftar tunggu")
+    default:
+        return nil, fmt.Errorf("status daftar tunggu tidak valid: %q", status)
+    }
+    note = strings.TrimSpace(note)
+    if status == WaitlistStatusWithdrawn && note == "" {
+        return nil, errors.New("alasan keluar dari daftar tunggu harus diisi")
+    }
+
+    entry, err := s.GetEntry(entryID)
+    if err != nil {
+        return nil, err
+    }
+    if entry.Status == WaitlistStatusConverted {
+        return nil, errors.New("rujukan sudah menjadi data anak")
+    }
+    if entry.Status == status {
+        return entry, nil
+    }
+
+    before := *entry
+    entry.Status = status
+    err = s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Model(&model.WaitlistEntry{}).Where("id = ?", entry.ID).Update("status", status).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionUpdate, AuditEntityWaitlistEntry, entry.ID, before, entry)
+        return addWaitlistEvent(tx, entry.ID, WaitlistEventStatusChanged, before.Status, status, note, actor)
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal mengubah status daftar tunggu: %w", err)
+    }
+    return s.GetEntry(entry.ID)
+}
+
+// ConvertEntry enrols a referral: it creates the child with the referral's details and
+// contact as primary guardian, optionally assigns a therapist, and books the first
+// appointment. The referral keeps its history and links to the new records.
+func (s *WaitlistService) ConvertEntry(entryID uint, input ConvertWaitlistInput, actor string) (*model.WaitlistEntry, error) {
+    entry, err := s.GetEntry(entryID)
+    if err != nil {
+        return nil, err
+    }
+    if entry.Status == WaitlistStatusConverted {
+        return nil, errors.New("rujukan sudah menjadi data anak")
+    }
+    if entry.Status == WaitlistStatusWithdrawn {
+        return nil, errors.New("rujukan sudah keluar dari daftar tunggu; kembalikan ke daftar tunggu terlebih dahulu")
+    }
+    appointmentAt, err := ParseSessionDateTime(input.AppointmentAt)
+    if err != nil {
+        return nil, err
+    }
+    var therapist *model.Therapist
+    if input.TherapistID != nil {
+        therapist = &model.Therapist{}
+        if err := s.db.First(therapist, *input.TherapistID).Error; err != nil {
+            if errors.Is(err, gorm.ErrRecordNotFound) {
+                return nil, errors.New("terapis tidak ditemukan")
+            }
+            return nil, fmt.Errorf("gagal mengambil data terapis: %w", err)
+        }
+    }
+
+    before := *entry
+    now := time.Now()
+    err = s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        // Claim the referral first so a repeated request cannot enrol the same family twice
+        claim := tx.Model(&model.WaitlistEntry{}).
+            Where("id = ? AND status NOT IN ?", entry.ID, []string{WaitlistStatusConverted, WaitlistStatusWithdrawn}).
+            Updates(map[string]interface{}{"status": WaitlistStatusConverted, "converted_at": now})
+        if claim.Error != nil {
+            return claim.Error
+        }
+        if claim.RowsAffected == 0 {
+            return errors.New("rujukan sudah menjadi data anak atau sudah keluar dari daftar tunggu")
+        }
+
+        child := &model.Child{
+            Name:               entry.ChildName,
+            DateOfBirth:        entry.DateOfBirth,
+            Gender:             entry.Gender,
+            ParentGuardianName: entry.ContactName,
+            ContactInfo:        strings.Join(nonEmpty(entry.ContactPhone, entry.ContactEmail), ", "),
+            ReferralSource:     entry.ReferralSource,
+        }
+        if err := tx.Omit("Therapists", "Guardians").Create(child).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionCreate, AuditEntityChild, child.ID, nil, child)
+
+        relationship := entry.Relationship
+        if relationship == "" {
+            relationship = RelationshipGuardian
+        }
+        guardian := &model.Guardian{
+            ChildID:          child.ID,
+            Name:             entry.ContactName,
+            Relationship:     relationship,
+            Phone:            entry.ContactPhone,
+            Email:            entry.ContactEmail,
+            IsPrimary:        true,
+            ConsentToContact: true,
+        }
+        if err := createGuardian(tx, trail, guardian); err != nil {
+            return err
+        }
+
+        if therapist != nil {
+            if err := tx.Model(child).Association("Therapists").Append(therapist); err != nil {
+                return err
+            }
+            trail.Add(AuditActionCreate, AuditEntityChildTherapist, child.ID, nil, map[string]uint{"child_id": child.ID, "therapist_id": therapist.ID})
+        }
+
+        appointment := &model.Session{
+            ChildID:      child.ID,
+            TherapistID:  input.TherapistID,
+            StartTime:    appointmentAt,
+            ScheduledFor: &appointmentAt,
+            Status:       SessionStatusScheduled,
+            TherapyType:  entry.TherapyType,
+        }
+        if err := tx.Create(appointment).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionCreate, AuditEntitySession, appointment.ID, nil, appointment)
+
+        entry.Status = WaitlistStatusConverted
+        entry.ChildID = &child.ID
+        entry.SessionID = &appointment.ID
+        entry.ConvertedAt = &now
+        if err := tx.Model(&model.WaitlistEntry{}).Where("id = ?", entry.ID).Updates(map[string]interface{}{
+            "child_id":   child.ID,
+            "session_id": appointment.ID,
+        }).Error; err != nil {
+            return err
+        }
+        trail.Add(AuditActionUpdate, AuditEntityWaitlistEntry, entry.ID, before, entry)
+        detail := fmt.Sprintf("Jadwal pertama %s", appointmentAt.Format("02-01-2006 15:04"))
+        return addWaitlistEvent(tx, entry.ID, WaitlistEventConverted, before.Status, WaitlistStatusConverted, detail, actor)
+    })
+    if err != nil {
+        return nil, fmt.Errorf("gagal mendaftarkan anak dari daftar tunggu: %w", err)
+    }
+    return s.GetEntry(entry.ID)
+}
+
+// DeleteEntry permanently removes a referral that was never enrolled, with its
+// availability and history, so the family's details are not kept after they withdraw
+// or are no longer expected. Converted referrals are removed when the child is erased.
+func (s *WaitlistService) DeleteEntry(entryID uint) error {
+    entry, err := s.GetEntry(entryID)
+    if err != nil {
+        return err
+    }
+    if entry.Status == WaitlistStatusConverted {
+        return errors.New("rujukan sudah menjadi data anak; hapus melalui data anak")
+    }
+
+    err = s.audit.Transaction(s.db, func(tx *gorm.DB, trail *AuditTrail) error {
+        if err := tx.Unscoped().Where("entry_id = ?", entry.ID).Delete(&model.WaitlistAvailability{}).Error; err != nil {
+            return err
+        }
+        if err := tx.Unscoped().Where("entry_id = ?", entry.ID).Delete(&model.WaitlistEvent{}).Error; err != nil {
+            return err
+        }
+        result := tx.Unscoped().Where("id = ? AND status <> ?", entry.ID, WaitlistStatusConverted).Delete(&model.WaitlistEntry{})
+        if result.Error != nil {
+            return result.Error
+        }
+        if result.RowsAffected == 0 {
+            return errors.New("rujukan sudah menjadi data anak")
+        }
+        trail.Add(AuditActionPurge, AuditEntityWaitlistEntry, entry.ID, entry, nil)
+        return nil
+    })
+    if err != nil {
+        return fmt.Errorf("gagal menghapus rujukan: %w", err)
+    }
+    return nil
+}
+
+// GetEntry returns a referral with its availability and history
+func (s *WaitlistService) GetEntry(entryID uint) (*model.WaitlistEntry, error) {
+    var entry model.WaitlistEntry
+    err := s.db.Preload("Availability", func(db *gorm.DB) *gorm.DB {
+        return db.Order("weekday ASC, start_time ASC")
+    }).Preload("Events", func(db *gorm.DB) *gorm.DB {
+        return db.Order("timestamp ASC, id ASC")
+    }).First(&entry, entryID).Error
+    if err != nil {
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil, errors.New("rujukan tidak ditemukan")
+        }
+        return nil, fmt.Errorf("gagal mengambil rujukan: %w", err)
+    }
+    return &entry, nil
+}
+
+// GetChildReferral returns the referral a child was enrolled from, with its history;
+// nil when the child was registered directly
+func (s *WaitlistService) GetChildReferral(childID uint) (*model.WaitlistEntry, error) {
+    var entry model.WaitlistEntry
+    err := s.db.Where("child_id = ?", childID).First(&entry).Error
+    if errors.Is(err, gorm.ErrRecordNotFound) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("gagal mengambil riwayat rujukan: %w", err)
+    }
+    return s.GetEntry(entry.ID)
+}
+
+// GetWaitlist returns the queue in order: effective priority, then referral date, then
+// intake order. A referral waiting longer than WaitlistEscalationDays moves up one
+// priority level; urgent stays urgent.
+func (s *WaitlistService) GetWaitlist(filter WaitlistFilter) ([]WaitlistPosition, error) {
+    statuses := filter.Statuses
+    if len(statuses) == 0 {
+        statuses = []string{WaitlistStatusWaiting, WaitlistStatusOffered}
+    }
+    query := s.db.Preload("Availability", func(db *gorm.DB) *gorm.DB {
+        return db.Order("weekday ASC, start_time ASC")
+    }).Where("status IN ?", statuses)
+    if therapyType := strings.TrimSpace(filter.TherapyType); therapyType != "" {
+        query = query.Where("LOWER(therapy_type) = ?", strings.ToLower(therapyType))
+    }
+    var entries []model.WaitlistEntry
+    if err := query.Find(&entries).Error; err != nil {
+        return nil, fmt.Errorf("gagal mengambil daftar tunggu: %w", err)
+    }
+
+    slotTime := ""
+    if filter.Weekday != nil {
+        var err error
+        if slotTime, err = normalizeClock(filter.Time); err != nil {
+            return nil, err
+        }
+    }
+
+    today := startOfDay(time.Now())
+    positions := make([]WaitlistPosition, 0, len(entries))
+    for _, entry := range entries {
+        if filter.Weekday != nil && !availableAt(entry.Availability, *filter.Weekday, slotTime) {
+            continue
+        }
+        waitingDays := int(today.Sub(startOfDay(entry.ReferralDate)).Hours() / 24)
+        positions = append(positions, WaitlistPosition{
+            WaitlistEntry:     entry,
+            WaitingDays:       waitingDays,
+            EffectivePriority: effectiveWaitlistPriority(entry.Priority, waitingDays),
+        })
+    }
+
+    sort.SliceStable(positions, func(i, j int) bool {
+        a, b := positions[i], positions[j]
+        if ra, rb := waitlistPriorityRank[a.EffectivePriority], waitlistPriorityRank[b.EffectivePriority]; ra != rb {
+            return ra < rb
+        }
+        if !a.ReferralDate.Equal(b.ReferralDate) {
+            return a.ReferralDate.Before(b.ReferralDate)
+        }
+        return a.ID < b.ID
+    })
+    for i := range positions {
+        positions[i].Position = i + 1
+    }
+    return positions, nil
+}
+
+func effectiveWaitlistPriority(priority string, waitingDays int) string {
+    rank := waitlistPriorityRank[priority]
+    if waitingDays > WaitlistEscalationDays && rank > 0 {
+        rank--
+    }
+    for p, r := range waitlistPriorityRank {
+        if r == rank {
+            return p
+        }
+    }
+    return priority
+}
+
+// availableAt reports whether a family can attend at the given weekday and time
+func availableAt(windows []model.WaitlistAvailability, weekday int, clock string) bool {
+    if len(windows) == 0 {
+        return true
+    }
+    for _, w := range windows {
+        if w.Weekday == weekday && (clock == "" || (w.StartTime <= clock && clock < w.EndTime)) {
+            return true
+        }
+    }
+    return false
+}
+
+func applyWaitlistInput(entry *model.WaitlistEntry, input WaitlistEntryInput) ([]model.WaitlistAvailability, error) {
+    childName := strings.TrimSpace(input.ChildName)
+    if childName == "" {
+        return nil, errors.New("nama anak harus diisi")
+    }
+    contactName := strings.TrimSpace(input.ContactName)
+    if contactName == "" {
+        return nil, errors.New("nama kontak harus diisi")
+    }
+    therapyType := strings.TrimSpace(input.TherapyType)
+    if therapyType == "" {
+        return nil, errors.New("jenis terapi harus diisi")
+    }
+    relationship := strings.TrimSpace(input.Relationship)
+    if relationship != "" && !validRelationships[relationship] {
+        return nil, fmt.Errorf("hubungan dengan anak tidak dikenal: %s", relationship)
+    }
+    phone, err := NormalizePhone(input.ContactPhone)
+    if err != nil {
+        return nil, err
+    }
+    email, err := NormalizeEmail(input.ContactEmail)
+    if err != nil {
+        return nil, err
+    }
+    if phone == "" && email == "" {
+        return nil, errors.New("nomor telepon atau email kontak harus diisi")
+    }
+    priority := strings.TrimSpace(input.Priority)
+    if priority == "" {
+        priority = WaitlistPriorityNormal
+    }
+    if _, ok := waitlistPriorityRank[priority]; !ok {
+        return nil, fmt.Errorf("prioritas tidak valid: %q", priority)
+    }
+    dob, err := ParseDateOfBirth(input.DateOfBirth)
+    if err != nil {
+        return nil, err
+    }
+    referralDate := startOfDay(time.Now())
+    if parsed, err := ParseCalendarDate(input.ReferralDate); err != nil {
+        return nil, err
+    } else if parsed != nil {
+        referralDate = *parsed
+    }
+    if referralDate.After(time.Now()) {
+        return nil, errors.New("tanggal rujukan tidak boleh di masa depan")
+    }
+
+    availability := make([]model.WaitlistAvailability, 0, len(input.Availability))
+    for _, window := range input.Availability {
+        if window.Weekday < 0 || window.Weekday > 6 {
+            return nil, fmt.Errorf("hari tidak valid: %d", window.Weekday)
+        }
+        start, err := normalizeClock(window.StartTime)
+        if err != nil {
+            return nil, err
+        }
+        end, err := normalizeClock(window.EndTime)
+        if err != nil {
+            return nil, err
+        }
+        if start == "" || end == "" || end <= start {
+            return nil, errors.New("jam ketersediaan harus diisi dan jam selesai harus setelah jam mulai")
+        }
+        availability = append(availability, model.WaitlistAvailability{Weekday: window.Weekday, StartTime: start, EndTime: end})
+    }
+
+    entry.ChildName = childName
+    entry.DateOfBirth = dob
+    entry.Gender = strings.TrimSpace(input.Gender)
+    entry.ContactName = contactName
+    entry.Relationship = relationship
+    entry.ContactPhone = phone
+    entry.ContactEmail = email
+    entry.ReferralDate = referralDate
+    entry.ReferralSource = strings.TrimSpace(input.ReferralSource)
+    entry.ReferralReason = strings.TrimSpace(input.ReferralReason)
+    entry.TherapyType = therapyType
+    entry.Priority = priority
+    entry.Notes = strings.TrimSpace(input.Notes)
+    return availability, nil
+}
+
+// normalizeClock validates a time of day and formats it as "15:04"; empty stays empty
+func normalizeClock(value string) (string, error) {
+    value = strings.TrimSpace(value)
+    if value == "" {
+        return "", nil
+    }
+    clock, err := time.Parse("15:04", value)
+    if err != nil {
+        return "", fmt.Errorf("format jam tidak valid: %s (gunakan JJ:MM)", value)
+    }
+    return clock.Format("15:04"), nil
+}
+
+func createWaitlistAvailability(tx *gorm.DB, entryID uint, availability []model.WaitlistAvailability) error {
+    for i := range availability {
+        availability[i].EntryID = entryID
+        if err := tx.Create(&availability[i]).Error; err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+func addWaitlistEvent(tx *gorm.DB, entryID uint, action, fromStatus, toStatus, detail, actor string) error {
+    return tx.Create(&model.WaitlistEvent{
+        EntryID:    entryID,
+        Action:     action,
+        FromStatus: fromStatus,
+        ToStatus:   toStatus,
+        Detail:     detail,
+        Actor:      actor,
+        Timestamp:  time.Now(),
+    }).Error
+}
+
+func nonEmpty(values ...string) []string {
+    result := make([]string, 0, len(values))
+    for _, v := range values {
+        if v != "" {
+            result = append(result, v)
+        }
+    }
+    return result
+}